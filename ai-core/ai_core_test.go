@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectEmotion(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior UserBehaviorData
+		want     EmotionState
+	}{
+		{"struggling learner", UserBehaviorData{TaskFailures: 5, Retries: 2, CompletionRate: 0.4, ResponseTime: 35}, EmotionFrustrated},
+		{"on a roll", UserBehaviorData{StreakDays: 10, CompletionRate: 0.9, SessionDuration: 45, RecentPerformance: "improving"}, EmotionMotivated},
+		{"stuck on a concept", UserBehaviorData{TaskFailures: 2, Retries: 4, CompletionRate: 0.6, ResponseTime: 70}, EmotionConfused},
+		{"late marathon session", UserBehaviorData{SessionDuration: 150, TimeOfDay: "night", RecentPerformance: "declining", CompletionRate: 0.7}, EmotionTired},
+		{"no strong signals", UserBehaviorData{CompletionRate: 0.7, SessionDuration: 20}, EmotionConfident},
+	}
+	core := NewAICore("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, confidence, err := core.DetectEmotion(context.Background(), tt.behavior)
+			if err != nil {
+				t.Fatalf("DetectEmotion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("DetectEmotion() = %s, want %s", got, tt.want)
+			}
+			if confidence < 0.4 || confidence > 1 {
+				t.Fatalf("confidence = %v, want within [0.4, 1]", confidence)
+			}
+		})
+	}
+}
+
+func TestExecutePromptChain(t *testing.T) {
+	tests := []struct {
+		chain       string
+		input       map[string]interface{}
+		steps       []string
+		personality BuddyPersonality
+	}{
+		{
+			chain:       "detect_adapt_suggest_reward",
+			input:       map[string]interface{}{"behavior_data": UserBehaviorData{TaskFailures: 5, CompletionRate: 0.4, ResponseTime: 35}},
+			steps:       []string{"detect_emotion", "adapt_personality", "generate_suggestion", "calculate_reward"},
+			personality: PersonalityMentor,
+		},
+		{
+			chain:       "problem_solving",
+			input:       map[string]interface{}{"problem_description": "My loop never terminates", "error_messages": "timeout"},
+			steps:       []string{"analyze_problem", "generate_solution", "calculate_reward"},
+			personality: PersonalityFocused,
+		},
+		{
+			chain:       "motivation_boost",
+			input:       map[string]interface{}{"behavior_data": UserBehaviorData{SessionDuration: 150, TimeOfDay: "night", RecentPerformance: "declining", CompletionRate: 0.7}},
+			steps:       []string{"assess_emotional_state", "provide_support", "calculate_reward"},
+			personality: PersonalityChill,
+		},
+	}
+	core := NewAICore("")
+	for _, tt := range tests {
+		t.Run(tt.chain, func(t *testing.T) {
+			result, err := core.ExecutePromptChain(context.Background(), tt.chain, tt.input)
+			if err != nil {
+				t.Fatalf("ExecutePromptChain() error = %v", err)
+			}
+			if !result.Success {
+				t.Fatalf("chain failed: %+v", result.Steps)
+			}
+			if len(result.Steps) != len(tt.steps) {
+				t.Fatalf("steps = %d, want %d", len(result.Steps), len(tt.steps))
+			}
+			for i, step := range result.Steps {
+				if step.StepName != tt.steps[i] || !step.Success {
+					t.Errorf("step %d = %s (success %v), want successful %s", i, step.StepName, step.Success, tt.steps[i])
+				}
+			}
+			final := result.FinalResult
+			if final.Message == "" || final.XPReward <= 0 || final.Personality != tt.personality {
+				t.Fatalf("final result = %+v, want a %s message with an XP reward", final, tt.personality)
+			}
+		})
+	}
+
+	if _, err := core.ExecutePromptChain(context.Background(), "unknown", nil); err == nil {
+		t.Fatalf("unknown chain type: expected an error")
+	}
+	if _, err := core.ExecutePromptChain(context.Background(), "problem_solving", map[string]interface{}{}); err == nil {
+		t.Fatalf("problem_solving without a problem_description: expected an error")
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	core := NewAICore("")
+
+	template, _ := core.Template("progress_celebration")
+	want := []string{"achievement", "xp_gained", "new_level", "streak_days", "total_progress", "time_invested", "growth_areas", "improved_skills", "challenges_overcome", "next_opportunities"}
+	if got := template.Variables; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("progress_celebration variables = %v, want %v", got, want)
+	}
+
+	complete := map[string]interface{}{}
+	for _, variable := range want {
+		complete[variable] = "value"
+	}
+	if err := core.ValidateTemplate("progress_celebration", complete); err != nil {
+		t.Fatalf("ValidateTemplate(complete) error = %v", err)
+	}
+
+	delete(complete, "new_level")
+	err := core.ValidateTemplate("progress_celebration", complete)
+	if err == nil || !strings.Contains(err.Error(), "new_level") {
+		t.Fatalf("ValidateTemplate(missing new_level) error = %v, want it to name new_level", err)
+	}
+
+	if err := core.ValidateTemplate("does_not_exist", complete); err == nil {
+		t.Fatalf("ValidateTemplate(unknown template): expected an error")
+	}
+}
+
+func TestCalculateResponseQuality(t *testing.T) {
+	core := NewAICore("")
+	helpful := "Let me help you learn this step by step. First read the error. Then check the loop. Finally add a test."
+
+	tests := []struct {
+		name           string
+		response       string
+		expectedLength int
+		want           float64
+	}{
+		{"helpful and well sized", helpful, len(helpful), 0.95},
+		{"helpful but far too short for the request", helpful, len(helpful) * 3, 0.7},
+		{"no quality markers", "Okay", 0, 0.75},
+		{"no expectation", "", 0, 0.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := core.CalculateResponseQuality(tt.response, tt.expectedLength)
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Fatalf("CalculateResponseQuality() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
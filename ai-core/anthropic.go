@@ -0,0 +1,250 @@
+// Anthropic-style messages API provider for Learning Buddy Platform
+
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultAnthropicMaxTokens is sent when a template sets no limit; the API requires one
+const defaultAnthropicMaxTokens = 1024
+
+// AnthropicClient talks to an Anthropic-style messages API
+type AnthropicClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	Version    string // sent as the anthropic-version header
+	HTTPClient *http.Client
+}
+
+// AnthropicRequest is the body of a messages API request. System prompts go in
+// System rather than in Messages.
+type AnthropicRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+// AnthropicResponse is the body of a messages API response
+type AnthropicResponse struct {
+	ID         string             `json:"id"`
+	Model      string             `json:"model"`
+	Content    []AnthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      AnthropicUsage     `json:"usage"`
+}
+
+// AnthropicContent is one content block of a response
+type AnthropicContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// AnthropicUsage reports the tokens a request consumed
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicStreamEvent covers the fields used from each streamed event
+type anthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Message *AnthropicResponse `json:"message,omitempty"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewAnthropicClient creates a new Anthropic client
+func NewAnthropicClient(apiKey string) *AnthropicClient {
+	return &AnthropicClient{
+		APIKey:  apiKey,
+		BaseURL: "https://api.anthropic.com/v1",
+		Model:   "claude-3-5-haiku-latest",
+		Version: "2023-06-01",
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Name returns ProviderAnthropic
+func (client *AnthropicClient) Name() string { return ProviderAnthropic }
+
+// Complete sends a messages request and returns the text of the reply
+func (client *AnthropicClient) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	resp, err := client.send(ctx, client.request(req, false), client.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var anthropicResp AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	var content strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	return &Completion{
+		Content:      content.String(),
+		Model:        anthropicResp.Model,
+		FinishReason: anthropicResp.StopReason,
+		Usage:        anthropicResp.Usage.usage(),
+	}, nil
+}
+
+// Stream sends a streaming messages request and calls onDelta with each text delta
+func (client *AnthropicClient) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	// The client timeout would cut long streams short; ctx bounds the request instead
+	httpClient := *client.HTTPClient
+	httpClient.Timeout = 0
+	resp, err := client.send(ctx, client.request(req, true), &httpClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Completion{}
+	var content strings.Builder
+	var usage AnthropicUsage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // event names are repeated in the data's type field
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			content.WriteString(event.Delta.Text)
+			if err := onDelta(event.Delta.Text); err != nil {
+				return nil, err
+			}
+		case "message_delta":
+			result.FinishReason = event.Delta.StopReason
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			providerErr := &ProviderError{Kind: ErrUpstream, Message: data}
+			if event.Error != nil {
+				providerErr.Message = event.Error.Type + ": " + event.Error.Message
+				if event.Error.Type == "rate_limit_error" {
+					providerErr.Kind = ErrRateLimited
+				}
+			}
+			return nil, providerErr
+		}
+		if event.Type == "message_stop" {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, transportError(ctx, fmt.Errorf("failed to read stream: %w", err))
+	}
+
+	result.Content = content.String()
+	result.Usage = usage.usage()
+	return result, nil
+}
+
+// request converts a CompletionRequest into the messages API format, moving
+// system messages into the system prompt
+func (client *AnthropicClient) request(req CompletionRequest, stream bool) AnthropicRequest {
+	anthropicReq := AnthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if anthropicReq.Model == "" {
+		anthropicReq.Model = client.Model
+	}
+	if anthropicReq.MaxTokens == 0 {
+		anthropicReq.MaxTokens = defaultAnthropicMaxTokens
+	}
+
+	var system []string
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		anthropicReq.Messages = append(anthropicReq.Messages, message)
+	}
+	anthropicReq.System = strings.Join(system, "\n\n")
+	return anthropicReq
+}
+
+// send posts a messages request and returns the response once it is known to be a success
+func (client *AnthropicClient) send(ctx context.Context, req AnthropicRequest, httpClient *http.Client) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", client.BaseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", client.APIKey)
+	httpReq.Header.Set("anthropic-version", client.Version)
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, responseError(resp, body)
+	}
+	return resp, nil
+}
+
+// usage converts Anthropic token counts to the shared Usage shape
+func (u AnthropicUsage) usage() Usage {
+	return Usage{PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens, TotalTokens: u.InputTokens + u.OutputTokens}
+}
@@ -0,0 +1,432 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrMissingChainInput is returned when a chain is started without an input one of its steps requires
+var ErrMissingChainInput = errors.New("missing chain input")
+
+// chainHandler computes a chain step in code. It reads the chain state (inputs,
+// defaults and earlier outputs) and returns the step's outputs.
+type chainHandler func(ai *AICore, ctx context.Context, state map[string]interface{}) (map[string]interface{}, error)
+
+// Backoff between attempts of a failed chain step: chainRetryBaseDelay
+// doubled per retry, capped at chainRetryMaxDelay
+const (
+	chainRetryBaseDelay = 100 * time.Millisecond
+	chainRetryMaxDelay  = 2 * time.Second
+)
+
+// chainHandlers are the built-in steps a chain configuration can name in "handler"
+var chainHandlers = map[string]chainHandler{
+	"detect_emotion":     (*AICore).detectEmotionStep,
+	"select_personality": (*AICore).selectPersonalityStep,
+	"calculate_reward":   (*AICore).calculateRewardStep,
+}
+
+// ExecutePromptChain runs a chain from chain_configurations. Steps run in order
+// and see the caller's input, the chain's defaults, the declared outputs of
+// earlier steps and completed_steps. A failed step is retried with backoff up
+// to the chain's retry_attempts and the whole chain is bounded by its
+// max_execution_time. Step failures are reported in the result; unknown
+// chains and missing inputs are errors.
+func (ai *AICore) ExecutePromptChain(ctx context.Context, chainType string, input map[string]interface{}) (*PromptChainResult, error) {
+	chain, ok := ai.Chain(chainType)
+	if !ok {
+		return nil, fmt.Errorf("unknown chain type: %s", chainType)
+	}
+
+	state := make(map[string]interface{}, len(chain.Defaults)+len(input))
+	for key, value := range chain.Defaults {
+		state[key] = value
+	}
+	for key, value := range input {
+		if value != nil {
+			state[key] = value
+		}
+	}
+	if missing := missingChainInputs(chain, state); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s chain needs %s", ErrMissingChainInput, chainType, strings.Join(missing, ", "))
+	}
+
+	if chain.MaxExecutionTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(chain.MaxExecutionTime)*time.Second)
+		defer cancel()
+	}
+
+	startTime := time.Now()
+	result := &PromptChainResult{
+		ChainID: fmt.Sprintf("%s_%d", chainType, startTime.Unix()),
+		Steps:   []PromptChainStep{},
+		Success: true,
+	}
+
+	var reply *AIResponse
+	for _, step := range chain.Steps {
+		state["completed_steps"] = len(result.Steps)
+		record, response := ai.runChainStep(ctx, step, chain.RetryAttempts, state)
+		result.Steps = append(result.Steps, record)
+		if !record.Success {
+			result.Success = false
+			break
+		}
+		for key, value := range record.Output {
+			state[key] = value
+		}
+		if response != nil {
+			reply = response
+		}
+	}
+
+	result.TotalTime = time.Since(startTime)
+	result.FinalResult = chainResponse(state, reply, result.TotalTime)
+	return result, nil
+}
+
+// missingChainInputs lists required inputs that are neither in state nor declared
+// as an output of an earlier step
+func missingChainInputs(chain ChainConfig, state map[string]interface{}) []string {
+	produced := make(map[string]bool)
+	seen := make(map[string]bool)
+	var missing []string
+	for _, step := range chain.Steps {
+		for _, name := range step.RequiredInputs {
+			if _, ok := state[name]; !ok && !produced[name] && !seen[name] {
+				seen[name] = true
+				missing = append(missing, name)
+			}
+		}
+		for _, name := range step.Outputs {
+			produced[name] = true
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// chainRetryDelay returns the wait before retry number attempt, counted from 1
+func chainRetryDelay(attempt int) time.Duration {
+	delay := chainRetryBaseDelay
+	for i := 1; i < attempt && delay < chainRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > chainRetryMaxDelay {
+		return chainRetryMaxDelay
+	}
+	return delay
+}
+
+// runChainStep runs one step with retries and returns its record and, for
+// template steps, the generated reply
+func (ai *AICore) runChainStep(ctx context.Context, step ChainStepConfig, retries int, state map[string]interface{}) (PromptChainStep, *AIResponse) {
+	stepStart := time.Now()
+	record := PromptChainStep{
+		StepName: step.Name,
+		Input:    make(map[string]interface{}, len(step.RequiredInputs)),
+	}
+	fail := func(err error) (PromptChainStep, *AIResponse) {
+		record.ErrorMsg = err.Error()
+		record.Duration = time.Since(stepStart)
+		return record, nil
+	}
+
+	for _, name := range step.RequiredInputs {
+		value, ok := state[name]
+		if !ok {
+			return fail(fmt.Errorf("required input %s was not produced by an earlier step", name))
+		}
+		record.Input[name] = value
+	}
+
+	run, err := ai.chainStepRunner(step, state)
+	if err != nil {
+		return fail(err)
+	}
+
+	var outputs map[string]interface{}
+	var response *AIResponse
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			if sleepErr := sleepContext(ctx, chainRetryDelay(attempt)); sleepErr != nil {
+				err = sleepErr
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		record.Attempts++
+		if outputs, response, err = run(ctx); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// Only declared outputs are passed on to later steps
+	record.Output = make(map[string]interface{}, len(step.Outputs))
+	for _, name := range step.Outputs {
+		if value, ok := outputs[name]; ok {
+			record.Output[name] = value
+		}
+	}
+	record.Success = true
+	record.Duration = time.Since(stepStart)
+	return record, response
+}
+
+// chainStepRunner resolves a step to the function that computes it: its
+// built-in handler, or generation from its template
+func (ai *AICore) chainStepRunner(step ChainStepConfig, state map[string]interface{}) (func(context.Context) (map[string]interface{}, *AIResponse, error), error) {
+	if step.Handler != "" {
+		handler, ok := chainHandlers[step.Handler]
+		if !ok {
+			return nil, fmt.Errorf("unknown handler %s", step.Handler)
+		}
+		return func(ctx context.Context) (map[string]interface{}, *AIResponse, error) {
+			outputs, err := handler(ai, ctx, state)
+			return outputs, nil, err
+		}, nil
+	}
+
+	templateID := *step.TemplateID
+	if strings.Contains(templateID, personalityPlaceholder) {
+		personality, ok := state["selected_personality"]
+		if !ok {
+			return nil, fmt.Errorf("template %s needs a selected_personality", templateID)
+		}
+		templateID = ai.PersonalityTemplateID(templateID, BuddyPersonality(fmt.Sprint(personality)))
+	}
+	template, ok := ai.Template(templateID)
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", templateID)
+	}
+	if err := ai.ValidateTemplate(templateID, state); err != nil {
+		return nil, fmt.Errorf("template %s: %w", templateID, err)
+	}
+
+	if template.Output != nil {
+		return func(ctx context.Context) (map[string]interface{}, *AIResponse, error) {
+			return ai.structuredStep(ctx, step, templateID, template.Personality, state)
+		}, nil
+	}
+
+	return func(ctx context.Context) (map[string]interface{}, *AIResponse, error) {
+		response, err := ai.generate(ctx, templateID, state, template.Personality)
+		if err != nil {
+			return nil, nil, err
+		}
+		actions := response.SuggestedActions
+		if len(actions) == 0 {
+			actions = extractSteps(response.Message)
+		}
+		// The reply text goes to the step's first output
+		return map[string]interface{}{
+			step.Outputs[0]:     response.Message,
+			"suggested_actions": actions,
+		}, response, nil
+	}, nil
+}
+
+// structuredStep runs a template that declares an output schema. The
+// validated JSON goes to the step's first output and, when it is an object,
+// each of its fields is also offered as an output of the same name.
+func (ai *AICore) structuredStep(ctx context.Context, step ChainStepConfig, templateID string, personality BuddyPersonality, state map[string]interface{}) (map[string]interface{}, *AIResponse, error) {
+	var data interface{}
+	response, err := ai.GenerateStructured(ctx, templateID, state, personality, &data)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs := map[string]interface{}{}
+	if fields, ok := data.(map[string]interface{}); ok {
+		for name, value := range fields {
+			outputs[name] = value
+		}
+	}
+	outputs[step.Outputs[0]] = data
+	return outputs, response, nil
+}
+
+// chainResponse assembles a chain's final answer from the last generated reply
+// and the emotion, confidence and reward recorded in the chain state
+func chainResponse(state map[string]interface{}, reply *AIResponse, elapsed time.Duration) AIResponse {
+	final := AIResponse{ProcessingTime: elapsed}
+	if reply != nil {
+		final.Message = reply.Message
+		final.Personality = reply.Personality
+		final.ConfidenceScore = reply.ConfidenceScore
+		final.Metadata = reply.Metadata
+	}
+	if emotion, ok := state["emotional_state"]; ok {
+		final.DetectedEmotion = EmotionState(fmt.Sprint(emotion))
+	}
+	if confidence, ok := toFloat(state["confidence"]); ok {
+		final.ConfidenceScore = confidence
+	}
+	if actions, ok := state["suggested_actions"].([]string); ok {
+		final.SuggestedActions = actions
+	}
+	if xp, ok := state["xp_reward"].(int); ok {
+		final.XPReward = xp
+	}
+	return final
+}
+
+// detectEmotionStep runs the configured emotion detector over behavior_data
+func (ai *AICore) detectEmotionStep(ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+	behavior, err := behaviorFromInput(state["behavior_data"])
+	if err != nil {
+		return nil, err
+	}
+	detection, _, err := ai.AnalyzeEmotion(ctx, behavior)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"emotional_state": detection.Emotion,
+		"confidence":      detection.Confidence,
+		"indicators":      detection.Indicators,
+		"stress_level":    stressLevel(detection.Emotion),
+		"support_needs":   supportNeeds(detection.Emotion),
+	}, nil
+}
+
+// selectPersonalityStep runs the personality policy over the detected
+// emotion, user_level, preferred_personality and personality_locked
+func (ai *AICore) selectPersonalityStep(ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+	level, ok := toFloat(state["user_level"])
+	if !ok {
+		level = 1
+	}
+	locked, _ := state["personality_locked"].(bool)
+	preferred := ""
+	if value, ok := state["preferred_personality"]; ok && value != nil {
+		preferred = fmt.Sprint(value)
+	}
+
+	choice := ai.SelectPersonality(PersonalityRequest{
+		Emotion:   EmotionState(fmt.Sprint(state["emotional_state"])),
+		Level:     int(level),
+		Preferred: BuddyPersonality(preferred),
+		Locked:    locked,
+	})
+	return map[string]interface{}{
+		"selected_personality": choice.Personality,
+		"reasoning":            choice.Reasoning,
+		"personality_scores":   choice.Scores,
+	}, nil
+}
+
+// calculateRewardStep computes the XP reward, scaled by activity_completion (0-1)
+func (ai *AICore) calculateRewardStep(ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+	emotion := EmotionState(fmt.Sprint(state["emotional_state"]))
+	confidence, ok := toFloat(state["confidence"])
+	if !ok {
+		confidence = 0.5
+	}
+	completion, ok := toFloat(state["activity_completion"])
+	if !ok || completion < 0 || completion > 1 {
+		return nil, fmt.Errorf("activity_completion must be a number between 0 and 1, got %v", state["activity_completion"])
+	}
+
+	steps, _ := state["completed_steps"].(int)
+	xp := int(float64(ai.calculateXPReward(emotion, confidence, steps)) * completion)
+	return map[string]interface{}{
+		"xp_reward":         xp,
+		"badge_eligibility": completion >= 1,
+	}, nil
+}
+
+// behaviorFromInput accepts behavior data as the struct, a pointer to it, or a
+// decoded JSON object
+func behaviorFromInput(value interface{}) (UserBehaviorData, error) {
+	switch behavior := value.(type) {
+	case UserBehaviorData:
+		return behavior, nil
+	case *UserBehaviorData:
+		if behavior != nil {
+			return *behavior, nil
+		}
+	case map[string]interface{}:
+		data, err := json.Marshal(behavior)
+		if err != nil {
+			return UserBehaviorData{}, fmt.Errorf("behavior_data: %w", err)
+		}
+		var decoded UserBehaviorData
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return UserBehaviorData{}, fmt.Errorf("behavior_data: %w", err)
+		}
+		return decoded, nil
+	}
+	return UserBehaviorData{}, fmt.Errorf("behavior_data must be UserBehaviorData, got %T", value)
+}
+
+// behaviorIndicators lists the behavior signals worth mentioning to the learner
+func behaviorIndicators(b UserBehaviorData) []string {
+	var indicators []string
+	if b.TaskFailures >= 3 {
+		indicators = append(indicators, fmt.Sprintf("%d failed attempts", b.TaskFailures))
+	}
+	if b.Retries >= 3 {
+		indicators = append(indicators, fmt.Sprintf("%d retries", b.Retries))
+	}
+	if b.SessionDuration >= 120 {
+		indicators = append(indicators, fmt.Sprintf("%d minute session", b.SessionDuration))
+	}
+	if b.StreakDays >= 7 {
+		indicators = append(indicators, fmt.Sprintf("%d day streak", b.StreakDays))
+	}
+	if b.RecentPerformance != "" && b.RecentPerformance != "stable" {
+		indicators = append(indicators, b.RecentPerformance+" performance")
+	}
+	return indicators
+}
+
+// stressLevel estimates stress on the 1-10 scale the motivation template uses
+func stressLevel(emotion EmotionState) int {
+	switch emotion {
+	case EmotionFrustrated:
+		return 8
+	case EmotionTired, EmotionConfused:
+		return 6
+	default:
+		return 3
+	}
+}
+
+// supportNeeds describes the kind of help an emotion calls for
+func supportNeeds(emotion EmotionState) string {
+	switch emotion {
+	case EmotionFrustrated, EmotionConfused:
+		return "guidance"
+	case EmotionTired:
+		return "rest"
+	default:
+		return "challenge"
+	}
+}
+
+// toFloat converts the numeric types chain state can hold to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
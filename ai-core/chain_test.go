@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newChainTestCore returns a core running the embedded config plus the given chain as "test"
+func newChainTestCore(t *testing.T, chain ChainConfig) *AICore {
+	t.Helper()
+	config, err := DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	config.ChainConfigurations["test"] = chain
+	if err := config.Validate(); err != nil {
+		t.Fatalf("test chain is invalid: %v", err)
+	}
+	return NewAICoreFromConfig(nil, config)
+}
+
+// withHandler registers a built-in step for the duration of a test
+func withHandler(t *testing.T, name string, handler chainHandler) {
+	t.Helper()
+	chainHandlers[name] = handler
+	t.Cleanup(func() { delete(chainHandlers, name) })
+}
+
+func TestChainPassesOutputsToLaterSteps(t *testing.T) {
+	result, err := NewAICore("").ExecutePromptChain(context.Background(), "problem_solving", map[string]interface{}{"problem_description": "Off by one"})
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	analysis := result.Steps[0].Output["problem_analysis"]
+	if analysis == nil || result.Steps[1].Input["problem_analysis"] != analysis {
+		t.Fatalf("generate_solution input = %v, want analyze_problem's problem_analysis %v", result.Steps[1].Input, analysis)
+	}
+	if solution := result.Steps[1].Output["solution_steps"]; result.FinalResult.Message != solution {
+		t.Fatalf("final message = %q, want the solution steps %q", result.FinalResult.Message, solution)
+	}
+	if result.FinalResult.DetectedEmotion != EmotionConfused {
+		t.Fatalf("detected emotion = %s, want the chain default confused", result.FinalResult.DetectedEmotion)
+	}
+}
+
+func TestChainResolvesPersonalityTemplate(t *testing.T) {
+	behavior := UserBehaviorData{StreakDays: 10, CompletionRate: 0.9, SessionDuration: 45, RecentPerformance: "improving"}
+	result, err := NewAICore("").ExecutePromptChain(context.Background(), "detect_adapt_suggest_reward", map[string]interface{}{"behavior_data": behavior})
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	if got := result.FinalResult.Metadata["template_id"]; got != "learning_suggestion_cheerleader" {
+		t.Fatalf("template = %q, want learning_suggestion_cheerleader", got)
+	}
+	if result.Steps[1].Output["reasoning"] == nil {
+		t.Fatalf("adapt_personality outputs = %v, want reasoning", result.Steps[1].Output)
+	}
+}
+
+func TestChainRejectsBadInput(t *testing.T) {
+	core := NewAICore("")
+
+	_, err := core.ExecutePromptChain(context.Background(), "detect_adapt_suggest_reward", map[string]interface{}{})
+	if !errors.Is(err, ErrMissingChainInput) || !strings.Contains(err.Error(), "behavior_data") {
+		t.Fatalf("missing behavior_data: error = %v, want ErrMissingChainInput naming behavior_data", err)
+	}
+
+	// A wrongly typed input fails the step instead of panicking
+	result, err := core.ExecutePromptChain(context.Background(), "detect_adapt_suggest_reward", map[string]interface{}{"behavior_data": "tired"})
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	if result.Success || len(result.Steps) != 1 || !strings.Contains(result.Steps[0].ErrorMsg, "behavior_data") {
+		t.Fatalf("result = %+v, want detect_emotion to fail on behavior_data", result)
+	}
+
+	// Behavior decoded from JSON is accepted
+	decoded := map[string]interface{}{"task_failures": 5.0, "completion_rate": 0.4, "response_time": 35.0}
+	result, err = core.ExecutePromptChain(context.Background(), "detect_adapt_suggest_reward", map[string]interface{}{"behavior_data": decoded})
+	if err != nil || !result.Success || result.FinalResult.DetectedEmotion != EmotionFrustrated {
+		t.Fatalf("decoded behavior: result = %+v, %v; want a frustrated learner", result, err)
+	}
+}
+
+func TestChainRetriesFailedSteps(t *testing.T) {
+	calls := 0
+	withHandler(t, "flaky", func(ai *AICore, ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("temporary failure")
+		}
+		return map[string]interface{}{"emotional_state": EmotionMotivated}, nil
+	})
+	steps := []ChainStepConfig{{Name: "flaky", Handler: "flaky", Outputs: []string{"emotional_state"}}}
+
+	core := newChainTestCore(t, ChainConfig{Steps: steps, RetryAttempts: 2})
+	result, err := core.ExecutePromptChain(context.Background(), "test", nil)
+	if err != nil || !result.Success || result.Steps[0].Attempts != 3 {
+		t.Fatalf("result = %+v, %v; want success on the third attempt", result, err)
+	}
+
+	calls = 0
+	core = newChainTestCore(t, ChainConfig{Steps: steps, RetryAttempts: 1})
+	result, _ = core.ExecutePromptChain(context.Background(), "test", nil)
+	if result.Success || result.Steps[0].Attempts != 2 || result.Steps[0].ErrorMsg != "temporary failure" {
+		t.Fatalf("result = %+v, want failure after two attempts", result)
+	}
+}
+
+func TestChainRetryDelay(t *testing.T) {
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond,
+		1600 * time.Millisecond, 2 * time.Second, 2 * time.Second}
+	for i, delay := range want {
+		if got := chainRetryDelay(i + 1); got != delay {
+			t.Errorf("chainRetryDelay(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestChainRetriesBackOffUntilDeadline(t *testing.T) {
+	withHandler(t, "down", func(ai *AICore, ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("rate limited")
+	})
+	core := newChainTestCore(t, ChainConfig{
+		Steps:            []ChainStepConfig{{Name: "down", Handler: "down"}},
+		MaxExecutionTime: 1,
+		RetryAttempts:    100,
+	})
+
+	result, err := core.ExecutePromptChain(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	// 100ms, 200ms and 400ms waits fit in the second; the 800ms one runs into the deadline
+	step := result.Steps[0]
+	if result.Success || step.Attempts != 4 || !strings.Contains(step.ErrorMsg, "deadline exceeded") {
+		t.Fatalf("step = %+v, want four attempts spaced out until the deadline", step)
+	}
+}
+
+func TestChainEnforcesMaxExecutionTime(t *testing.T) {
+	withHandler(t, "slow", func(ai *AICore, ctx context.Context, state map[string]interface{}) (map[string]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	core := newChainTestCore(t, ChainConfig{
+		Steps:            []ChainStepConfig{{Name: "slow", Handler: "slow"}},
+		MaxExecutionTime: 1,
+		RetryAttempts:    3,
+	})
+
+	result, err := core.ExecutePromptChain(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	step := result.Steps[0]
+	if result.Success || step.Attempts != 1 || !strings.Contains(step.ErrorMsg, "deadline exceeded") {
+		t.Fatalf("step = %+v, want one attempt failing on the deadline", step)
+	}
+}
@@ -0,0 +1,315 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed prompt_templates.json
+var embeddedConfig []byte
+
+// ErrInvalidConfig is returned when a prompt configuration fails validation
+var ErrInvalidConfig = errors.New("invalid prompt configuration")
+
+// personalityPlaceholder is substituted with each personality in chain template IDs
+const personalityPlaceholder = "{personality}"
+
+// Config is the contents of prompt_templates.json
+type Config struct {
+	PromptTemplates     map[string]PromptTemplate              `json:"prompt_templates"`
+	PersonalityConfigs  map[BuddyPersonality]PersonalityConfig `json:"personality_configs"`
+	ChainConfigurations map[string]ChainConfig                 `json:"chain_configurations"`
+}
+
+// ChainConfig declares the steps of a prompt chain. Defaults fill inputs the
+// caller leaves out.
+type ChainConfig struct {
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	Steps            []ChainStepConfig      `json:"steps"`
+	Defaults         map[string]interface{} `json:"defaults,omitempty"`
+	MaxExecutionTime int                    `json:"max_execution_time"` // seconds
+	RetryAttempts    int                    `json:"retry_attempts"`
+}
+
+// ChainStepConfig declares one step of a prompt chain. A step either names a
+// built-in Handler or renders its template; "{personality}" in a TemplateID is
+// replaced with the personality selected earlier in the chain.
+type ChainStepConfig struct {
+	Name           string   `json:"name"`
+	TemplateID     *string  `json:"template_id"`
+	Handler        string   `json:"handler,omitempty"`
+	RequiredInputs []string `json:"required_inputs"`
+	Outputs        []string `json:"outputs"`
+}
+
+// DefaultConfig parses the prompt_templates.json embedded in the binary
+func DefaultConfig() (*Config, error) {
+	return ParseConfig(embeddedConfig)
+}
+
+// LoadConfig reads and validates a prompt configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt config: %w", err)
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes a prompt configuration, rejecting unknown fields, and validates it
+func ParseConfig(data []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that templates, personalities and chains are complete and
+// that every template a chain refers to exists. All problems are reported together.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.PersonalityConfigs) == 0 {
+		add("no personality_configs")
+	}
+	for name, personality := range c.PersonalityConfigs {
+		if personality.Name == "" {
+			add("personality %q: missing name", name)
+		}
+		if personality.UnlockLevel < 1 {
+			add("personality %q: unlock_level must be at least 1", name)
+		}
+	}
+
+	if len(c.PromptTemplates) == 0 {
+		add("no prompt_templates")
+	}
+	for id, template := range c.PromptTemplates {
+		if template.ID != id {
+			add("template %q: id %q does not match its key", id, template.ID)
+		}
+		if strings.TrimSpace(template.Template) == "" {
+			add("template %q: empty template", id)
+		}
+		if _, ok := c.PersonalityConfigs[template.Personality]; !ok {
+			add("template %q: unknown personality %q", id, template.Personality)
+		}
+		if template.MaxTokens <= 0 {
+			add("template %q: max_tokens must be positive", id)
+		}
+		if template.Temperature < 0 || template.Temperature > 2 {
+			add("template %q: temperature must be between 0 and 2", id)
+		}
+		if template.Output != nil {
+			for _, problem := range template.Output.problems("$") {
+				add("template %q: output_schema %s", id, problem)
+			}
+		}
+		declared := make(map[string]bool, len(template.Variables))
+		for _, name := range template.Variables {
+			declared[name] = true
+		}
+		used := make(map[string]bool)
+		for _, name := range templateVariables(template.Template) {
+			used[name] = true
+			if !declared[name] {
+				add("template %q: placeholder {{.%s}} is not listed in variables", id, name)
+			}
+		}
+		for _, name := range template.Variables {
+			if !used[name] {
+				add("template %q: variable %q is never used", id, name)
+			}
+		}
+	}
+
+	for name, chain := range c.ChainConfigurations {
+		if len(chain.Steps) == 0 {
+			add("chain %q: no steps", name)
+		}
+		if chain.MaxExecutionTime < 0 || chain.RetryAttempts < 0 {
+			add("chain %q: max_execution_time and retry_attempts must not be negative", name)
+		}
+		for _, step := range chain.Steps {
+			if step.Name == "" {
+				add("chain %q: step without a name", name)
+			}
+			if step.Handler != "" {
+				if _, ok := chainHandlers[step.Handler]; !ok {
+					add("chain %q step %q: unknown handler %q", name, step.Name, step.Handler)
+				}
+			}
+			if step.TemplateID == nil {
+				if step.Handler == "" {
+					add("chain %q step %q: needs a template_id or a handler", name, step.Name)
+				}
+				continue
+			}
+			if step.Handler == "" && len(step.Outputs) == 0 {
+				add("chain %q step %q: template steps need an output for the reply", name, step.Name)
+			}
+			for _, id := range c.expandTemplateID(*step.TemplateID) {
+				if _, ok := c.PromptTemplates[id]; !ok {
+					add("chain %q step %q: unknown template %q", name, step.Name, id)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w:\n  %s", ErrInvalidConfig, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// expandTemplateID returns the concrete template IDs a chain step may use
+func (c *Config) expandTemplateID(id string) []string {
+	if !strings.Contains(id, personalityPlaceholder) {
+		return []string{id}
+	}
+	ids := make([]string, 0, len(c.PersonalityConfigs))
+	for personality := range c.PersonalityConfigs {
+		ids = append(ids, strings.ReplaceAll(id, personalityPlaceholder, string(personality)))
+	}
+	return ids
+}
+
+// ApplyConfig replaces the core's templates, personalities and chains. Templates
+// without a variables list get one derived from their placeholders.
+func (ai *AICore) ApplyConfig(config *Config) {
+	templates := make(map[string]PromptTemplate, len(config.PromptTemplates))
+	for id, template := range config.PromptTemplates {
+		if template.Variables == nil {
+			template.Variables = templateVariables(template.Template)
+		}
+		templates[id] = template
+	}
+	personalities := make(map[BuddyPersonality]PersonalityConfig, len(config.PersonalityConfigs))
+	for name, personality := range config.PersonalityConfigs {
+		personalities[name] = personality
+	}
+	chains := make(map[string]ChainConfig, len(config.ChainConfigurations))
+	for name, chain := range config.ChainConfigurations {
+		chains[name] = chain
+	}
+
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	ai.PromptTemplates = templates
+	ai.PersonalityMap = personalities
+	ai.Chains = chains
+}
+
+// Template returns a prompt template by ID
+func (ai *AICore) Template(id string) (PromptTemplate, bool) {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+	template, ok := ai.PromptTemplates[id]
+	return template, ok
+}
+
+// Personality returns a personality configuration
+func (ai *AICore) Personality(personality BuddyPersonality) (PersonalityConfig, bool) {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+	config, ok := ai.PersonalityMap[personality]
+	return config, ok
+}
+
+// Chain returns a chain configuration by name
+func (ai *AICore) Chain(name string) (ChainConfig, bool) {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+	chain, ok := ai.Chains[name]
+	return chain, ok
+}
+
+// ConfigWatcher reloads an AICore's configuration from a file when the file
+// changes or a reload is requested (for example on SIGHUP). An invalid file
+// is logged and the previous configuration stays in effect.
+type ConfigWatcher struct {
+	core     *AICore
+	path     string
+	interval time.Duration
+	logf     func(format string, args ...interface{})
+	modTime  time.Time
+	size     int64
+}
+
+// NewConfigWatcher creates a watcher that polls path every interval
+func NewConfigWatcher(core *AICore, path string, interval time.Duration, logf func(format string, args ...interface{})) *ConfigWatcher {
+	w := &ConfigWatcher{core: core, path: path, interval: interval, logf: logf}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	return w
+}
+
+// Run watches until ctx is cancelled. Each value received on reload forces a reload.
+func (w *ConfigWatcher) Run(ctx context.Context, reload <-chan os.Signal) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			w.Reload()
+		case <-ticker.C:
+			if w.changed() {
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the file and applies it if it is valid
+func (w *ConfigWatcher) Reload() error {
+	config, err := LoadConfig(w.path)
+	if err != nil {
+		w.logf("prompt config reload failed, keeping previous config: %v", err)
+		return err
+	}
+	w.core.ApplyConfig(config)
+	w.logf("reloaded prompt config from %s (%d templates, %d personalities, %d chains)",
+		w.path, len(config.PromptTemplates), len(config.PersonalityConfigs), len(config.ChainConfigurations))
+	return nil
+}
+
+// changed reports whether the file's modification time or size differs from the last check
+func (w *ConfigWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	return true
+}
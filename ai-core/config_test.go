@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	config, err := DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	if len(config.PromptTemplates) != 13 || len(config.PersonalityConfigs) != 4 || len(config.ChainConfigurations) != 3 {
+		t.Fatalf("config has %d templates, %d personalities, %d chains; want 13, 4, 3",
+			len(config.PromptTemplates), len(config.PersonalityConfigs), len(config.ChainConfigurations))
+	}
+
+	core := NewAICoreFromConfig(nil, config)
+	if _, ok := core.Template("step_by_step_solution"); !ok {
+		t.Fatalf("step_by_step_solution template not loaded")
+	}
+	if mentor, ok := core.Personality(PersonalityMentor); !ok || mentor.UnlockLevel != 1 {
+		t.Fatalf("mentor personality = %+v, %v; want unlock level 1", mentor, ok)
+	}
+	if chain, ok := core.Chain("problem_solving"); !ok || chain.MaxExecutionTime != 45 || chain.RetryAttempts != 3 {
+		t.Fatalf("problem_solving chain = %+v, %v", chain, ok)
+	}
+}
+
+func TestParseConfigRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(string) string
+		wantMsg string
+	}{
+		{
+			name: "unknown chain template",
+			mutate: func(s string) string {
+				return strings.Replace(s, `"template_id": "step_by_step_solution"`, `"template_id": "missing_solution"`, 1)
+			},
+			wantMsg: `unknown template "missing_solution"`,
+		},
+		{
+			name: "personality pattern without a template",
+			mutate: func(s string) string {
+				return strings.Replace(s, `"learning_suggestion_{personality}"`, `"tips_{personality}"`, 1)
+			},
+			wantMsg: `unknown template "tips_mentor"`,
+		},
+		{
+			name:    "undeclared placeholder",
+			mutate:  func(s string) string { return strings.Replace(s, `"problem_analysis",`, ``, 1) },
+			wantMsg: "{{.problem_analysis}} is not listed in variables",
+		},
+		{
+			name: "unknown field",
+			mutate: func(s string) string {
+				return strings.Replace(s, `"max_tokens": 400`, `"max_tokens": 400, "max_token": 400`, 1)
+			},
+			wantMsg: `unknown field "max_token"`,
+		},
+		{
+			name: "output schema requires an undeclared property",
+			mutate: func(s string) string {
+				return strings.Replace(s, `"required": ["emotional_state",`, `"required": ["mood", "emotional_state",`, 1)
+			},
+			wantMsg: `output_schema $: required property "mood" is not declared`,
+		},
+		{
+			name: "output schema with an unknown type",
+			mutate: func(s string) string {
+				return strings.Replace(s, `"difficulty": {"type": "integer"`, `"difficulty": {"type": "int"`, 1)
+			},
+			wantMsg: `output_schema $.difficulty: unknown type "int"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.mutate(string(embeddedConfig))
+			if data == string(embeddedConfig) {
+				t.Fatalf("mutation did not change the config")
+			}
+			_, err := ParseConfig([]byte(data))
+			if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Fatalf("ParseConfig() error = %v, want ErrInvalidConfig mentioning %s", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestConfigWatcherReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt_templates.json")
+	if err := os.WriteFile(path, embeddedConfig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	core := NewAICoreFromConfig(nil, config)
+	watcher := NewConfigWatcher(core, path, time.Hour, t.Logf)
+
+	// An invalid file is rejected and the previous templates stay in place
+	if err := os.WriteFile(path, []byte(`{"prompt_templates": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Reload(); err == nil {
+		t.Fatalf("Reload() of an invalid file: expected an error")
+	}
+	if _, ok := core.Template("motivation_boost"); !ok {
+		t.Fatalf("invalid reload dropped the previous templates")
+	}
+
+	// A valid edit is picked up when a reload signal arrives
+	edited := strings.Replace(string(embeddedConfig), `"name": "Gentle Motivation and Support"`, `"name": "Pep Talk"`, 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal, 1)
+	go watcher.Run(ctx, reload)
+	reload <- os.Interrupt
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if template, _ := core.Template("motivation_boost"); template.Name == "Pep Talk" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("template not reloaded after signal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
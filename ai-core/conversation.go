@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// summaryTemplateID is the prompt template used to fold old turns into a memory note
+const summaryTemplateID = "conversation_summary"
+
+// maxMockSummaryLength caps the extractive summary built without an LLM
+const maxMockSummaryLength = 1200
+
+// EstimateTokens approximates the token count of text at four characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// ConversationHistory assembles the earlier context of a chat for a request:
+// the rolling summary as a system note, followed by as many of the most
+// recent turns as fit in budget tokens. Turns are oldest first; trimming drops
+// whole exchanges, so the kept turns never open with an assistant reply whose
+// question was cut.
+func ConversationHistory(summary string, turns []Message, budget int) []Message {
+	var history []Message
+	if summary != "" {
+		note := Message{Role: "system", Content: "Earlier in this conversation: " + summary}
+		history = append(history, note)
+		budget -= EstimateTokens(note.Content)
+	}
+
+	start := len(turns)
+	for start > 0 {
+		cost := EstimateTokens(turns[start-1].Content)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		start--
+	}
+	for start < len(turns) && turns[start].Role == "assistant" {
+		start++
+	}
+	return append(history, turns[start:]...)
+}
+
+// SummarizeConversation folds turns into the previous rolling summary. With an
+// LLM provider the conversation_summary template writes the note; with the mock
+// a short extractive note of the learner's messages is kept instead. The
+// AIResponse is the LLM call that wrote the note, nil when none was made.
+func (ai *AICore) SummarizeConversation(ctx context.Context, previous string, turns []Message) (string, *AIResponse, error) {
+	if len(turns) == 0 {
+		return previous, nil, nil
+	}
+
+	if ai.UsesMock() {
+		return extractiveSummary(previous, turns), nil, nil
+	}
+
+	var transcript strings.Builder
+	for _, turn := range turns {
+		fmt.Fprintf(&transcript, "%s: %s\n", turn.Role, turn.Content)
+	}
+	variables := map[string]interface{}{
+		"previous_summary": previous,
+		"conversation":     transcript.String(),
+	}
+	response, err := ai.GenerateResponseWithProvider(ctx, summaryTemplateID, variables, PersonalityMentor, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("summarize conversation: %w", err)
+	}
+	return strings.TrimSpace(response.Message), response, nil
+}
+
+// extractiveSummary appends a line per learner message to the previous summary,
+// dropping the oldest lines once it grows past maxMockSummaryLength
+func extractiveSummary(previous string, turns []Message) string {
+	var notes []string
+	if previous != "" {
+		notes = strings.Split(previous, "\n")
+	}
+	for _, turn := range turns {
+		if turn.Role != "user" {
+			continue
+		}
+		said := strings.Join(strings.Fields(turn.Content), " ")
+		if utf8.RuneCountInString(said) > 120 {
+			said = string([]rune(said)[:117]) + "..."
+		}
+		notes = append(notes, fmt.Sprintf("The learner said %q.", said))
+	}
+
+	summary := strings.Join(notes, "\n")
+	for utf8.RuneCountInString(summary) > maxMockSummaryLength && len(notes) > 1 {
+		notes = notes[1:]
+		summary = strings.Join(notes, "\n")
+	}
+	return summary
+}
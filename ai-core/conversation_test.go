@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestConversationHistoryKeepsRecentTurnsWithinBudget(t *testing.T) {
+	turns := []Message{
+		{Role: "user", Content: strings.Repeat("a", 400)}, // 100 tokens
+		{Role: "assistant", Content: strings.Repeat("b", 400)},
+		{Role: "user", Content: strings.Repeat("c", 40)}, // 10 tokens
+		{Role: "assistant", Content: strings.Repeat("d", 40)},
+	}
+
+	// the budget reaches the first reply but not its question, so the whole exchange goes
+	history := ConversationHistory("", turns, 150)
+	if len(history) != 2 || history[0].Content != turns[2].Content {
+		t.Fatalf("history = %d messages starting %q, want the last exchange", len(history), history[0].Content[:1])
+	}
+
+	history = ConversationHistory("Learner is building a todo app.", turns, 40)
+	if len(history) != 3 || history[0].Role != "system" || !strings.Contains(history[0].Content, "todo app") {
+		t.Fatalf("history = %+v, want the memory note and the two short turns", history)
+	}
+}
+
+func TestCompletionRequestPlacesHistoryBeforePrompt(t *testing.T) {
+	core := NewAICore("")
+	history := []Message{{Role: "user", Content: "My name is Sam"}, {Role: "assistant", Content: "Hi Sam!"}}
+
+	req, err := core.completionRequest("motivation_boost", map[string]interface{}{"user_goals": "ship it"}, PersonalityChill, history)
+	if err != nil {
+		t.Fatalf("completionRequest() error = %v", err)
+	}
+	roles := []string{}
+	for _, message := range req.Messages {
+		roles = append(roles, message.Role)
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,user" || req.Messages[1].Content != "My name is Sam" {
+		t.Fatalf("messages = %+v, want system prompt, history, then the rendered template", req.Messages)
+	}
+	if !strings.Contains(req.Messages[3].Content, "ship it") {
+		t.Fatalf("last message = %q, want the rendered template", req.Messages[3].Content)
+	}
+}
+
+func TestSummarizeConversationWithoutOpenAI(t *testing.T) {
+	core := NewAICore("")
+	turns := []Message{
+		{Role: "user", Content: "I'm learning recursion"},
+		{Role: "assistant", Content: "Great, let's start with factorial."},
+	}
+
+	summary, response, err := core.SummarizeConversation(context.Background(), "", turns)
+	if err != nil || !strings.Contains(summary, "recursion") || strings.Contains(summary, "factorial") {
+		t.Fatalf("summary = %q, %v; want the learner's message only", summary, err)
+	}
+	if response != nil {
+		t.Fatalf("response = %+v, want none without an LLM", response)
+	}
+
+	for i := 0; i < 40; i++ {
+		summary, _, _ = core.SummarizeConversation(context.Background(), summary, []Message{{Role: "user", Content: strings.Repeat("x", 100)}})
+	}
+	if len(summary) > maxMockSummaryLength || strings.Contains(summary, "recursion") {
+		t.Fatalf("summary is %d characters, want the oldest notes dropped below %d", len(summary), maxMockSummaryLength)
+	}
+}
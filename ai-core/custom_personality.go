@@ -0,0 +1,187 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidPersonality is returned when a custom personality fails validation
+var ErrInvalidPersonality = errors.New("invalid personality")
+
+// customPersonalityTemplate replaces {personality} in template IDs for
+// personalities without templates of their own
+const customPersonalityTemplate = "custom"
+
+// Limits on custom personalities, which learners and instructors write themselves
+const (
+	MaxPersonalityNameLength = 50
+	MaxPersonalityTextLength = 300 // description, response_style entries and prompt modifiers
+	MaxPersonalityTraits     = 8
+	MaxPersonalityStyles     = 8
+	MaxPersonalityUnlock     = 100
+)
+
+// promptModifierKeys are the prompt modifiers a personality may set
+var promptModifierKeys = map[string]bool{"prefix": true, "style": true, "suffix": true}
+
+var (
+	traitNamePattern  = regexp.MustCompile(`^[a-z][a-z_]{0,29}$`)
+	colorThemePattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+)
+
+// PersonalityKey derives the key of a custom personality from its name,
+// e.g. "Socratic Tutor" becomes "socratic_tutor"
+func PersonalityKey(name string) BuddyPersonality {
+	var key strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if underscore && key.Len() > 0 {
+				key.WriteByte('_')
+			}
+			key.WriteRune(r)
+			underscore = false
+		default:
+			underscore = true
+		}
+	}
+	return BuddyPersonality(key.String())
+}
+
+// ValidateCustomPersonality checks a user-defined personality against the
+// limits above. All problems are reported together.
+func ValidateCustomPersonality(config PersonalityConfig) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	name := strings.TrimSpace(config.Name)
+	switch {
+	case name == "":
+		add("name is required")
+	case len(name) > MaxPersonalityNameLength:
+		add("name is longer than %d characters", MaxPersonalityNameLength)
+	case PersonalityKey(name) == "":
+		add("name needs at least one letter or digit")
+	}
+	if len(config.Description) > MaxPersonalityTextLength {
+		add("description is longer than %d characters", MaxPersonalityTextLength)
+	}
+
+	if len(config.Traits) == 0 {
+		add("at least one trait is required")
+	}
+	if len(config.Traits) > MaxPersonalityTraits {
+		add("more than %d traits", MaxPersonalityTraits)
+	}
+	for _, trait := range sortedKeys(config.Traits) {
+		if !traitNamePattern.MatchString(trait) {
+			add("trait %q: names are lowercase letters and underscores", trait)
+		}
+		if score := config.Traits[trait]; score < 1 || score > 10 {
+			add("trait %q: score must be between 1 and 10", trait)
+		}
+	}
+
+	if len(config.ResponseStyle) > MaxPersonalityStyles {
+		add("more than %d response_style entries", MaxPersonalityStyles)
+	}
+	for _, key := range sortedKeys(config.ResponseStyle) {
+		if !traitNamePattern.MatchString(key) {
+			add("response_style %q: names are lowercase letters and underscores", key)
+		}
+		if len(config.ResponseStyle[key]) > MaxPersonalityTextLength {
+			add("response_style %q is longer than %d characters", key, MaxPersonalityTextLength)
+		}
+	}
+
+	modifiers := 0
+	for _, key := range sortedKeys(config.PromptModifiers) {
+		value := config.PromptModifiers[key]
+		if !promptModifierKeys[key] {
+			add("prompt_modifiers %q: only prefix, style and suffix are allowed", key)
+		}
+		if len(value) > MaxPersonalityTextLength {
+			add("prompt_modifiers %q is longer than %d characters", key, MaxPersonalityTextLength)
+		}
+		if strings.TrimSpace(value) != "" {
+			modifiers++
+		}
+	}
+	if modifiers == 0 {
+		add("prompt_modifiers needs a prefix, style or suffix")
+	}
+
+	if config.UnlockLevel < 1 || config.UnlockLevel > MaxPersonalityUnlock {
+		add("unlock_level must be between 1 and %d", MaxPersonalityUnlock)
+	}
+	if config.ColorTheme != "" && !colorThemePattern.MatchString(config.ColorTheme) {
+		add("color_theme must look like #4F46E5")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidPersonality, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// WithPersonalities returns a view of the core that also knows the given
+// custom personalities, for the duration of one request. Custom personalities
+// cannot replace configured ones and are only chosen when a learner prefers
+// them. The view shares the core's provider, templates and chains.
+func (ai *AICore) WithPersonalities(custom map[BuddyPersonality]PersonalityConfig) *AICore {
+	if len(custom) == 0 {
+		return ai
+	}
+
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+	personalities := make(map[BuddyPersonality]PersonalityConfig, len(ai.PersonalityMap)+len(custom))
+	for name, personality := range ai.PersonalityMap {
+		personalities[name] = personality
+	}
+	for name, personality := range custom {
+		if _, ok := personalities[name]; ok {
+			continue
+		}
+		personality.Custom = true
+		personalities[name] = personality
+	}
+	return &AICore{
+		Provider:        ai.Provider,
+		PromptTemplates: ai.PromptTemplates,
+		PersonalityMap:  personalities,
+		Chains:          ai.Chains,
+		MockStreamDelay: ai.MockStreamDelay,
+		EmotionDetector: ai.EmotionDetector,
+	}
+}
+
+// PersonalityTemplateID substitutes a personality into a template ID such as
+// "learning_suggestion_{personality}". Personalities without a template of
+// their own get the "custom" variant.
+func (ai *AICore) PersonalityTemplateID(id string, personality BuddyPersonality) string {
+	resolved := strings.ReplaceAll(id, personalityPlaceholder, string(personality))
+	if _, ok := ai.Template(resolved); ok || resolved == id {
+		return resolved
+	}
+	return strings.ReplaceAll(id, personalityPlaceholder, customPersonalityTemplate)
+}
+
+// Personalities returns the personality keys the core knows, sorted
+func (ai *AICore) Personalities() []BuddyPersonality {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+	keys := make([]BuddyPersonality, 0, len(ai.PersonalityMap))
+	for key := range ai.PersonalityMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
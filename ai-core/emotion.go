@@ -0,0 +1,278 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// Emotion detector modes for AICore.EmotionDetector
+const (
+	EmotionDetectorRules = "rules" // behavioral thresholds only
+	EmotionDetectorLLM   = "llm"   // the emotion_detection template, merged with the rules
+)
+
+// llmEmotionWeight is the share of the merged score that comes from the LLM;
+// the rest comes from the rule scores
+const llmEmotionWeight = 0.6
+
+// emotionOrder fixes the order emotions are compared in, so ties are broken
+// the same way every time
+var emotionOrder = []EmotionState{
+	EmotionFrustrated,
+	EmotionConfused,
+	EmotionTired,
+	EmotionMotivated,
+	EmotionExcited,
+	EmotionConfident,
+}
+
+// EmotionReply is the JSON object the emotion_detection template declares
+type EmotionReply struct {
+	EmotionalState         EmotionState     `json:"emotional_state"`
+	Confidence             *float64         `json:"confidence"`
+	Indicators             []string         `json:"indicators"`
+	RecommendedPersonality BuddyPersonality `json:"recommended_personality"`
+	Reasoning              string           `json:"reasoning"`
+}
+
+// EmotionDetection is a detected emotion and what it was based on. Source is
+// the detector that produced it; FallbackReason says why an LLM detection
+// fell back to the rules.
+type EmotionDetection struct {
+	Emotion                EmotionState             `json:"emotional_state"`
+	Confidence             float64                  `json:"confidence"`
+	Indicators             []string                 `json:"indicators"`
+	RecommendedPersonality BuddyPersonality         `json:"recommended_personality,omitempty"`
+	Reasoning              string                   `json:"reasoning,omitempty"`
+	Source                 string                   `json:"source"`
+	RuleScores             map[EmotionState]float64 `json:"rule_scores"`
+	LLM                    *EmotionReply            `json:"llm,omitempty"`
+	FallbackReason         string                   `json:"fallback_reason,omitempty"`
+}
+
+// Factors returns what contributed to the detection, for logging
+func (d *EmotionDetection) Factors() map[string]interface{} {
+	factors := map[string]interface{}{
+		"source":      d.Source,
+		"indicators":  d.Indicators,
+		"rule_scores": d.RuleScores,
+	}
+	if d.LLM != nil {
+		factors["llm"] = d.LLM
+	}
+	if d.FallbackReason != "" {
+		factors["fallback_reason"] = d.FallbackReason
+	}
+	return factors
+}
+
+// DetectEmotion analyzes user behavior to detect emotional state
+func (ai *AICore) DetectEmotion(ctx context.Context, behaviorData UserBehaviorData) (EmotionState, float64, error) {
+	detection, _, err := ai.AnalyzeEmotion(ctx, behaviorData)
+	if err != nil {
+		return "", 0, err
+	}
+	return detection.Emotion, detection.Confidence, nil
+}
+
+// AnalyzeEmotion detects the user's emotion with the configured detector. The
+// LLM detector falls back to the rules when the provider fails or its reply
+// does not validate even after the repair prompt; FallbackReason records why.
+// The AIResponse is the LLM call the detection paid for, nil when none was made.
+func (ai *AICore) AnalyzeEmotion(ctx context.Context, behavior UserBehaviorData) (*EmotionDetection, *AIResponse, error) {
+	detection := DetectEmotionRules(behavior)
+	if ai.EmotionDetector != EmotionDetectorLLM || ai.UsesMock() {
+		return detection, nil, nil
+	}
+
+	var reply EmotionReply
+	response, err := ai.GenerateStructured(ctx, "emotion_detection", emotionVariables(behavior), PersonalityMentor, &reply)
+	if err == nil {
+		err = ai.checkEmotionReply(&reply)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		detection.FallbackReason = err.Error()
+		return detection, response, nil
+	}
+	return mergeEmotion(detection, &reply), response, nil
+}
+
+// DetectEmotionRules scores each emotion from behavioral thresholds and picks
+// the strongest. Without a score of at least 0.4 the learner is taken to be confident.
+func DetectEmotionRules(behavior UserBehaviorData) *EmotionDetection {
+	scores := ruleEmotionScores(behavior)
+	emotion, confidence := strongestEmotion(scores)
+	return &EmotionDetection{
+		Emotion:    emotion,
+		Confidence: confidence,
+		Indicators: behaviorIndicators(behavior),
+		Source:     EmotionDetectorRules,
+		RuleScores: scores,
+	}
+}
+
+// ruleEmotionScores scores each emotion between 0 and 1 from the behavior data
+func ruleEmotionScores(b UserBehaviorData) map[EmotionState]float64 {
+	scores := make(map[EmotionState]float64, len(emotionOrder))
+
+	// Frustration indicators
+	if b.TaskFailures > 3 {
+		scores[EmotionFrustrated] += 0.3
+	}
+	if b.Retries > 5 {
+		scores[EmotionFrustrated] += 0.2
+	}
+	if b.CompletionRate < 0.5 {
+		scores[EmotionFrustrated] += 0.3
+	}
+	if b.ResponseTime > 30 {
+		scores[EmotionFrustrated] += 0.2
+	}
+
+	// Motivation indicators: sustained effort
+	if b.StreakDays > 3 {
+		scores[EmotionMotivated] += 0.3
+	}
+	if b.CompletionRate > 0.8 {
+		scores[EmotionMotivated] += 0.3
+	}
+	if b.SessionDuration > 30 {
+		scores[EmotionMotivated] += 0.2
+	}
+	if b.RecentPerformance == "improving" {
+		scores[EmotionMotivated] += 0.2
+	}
+
+	// Excitement indicators: a burst of quick, flawless progress
+	if b.RecentPerformance == "improving" {
+		scores[EmotionExcited] += 0.3
+	}
+	if b.CompletionRate >= 0.95 {
+		scores[EmotionExcited] += 0.3
+	}
+	if b.ResponseTime > 0 && b.ResponseTime < 10 {
+		scores[EmotionExcited] += 0.2
+	}
+	if b.SessionDuration > 0 && b.TaskFailures == 0 && b.Retries == 0 {
+		scores[EmotionExcited] += 0.2
+	}
+
+	// Confusion indicators
+	if b.TaskFailures > 1 && b.Retries > 3 {
+		scores[EmotionConfused] += 0.4
+	}
+	if b.ResponseTime > 60 {
+		scores[EmotionConfused] += 0.3
+	}
+	if b.CompletionRate < 0.3 {
+		scores[EmotionConfused] += 0.3
+	}
+
+	// Fatigue indicators
+	if b.SessionDuration > 120 {
+		scores[EmotionTired] += 0.3
+	}
+	if b.TimeOfDay == "night" {
+		scores[EmotionTired] += 0.2
+	}
+	if b.RecentPerformance == "declining" {
+		scores[EmotionTired] += 0.3
+	}
+	if b.ResponseTime > 45 {
+		scores[EmotionTired] += 0.2
+	}
+
+	if _, highest := strongestEmotion(scores); highest < 0.4 {
+		scores[EmotionConfident] = 0.6
+	}
+	for emotion, score := range scores {
+		scores[emotion] = clamp01(score)
+	}
+	return scores
+}
+
+// strongestEmotion returns the highest-scoring emotion, earliest in emotionOrder on a tie
+func strongestEmotion(scores map[EmotionState]float64) (EmotionState, float64) {
+	var strongest EmotionState
+	highest := 0.0
+	for _, emotion := range emotionOrder {
+		if scores[emotion] > highest {
+			strongest, highest = emotion, scores[emotion]
+		}
+	}
+	return strongest, highest
+}
+
+// mergeEmotion blends an LLM reply into the rule detection: each emotion
+// scores its rule score weighted by 1-llmEmotionWeight, plus the LLM's
+// confidence weighted by llmEmotionWeight for the emotion the LLM chose
+func mergeEmotion(rules *EmotionDetection, reply *EmotionReply) *EmotionDetection {
+	merged := make(map[EmotionState]float64, len(emotionOrder))
+	for _, emotion := range emotionOrder {
+		merged[emotion] = (1 - llmEmotionWeight) * rules.RuleScores[emotion]
+	}
+	merged[reply.EmotionalState] += llmEmotionWeight * *reply.Confidence
+	emotion, confidence := strongestEmotion(merged)
+
+	indicators := append([]string{}, reply.Indicators...)
+	for _, indicator := range rules.Indicators {
+		if !containsString(indicators, indicator) {
+			indicators = append(indicators, indicator)
+		}
+	}
+	return &EmotionDetection{
+		Emotion:                emotion,
+		Confidence:             confidence,
+		Indicators:             indicators,
+		RecommendedPersonality: reply.RecommendedPersonality,
+		Reasoning:              reply.Reasoning,
+		Source:                 EmotionDetectorLLM,
+		RuleScores:             rules.RuleScores,
+		LLM:                    reply,
+	}
+}
+
+// checkEmotionReply rejects a schema-valid reply recommending a personality
+// the core does not know
+func (ai *AICore) checkEmotionReply(reply *EmotionReply) error {
+	if _, ok := ai.Personality(reply.RecommendedPersonality); !ok {
+		return fmt.Errorf("emotion reply recommends unknown personality %q", reply.RecommendedPersonality)
+	}
+	return nil
+}
+
+// emotionVariables fills the emotion_detection template from behavior data
+func emotionVariables(b UserBehaviorData) map[string]interface{} {
+	return map[string]interface{}{
+		"session_duration":   b.SessionDuration,
+		"task_failures":      b.TaskFailures,
+		"retries":            b.Retries,
+		"completion_rate":    int(b.CompletionRate*100 + 0.5),
+		"response_time":      b.ResponseTime,
+		"streak_days":        b.StreakDays,
+		"time_of_day":        b.TimeOfDay,
+		"recent_performance": b.RecentPerformance,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
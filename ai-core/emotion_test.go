@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"learning-buddy-ai/fakeopenai"
+)
+
+// emotionFixture is a behavior sample labeled with the emotion a human reviewer assigned
+type emotionFixture struct {
+	Name     string           `json:"name"`
+	Label    EmotionState     `json:"label"`
+	Behavior UserBehaviorData `json:"behavior"`
+}
+
+func loadEmotionFixtures(t *testing.T) []emotionFixture {
+	t.Helper()
+	data, err := os.ReadFile("testdata/emotion_fixtures.json")
+	if err != nil {
+		t.Fatalf("read fixtures: %v", err)
+	}
+	var fixtures []emotionFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("parse fixtures: %v", err)
+	}
+	return fixtures
+}
+
+// confusionMatrix counts predictions per label: matrix[label][predicted]
+type confusionMatrix map[EmotionState]map[EmotionState]int
+
+func (m confusionMatrix) add(label, predicted EmotionState) {
+	if m[label] == nil {
+		m[label] = map[EmotionState]int{}
+	}
+	m[label][predicted]++
+}
+
+// recall returns the share of a label's samples predicted correctly
+func (m confusionMatrix) recall(label EmotionState) float64 {
+	total := 0
+	for _, n := range m[label] {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(m[label][label]) / float64(total)
+}
+
+func (m confusionMatrix) accuracy() float64 {
+	correct, total := 0, 0
+	for label, row := range m {
+		for predicted, n := range row {
+			total += n
+			if predicted == label {
+				correct += n
+			}
+		}
+	}
+	return float64(correct) / float64(total)
+}
+
+func (m confusionMatrix) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-12s", "label\\pred")
+	for _, predicted := range emotionOrder {
+		fmt.Fprintf(&b, "%12s", predicted)
+	}
+	for _, label := range emotionOrder {
+		fmt.Fprintf(&b, "\n%-12s", label)
+		for _, predicted := range emotionOrder {
+			fmt.Fprintf(&b, "%12d", m[label][predicted])
+		}
+	}
+	return b.String()
+}
+
+// TestEmotionRulesConfusionMatrix guards the rule detector against
+// regressions over the labeled fixtures
+func TestEmotionRulesConfusionMatrix(t *testing.T) {
+	matrix := confusionMatrix{}
+	for _, fixture := range loadEmotionFixtures(t) {
+		detection := DetectEmotionRules(fixture.Behavior)
+		matrix.add(fixture.Label, detection.Emotion)
+		if detection.Emotion != fixture.Label {
+			t.Logf("%s: labeled %s, detected %s (scores %v)", fixture.Name, fixture.Label, detection.Emotion, detection.RuleScores)
+		}
+	}
+
+	if accuracy := matrix.accuracy(); accuracy < 0.9 {
+		t.Errorf("accuracy = %.2f, want at least 0.90\n%s", accuracy, matrix)
+	}
+	for _, label := range emotionOrder {
+		if recall := matrix.recall(label); recall < 0.75 {
+			t.Errorf("%s recall = %.2f, want at least 0.75\n%s", label, recall, matrix)
+		}
+	}
+}
+
+// TestEmotionLLMConfusionMatrix runs the fixtures through the LLM detector
+// against a fake that answers with each fixture's label, and checks the merge
+// keeps what the model said without losing accuracy
+func TestEmotionLLMConfusionMatrix(t *testing.T) {
+	fixtures := loadEmotionFixtures(t)
+	core, server := newLLMEmotionCore(t)
+	server.SetReply(func(fakeopenai.Request) string {
+		label := fixtures[len(server.Requests())-1].Label
+		return fmt.Sprintf(`{"emotional_state":%q,"confidence":0.8,"indicators":["labeled %s"],"recommended_personality":"mentor","reasoning":"fixture"}`, label, label)
+	})
+
+	matrix := confusionMatrix{}
+	for _, fixture := range fixtures {
+		detection, _, err := core.AnalyzeEmotion(context.Background(), fixture.Behavior)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeEmotion() error = %v", fixture.Name, err)
+		}
+		if detection.Source != EmotionDetectorLLM || detection.LLM == nil {
+			t.Fatalf("%s: detection = %+v, want an LLM detection", fixture.Name, detection)
+		}
+		matrix.add(fixture.Label, detection.Emotion)
+	}
+	if accuracy := matrix.accuracy(); accuracy != 1 {
+		t.Errorf("accuracy = %.2f, want 1\n%s", accuracy, matrix)
+	}
+}
+
+func newLLMEmotionCore(t *testing.T) (*AICore, *fakeopenai.Server) {
+	t.Helper()
+	server := fakeopenai.NewServer()
+	t.Cleanup(server.Close)
+	client := NewOpenAIClient("test-key")
+	client.BaseURL = server.BaseURL()
+	core := NewAICore("")
+	core.Provider = client
+	core.EmotionDetector = EmotionDetectorLLM
+	return core, server
+}
+
+func TestEmotionLLMMergesWithRules(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	frustrated := UserBehaviorData{TaskFailures: 6, Retries: 9, CompletionRate: 0.2, ResponseTime: 40}
+
+	// A hesitant model does not outvote strong behavioral evidence
+	server.SetReply(func(fakeopenai.Request) string {
+		return "```json\n" + `{"emotional_state":"confident","confidence":0.3,"indicators":["short messages"],"recommended_personality":"focused","reasoning":"terse"}` + "\n```"
+	})
+	detection, response, err := core.AnalyzeEmotion(context.Background(), frustrated)
+	if err != nil {
+		t.Fatalf("AnalyzeEmotion() error = %v", err)
+	}
+	if response == nil || response.Usage.TotalTokens == 0 {
+		t.Fatalf("response = %+v, want the LLM call with its token usage", response)
+	}
+	if detection.Emotion != EmotionFrustrated || detection.Source != EmotionDetectorLLM || detection.RecommendedPersonality != PersonalityFocused {
+		t.Fatalf("detection = %+v, want frustrated from the merged scores", detection)
+	}
+	if !containsString(detection.Indicators, "short messages") || !containsString(detection.Indicators, "6 failed attempts") {
+		t.Fatalf("indicators = %v, want both the model's and the rules'", detection.Indicators)
+	}
+	prompt := server.Requests()[0].Messages[1].Content
+	if !strings.Contains(prompt, "Task Failures: 6") || !strings.Contains(prompt, "Completion Rate: 20%") {
+		t.Fatalf("prompt = %q, want the behavior data", prompt)
+	}
+}
+
+func TestEmotionLLMFallsBackToRules(t *testing.T) {
+	tests := map[string]string{
+		"not json":        "The learner seems frustrated.",
+		"unknown field":   `{"emotional_state":"tired","confidence":0.9,"indicators":["late"],"recommended_personality":"chill","reasoning":"late","mood":"sleepy"}`,
+		"unknown emotion": `{"emotional_state":"focused","confidence":0.9,"indicators":["steady"],"recommended_personality":"mentor","reasoning":"steady"}`,
+		"percent":         `{"emotional_state":"tired","confidence":85,"indicators":["late"],"recommended_personality":"chill","reasoning":"late"}`,
+		"no confidence":   `{"emotional_state":"tired","indicators":["late"],"recommended_personality":"chill","reasoning":"late"}`,
+		"no indicators":   `{"emotional_state":"tired","confidence":0.9,"indicators":[],"recommended_personality":"chill","reasoning":"late"}`,
+		"bad personality": `{"emotional_state":"tired","confidence":0.9,"indicators":["late"],"recommended_personality":"drill_sergeant","reasoning":"late"}`,
+		"trailing text":   `{"emotional_state":"tired","confidence":0.9,"indicators":["late"],"recommended_personality":"chill","reasoning":"late"} {}`,
+	}
+	behavior := UserBehaviorData{TaskFailures: 6, Retries: 9, CompletionRate: 0.2, ResponseTime: 40}
+	for name, reply := range tests {
+		t.Run(name, func(t *testing.T) {
+			core, server := newLLMEmotionCore(t)
+			server.SetReply(func(fakeopenai.Request) string { return reply })
+
+			detection, _, err := core.AnalyzeEmotion(context.Background(), behavior)
+			if err != nil {
+				t.Fatalf("AnalyzeEmotion() error = %v", err)
+			}
+			if detection.Source != EmotionDetectorRules || detection.Emotion != EmotionFrustrated || detection.FallbackReason == "" {
+				t.Fatalf("detection = %+v, want the rule detection with a fallback reason", detection)
+			}
+		})
+	}
+
+	core, server := newLLMEmotionCore(t)
+	server.Fail(fakeopenai.Failure{Status: 503, Body: "down"})
+	detection, _, err := core.AnalyzeEmotion(context.Background(), behavior)
+	if err != nil || detection.Source != EmotionDetectorRules || !strings.Contains(detection.FallbackReason, "upstream") {
+		t.Fatalf("detection = %+v, %v; want the rules after an upstream error", detection, err)
+	}
+}
+
+func TestEmotionRulesDetectorSkipsLLM(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	core.EmotionDetector = EmotionDetectorRules
+	if _, response, err := core.AnalyzeEmotion(context.Background(), UserBehaviorData{CompletionRate: 1}); err != nil || response != nil {
+		t.Fatalf("AnalyzeEmotion() = %+v, %v; want no LLM call", response, err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Fatalf("llm requests = %d, want none in rules mode", len(server.Requests()))
+	}
+}
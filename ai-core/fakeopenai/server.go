@@ -0,0 +1,209 @@
+// Package fakeopenai runs an in-process OpenAI-compatible chat completions
+// server for tests, so every AI path can be exercised without the network.
+//
+//	server := fakeopenai.NewServer()
+//	defer server.Close()
+//	client := ai.NewOpenAIClient("test-key")
+//	client.BaseURL = server.BaseURL()
+package fakeopenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Model is reported when a request names no model
+const Model = "fake-gpt"
+
+// Request is a chat completion request as the server received it
+type Request struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+
+	Authorization string `json:"-"` // the Authorization header
+}
+
+// Message is one chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Failure is an error response the server sends instead of a completion
+type Failure struct {
+	Status int
+	Body   string
+	Header http.Header
+}
+
+// Server is a fake OpenAI API. Replies come from Reply, which by default
+// echoes the last user message; queued failures are sent first.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	reply    func(Request) string
+	apiKey   string
+	requests []Request
+	failures []Failure
+}
+
+// NewServer starts a fake server; Close it when done
+func NewServer() *Server {
+	s := &Server{reply: echo}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("/chat/completions", s.chatCompletions)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// BaseURL is the API root to configure clients with
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// SetReply replaces the function that writes completions
+func (s *Server) SetReply(reply func(Request) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = reply
+}
+
+// RequireKey makes the server reject requests without "Bearer key" with 401
+func (s *Server) RequireKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// Fail queues an error response for the next request. Queued failures are
+// sent in order, one per request.
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure)
+}
+
+// Requests returns the requests received so far, failed ones included
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":{"message":"method not allowed"}}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":{"message":"invalid JSON body"}}`, http.StatusBadRequest)
+		return
+	}
+	req.Authorization = r.Header.Get("Authorization")
+	if req.Model == "" {
+		req.Model = Model
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	apiKey, reply := s.apiKey, s.reply
+	var failure *Failure
+	if len(s.failures) > 0 {
+		failure = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if apiKey != "" && req.Authorization != "Bearer "+apiKey {
+		http.Error(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`, http.StatusUnauthorized)
+		return
+	}
+	if failure != nil {
+		for name, values := range failure.Header {
+			w.Header()[name] = values
+		}
+		http.Error(w, failure.Body, failure.Status)
+		return
+	}
+
+	content := reply(req)
+	usage := usageFor(req, content)
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       Message{Role: "assistant", Content: content},
+				"finish_reason": "stop",
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(delta map[string]string, finishReason interface{}, usage interface{}) {
+		chunk := map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	send(map[string]string{"role": "assistant"}, nil, nil)
+	for _, word := range strings.SplitAfter(content, " ") {
+		send(map[string]string{"content": word}, nil, nil)
+	}
+	send(map[string]string{}, "stop", usage)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// echo answers with the last user message
+func echo(req Request) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return "You said: " + req.Messages[i].Content
+		}
+	}
+	return "Hello from the fake server!"
+}
+
+// usageFor estimates token usage at four characters per token
+func usageFor(req Request, content string) map[string]int {
+	prompt := 0
+	for _, message := range req.Messages {
+		prompt += (len(message.Content) + 3) / 4
+	}
+	completion := (len(content) + 3) / 4
+	return map[string]int{
+		"prompt_tokens":     prompt,
+		"completion_tokens": completion,
+		"total_tokens":      prompt + completion,
+	}
+}
@@ -0,0 +1,3 @@
+module learning-buddy-ai
+
+go 1.21.5
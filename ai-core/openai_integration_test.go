@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIClientStreamResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request = %+v, %v; want stream: true", req, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"c1","model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant"},"finish_reason":null}]}`,
+			`{"id":"c1","model":"gpt-4","choices":[{"index":0,"delta":{"content":"Keep "},"finish_reason":null}]}`,
+			`{"id":"c1","model":"gpt-4","choices":[{"index":0,"delta":{"content":"going!"},"finish_reason":null}]}`,
+			`{"id":"c1","model":"gpt-4","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`,
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, ": keep-alive\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.BaseURL = server.URL
+
+	var deltas []string
+	resp, err := client.StreamResponse(context.Background(), OpenAIRequest{}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamResponse() error = %v", err)
+	}
+	if strings.Join(deltas, "|") != "Keep |going!" {
+		t.Fatalf("deltas = %q, want [Keep  going!]", deltas)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Keep going!" || choice.FinishReason != "stop" || resp.Usage.TotalTokens != 15 {
+		t.Fatalf("response = %+v, want assembled content, stop and usage", resp)
+	}
+}
+
+func TestOpenAIClientStreamResponseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("bad-key")
+	client.BaseURL = server.URL
+	_, err := client.StreamResponse(context.Background(), OpenAIRequest{}, func(string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("StreamResponse() error = %v, want the 401 status", err)
+	}
+}
+
+func TestStreamMockResponseWordByWord(t *testing.T) {
+	core := NewAICore("")
+	var tokens []string
+	reply, err := core.StreamMockResponse(context.Background(), "motivation_boost", nil, PersonalityChill, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamMockResponse() error = %v", err)
+	}
+	if len(tokens) < 10 || strings.Join(tokens, "") != reply.Message {
+		t.Fatalf("%d tokens joining to %q, want the words of %q", len(tokens), strings.Join(tokens, ""), reply.Message)
+	}
+}
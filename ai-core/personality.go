@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Weights of the personality policy. A preferred personality wins unless
+// another fits the emotion better by more than Preference/Emotion.
+const (
+	personalityEmotionWeight    = 0.6
+	personalityPreferenceWeight = 0.4
+)
+
+// neutralEmotionFit is the fit of personalities the table below does not rate,
+// such as custom ones
+const neutralEmotionFit = 0.5
+
+// emotionFit rates from 0 to 1 how well each personality suits a learner in each emotional state
+var emotionFit = map[EmotionState]map[BuddyPersonality]float64{
+	EmotionFrustrated: {PersonalityMentor: 1.0, PersonalityChill: 0.7, PersonalityCheerleader: 0.4, PersonalityFocused: 0.2},
+	EmotionConfused:   {PersonalityMentor: 1.0, PersonalityFocused: 0.7, PersonalityChill: 0.4, PersonalityCheerleader: 0.2},
+	EmotionTired:      {PersonalityChill: 1.0, PersonalityCheerleader: 0.5, PersonalityMentor: 0.4, PersonalityFocused: 0.1},
+	EmotionMotivated:  {PersonalityCheerleader: 1.0, PersonalityFocused: 0.7, PersonalityMentor: 0.5, PersonalityChill: 0.3},
+	EmotionExcited:    {PersonalityCheerleader: 1.0, PersonalityChill: 0.6, PersonalityFocused: 0.4, PersonalityMentor: 0.3},
+	EmotionConfident:  {PersonalityFocused: 1.0, PersonalityCheerleader: 0.6, PersonalityMentor: 0.5, PersonalityChill: 0.4},
+}
+
+// PersonalityRequest is what the personality policy decides from
+type PersonalityRequest struct {
+	Emotion   EmotionState
+	Level     int
+	Preferred BuddyPersonality // the learner's chosen personality, empty for none
+	Locked    bool             // always use Preferred while it is unlocked
+}
+
+// PersonalityScore is one unlocked candidate's score:
+// EmotionFit*personalityEmotionWeight + Preference*personalityPreferenceWeight
+type PersonalityScore struct {
+	Personality BuddyPersonality `json:"personality"`
+	EmotionFit  float64          `json:"emotion_fit"`
+	Preference  float64          `json:"preference"`
+	Score       float64          `json:"score"`
+}
+
+// PersonalityChoice is the policy's pick and why
+type PersonalityChoice struct {
+	Personality BuddyPersonality   `json:"personality"`
+	Reasoning   string             `json:"reasoning"`
+	Locked      bool               `json:"locked"`
+	Scores      []PersonalityScore `json:"scores"` // unlocked candidates, best first
+}
+
+// SelectPersonality picks the buddy personality from those unlocked at the
+// learner's level. A locked, unlocked preference is used as is; otherwise
+// each candidate is scored on how well it fits the detected emotion and
+// whether the learner prefers it. Custom personalities are only candidates
+// when preferred.
+func (ai *AICore) SelectPersonality(req PersonalityRequest) PersonalityChoice {
+	ai.mu.RLock()
+	var candidates, firstUnlocks []BuddyPersonality
+	firstLevel := 0
+	preferredLevel, preferredKnown := 0, false
+	for personality, config := range ai.PersonalityMap {
+		if personality == req.Preferred {
+			preferredLevel, preferredKnown = config.UnlockLevel, true
+		}
+		if config.Custom && personality != req.Preferred {
+			continue
+		}
+		if req.Level >= config.UnlockLevel {
+			candidates = append(candidates, personality)
+		}
+		switch {
+		case len(firstUnlocks) == 0 || config.UnlockLevel < firstLevel:
+			firstUnlocks, firstLevel = []BuddyPersonality{personality}, config.UnlockLevel
+		case config.UnlockLevel == firstLevel:
+			firstUnlocks = append(firstUnlocks, personality)
+		}
+	}
+	ai.mu.RUnlock()
+
+	var notes []string
+	switch {
+	case req.Preferred == "":
+	case !preferredKnown:
+		notes = append(notes, fmt.Sprintf("preferred %s is not a known personality", req.Preferred))
+		req.Preferred = ""
+	case req.Level < preferredLevel:
+		notes = append(notes, fmt.Sprintf("preferred %s unlocks at level %d", req.Preferred, preferredLevel))
+		req.Preferred = ""
+	}
+
+	if len(candidates) == 0 {
+		// Below every unlock level; offer the personalities that unlock first
+		candidates = firstUnlocks
+	}
+	scores := make([]PersonalityScore, 0, len(candidates))
+	for _, personality := range candidates {
+		score := PersonalityScore{Personality: personality, EmotionFit: fitFor(req.Emotion, personality)}
+		if personality == req.Preferred {
+			score.Preference = 1
+		}
+		score.Score = round2(score.EmotionFit*personalityEmotionWeight + score.Preference*personalityPreferenceWeight)
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Personality < scores[j].Personality
+	})
+
+	if req.Locked && req.Preferred != "" {
+		return PersonalityChoice{
+			Personality: req.Preferred,
+			Reasoning:   fmt.Sprintf("%s is locked in by the learner", req.Preferred),
+			Locked:      true,
+			Scores:      scores,
+		}
+	}
+
+	best := scores[0]
+	reasons := []string{fmt.Sprintf("%s scored %.2f: emotion fit %.2f for a %s learner x %.1f + preference %.0f x %.1f",
+		best.Personality, best.Score, best.EmotionFit, emotionLabel(req.Emotion), personalityEmotionWeight, best.Preference, personalityPreferenceWeight)}
+	if req.Preferred != "" && req.Preferred != best.Personality {
+		for _, score := range scores {
+			if score.Personality == req.Preferred {
+				reasons = append(reasons, fmt.Sprintf("preferred %s scored %.2f", score.Personality, score.Score))
+			}
+		}
+	}
+	reasons = append(reasons, notes...)
+	return PersonalityChoice{
+		Personality: best.Personality,
+		Reasoning:   strings.Join(reasons, "; "),
+		Scores:      scores,
+	}
+}
+
+// fitFor returns how well a personality suits an emotion
+func fitFor(emotion EmotionState, personality BuddyPersonality) float64 {
+	if fit, ok := emotionFit[emotion][personality]; ok {
+		return fit
+	}
+	return neutralEmotionFit
+}
+
+func emotionLabel(emotion EmotionState) string {
+	if emotion == "" {
+		return "undetected"
+	}
+	return string(emotion)
+}
+
+func round2(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
@@ -0,0 +1,48 @@
+package ai
+
+import "context"
+
+// ProjectPlan is the DIY project the diy_project_generator template declares
+type ProjectPlan struct {
+	Title          string             `json:"title"`
+	Description    string             `json:"description"`
+	Difficulty     int                `json:"difficulty"` // 1 (beginner) to 5 (expert)
+	EstimatedHours int                `json:"estimated_hours"`
+	Skills         []string           `json:"skills"`
+	Milestones     []ProjectMilestone `json:"milestones"`
+	Resources      []string           `json:"resources"`
+	SuccessMetrics []string           `json:"success_metrics"`
+}
+
+// ProjectMilestone is one checkable step of a ProjectPlan
+type ProjectMilestone struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Week        int    `json:"week"`
+	XPReward    int    `json:"xp_reward"`
+}
+
+// PlanProject generates a DIY project plan. variables fill the
+// diy_project_generator template; see its variables list.
+func (ai *AICore) PlanProject(ctx context.Context, variables map[string]interface{}) (*ProjectPlan, *AIResponse, error) {
+	var plan ProjectPlan
+	response, err := ai.GenerateStructured(ctx, "diy_project_generator", variables, PersonalityMentor, &plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &plan, response, nil
+}
+
+// MockPlanProject is PlanProject answered by the mock provider, for callers
+// the LLM is unavailable to
+func (ai *AICore) MockPlanProject(ctx context.Context, variables map[string]interface{}) (*ProjectPlan, *AIResponse, error) {
+	ai.mu.RLock()
+	mock := &AICore{
+		Provider:        &MockProvider{},
+		PromptTemplates: ai.PromptTemplates,
+		PersonalityMap:  ai.PersonalityMap,
+		Chains:          ai.Chains,
+	}
+	ai.mu.RUnlock()
+	return mock.PlanProject(ctx, variables)
+}
@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provider kinds accepted by NewProvider
+const (
+	ProviderOpenAI    = "openai"    // OpenAI and compatible servers such as Ollama, vLLM or llama.cpp
+	ProviderAnthropic = "anthropic" // Anthropic-style messages API
+	ProviderMock      = "mock"      // canned replies, no network
+)
+
+// LLMProvider generates chat completions. The AI core renders prompts into a
+// CompletionRequest and leaves the wire format to the provider.
+type LLMProvider interface {
+	// Name returns the provider kind, one of the Provider constants
+	Name() string
+	// Complete returns the whole completion at once
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	// Stream calls onDelta with each piece of content as it arrives and returns
+	// the assembled completion. An error from onDelta stops the stream.
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error)
+}
+
+// CompletionRequest is a provider-neutral chat completion request. An empty
+// Model uses the provider's default. TemplateID and Personality are not sent
+// upstream; the mock uses them to pick its canned reply.
+type CompletionRequest struct {
+	Model       string
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+	TemplateID  string
+	Personality BuddyPersonality
+}
+
+// Completion is a provider's reply to a CompletionRequest. Provider is set
+// when a wrapping provider answered with a different one.
+type Completion struct {
+	Content      string
+	Model        string
+	Provider     string
+	FinishReason string
+	Usage        Usage
+}
+
+// modelKey is the context key of the WithModel override
+type modelKey struct{}
+
+// WithModel returns a context whose completions use model instead of the
+// provider's default, e.g. a cheaper model for a user over quota
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFromContext returns the model set with WithModel, or "" for the provider default
+func ModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(modelKey{}).(string)
+	return model
+}
+
+// ProviderConfig selects and configures an LLMProvider
+type ProviderConfig struct {
+	Kind    string // one of the Provider constants; empty means mock
+	BaseURL string // API root, e.g. http://localhost:11434/v1 for Ollama; empty uses the hosted API
+	APIKey  string
+	Model   string // empty uses the provider's default model
+}
+
+// NewProvider builds the provider described by config. The hosted OpenAI and
+// Anthropic APIs need a key; a custom BaseURL may not.
+func NewProvider(config ProviderConfig) (LLMProvider, error) {
+	switch config.Kind {
+	case "", ProviderMock:
+		return &MockProvider{}, nil
+	case ProviderOpenAI:
+		if config.APIKey == "" && config.BaseURL == "" {
+			return nil, fmt.Errorf("openai provider needs an API key or a base URL")
+		}
+		client := NewOpenAIClient(config.APIKey)
+		if config.BaseURL != "" {
+			client.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+		}
+		if config.Model != "" {
+			client.Model = config.Model
+		}
+		return client, nil
+	case ProviderAnthropic:
+		if config.APIKey == "" && config.BaseURL == "" {
+			return nil, fmt.Errorf("anthropic provider needs an API key or a base URL")
+		}
+		client := NewAnthropicClient(config.APIKey)
+		if config.BaseURL != "" {
+			client.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+		}
+		if config.Model != "" {
+			client.Model = config.Model
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", config.Kind)
+	}
+}
+
+// MockProvider answers with the canned reply for the request's template and
+// personality, for development and tests without an LLM
+type MockProvider struct {
+	Delay time.Duration // pause between words when streaming
+}
+
+// Name returns ProviderMock
+func (m *MockProvider) Name() string { return ProviderMock }
+
+// Complete returns the canned reply
+func (m *MockProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	content := mockReply(req.TemplateID, req.Personality)
+	return &Completion{
+		Content:      content,
+		Model:        ProviderMock,
+		FinishReason: "stop",
+		Usage:        estimateUsage(req.Messages, content),
+	}, nil
+}
+
+// Stream sends the canned reply word by word
+func (m *MockProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	completion, err := m.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := streamWords(ctx, completion.Content, m.Delay, onDelta); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+// estimateUsage approximates the token usage a provider would report
+func estimateUsage(messages []Message, content string) Usage {
+	var prompt int
+	for _, message := range messages {
+		prompt += EstimateTokens(message.Content)
+	}
+	completion := EstimateTokens(content)
+	return Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+// streamWords passes text to onDelta a word at a time, waiting delay between words
+func streamWords(ctx context.Context, text string, delay time.Duration, onDelta func(string) error) error {
+	for i, word := range strings.SplitAfter(text, " ") {
+		if i > 0 && delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if err := onDelta(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of provider failure. ProviderError unwraps to one of them, so callers
+// can test with errors.Is(err, ai.ErrRateLimited).
+var (
+	ErrRateLimited    = errors.New("rate limited")
+	ErrContextTooLong = errors.New("context too long")
+	ErrAuthFailed     = errors.New("authentication failed")
+	ErrUpstream       = errors.New("upstream error")
+)
+
+// maxErrorMessageLength caps the upstream text kept in a ProviderError
+const maxErrorMessageLength = 300
+
+// ProviderError is a failed call to an LLM provider
+type ProviderError struct {
+	Kind       error         // ErrRateLimited, ErrContextTooLong, ErrAuthFailed or ErrUpstream
+	StatusCode int           // HTTP status, 0 when no response arrived
+	RetryAfter time.Duration // wait the upstream asked for, 0 if it named none
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%v: %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%v (status %d): %s", e.Kind, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// Retryable reports whether err is worth another attempt: rate limits, 5xx
+// responses and requests that never got a response
+func Retryable(err error) bool {
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) {
+		return false
+	}
+	switch providerErr.Kind {
+	case ErrRateLimited:
+		return true
+	case ErrUpstream:
+		return providerErr.StatusCode == 0 || providerErr.StatusCode >= 500
+	default:
+		return false
+	}
+}
+
+// responseError classifies a non-200 provider response
+func responseError(resp *http.Response, body []byte) *ProviderError {
+	message := errorMessage(body)
+	err := &ProviderError{Kind: ErrUpstream, StatusCode: resp.StatusCode, Message: message}
+
+	switch lower := strings.ToLower(message); {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		err.Kind = ErrAuthFailed
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err.Kind = ErrRateLimited
+		err.RetryAfter = retryAfter(resp.Header, time.Now())
+	case resp.StatusCode == http.StatusRequestEntityTooLarge,
+		strings.Contains(lower, "context_length_exceeded"),
+		strings.Contains(lower, "maximum context length"),
+		strings.Contains(lower, "prompt is too long"):
+		err.Kind = ErrContextTooLong
+	case resp.StatusCode >= 500:
+		err.RetryAfter = retryAfter(resp.Header, time.Now())
+	}
+	return err
+}
+
+// transportError wraps a request that failed before a response arrived. The
+// caller's own cancellation is returned as is.
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return &ProviderError{Kind: ErrUpstream, Message: err.Error()}
+}
+
+// errorMessage extracts {"error": {"message": ...}} from an error body, or
+// returns the start of the raw body
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error struct {
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		message := parsed.Error.Message
+		if parsed.Error.Code != "" {
+			message = parsed.Error.Code + ": " + message
+		}
+		return message
+	}
+
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorMessageLength {
+		message = message[:maxErrorMessageLength] + "..."
+	}
+	return message
+}
+
+// retryAfter reads the wait from Retry-After (seconds or an HTTP date) or
+// OpenAI's retry-after-ms header
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.Atoi(header.Get("Retry-After-Ms")); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"learning-buddy-ai/fakeopenai"
+)
+
+func newFakeOpenAICore(t *testing.T, config ProviderConfig) (*AICore, *fakeopenai.Server) {
+	t.Helper()
+	server := fakeopenai.NewServer()
+	t.Cleanup(server.Close)
+
+	config.Kind = ProviderOpenAI
+	config.BaseURL = server.BaseURL()
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	defaults, err := DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAICoreFromConfig(provider, defaults), server
+}
+
+func TestOpenAIProviderUsesConfiguredModelAndBaseURL(t *testing.T) {
+	core, server := newFakeOpenAICore(t, ProviderConfig{APIKey: "test-key", Model: "llama3"})
+	server.RequireKey("test-key")
+	history := []Message{{Role: "user", Content: "I'm Sam"}, {Role: "assistant", Content: "Hi Sam!"}}
+
+	reply, err := core.GenerateResponseWithProvider(context.Background(), "motivation_boost", map[string]interface{}{"user_goals": "ship it"}, PersonalityChill, history)
+	if err != nil {
+		t.Fatalf("GenerateResponseWithProvider() error = %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Model != "llama3" || req.Stream || len(req.Messages) != 4 || req.MaxTokens == 0 {
+		t.Fatalf("request = %+v, want llama3 with system, history and prompt", req)
+	}
+	if !strings.HasPrefix(reply.Message, "You said: ") || !strings.Contains(reply.Message, "ship it") {
+		t.Fatalf("reply = %q, want the fake server's echo of the prompt", reply.Message)
+	}
+	if reply.Metadata["provider"] != ProviderOpenAI || reply.Metadata["model"] != "llama3" || reply.Metadata["total_tokens"] == "0" {
+		t.Fatalf("metadata = %v, want provider, model and usage", reply.Metadata)
+	}
+}
+
+func TestOpenAIProviderStreamsFromFakeServer(t *testing.T) {
+	core, server := newFakeOpenAICore(t, ProviderConfig{})
+	server.SetReply(func(fakeopenai.Request) string { return "One step at a time." })
+
+	var tokens []string
+	reply, err := core.StreamResponseWithProvider(context.Background(), "motivation_boost", nil, PersonalityChill, nil, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamResponseWithProvider() error = %v", err)
+	}
+	if strings.Join(tokens, "|") != "One |step |at |a |time." || reply.Message != "One step at a time." {
+		t.Fatalf("tokens = %q, reply %q", tokens, reply.Message)
+	}
+	if req := server.Requests()[0]; !req.Stream || req.Authorization != "" || req.Model != "gpt-4" {
+		t.Fatalf("request = %+v, want a keyless gpt-4 stream", req)
+	}
+}
+
+func TestOpenAIProviderReportsUpstreamErrors(t *testing.T) {
+	core, server := newFakeOpenAICore(t, ProviderConfig{APIKey: "test-key"})
+	server.Fail(fakeopenai.Failure{Status: http.StatusTooManyRequests, Body: `{"error":{"message":"slow down"}}`})
+
+	_, err := core.GenerateResponseWithProvider(context.Background(), "motivation_boost", nil, PersonalityChill, nil)
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("error = %v, want the 429 status", err)
+	}
+	if _, err := core.GenerateResponseWithProvider(context.Background(), "motivation_boost", nil, PersonalityChill, nil); err != nil {
+		t.Fatalf("second request error = %v, want the queued failure used up", err)
+	}
+}
+
+func TestChainsRunThroughProvider(t *testing.T) {
+	core, server := newFakeOpenAICore(t, ProviderConfig{APIKey: "test-key"})
+	server.SetReply(func(req fakeopenai.Request) string { return "1) Reproduce the bug 2) Fix it" })
+
+	result, err := core.ExecutePromptChain(context.Background(), "problem_solving", map[string]interface{}{"problem_description": "my loop never ends"})
+	if err != nil {
+		t.Fatalf("ExecutePromptChain() error = %v", err)
+	}
+	if len(server.Requests()) == 0 || !strings.Contains(result.FinalResult.Message, "Fix it") {
+		t.Fatalf("requests = %d, final %q; want the chain answered by the fake server", len(server.Requests()), result.FinalResult.Message)
+	}
+}
+
+func TestAnthropicClientComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AnthropicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("path %s, headers %v", r.URL.Path, r.Header)
+		}
+		if req.System != "Be kind." || len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.MaxTokens != defaultAnthropicMaxTokens {
+			t.Errorf("request = %+v, want the system prompt split out and a default max_tokens", req)
+		}
+		fmt.Fprint(w, `{"id":"msg_1","model":"test-model","content":[{"type":"text","text":"Keep going!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":3}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{Kind: ProviderAnthropic, BaseURL: server.URL + "/v1", APIKey: "test-key", Model: "test-model"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	completion, err := provider.Complete(context.Background(), CompletionRequest{Messages: []Message{{Role: "system", Content: "Be kind."}, {Role: "user", Content: "Help"}}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if completion.Content != "Keep going!" || completion.FinishReason != "end_turn" || completion.Usage.TotalTokens != 13 {
+		t.Fatalf("completion = %+v", completion)
+	}
+}
+
+func TestAnthropicClientStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"test-model","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Keep "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"going!"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.BaseURL = server.URL
+	var deltas []string
+	completion, err := client.Stream(context.Background(), CompletionRequest{Messages: []Message{{Role: "user", Content: "Help"}}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if strings.Join(deltas, "|") != "Keep |going!" || completion.Content != "Keep going!" || completion.Model != "test-model" || completion.Usage.TotalTokens != 13 {
+		t.Fatalf("deltas = %q, completion = %+v", deltas, completion)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	for _, config := range []ProviderConfig{{Kind: "gemini"}, {Kind: ProviderOpenAI}, {Kind: ProviderAnthropic}} {
+		if _, err := NewProvider(config); err == nil {
+			t.Errorf("NewProvider(%+v) succeeded, want an error", config)
+		}
+	}
+	provider, err := NewProvider(ProviderConfig{Kind: ProviderOpenAI, BaseURL: "http://localhost:11434/v1/", Model: "llama3"})
+	if err != nil {
+		t.Fatalf("NewProvider(ollama) error = %v", err)
+	}
+	if client := provider.(*OpenAIClient); client.BaseURL != "http://localhost:11434/v1" || client.Model != "llama3" {
+		t.Fatalf("client = %+v, want the local base URL and model", client)
+	}
+	if provider, _ := NewProvider(ProviderConfig{}); provider.Name() != ProviderMock {
+		t.Fatalf("default provider = %s, want mock", provider.Name())
+	}
+}
+
+func TestMockProviderUsesTemplateReply(t *testing.T) {
+	core := NewAICore("")
+	reply, err := core.GenerateResponseWithProvider(context.Background(), "motivation_boost", nil, PersonalityChill, nil)
+	if err != nil {
+		t.Fatalf("GenerateResponseWithProvider() error = %v", err)
+	}
+	if !core.UsesMock() || reply.Message != mockReply("motivation_boost", PersonalityChill) || reply.Metadata["provider"] != ProviderMock {
+		t.Fatalf("reply = %+v, want the canned motivation_boost reply", reply)
+	}
+}
+
+func TestWithModelOverridesProviderDefault(t *testing.T) {
+	core, server := newFakeOpenAICore(t, ProviderConfig{APIKey: "test-key", Model: "gpt-4"})
+
+	ctx := WithModel(context.Background(), "gpt-4o-mini")
+	reply, err := core.StreamResponseWithProvider(ctx, "motivation_boost", nil, PersonalityChill, nil, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("StreamResponseWithProvider() error = %v", err)
+	}
+	if req := server.Requests()[0]; req.Model != "gpt-4o-mini" || reply.Usage.TotalTokens == 0 {
+		t.Fatalf("model = %q, usage %+v; want the override and reported usage", req.Model, reply.Usage)
+	}
+}
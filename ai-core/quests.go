@@ -0,0 +1,24 @@
+package ai
+
+import "context"
+
+// QuestPlan is the custom quest the quest_generator template declares
+type QuestPlan struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`       // code, focus, learn, debug or test
+	Difficulty  int      `json:"difficulty"` // 1 (beginner) to 5 (expert)
+	XP          int      `json:"xp"`
+	Tasks       []string `json:"tasks"`
+}
+
+// PlanQuest generates a custom quest. variables fill the quest_generator
+// template; see its variables list.
+func (ai *AICore) PlanQuest(ctx context.Context, variables map[string]interface{}) (*QuestPlan, *AIResponse, error) {
+	var plan QuestPlan
+	response, err := ai.GenerateStructured(ctx, "quest_generator", variables, PersonalityMentor, &plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &plan, response, nil
+}
@@ -0,0 +1,283 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while the circuit
+// breaker is open and no fallback provider is configured. It unwraps to ErrUpstream.
+var ErrCircuitOpen = fmt.Errorf("circuit open: %w", ErrUpstream)
+
+// ResilienceConfig tunes retries and the circuit breaker of a ResilientProvider
+type ResilienceConfig struct {
+	MaxAttempts      int           // attempts per request, including the first
+	BaseDelay        time.Duration // backoff before the first retry, doubled after each
+	MaxDelay         time.Duration // cap on a single wait; a longer Retry-After is not waited out
+	FailureThreshold int           // consecutive failures that open the circuit
+	Cooldown         time.Duration // how long the circuit stays open before a trial request
+}
+
+// DefaultResilienceConfig returns the settings used when none are configured
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// ResilientProvider wraps a provider with retries, exponential backoff with
+// jitter, and a circuit breaker. While the circuit is open, or once retries
+// are exhausted, requests go to Fallback when one is set.
+type ResilientProvider struct {
+	Primary  LLMProvider
+	Fallback LLMProvider // optional
+	Logf     func(format string, args ...interface{})
+
+	config  ResilienceConfig
+	breaker *CircuitBreaker
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientProvider wraps primary. Zero fields of config take their defaults.
+func NewResilientProvider(primary, fallback LLMProvider, config ResilienceConfig) *ResilientProvider {
+	defaults := DefaultResilienceConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = defaults.BaseDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = defaults.MaxDelay
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaults.Cooldown
+	}
+	return &ResilientProvider{
+		Primary:  primary,
+		Fallback: fallback,
+		config:   config,
+		breaker:  NewCircuitBreaker(config.FailureThreshold, config.Cooldown),
+		sleep:    sleepContext,
+	}
+}
+
+// Name returns the primary provider's name
+func (p *ResilientProvider) Name() string { return p.Primary.Name() }
+
+// Breaker returns the provider's circuit breaker
+func (p *ResilientProvider) Breaker() *CircuitBreaker { return p.breaker }
+
+// Complete calls the primary provider with retries, failing over when it is unhealthy
+func (p *ResilientProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	return p.call(ctx, func(provider LLMProvider) (*Completion, error) {
+		return provider.Complete(ctx, req)
+	}, func() bool { return true })
+}
+
+// Stream is Complete for streams. Once a delta has reached onDelta the reply
+// cannot be restarted, so later failures are returned as they are.
+func (p *ResilientProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	streamed := false
+	return p.call(ctx, func(provider LLMProvider) (*Completion, error) {
+		return provider.Stream(ctx, req, func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+	}, func() bool { return !streamed })
+}
+
+// call runs attempt against the primary until it succeeds, fails for good or
+// the circuit opens, then against the fallback. restartable reports whether a
+// failed attempt may be repeated.
+func (p *ResilientProvider) call(ctx context.Context, attempt func(LLMProvider) (*Completion, error), restartable func() bool) (*Completion, error) {
+	if !p.breaker.Allow() {
+		return p.failover(ErrCircuitOpen, attempt)
+	}
+
+	var err error
+	for i := 0; i < p.config.MaxAttempts; i++ {
+		var completion *Completion
+		completion, err = attempt(p.Primary)
+		if err == nil {
+			p.breaker.Success()
+			return completion, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrContextTooLong) || !restartable() {
+			// The request, not the upstream, is at fault
+			p.breaker.Release()
+			return nil, err
+		}
+		if !Retryable(err) || i == p.config.MaxAttempts-1 {
+			break
+		}
+
+		wait, ok := p.backoff(i, err)
+		if !ok {
+			break
+		}
+		p.logf("%s attempt %d failed, retrying in %s: %v", p.Primary.Name(), i+1, wait.Round(time.Millisecond), err)
+		if sleepErr := p.sleep(ctx, wait); sleepErr != nil {
+			p.breaker.Release()
+			return nil, sleepErr
+		}
+	}
+
+	if p.breaker.Failure() {
+		p.logf("%s circuit opened for %s after repeated failures: %v", p.Primary.Name(), p.config.Cooldown, err)
+	}
+	return p.failover(err, attempt)
+}
+
+// failover sends the request to the fallback provider, or returns err without one
+func (p *ResilientProvider) failover(err error, attempt func(LLMProvider) (*Completion, error)) (*Completion, error) {
+	if p.Fallback == nil {
+		return nil, err
+	}
+	completion, fallbackErr := attempt(p.Fallback)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w; fallback %s: %v", err, p.Fallback.Name(), fallbackErr)
+	}
+	completion.Provider = p.Fallback.Name()
+	return completion, nil
+}
+
+// backoff returns the wait before retry number i+1: BaseDelay doubled per
+// attempt with jitter, or the upstream's Retry-After. ok is false when the
+// upstream asks for longer than MaxDelay.
+func (p *ResilientProvider) backoff(i int, err error) (time.Duration, bool) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter, providerErr.RetryAfter <= p.config.MaxDelay
+	}
+
+	delay := p.config.BaseDelay << i
+	if delay <= 0 || delay > p.config.MaxDelay {
+		delay = p.config.MaxDelay
+	}
+	// Equal jitter: half the delay fixed, half random, so retries from many
+	// requests spread out without collapsing to zero
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+func (p *ResilientProvider) logf(format string, args ...interface{}) {
+	if p.Logf != nil {
+		p.Logf(format, args...)
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops calls to an unhealthy upstream. After threshold
+// consecutive failures it opens for cooldown, then lets a single trial call
+// through: success closes it, failure opens it again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: CircuitClosed}
+}
+
+// Allow reports whether a call may go to the upstream. Every allowed call
+// must be followed by Success, Failure or Release.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+		return true
+	case CircuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call and closes the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call and reports whether it opened the circuit
+func (b *CircuitBreaker) Failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		opened := b.state != CircuitOpen
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+		return opened
+	}
+	return false
+}
+
+// Release ends an allowed call whose outcome says nothing about upstream health
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// State returns the breaker's current state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
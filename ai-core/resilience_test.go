@@ -0,0 +1,204 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"learning-buddy-ai/fakeopenai"
+)
+
+// newResilientFake wraps a fake-server OpenAI client and records backoff waits instead of sleeping
+func newResilientFake(t *testing.T, fallback LLMProvider, config ResilienceConfig) (*ResilientProvider, *fakeopenai.Server, *[]time.Duration) {
+	t.Helper()
+	server := fakeopenai.NewServer()
+	t.Cleanup(server.Close)
+	client := NewOpenAIClient("test-key")
+	client.BaseURL = server.BaseURL()
+
+	provider := NewResilientProvider(client, fallback, config)
+	var waits []time.Duration
+	provider.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return provider, server, &waits
+}
+
+var chatRequest = CompletionRequest{Messages: []Message{{Role: "user", Content: "help"}}}
+
+func TestProviderErrorsAreTyped(t *testing.T) {
+	tests := []struct {
+		name      string
+		failure   fakeopenai.Failure
+		kind      error
+		retryable bool
+	}{
+		{"rate limit", fakeopenai.Failure{Status: 429, Body: `{"error":{"message":"slow down"}}`, Header: http.Header{"Retry-After": {"2"}}}, ErrRateLimited, true},
+		{"bad key", fakeopenai.Failure{Status: 401, Body: `{"error":{"message":"bad key"}}`}, ErrAuthFailed, false},
+		{"context", fakeopenai.Failure{Status: 400, Body: `{"error":{"code":"context_length_exceeded","message":"This model's maximum context length is 8192 tokens"}}`}, ErrContextTooLong, false},
+		{"server error", fakeopenai.Failure{Status: 503, Body: "overloaded"}, ErrUpstream, true},
+		{"bad request", fakeopenai.Failure{Status: 400, Body: `{"error":{"message":"invalid temperature"}}`}, ErrUpstream, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeopenai.NewServer()
+			defer server.Close()
+			client := NewOpenAIClient("test-key")
+			client.BaseURL = server.BaseURL()
+			server.Fail(tt.failure)
+
+			_, err := client.Complete(context.Background(), chatRequest)
+			var providerErr *ProviderError
+			if !errors.Is(err, tt.kind) || !errors.As(err, &providerErr) || providerErr.StatusCode != tt.failure.Status {
+				t.Fatalf("error = %v, want %v with status %d", err, tt.kind, tt.failure.Status)
+			}
+			if Retryable(err) != tt.retryable {
+				t.Fatalf("Retryable(%v) = %v, want %v", err, !tt.retryable, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		header http.Header
+		want   time.Duration
+	}{
+		"seconds":   {http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		"http date": {http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		"ms":        {http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		"missing":   {http.Header{}, 0},
+	}
+	for name, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("%s: retryAfter() = %s, want %s", name, got, tt.want)
+		}
+	}
+}
+
+func TestResilientProviderBacksOffWithJitter(t *testing.T) {
+	provider, server, waits := newResilientFake(t, nil, ResilienceConfig{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond})
+	server.Fail(fakeopenai.Failure{Status: 502, Body: "bad gateway"})
+	server.Fail(fakeopenai.Failure{Status: 500, Body: "oops"})
+
+	completion, err := provider.Complete(context.Background(), chatRequest)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(server.Requests()) != 3 || completion.Content == "" {
+		t.Fatalf("requests = %d, completion %+v; want success on the third attempt", len(server.Requests()), completion)
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waits = %v, want two backoffs", *waits)
+	}
+	if first := (*waits)[0]; first < 50*time.Millisecond || first > 100*time.Millisecond {
+		t.Errorf("first wait = %s, want 50-100ms", first)
+	}
+	if second := (*waits)[1]; second < 100*time.Millisecond || second > 200*time.Millisecond {
+		t.Errorf("second wait = %s, want 100-200ms", second)
+	}
+	if provider.Breaker().State() != CircuitClosed {
+		t.Fatalf("breaker = %s, want closed after a success", provider.Breaker().State())
+	}
+}
+
+func TestResilientProviderHonorsRetryAfter(t *testing.T) {
+	provider, server, waits := newResilientFake(t, nil, ResilienceConfig{MaxAttempts: 3, MaxDelay: 5 * time.Second})
+	server.Fail(fakeopenai.Failure{Status: 429, Body: "slow down", Header: http.Header{"Retry-After": {"3"}}})
+
+	if _, err := provider.Complete(context.Background(), chatRequest); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Fatalf("waits = %v, want the 3s Retry-After", *waits)
+	}
+
+	server.Fail(fakeopenai.Failure{Status: 429, Body: "slow down", Header: http.Header{"Retry-After": {"60"}}})
+	if _, err := provider.Complete(context.Background(), chatRequest); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Complete() error = %v, want ErrRateLimited rather than a 60s wait", err)
+	}
+	if len(*waits) != 1 {
+		t.Fatalf("waits = %v, want no wait beyond MaxDelay", *waits)
+	}
+}
+
+func TestResilientProviderDoesNotRetryRequestErrors(t *testing.T) {
+	provider, server, _ := newResilientFake(t, nil, ResilienceConfig{MaxAttempts: 3})
+	server.Fail(fakeopenai.Failure{Status: 400, Body: `{"error":{"code":"context_length_exceeded","message":"too long"}}`})
+
+	if _, err := provider.Complete(context.Background(), chatRequest); !errors.Is(err, ErrContextTooLong) {
+		t.Fatalf("Complete() error = %v, want ErrContextTooLong", err)
+	}
+	if len(server.Requests()) != 1 {
+		t.Fatalf("requests = %d, want 1", len(server.Requests()))
+	}
+}
+
+func TestCircuitBreakerFailsOverToFallback(t *testing.T) {
+	provider, server, _ := newResilientFake(t, &MockProvider{}, ResilienceConfig{MaxAttempts: 1, FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Now()
+	provider.Breaker().now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		server.Fail(fakeopenai.Failure{Status: 503, Body: "down"})
+	}
+
+	for i := 0; i < 3; i++ {
+		completion, err := provider.Complete(context.Background(), CompletionRequest{TemplateID: "motivation_boost", Personality: PersonalityChill})
+		if err != nil || completion.Provider != ProviderMock {
+			t.Fatalf("call %d = %+v, %v; want the mock fallback", i, completion, err)
+		}
+	}
+	if len(server.Requests()) != 2 || provider.Breaker().State() != CircuitOpen {
+		t.Fatalf("requests = %d, breaker %s; want the open circuit to skip the upstream", len(server.Requests()), provider.Breaker().State())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if provider.Breaker().State() != CircuitHalfOpen {
+		t.Fatalf("breaker = %s after cooldown, want half_open", provider.Breaker().State())
+	}
+	if _, err := provider.Complete(context.Background(), chatRequest); err != nil {
+		t.Fatalf("trial call error = %v", err)
+	}
+	// The third queued failure answers the trial, which falls back and reopens
+	if provider.Breaker().State() != CircuitOpen || len(server.Requests()) != 3 {
+		t.Fatalf("breaker = %s, requests %d; want a failed trial to reopen", provider.Breaker().State(), len(server.Requests()))
+	}
+
+	now = now.Add(2 * time.Minute)
+	completion, err := provider.Complete(context.Background(), chatRequest)
+	if err != nil || completion.Provider != "" || provider.Breaker().State() != CircuitClosed {
+		t.Fatalf("completion = %+v, %v, breaker %s; want a healthy upstream to close the circuit", completion, err, provider.Breaker().State())
+	}
+}
+
+func TestCircuitOpenWithoutFallback(t *testing.T) {
+	provider, server, _ := newResilientFake(t, nil, ResilienceConfig{MaxAttempts: 1, FailureThreshold: 1})
+	server.Fail(fakeopenai.Failure{Status: 500, Body: "down"})
+
+	if _, err := provider.Complete(context.Background(), chatRequest); !errors.Is(err, ErrUpstream) {
+		t.Fatalf("first call error = %v, want ErrUpstream", err)
+	}
+	_, err := provider.Stream(context.Background(), chatRequest, func(string) error { return nil })
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUpstream) || len(server.Requests()) != 1 {
+		t.Fatalf("second call error = %v, requests %d; want ErrCircuitOpen without a request", err, len(server.Requests()))
+	}
+}
+
+func TestResilientProviderRetriesStreamBeforeFirstToken(t *testing.T) {
+	provider, server, _ := newResilientFake(t, nil, ResilienceConfig{MaxAttempts: 2})
+	server.Fail(fakeopenai.Failure{Status: 503, Body: "down"})
+	server.SetReply(func(fakeopenai.Request) string { return "still here" })
+
+	var streamed string
+	completion, err := provider.Stream(context.Background(), chatRequest, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil || streamed != "still here" || completion.Content != "still here" {
+		t.Fatalf("stream = %q, %+v, %v; want the retried stream", streamed, completion, err)
+	}
+}
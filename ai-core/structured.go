@@ -0,0 +1,279 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOutput is returned when a template's reply still fails its output
+// schema after the repair prompt
+var ErrInvalidOutput = errors.New("invalid structured output")
+
+// OutputError reports a structured reply that could not be used. It unwraps to ErrInvalidOutput.
+type OutputError struct {
+	TemplateID string
+	Output     string // the last reply received
+	Err        error  // why it failed validation
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("template %s: %v: %v", e.TemplateID, ErrInvalidOutput, e.Err)
+}
+
+func (e *OutputError) Unwrap() error { return ErrInvalidOutput }
+
+// Output schema types
+const (
+	SchemaObject  = "object"
+	SchemaArray   = "array"
+	SchemaString  = "string"
+	SchemaNumber  = "number"
+	SchemaInteger = "integer"
+	SchemaBoolean = "boolean"
+)
+
+// OutputSchema is the subset of JSON Schema templates use to declare their
+// replies. Objects reject properties they do not declare.
+type OutputSchema struct {
+	Type       string                   `json:"type"`
+	Properties map[string]*OutputSchema `json:"properties,omitempty"`
+	Required   []string                 `json:"required,omitempty"`
+	Items      *OutputSchema            `json:"items,omitempty"`
+	Enum       []string                 `json:"enum,omitempty"`
+	Minimum    *float64                 `json:"minimum,omitempty"`
+	Maximum    *float64                 `json:"maximum,omitempty"`
+	MinItems   int                      `json:"minItems,omitempty"`
+	MaxItems   int                      `json:"maxItems,omitempty"`
+	MinLength  int                      `json:"minLength,omitempty"`
+}
+
+// repairPrompt asks the model to fix a reply that failed its schema
+const repairPrompt = "Your previous reply could not be used: %v\n\nReply again with only a JSON value matching this schema, with no other text:\n%s"
+
+// GenerateStructured renders a template that declares an output schema,
+// validates the reply against it and decodes it into out. A reply that fails
+// validation gets one repair prompt; if that fails too the error is an
+// *OutputError. The returned response carries the validated JSON in Data.
+func (ai *AICore) GenerateStructured(ctx context.Context, templateID string, variables map[string]interface{}, personality BuddyPersonality, out interface{}) (*AIResponse, error) {
+	template, ok := ai.Template(templateID)
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", templateID)
+	}
+	if template.Output == nil {
+		return nil, fmt.Errorf("template %s declares no output_schema", templateID)
+	}
+	req, err := ai.completionRequest(templateID, variables, personality, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = ModelFromContext(ctx)
+
+	provider := ai.provider()
+	startTime := time.Now()
+	completion, err := provider.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", provider.Name(), err)
+	}
+	data, invalid := template.Output.decode(completion.Content, out)
+	repaired := false
+	if invalid != nil {
+		schema, _ := json.MarshalIndent(template.Output, "", "  ")
+		req.Messages = append(req.Messages,
+			Message{Role: "assistant", Content: completion.Content},
+			Message{Role: "user", Content: fmt.Sprintf(repairPrompt, invalid, schema)},
+		)
+		usage := completion.Usage
+		completion, err = provider.Complete(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("%s repair request failed: %w", provider.Name(), err)
+		}
+		completion.Usage = addUsage(usage, completion.Usage)
+		if data, invalid = template.Output.decode(completion.Content, out); invalid != nil {
+			return nil, &OutputError{TemplateID: templateID, Output: completion.Content, Err: invalid}
+		}
+		repaired = true
+	}
+
+	response := completionReply(completion, provider.Name(), templateID, personality, time.Since(startTime))
+	response.Data = data
+	if repaired {
+		response.Metadata["repaired"] = "true"
+	}
+	return response, nil
+}
+
+// decode validates a reply against the schema and unmarshals it into out,
+// returning the compacted JSON
+func (s *OutputSchema) decode(content string, out interface{}) (json.RawMessage, error) {
+	decoder := json.NewDecoder(strings.NewReader(stripCodeFence(content)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("reply is not valid JSON: %v", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("reply has text after the JSON value")
+	}
+	if err := s.validate("$", value); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("reply does not fit %T: %v", out, err)
+		}
+	}
+	return data, nil
+}
+
+// validate checks a decoded JSON value against the schema, naming the first
+// offending path
+func (s *OutputSchema) validate(path string, value interface{}) error {
+	switch s.Type {
+	case SchemaObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s is missing %q", path, name)
+			}
+		}
+		for _, name := range sortedKeys(object) {
+			property, ok := s.Properties[name]
+			if !ok {
+				return fmt.Errorf("%s has unknown property %q", path, name)
+			}
+			if err := property.validate(path+"."+name, object[name]); err != nil {
+				return err
+			}
+		}
+	case SchemaArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if len(items) < s.MinItems {
+			return fmt.Errorf("%s needs at least %d items", path, s.MinItems)
+		}
+		if s.MaxItems > 0 && len(items) > s.MaxItems {
+			return fmt.Errorf("%s allows at most %d items", path, s.MaxItems)
+		}
+		for i, item := range items {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case SchemaString:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if len(strings.TrimSpace(text)) < s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", path, s.MinLength)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, text) {
+			return fmt.Errorf("%s must be one of %s, got %q", path, strings.Join(s.Enum, ", "), text)
+		}
+	case SchemaNumber, SchemaInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		if s.Type == SchemaInteger {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", path)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s must be at least %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s must be at most %v", path, *s.Maximum)
+		}
+	case SchemaBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+// problems lists what is wrong with the schema itself, for config validation
+func (s *OutputSchema) problems(path string) []string {
+	var problems []string
+	switch s.Type {
+	case SchemaObject:
+		if len(s.Properties) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: object without properties", path))
+		}
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: required property %q is not declared", path, name))
+			}
+		}
+		for _, name := range sortedKeys(s.Properties) {
+			if s.Properties[name] == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: empty schema", path, name))
+				continue
+			}
+			problems = append(problems, s.Properties[name].problems(path+"."+name)...)
+		}
+	case SchemaArray:
+		if s.Items == nil {
+			problems = append(problems, fmt.Sprintf("%s: array without items", path))
+		} else {
+			problems = append(problems, s.Items.problems(path+"[]")...)
+		}
+	case SchemaString, SchemaNumber, SchemaInteger, SchemaBoolean:
+	default:
+		problems = append(problems, fmt.Sprintf("%s: unknown type %q", path, s.Type))
+	}
+	return problems
+}
+
+// stripCodeFence removes a ```json ... ``` fence around a reply
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if newline := strings.IndexByte(content, '\n'); newline >= 0 {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+// addUsage sums the usage of two completions
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
+
+// sortedKeys returns a map's keys in order, so validation errors are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
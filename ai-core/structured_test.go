@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"learning-buddy-ai/fakeopenai"
+)
+
+const validPlan = `{"title":"Weather CLI","description":"Fetch and print forecasts.","difficulty":2,"estimated_hours":8,` +
+	`"skills":["HTTP","JSON"],"milestones":[{"title":"Call the API","description":"Print raw JSON.","week":1,"xp_reward":50}],` +
+	`"resources":["API docs"],"success_metrics":["Prints tomorrow's forecast"]}`
+
+var planVariables = map[string]interface{}{
+	"skill_level": "beginner", "interests": "weather", "available_time": 3, "project_type": "cli",
+	"learning_goals": "HTTP clients", "available_resources": "laptop", "previous_projects": "none", "timeline": 2,
+}
+
+func TestGenerateStructuredDecodesIntoStruct(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	server.SetReply(func(fakeopenai.Request) string { return "```json\n" + validPlan + "\n```" })
+
+	plan, response, err := core.PlanProject(context.Background(), planVariables)
+	if err != nil {
+		t.Fatalf("PlanProject() error = %v", err)
+	}
+	if plan.Title != "Weather CLI" || plan.Difficulty != 2 || len(plan.Milestones) != 1 || plan.Milestones[0].XPReward != 50 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if len(response.Data) == 0 || response.Metadata["repaired"] != "" || len(server.Requests()) != 1 {
+		t.Fatalf("response = %+v, requests %d; want the JSON in Data after one request", response, len(server.Requests()))
+	}
+}
+
+func TestGenerateStructuredRepairsOnce(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	server.SetReply(func(fakeopenai.Request) string {
+		if len(server.Requests()) == 1 {
+			return `{"title":"Weather CLI","difficulty":"easy"}`
+		}
+		return validPlan
+	})
+
+	plan, response, err := core.PlanProject(context.Background(), planVariables)
+	if err != nil {
+		t.Fatalf("PlanProject() error = %v", err)
+	}
+	if plan.Title != "Weather CLI" || response.Metadata["repaired"] != "true" {
+		t.Fatalf("plan = %+v, metadata %v; want the repaired plan", plan, response.Metadata)
+	}
+	repair := server.Requests()[1].Messages
+	if len(repair) != 4 || repair[2].Role != "assistant" || !strings.Contains(repair[3].Content, `$ is missing "description"`) {
+		t.Fatalf("repair messages = %+v, want the bad reply and what was wrong with it", repair)
+	}
+	if response.Usage.TotalTokens <= 0 || response.Metadata["total_tokens"] == "0" {
+		t.Fatalf("usage = %+v, want both calls counted", response.Usage)
+	}
+}
+
+func TestGenerateStructuredReturnsTypedError(t *testing.T) {
+	tests := map[string]struct {
+		reply   string
+		wantErr string
+	}{
+		"prose":           {"Here is a great project for you!", "not valid JSON"},
+		"wrong type":      {strings.Replace(validPlan, `"difficulty":2`, `"difficulty":"easy"`, 1), "$.difficulty must be a number"},
+		"fraction":        {strings.Replace(validPlan, `"difficulty":2`, `"difficulty":2.5`, 1), "$.difficulty must be an integer"},
+		"out of range":    {strings.Replace(validPlan, `"xp_reward":50`, `"xp_reward":5000`, 1), "$.milestones[0].xp_reward must be at most 200"},
+		"no milestones":   {strings.Replace(validPlan, `[{"title":"Call the API","description":"Print raw JSON.","week":1,"xp_reward":50}]`, `[]`, 1), "$.milestones needs at least 1 items"},
+		"unknown field":   {strings.Replace(validPlan, `"title"`, `"cost":0,"title"`, 1), `unknown property "cost"`},
+		"trailing object": {validPlan + " {}", "text after the JSON value"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			core, server := newLLMEmotionCore(t)
+			server.SetReply(func(fakeopenai.Request) string { return tt.reply })
+
+			_, _, err := core.PlanProject(context.Background(), planVariables)
+			var outputErr *OutputError
+			if !errors.Is(err, ErrInvalidOutput) || !errors.As(err, &outputErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("PlanProject() error = %v, want ErrInvalidOutput mentioning %q", err, tt.wantErr)
+			}
+			if outputErr.TemplateID != "diy_project_generator" || outputErr.Output != tt.reply || len(server.Requests()) != 2 {
+				t.Fatalf("error = %+v after %d requests, want one repair attempt", outputErr, len(server.Requests()))
+			}
+		})
+	}
+}
+
+func TestGenerateStructuredNeedsSchema(t *testing.T) {
+	core := NewAICore("")
+	if _, err := core.GenerateStructured(context.Background(), "motivation_boost", nil, PersonalityChill, nil); err == nil {
+		t.Fatal("GenerateStructured() on a prose template succeeded, want an error")
+	}
+}
+
+// TestMockRepliesMatchOutputSchemas keeps the canned replies usable by
+// handlers that expect structs
+func TestMockRepliesMatchOutputSchemas(t *testing.T) {
+	core := NewAICore("")
+	for id, template := range core.PromptTemplates {
+		if template.Output == nil {
+			continue
+		}
+		var data interface{}
+		if _, err := core.GenerateStructured(context.Background(), id, nil, template.Personality, &data); err != nil {
+			t.Errorf("%s: mock reply does not match its schema: %v", id, err)
+		}
+	}
+
+	plan, _, err := core.PlanProject(context.Background(), planVariables)
+	if err != nil || len(plan.Milestones) == 0 {
+		t.Fatalf("PlanProject() = %+v, %v; want the mock plan", plan, err)
+	}
+}
+
+func TestPlanQuestDecodesIntoStruct(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	server.SetReply(func(fakeopenai.Request) string {
+		return `{"title":"Table tests","description":"Cover a parser.","type":"test","difficulty":3,"xp":180,"tasks":["List cases","Write the table","Fix failures"]}`
+	})
+
+	plan, _, err := core.PlanQuest(context.Background(), map[string]interface{}{"target_difficulty": 3})
+	if err != nil || plan.Type != "test" || plan.XP != 180 || len(plan.Tasks) != 3 {
+		t.Fatalf("PlanQuest() = %+v, %v", plan, err)
+	}
+
+	server.SetReply(func(fakeopenai.Request) string {
+		return `{"title":"Table tests","description":"Cover a parser.","type":"poetry","difficulty":3,"xp":180,"tasks":["List cases","Write the table","Fix failures"]}`
+	})
+	if _, _, err := core.PlanQuest(context.Background(), nil); !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("PlanQuest() with an unknown type: error = %v, want ErrInvalidOutput", err)
+	}
+}
+
+func TestMockPlanProjectSkipsTheLLM(t *testing.T) {
+	core, server := newLLMEmotionCore(t)
+	plan, response, err := core.MockPlanProject(context.Background(), planVariables)
+	if err != nil || len(plan.Milestones) == 0 {
+		t.Fatalf("MockPlanProject() = %+v, %v; want the mock plan", plan, err)
+	}
+	if response.Metadata["provider"] != ProviderMock || len(server.Requests()) != 0 {
+		t.Fatalf("metadata = %v, requests %d; want the mock provider and no LLM calls", response.Metadata, len(server.Requests()))
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+
+	minPasswordLength = 8
+)
+
+var (
+	// ErrInvalidCredentials is returned when a login does not match any user/password pair
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrInvalidToken is returned for expired, malformed, revoked or wrongly typed tokens
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrInvalidRegistration is returned when a registration's username, email or password is rejected
+	ErrInvalidRegistration = errors.New("invalid registration")
+)
+
+// RefreshToken is the server-side record of an issued refresh token
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	UserID    int        `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TokenPair is returned to clients after a successful login, registration or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// AuthClaims are the JWT claims carried by access and refresh tokens
+type AuthClaims struct {
+	TokenType string `json:"typ"`
+	Role      string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID int
+	Role   string
+}
+
+// IsAdmin reports whether the principal may act on any user's data
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// AuthService handles user authentication and authorization
+type AuthService struct {
+	users        UserRepository
+	tokens       RefreshTokenRepository
+	secret       []byte
+	passwordCost int
+	now          func() time.Time
+}
+
+// NewAuthService creates a new auth service instance
+func NewAuthService(users UserRepository, tokens RefreshTokenRepository, secret []byte) *AuthService {
+	return &AuthService{
+		users:        users,
+		tokens:       tokens,
+		secret:       secret,
+		passwordCost: bcrypt.DefaultCost,
+		now:          time.Now,
+	}
+}
+
+// LoadJWTSecret reads JWT_SECRET from the environment. When it is unset a random
+// secret is generated, which invalidates all tokens on restart.
+func LoadJWTSecret() []byte {
+	if secret := getEnv("JWT_SECRET", ""); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("⚠️  JWT_SECRET not set, generating an ephemeral secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("generate JWT secret: %v", err)
+	}
+	return secret
+}
+
+// Register creates a new user with a bcrypt-hashed password
+func (a *AuthService) Register(ctx context.Context, username, email, password string) (*User, error) {
+	username = strings.TrimSpace(username)
+	email = strings.TrimSpace(email)
+
+	if len(username) < 3 || len(username) > 50 {
+		return nil, fmt.Errorf("%w: username must be between 3 and 50 characters", ErrInvalidRegistration)
+	}
+	if !strings.Contains(email, "@") || len(email) > 255 {
+		return nil, fmt.Errorf("%w: a valid email address is required", ErrInvalidRegistration)
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidRegistration, minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.passwordCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &User{
+		Username:         username,
+		Email:            email,
+		PasswordHash:     string(hash),
+		Role:             RoleLearner,
+		Plan:             PlanFree,
+		BuddyPersonality: defaultBuddyPersonality,
+		Level:            1,
+		Mood:             "mentor",
+		Timezone:         "UTC",
+	}
+	if err := a.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate checks a username (or email) and password pair
+func (a *AuthService) Authenticate(ctx context.Context, login, password string) (*User, error) {
+	login = strings.TrimSpace(login)
+
+	var user *User
+	var err error
+	if strings.Contains(login, "@") {
+		user, err = a.users.GetByEmail(ctx, login)
+	} else {
+		user, err = a.users.GetByUsername(ctx, login)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// IssueTokens creates a signed access token and a persisted refresh token for a user
+func (a *AuthService) IssueTokens(ctx context.Context, user *User) (*TokenPair, error) {
+	now := a.now()
+	userID := user.ID
+
+	accessToken, err := a.sign(userID, user.Role, tokenTypeAccess, newTokenID(), now, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := RefreshToken{
+		ID:        newTokenID(),
+		UserID:    userID,
+		ExpiresAt: now.Add(refreshTokenTTL),
+	}
+	refreshToken, err := a.sign(userID, "", tokenTypeRefresh, refresh.ID, now, refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.tokens.Create(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh rotates a refresh token: the old one is revoked and a new pair is issued
+func (a *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := a.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := a.tokens.Revoke(ctx, claims.ID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	// Reload the user so role changes take effect on the next access token
+	user, err := a.users.GetByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.IssueTokens(ctx, user)
+}
+
+// Logout revokes a refresh token so it can no longer be used
+func (a *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := a.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if err := a.tokens.Revoke(ctx, claims.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// ValidateAccessToken verifies an access token and returns the principal it was issued for
+func (a *AuthService) ValidateAccessToken(token string) (Principal, error) {
+	claims, err := a.parse(token, tokenTypeAccess)
+	if err != nil {
+		return Principal{}, err
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	return Principal{UserID: userID, Role: claims.Role}, nil
+}
+
+func (a *AuthService) sign(userID int, role, tokenType, id string, now time.Time, ttl time.Duration) (string, error) {
+	claims := AuthClaims{
+		TokenType: tokenType,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Subject:   strconv.Itoa(userID),
+			Issuer:    "learning-buddy-backend",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %w", tokenType, err)
+	}
+	return signed, nil
+}
+
+func (a *AuthService) parse(token, tokenType string) (*AuthClaims, error) {
+	claims := &AuthClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(a.now),
+	)
+	if err != nil || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Authentication middleware
+
+type contextKey string
+
+const principalContextKey contextKey = "principal"
+
+// PrincipalFromContext returns the authenticated caller placed on the context by RequireAuth
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the authenticated user ID placed on the context by RequireAuth
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.UserID, ok
+}
+
+// RequireAuth rejects requests without a valid bearer access token and puts
+// the authenticated principal on the request context
+func (a *AuthService) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Missing bearer token")
+			return
+		}
+
+		principal, err := a.ValidateAccessToken(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid or expired token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalContextKey, principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Auth handlers
+
+type authResponse struct {
+	*TokenPair
+	User *User `json:"user,omitempty"`
+}
+
+func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.auth.Register(r.Context(), req.Username, req.Email, req.Password)
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		http.Error(w, "Username or email already registered", http.StatusConflict)
+		return
+	case errors.Is(err, ErrInvalidRegistration):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("register %q: %v", req.Username, err)
+		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := s.auth.IssueTokens(r.Context(), user)
+	if err != nil {
+		log.Printf("issue tokens for user %d: %v", user.ID, err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(authResponse{TokenPair: tokens, User: user})
+}
+
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	login := req.Username
+	if login == "" {
+		login = req.Email
+	}
+
+	user, err := s.auth.Authenticate(r.Context(), login, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("authenticate %q: %v", login, err)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := s.auth.IssueTokens(r.Context(), user)
+	if err != nil {
+		log.Printf("issue tokens for user %d: %v", user.ID, err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResponse{TokenPair: tokens, User: user})
+}
+
+func (s *Server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := s.auth.Refresh(r.Context(), req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("refresh token: %v", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResponse{TokenPair: tokens})
+}
+
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := s.auth.Logout(r.Context(), req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("logout: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestAuthService returns an auth service on fresh memory repositories with cheap hashing
+func newTestAuthService(t *testing.T) *AuthService {
+	t.Helper()
+	repos := NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.RefreshTokens, []byte("test-secret"))
+	auth.passwordCost = bcrypt.MinCost
+	return auth
+}
+
+func TestAuthRegisterAndAuthenticate(t *testing.T) {
+	auth := newTestAuthService(t)
+	ctx := context.Background()
+
+	user, err := auth.Register(ctx, " carol ", "carol@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if user.Username != "carol" || user.PasswordHash == "password123" || user.Role != RoleLearner {
+		t.Fatalf("user = %+v, want a trimmed learner with a hashed password", user)
+	}
+	if _, err := auth.Register(ctx, "carol", "carol2@example.com", "password123"); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Register(duplicate) error = %v, want ErrDuplicate", err)
+	}
+
+	for _, login := range []string{"carol", "carol@example.com"} {
+		if got, err := auth.Authenticate(ctx, login, "password123"); err != nil || got.ID != user.ID {
+			t.Fatalf("Authenticate(%s) = %+v, %v; want carol", login, got, err)
+		}
+	}
+	if _, err := auth.Authenticate(ctx, "carol", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate(wrong password) error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := auth.Authenticate(ctx, "nobody", "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate(unknown user) error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthRefreshRotatesAndRevokes(t *testing.T) {
+	auth := newTestAuthService(t)
+	ctx := context.Background()
+	user, err := auth.Register(ctx, "carol", "carol@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	first, err := auth.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens() error = %v", err)
+	}
+
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Refresh() returned the same refresh token")
+	}
+	if _, err := auth.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reusing a rotated token: error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refreshing with an access token: error = %v, want ErrInvalidToken", err)
+	}
+
+	if err := auth.Logout(ctx, second.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := auth.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refreshing after logout: error = %v, want ErrInvalidToken", err)
+	}
+}
+
+// failingUserRepository fails every Create, like a database that is down
+type failingUserRepository struct {
+	UserRepository
+}
+
+func (r failingUserRepository) Create(ctx context.Context, user *User) error {
+	return errors.New("pq: connection refused")
+}
+
+func TestRegisterHidesServerErrors(t *testing.T) {
+	f := newTestFixture(t)
+
+	rec := f.do("POST", "/api/v1/auth/register", "", `{"username":"carol","email":"carol@example.com","password":"short"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "password must be at least") {
+		t.Fatalf("short password: status = %d, body %q; want 400 naming the rule", rec.Code, rec.Body)
+	}
+
+	f.server.auth.users = failingUserRepository{f.server.auth.users}
+	rec = f.do("POST", "/api/v1/auth/register", "", `{"username":"carol","email":"carol@example.com","password":"password123"}`)
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "pq:") {
+		t.Fatalf("database failure: status = %d, body %q; want a generic 500", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Activity stats recorded in the activity log and referenced by badge rules.
+// User stats (level, total_xp, current_streak, max_streak) are read from the
+// user record and ignore time windows.
+const (
+	StatQuestsCompleted = "quests_completed"
+	StatPerfectQuests   = "perfect_quests"
+	StatBugsFixed       = "bugs_fixed"
+	StatConceptsLearned = "concepts_learned"
+	StatFocusSessions   = "focus_sessions"
+	StatEarlySessions   = "early_sessions"
+	StatNightSessions   = "night_sessions"
+	StatHelpedUsers     = "helped_users"
+	StatBugsReported    = "bugs_reported"
+)
+
+// questTypeStats maps a quest type to the stat credited with the quest's total when it completes
+var questTypeStats = map[string]string{
+	"code":  StatBugsFixed,
+	"debug": StatBugsFixed,
+	"learn": StatConceptsLearned,
+}
+
+// ErrInvalidBadgeRule is returned when a badge's requirements cannot be parsed
+var ErrInvalidBadgeRule = errors.New("invalid badge rule")
+
+// BadgeDefinition is a badge as stored in the badges table
+type BadgeDefinition struct {
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Icon         string          `json:"icon"`
+	Category     string          `json:"category"`
+	Requirements json.RawMessage `json:"requirements"`
+	XPReward     int             `json:"xp_reward"`
+	Rarity       string          `json:"rarity"`
+}
+
+// BadgeRule is a node in a badge requirement tree: either an AND (All) or OR
+// (Any) of child rules, or a single comparison.
+//
+// Requirements are JSON objects. Each key other than "all" and "any" names a
+// stat and maps either to a number (stat >= number) or to a condition object;
+// multiple keys are ANDed:
+//
+//	{"quests_completed": 25}
+//	{"any": [{"early_sessions": 10}, {"night_sessions": 10}]}
+//	{"all": [{"level": 5}, {"quests_completed": {"op": ">=", "value": 3, "within_days": 7}}]}
+type BadgeRule struct {
+	All       []BadgeRule
+	Any       []BadgeRule
+	Condition *BadgeCondition
+}
+
+// BadgeCondition compares a stat against a value. WithinDays limits activity
+// stats to the trailing number of days; zero means all time.
+type BadgeCondition struct {
+	Stat       string `json:"stat"`
+	Op         string `json:"op"`
+	Value      int    `json:"value"`
+	WithinDays int    `json:"within_days"`
+}
+
+// badgeOperators are the supported comparison operators
+var badgeOperators = map[string]func(a, b int) bool{
+	">=": func(a, b int) bool { return a >= b },
+	">":  func(a, b int) bool { return a > b },
+	"<=": func(a, b int) bool { return a <= b },
+	"<":  func(a, b int) bool { return a < b },
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+}
+
+// ParseBadgeRule parses a badges.requirements JSON document into a rule tree
+func ParseBadgeRule(raw json.RawMessage) (BadgeRule, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return BadgeRule{}, fmt.Errorf("%w: %v", ErrInvalidBadgeRule, err)
+	}
+	if len(fields) == 0 {
+		return BadgeRule{}, fmt.Errorf("%w: no requirements", ErrInvalidBadgeRule)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var rules []BadgeRule
+	for _, key := range keys {
+		rule, err := parseBadgeField(key, fields[key])
+		if err != nil {
+			return BadgeRule{}, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+	return BadgeRule{All: rules}, nil
+}
+
+// parseBadgeField parses one key of a requirements object
+func parseBadgeField(key string, raw json.RawMessage) (BadgeRule, error) {
+	if key == "all" || key == "any" {
+		var children []json.RawMessage
+		if err := json.Unmarshal(raw, &children); err != nil || len(children) == 0 {
+			return BadgeRule{}, fmt.Errorf("%w: %q must be a non-empty list of rules", ErrInvalidBadgeRule, key)
+		}
+		rules := make([]BadgeRule, 0, len(children))
+		for _, child := range children {
+			rule, err := ParseBadgeRule(child)
+			if err != nil {
+				return BadgeRule{}, err
+			}
+			rules = append(rules, rule)
+		}
+		if key == "all" {
+			return BadgeRule{All: rules}, nil
+		}
+		return BadgeRule{Any: rules}, nil
+	}
+
+	condition := BadgeCondition{Stat: key, Op: ">="}
+	if err := json.Unmarshal(raw, &condition.Value); err != nil {
+		if err := json.Unmarshal(raw, &condition); err != nil {
+			return BadgeRule{}, fmt.Errorf("%w: %q must be a number or a condition", ErrInvalidBadgeRule, key)
+		}
+		condition.Stat = key
+	}
+	if _, ok := badgeOperators[condition.Op]; !ok {
+		return BadgeRule{}, fmt.Errorf("%w: unknown operator %q for %q", ErrInvalidBadgeRule, condition.Op, key)
+	}
+	if condition.WithinDays < 0 {
+		return BadgeRule{}, fmt.Errorf("%w: within_days for %q must not be negative", ErrInvalidBadgeRule, key)
+	}
+	return BadgeRule{Condition: &condition}, nil
+}
+
+// Evaluate reports whether the rule holds. stats returns the user's stats for
+// a trailing window of days (zero for all time).
+func (r BadgeRule) Evaluate(stats func(withinDays int) (map[string]int, error)) (bool, error) {
+	switch {
+	case r.Condition != nil:
+		values, err := stats(r.Condition.WithinDays)
+		if err != nil {
+			return false, err
+		}
+		return badgeOperators[r.Condition.Op](values[r.Condition.Stat], r.Condition.Value), nil
+	case len(r.Any) > 0:
+		for _, rule := range r.Any {
+			ok, err := rule.Evaluate(stats)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	default:
+		for _, rule := range r.All {
+			ok, err := rule.Evaluate(stats)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return len(r.All) > 0, nil
+	}
+}
+
+// BadgeService methods
+
+// Evaluate checks every badge the user has not yet earned against their stats,
+// awards the ones whose rules now hold and credits their XP reward. Earned
+// badges whose credit is missing, because an earlier award failed, are credited
+// again. It returns the newly earned badges. Badges with invalid requirements are logged and skipped.
+func (b *BadgeService) Evaluate(ctx context.Context, userID int) ([]Badge, error) {
+	definitions, err := b.badges.ListDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := b.badges.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	earned := make(map[int]bool, len(current))
+	for _, badge := range current {
+		earned[badge.ID] = badge.Earned
+	}
+
+	user, err := b.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := b.now()
+	windows := make(map[int]map[string]int)
+	stats := func(withinDays int) (map[string]int, error) {
+		if values, ok := windows[withinDays]; ok {
+			return values, nil
+		}
+		var since time.Time
+		if withinDays > 0 {
+			since = now.AddDate(0, 0, -withinDays)
+		}
+		values, err := b.activity.Totals(ctx, userID, since)
+		if err != nil {
+			return nil, err
+		}
+		values["level"] = user.Level
+		values["total_xp"] = user.XP
+		values["current_streak"] = user.Streak
+		values["max_streak"] = user.MaxStreak
+		windows[withinDays] = values
+		return values, nil
+	}
+
+	credited, err := b.xp.Credited(ctx, userID, XPSourceBadge)
+	if err != nil {
+		return nil, err
+	}
+
+	var awarded []Badge
+	for _, definition := range definitions {
+		if earned[definition.ID] {
+			if definition.XPReward != 0 && !credited[definition.ID] {
+				// The badge was recorded but its XP credit failed; retry it
+				if err := b.credit(ctx, userID, definition); err != nil {
+					return awarded, err
+				}
+			}
+			continue
+		}
+		rule, err := ParseBadgeRule(definition.Requirements)
+		if err != nil {
+			log.Printf("badge %d (%s): %v", definition.ID, definition.Name, err)
+			continue
+		}
+		ok, err := rule.Evaluate(stats)
+		if err != nil {
+			return awarded, err
+		}
+		if !ok {
+			continue
+		}
+
+		isNew, err := b.badges.Award(ctx, userID, definition.ID, now)
+		if err != nil {
+			return awarded, err
+		}
+		if !isNew {
+			continue // awarded concurrently
+		}
+		badge := Badge{ID: definition.ID, Name: definition.Name, Description: definition.Description, Icon: definition.Icon, Earned: true, UserID: userID}
+		awarded = append(awarded, badge)
+
+		if b.events != nil {
+			b.events.Publish(ctx, Event{Type: EventBadgeEarned, UserID: userID, OccurredAt: now, Badge: &badge})
+		}
+		if definition.XPReward != 0 {
+			if err := b.credit(ctx, userID, definition); err != nil {
+				return awarded, err
+			}
+		}
+	}
+	return awarded, nil
+}
+
+// credit awards a badge's XP reward. The ledger credits each badge once, so a
+// credit that lost a race with another evaluation is not an error.
+func (b *BadgeService) credit(ctx context.Context, userID int, definition BadgeDefinition) error {
+	_, err := b.xp.Award(ctx, userID, definition.XPReward, XPSourceBadge, intPtr(definition.ID), "Earned "+definition.Name+" badge")
+	if errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+// RecordActivity adds to a user's activity stat and re-evaluates their badges
+func (b *BadgeService) RecordActivity(ctx context.Context, userID int, stat string, amount int) error {
+	if err := b.activity.Record(ctx, userID, stat, amount, b.now()); err != nil {
+		return err
+	}
+	_, err := b.Evaluate(ctx, userID)
+	return err
+}
+
+// RecordDailyActivity adds one to a user's activity stat unless they already
+// have it today in loc, then re-evaluates their badges. It reports whether the
+// stat was recorded. Callers serialize calls for the same user.
+func (b *BadgeService) RecordDailyActivity(ctx context.Context, userID int, stat string, loc *time.Location) (bool, error) {
+	year, month, day := b.now().In(loc).Date()
+	totals, err := b.activity.Totals(ctx, userID, time.Date(year, month, day, 0, 0, 0, 0, loc))
+	if err != nil {
+		return false, err
+	}
+	if totals[stat] > 0 {
+		return false, nil
+	}
+	return true, b.RecordActivity(ctx, userID, stat, 1)
+}
+
+// HandleEvent records activity from quest events and re-evaluates badges
+func (b *BadgeService) HandleEvent(ctx context.Context, event Event) error {
+	if event.Type == EventQuestCompleted && event.Quest != nil {
+		quest := event.Quest
+		at := b.now()
+		if err := b.activity.Record(ctx, event.UserID, StatQuestsCompleted, 1, at); err != nil {
+			return err
+		}
+		if isPerfectQuest(quest) {
+			if err := b.activity.Record(ctx, event.UserID, StatPerfectQuests, 1, at); err != nil {
+				return err
+			}
+		}
+		if stat, ok := questTypeStats[quest.Type]; ok && quest.Total > 0 {
+			if err := b.activity.Record(ctx, event.UserID, stat, quest.Total, at); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := b.Evaluate(ctx, event.UserID)
+	return err
+}
+
+// isPerfectQuest reports whether every task of a completed quest was checked off before its due date
+func isPerfectQuest(quest *Quest) bool {
+	if len(quest.Tasks) == 0 {
+		return false
+	}
+	for _, task := range quest.Tasks {
+		if !task.Completed {
+			return false
+		}
+	}
+	return quest.DueDate == nil || quest.CompletedAt == nil || !quest.CompletedAt.After(*quest.DueDate)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBadgeRuleEvaluate(t *testing.T) {
+	allTime := map[string]int{"quests_completed": 30, "early_sessions": 4, "night_sessions": 12, "level": 5}
+	lastWeek := map[string]int{"quests_completed": 2}
+	stats := func(withinDays int) (map[string]int, error) {
+		if withinDays == 7 {
+			return lastWeek, nil
+		}
+		return allTime, nil
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		want  bool
+		valid bool
+	}{
+		{"shorthand met", `{"quests_completed": 25}`, true, true},
+		{"shorthand not met", `{"early_sessions": 10}`, false, true},
+		{"implicit and", `{"quests_completed": 25, "level": 10}`, false, true},
+		{"any", `{"any": [{"early_sessions": 10}, {"night_sessions": 10}]}`, true, true},
+		{"all", `{"all": [{"level": 5}, {"night_sessions": {"op": ">", "value": 11}}]}`, true, true},
+		{"time window", `{"quests_completed": {"value": 3, "within_days": 7}}`, false, true},
+		{"less than", `{"early_sessions": {"op": "<", "value": 5}}`, true, true},
+		{"unknown stat is zero", `{"helped_users": 1}`, false, true},
+		{"unknown operator", `{"level": {"op": "~", "value": 1}}`, false, false},
+		{"empty", `{}`, false, false},
+		{"empty any", `{"any": []}`, false, false},
+		{"non-numeric", `{"level": "ten"}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseBadgeRule(json.RawMessage(tt.rule))
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidBadgeRule) {
+					t.Fatalf("ParseBadgeRule() error = %v, want ErrInvalidBadgeRule", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBadgeRule() error = %v", err)
+			}
+			got, err := rule.Evaluate(stats)
+			if err != nil || got != tt.want {
+				t.Fatalf("Evaluate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBadgeEvaluateAwardsOnceAndCreditsXP(t *testing.T) {
+	repos := NewMemoryRepositories()
+	events := NewEventBus()
+	xp := NewXPService(repos.XP, repos.Users, events)
+	badges := NewBadgeService(repos.Badges, repos.Activity, repos.Users, xp, events)
+	badges.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	for i := 0; i < 9; i++ {
+		if err := badges.RecordActivity(ctx, 1, StatEarlySessions, 1); err != nil {
+			t.Fatalf("RecordActivity() error = %v", err)
+		}
+	}
+	if earned, _ := badges.Evaluate(ctx, 1); len(earned) != 0 {
+		t.Fatalf("earned %v before reaching 10 early sessions", earned)
+	}
+
+	before, _ := repos.Users.GetByID(ctx, 1)
+	if err := badges.RecordActivity(ctx, 1, StatEarlySessions, 1); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	after, _ := repos.Users.GetByID(ctx, 1)
+	if after.XP-before.XP != 50 {
+		t.Fatalf("xp gained = %d, want Early Bird reward 50", after.XP-before.XP)
+	}
+
+	if earned, err := badges.Evaluate(ctx, 1); err != nil || len(earned) != 0 {
+		t.Fatalf("re-evaluate = %v, %v, want no new badges", earned, err)
+	}
+	list, _ := repos.Badges.ListByUser(ctx, 1)
+	if !list[8].Earned {
+		t.Fatalf("Early Bird not marked earned: %+v", list[8])
+	}
+}
+
+// flakyXPRepository fails the next Record call while fail is set
+type flakyXPRepository struct {
+	XPRepository
+	fail bool
+}
+
+func (r *flakyXPRepository) Record(ctx context.Context, tx *XPTransaction, levelFor func(int) int) (*User, int, error) {
+	if r.fail {
+		r.fail = false
+		return nil, 0, errors.New("connection reset")
+	}
+	return r.XPRepository.Record(ctx, tx, levelFor)
+}
+
+func TestBadgeEvaluateRetriesFailedXPCredit(t *testing.T) {
+	repos := NewMemoryRepositories()
+	ledger := &flakyXPRepository{XPRepository: repos.XP}
+	xp := NewXPService(ledger, repos.Users, nil)
+	badges := NewBadgeService(repos.Badges, repos.Activity, repos.Users, xp, nil)
+	badges.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	if err := repos.Activity.Record(ctx, 1, StatEarlySessions, 10, badges.now()); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	before, _ := repos.Users.GetByID(ctx, 1)
+	ledger.fail = true
+	if _, err := badges.Evaluate(ctx, 1); err == nil {
+		t.Fatal("Evaluate() error = nil, want the failed XP credit")
+	}
+
+	for i := 0; i < 2; i++ {
+		if earned, err := badges.Evaluate(ctx, 1); err != nil || len(earned) != 0 {
+			t.Fatalf("retry %d = %v, %v; want no new badges", i, earned, err)
+		}
+	}
+	after, _ := repos.Users.GetByID(ctx, 1)
+	if after.XP-before.XP != 50 {
+		t.Fatalf("xp gained = %d, want the Early Bird reward 50 once", after.XP-before.XP)
+	}
+	if _, _, err := repos.XP.Record(ctx, &XPTransaction{UserID: 1, Amount: 50, SourceType: XPSourceBadge, SourceID: intPtr(9)}, xp.CalculateLevel); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second Early Bird credit error = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestBadgeRulesAddedWithoutCodeChanges(t *testing.T) {
+	repos := NewMemoryRepositories()
+	store := repos.Badges.(*memoryBadgeRepository)
+	store.definitions = append(store.definitions, BadgeDefinition{
+		ID: 13, Name: "Weekend Warrior", XPReward: 10,
+		Requirements: json.RawMessage(`{"all": [{"quests_completed": {"value": 1, "within_days": 2}}, {"any": [{"level": 3}, {"max_streak": 50}]}]}`),
+	})
+
+	events := NewEventBus()
+	xp := NewXPService(repos.XP, repos.Users, events)
+	badges := NewBadgeService(repos.Badges, repos.Activity, repos.Users, xp, events)
+	events.Subscribe(badges.HandleEvent, EventQuestCompleted, EventXPAwarded)
+	var earned []string
+	events.Subscribe(func(ctx context.Context, event Event) error {
+		earned = append(earned, event.Badge.Name)
+		return nil
+	}, EventBadgeEarned)
+
+	quests := NewQuestService(repos.Quests, events)
+	if _, err := quests.Transition(context.Background(), 3, QuestStatusCompleted); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	if len(earned) != 1 || earned[0] != "Weekend Warrior" {
+		t.Fatalf("earned = %v, want [Weekend Warrior]", earned)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ai "learning-buddy-ai"
+)
+
+// LoadProviderConfig reads the LLM provider settings under prefix (LLM_ or
+// LLM_FALLBACK_) from the environment. LLM_PROVIDER defaults to openai when
+// OPENAI_API_KEY or LLM_BASE_URL is set and to the mock otherwise;
+// LLM_FALLBACK_PROVIDER defaults to none. The API key falls back to the
+// provider's usual variable.
+func LoadProviderConfig(prefix string) ai.ProviderConfig {
+	config := ai.ProviderConfig{
+		Kind:    getEnv(prefix+"PROVIDER", ""),
+		BaseURL: getEnv(prefix+"BASE_URL", ""),
+		APIKey:  getEnv(prefix+"API_KEY", ""),
+		Model:   getEnv(prefix+"MODEL", ""),
+	}
+	if config.Kind == "" && prefix == "LLM_" {
+		config.Kind = ai.ProviderMock
+		if getEnv("OPENAI_API_KEY", "") != "" || config.BaseURL != "" {
+			config.Kind = ai.ProviderOpenAI
+		}
+	}
+	if config.APIKey == "" {
+		switch config.Kind {
+		case ai.ProviderOpenAI:
+			config.APIKey = getEnv("OPENAI_API_KEY", "")
+		case ai.ProviderAnthropic:
+			config.APIKey = getEnv("ANTHROPIC_API_KEY", "")
+		}
+	}
+	return config
+}
+
+// LoadResilienceConfig reads retry and circuit breaker settings from the environment
+func LoadResilienceConfig() ai.ResilienceConfig {
+	return ai.ResilienceConfig{
+		MaxAttempts:      getEnvInt("LLM_MAX_ATTEMPTS", 3),
+		BaseDelay:        time.Duration(getEnvInt("LLM_RETRY_BASE_MS", 500)) * time.Millisecond,
+		MaxDelay:         time.Duration(getEnvInt("LLM_RETRY_MAX_MS", 10000)) * time.Millisecond,
+		FailureThreshold: getEnvInt("LLM_BREAKER_THRESHOLD", 5),
+		Cooldown:         time.Duration(getEnvInt("LLM_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second,
+	}
+}
+
+// LoadLLMProvider builds the configured LLM provider. A real provider is
+// wrapped with retries and a circuit breaker that fails over to the optional
+// LLM_FALLBACK_ provider; without one the AI service falls back to the mock.
+func LoadLLMProvider() (ai.LLMProvider, error) {
+	primary, err := ai.NewProvider(LoadProviderConfig("LLM_"))
+	if err != nil {
+		return nil, err
+	}
+	if primary.Name() == ai.ProviderMock {
+		return primary, nil
+	}
+
+	var fallback ai.LLMProvider
+	if fallbackConfig := LoadProviderConfig("LLM_FALLBACK_"); fallbackConfig.Kind != "" {
+		if fallback, err = ai.NewProvider(fallbackConfig); err != nil {
+			return nil, fmt.Errorf("fallback provider: %w", err)
+		}
+	}
+	provider := ai.NewResilientProvider(primary, fallback, LoadResilienceConfig())
+	provider.Logf = log.Printf
+	return provider, nil
+}
+
+// LoadAICore builds the AI core from the prompt config at path, or from the copy
+// embedded in ai-core when path is empty. An invalid config is an error so the
+// server fails at startup instead of on the first chat. A nil provider uses the mock.
+func LoadAICore(provider ai.LLMProvider, path string) (*ai.AICore, error) {
+	if path == "" {
+		config, err := ai.DefaultConfig()
+		if err != nil {
+			return nil, err
+		}
+		return newAICore(provider, config), nil
+	}
+	config, err := ai.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return newAICore(provider, config), nil
+}
+
+// newAICore builds the core with the emotion detector chosen by BUDDY_EMOTION_DETECTOR
+func newAICore(provider ai.LLMProvider, config *ai.Config) *ai.AICore {
+	core := ai.NewAICoreFromConfig(provider, config)
+	core.EmotionDetector = getEnv("BUDDY_EMOTION_DETECTOR", ai.EmotionDetectorRules)
+	return core
+}
+
+// WatchPromptConfig reloads the core's prompt config from path whenever the
+// file changes or a signal arrives on reload, until ctx is cancelled
+func WatchPromptConfig(ctx context.Context, core *ai.AICore, path string, reload <-chan os.Signal) {
+	ai.NewConfigWatcher(core, path, 5*time.Second, log.Printf).Run(ctx, reload)
+}
+
+// timeOfDay buckets a local time into the periods ai-core's emotion rules expect
+func timeOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 17:
+		return "afternoon"
+	case hour >= 17 && hour < 22:
+		return "evening"
+	default:
+		return "night"
+	}
+}
+
+// behaviorFor builds the user's behavior for emotion detection from their
+// recent learning sessions
+func (s *Server) behaviorFor(ctx context.Context, user *User) ai.UserBehaviorData {
+	behavior, err := s.sessions.Behavior(ctx, user)
+	if err != nil {
+		log.Printf("build behavior for user %d: %v", user.ID, err)
+	}
+	return behavior
+}
+
+// chatRequest is the body of a buddy chat request. A zero ThreadID starts a
+// new thread; a Mood locks the buddy personality for this reply.
+type chatRequest struct {
+	Message  string `json:"message"`
+	Mood     string `json:"mood"`
+	ThreadID int    `json:"thread_id"`
+}
+
+// chatTurn is what a chat handler needs to generate a reply
+type chatTurn struct {
+	user    *User
+	thread  *ConversationThread
+	history []ai.Message
+}
+
+func (s *Server) buddyChatHandler(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	turn, ok := s.startChatTurn(w, r, req)
+	if !ok {
+		return
+	}
+
+	reply, err := s.ai.GenerateResponse(r.Context(), turn.user, s.behaviorFor(r.Context(), turn.user), turn.history, req.Message, req.Mood)
+	if err != nil {
+		log.Printf("buddy reply for user %d: %v", turn.user.ID, err)
+		http.Error(w, "Failed to generate buddy reply", http.StatusInternalServerError)
+		return
+	}
+
+	buddyResponse, err := s.saveBuddyReply(r.Context(), turn, req.Message, reply)
+	if err != nil {
+		log.Printf("save conversation for user %d: %v", turn.user.ID, err)
+		http.Error(w, "Failed to save conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buddyResponse)
+	s.compactThread(r.Context(), turn.user, turn.thread.ID)
+}
+
+// buddyChatStreamHandler streams the buddy's reply as Server-Sent Events: one
+// "token" event per generated token, then a "done" event carrying the payload
+// /buddy/chat returns, or an "error" event. GET reads message, mood and
+// thread_id from the query string, POST from the JSON body.
+func (s *Server) buddyChatStreamHandler(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Message = query.Get("message")
+		req.Mood = query.Get("mood")
+		if threadID := query.Get("thread_id"); threadID != "" {
+			var err error
+			if req.ThreadID, err = strconv.Atoi(threadID); err != nil {
+				http.Error(w, "Invalid thread ID", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	turn, ok := s.startChatTurn(w, r, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, data interface{}) error {
+		if err := writeSSE(w, event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	reply, err := s.ai.StreamResponse(r.Context(), turn.user, s.behaviorFor(r.Context(), turn.user), turn.history, req.Message, req.Mood, func(token string) error {
+		return send("token", map[string]string{"token": token})
+	})
+	if err != nil {
+		log.Printf("buddy stream for user %d: %v", turn.user.ID, err)
+		send("error", map[string]string{"error": "Failed to generate buddy reply"})
+		return
+	}
+
+	buddyResponse, err := s.saveBuddyReply(r.Context(), turn, req.Message, reply)
+	if err != nil {
+		log.Printf("save conversation for user %d: %v", turn.user.ID, err)
+		send("error", map[string]string{"error": "Failed to save conversation"})
+		return
+	}
+	send("done", buddyResponse)
+	s.compactThread(r.Context(), turn.user, turn.thread.ID)
+}
+
+// startChatTurn loads the caller, opens their thread and assembles its history.
+// It writes the error response and returns false when any of that fails.
+func (s *Server) startChatTurn(w http.ResponseWriter, r *http.Request, req chatRequest) (*chatTurn, bool) {
+	userID, _ := UserIDFromContext(r.Context())
+
+	user, err := s.repos.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("load user %d for chat: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	thread, err := s.conversations.Open(r.Context(), userID, req.ThreadID, req.Message)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("open thread %d for user %d: %v", req.ThreadID, userID, err)
+		http.Error(w, "Failed to load thread", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	history, err := s.conversations.History(r.Context(), thread)
+	if err != nil {
+		log.Printf("load history of thread %d: %v", thread.ID, err)
+		http.Error(w, "Failed to load thread", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &chatTurn{user: user, thread: thread, history: history}, true
+}
+
+// saveBuddyReply stores a chat exchange in its thread, credits the reply's XP
+// reward and returns the chat response payload. Chat XP is capped per local day
+// of the user, so the payload reports what was actually credited: less than
+// the reply's reward near the cap, and zero once it is used up or when the
+// credit fails.
+func (s *Server) saveBuddyReply(ctx context.Context, turn *chatTurn, message string, reply *ai.AIResponse) (*BuddyChatResponse, error) {
+	buddyResponse := &BuddyChatResponse{
+		BuddyMessage: BuddyMessage{
+			UserID:   turn.user.ID,
+			Message:  message,
+			Response: reply.Message,
+			Mood:     string(reply.Personality),
+			Context: map[string]interface{}{
+				"template_id":           reply.Metadata["template_id"],
+				"detected_emotion":      reply.DetectedEmotion,
+				"history_messages":      len(turn.history),
+				"personality_reasoning": reply.Metadata["personality_reasoning"],
+			},
+			Timestamp: time.Now(),
+		},
+		Personality:      string(reply.Personality),
+		DetectedEmotion:  string(reply.DetectedEmotion),
+		SuggestedActions: reply.SuggestedActions,
+		ConfidenceScore:  reply.ConfidenceScore,
+	}
+	if buddyResponse.SuggestedActions == nil {
+		buddyResponse.SuggestedActions = []string{}
+	}
+
+	if err := s.conversations.Record(ctx, turn.thread, &buddyResponse.BuddyMessage); err != nil {
+		return nil, err
+	}
+
+	if limit := s.conversations.config.DailyXP; reply.XPReward > 0 && limit > 0 {
+		loc := userLocation(turn.user.Timezone)
+		year, month, day := s.conversations.now().In(loc).Date()
+		today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+		description := fmt.Sprintf("Buddy chat (%s)", reply.Metadata["template_id"])
+		award, err := s.xp.AwardCapped(ctx, turn.user.ID, reply.XPReward, XPSourceAIChain, &buddyResponse.ID, description, today, limit)
+		switch {
+		case errors.Is(err, ErrXPCapReached):
+		case err != nil:
+			log.Printf("award chat xp to user %d: %v", turn.user.ID, err)
+		default:
+			buddyResponse.XPReward = award.Transaction.Amount
+			buddyResponse.TotalXP = award.TotalXP
+		}
+	}
+	return buddyResponse, nil
+}
+
+// compactThread folds old turns into the thread's memory note after a reply has
+// been sent. Failures only cost memory, so they are logged.
+func (s *Server) compactThread(ctx context.Context, user *User, threadID int) {
+	if err := s.conversations.Compact(ctx, user, threadID); err != nil {
+		log.Printf("summarize thread %d: %v", threadID, err)
+	}
+}
+
+// writeSSE writes one Server-Sent Event with a JSON payload
+func writeSSE(w io.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
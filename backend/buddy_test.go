@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ai "learning-buddy-ai"
+	"learning-buddy-ai/fakeopenai"
+)
+
+// newFakeLLMService returns an AI service whose provider is a local fake OpenAI server
+func newFakeLLMService(t *testing.T) (*AIService, *fakeopenai.Server) {
+	t.Helper()
+	server := fakeopenai.NewServer()
+	t.Cleanup(server.Close)
+
+	client := ai.NewOpenAIClient("test-key")
+	client.BaseURL = server.BaseURL()
+	core, err := LoadAICore(ai.NewResilientProvider(client, nil, ai.ResilienceConfig{MaxAttempts: 1, FailureThreshold: 3}), "")
+	if err != nil {
+		t.Fatalf("LoadAICore() error = %v", err)
+	}
+	return NewAIService(core), server
+}
+
+func TestAIServiceFallsBackToMock(t *testing.T) {
+	service, server := newFakeLLMService(t)
+	server.Fail(fakeopenai.Failure{Status: http.StatusServiceUnavailable, Body: "upstream unavailable"})
+	user := &User{ID: 1, Level: 3, Streak: 5}
+
+	reply, err := service.GenerateResponse(context.Background(), user, ai.UserBehaviorData{CompletionRate: 1, StreakDays: 5}, nil, "what next?", "cheerleader")
+	if err != nil {
+		t.Fatalf("GenerateResponse() error = %v", err)
+	}
+	if len(server.Requests()) != 1 {
+		t.Fatalf("llm calls = %d, want 1", len(server.Requests()))
+	}
+	if reply.Metadata["mode"] != "mock" || reply.Personality != ai.PersonalityCheerleader || reply.Message == "" {
+		t.Fatalf("reply = %+v, want a cheerleader mock reply", reply)
+	}
+}
+
+func TestAIServiceStreamFallsBackToMock(t *testing.T) {
+	service, server := newFakeLLMService(t)
+	server.Fail(fakeopenai.Failure{Status: http.StatusServiceUnavailable, Body: "upstream unavailable"})
+	user := &User{ID: 1, Level: 3, Streak: 5}
+
+	var streamed strings.Builder
+	reply, err := service.StreamResponse(context.Background(), user, ai.UserBehaviorData{CompletionRate: 1}, nil, "what next?", "chill", func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamResponse() error = %v", err)
+	}
+	if len(server.Requests()) != 1 || reply.Metadata["mode"] != "mock" || streamed.String() != reply.Message {
+		t.Fatalf("calls = %d, reply = %+v, streamed %q; want the mock reply streamed after one LLM attempt", len(server.Requests()), reply, streamed.String())
+	}
+}
+
+func TestBuddyChatSurvivesUpstreamOutage(t *testing.T) {
+	f := newTestFixture(t)
+	service, server := newFakeLLMService(t)
+	f.server.ai = service
+	for i := 0; i < 10; i++ {
+		server.Fail(fakeopenai.Failure{Status: http.StatusServiceUnavailable, Body: "upstream unavailable"})
+	}
+
+	for i := 0; i < 5; i++ {
+		rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"are you there?"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("chat %d status = %d, body %s", i, rec.Code, rec.Body)
+		}
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Fatalf("upstream requests = %d, want the circuit to open after 3 failures", got)
+	}
+}
+
+func TestLoadLLMProvider(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("LLM_PROVIDER", "")
+	t.Setenv("LLM_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("LLM_FALLBACK_PROVIDER", "mock")
+
+	provider, err := LoadLLMProvider()
+	if err != nil {
+		t.Fatalf("LoadLLMProvider() error = %v", err)
+	}
+	resilient, ok := provider.(*ai.ResilientProvider)
+	if !ok || resilient.Primary.Name() != ai.ProviderOpenAI || resilient.Fallback == nil || resilient.Fallback.Name() != ai.ProviderMock {
+		t.Fatalf("provider = %#v, want openai wrapped with a mock fallback", provider)
+	}
+
+	t.Setenv("LLM_PROVIDER", "gemini")
+	if _, err := LoadLLMProvider(); err == nil {
+		t.Fatal("LoadLLMProvider(gemini) succeeded, want an error")
+	}
+}
+
+func TestBuddyChatThroughLLMProvider(t *testing.T) {
+	f := newTestFixture(t)
+	service, server := newFakeLLMService(t)
+	f.server.ai = service
+	server.SetReply(func(req fakeopenai.Request) string { return fmt.Sprintf("reply %d", len(server.Requests())) })
+
+	var first BuddyChatResponse
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"My name is Sam"}`)
+	json.NewDecoder(rec.Body).Decode(&first)
+	if rec.Code != http.StatusOK || first.Response != "reply 1" {
+		t.Fatalf("status = %d, reply %+v; want the fake server's reply", rec.Code, first)
+	}
+
+	rec = f.do("POST", "/api/v1/buddy/chat/stream", "owner", fmt.Sprintf(`{"message":"What is my name?","thread_id":%d}`, first.ThreadID))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"response":"reply 2"`) {
+		t.Fatalf("stream status = %d, body %s", rec.Code, rec.Body)
+	}
+	requests := server.Requests()
+	second := requests[1]
+	if !second.Stream || len(second.Messages) != 4 || second.Messages[1].Content != "My name is Sam" || second.Messages[2].Content != "reply 1" {
+		t.Fatalf("second request = %+v, want the first turn as history", second)
+	}
+}
+
+func TestBuddyChatLogsLLMEmotion(t *testing.T) {
+	t.Setenv("BUDDY_EMOTION_DETECTOR", ai.EmotionDetectorLLM)
+	f := newTestFixture(t)
+	service, server := newFakeLLMService(t)
+	moods := &memoryMoodLogRepository{}
+	service.moods = moods
+	f.server.ai = service
+	server.SetReply(func(req fakeopenai.Request) string {
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "determine the user's emotional state") {
+			return `{"emotional_state":"excited","confidence":0.9,"indicators":["fast answers"],"recommended_personality":"cheerleader","reasoning":"quick wins"}`
+		}
+		return "Let's go!"
+	})
+
+	var reply BuddyChatResponse
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"I just solved it!"}`)
+	json.NewDecoder(rec.Body).Decode(&reply)
+	if rec.Code != http.StatusOK || reply.DetectedEmotion != string(ai.EmotionExcited) {
+		t.Fatalf("status = %d, reply %+v; want the LLM's emotion", rec.Code, reply)
+	}
+	if len(moods.entries) != 1 {
+		t.Fatalf("mood logs = %d, want 1", len(moods.entries))
+	}
+	entry := moods.entries[0]
+	if entry.UserID != 1 || entry.Mood != "excited" || entry.Factors["source"] != ai.EmotionDetectorLLM || entry.Factors["llm"] == nil {
+		t.Fatalf("mood log = %+v, want the merged LLM detection", entry)
+	}
+
+	// A reply that fails validation falls back to the rules and says why
+	server.SetReply(func(fakeopenai.Request) string { return "You seem happy!" })
+	f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"again"}`)
+	if entry := moods.entries[1]; entry.Factors["source"] != ai.EmotionDetectorRules || entry.Factors["fallback_reason"] == nil {
+		t.Fatalf("mood log = %+v, want a rule fallback", entry)
+	}
+}
+
+func TestBuddyChatStreamSendsTokensThenDone(t *testing.T) {
+	f := newTestFixture(t)
+
+	rec := f.do("POST", "/api/v1/buddy/chat/stream", "owner", `{"message":"I keep failing this test","mood":"chill"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var tokens strings.Builder
+	var done BuddyChatResponse
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		event, data, _ := strings.Cut(block, "\n")
+		event = strings.TrimPrefix(event, "event: ")
+		data = strings.TrimPrefix(data, "data: ")
+		events = append(events, event)
+		switch event {
+		case "token":
+			var token struct{ Token string }
+			if err := json.Unmarshal([]byte(data), &token); err != nil {
+				t.Fatalf("decode token %q: %v", data, err)
+			}
+			tokens.WriteString(token.Token)
+		case "done":
+			if err := json.Unmarshal([]byte(data), &done); err != nil {
+				t.Fatalf("decode done %q: %v", data, err)
+			}
+		}
+	}
+
+	if len(events) < 3 || events[len(events)-1] != "done" {
+		t.Fatalf("events = %v, want tokens followed by done", events)
+	}
+	if done.Response == "" || tokens.String() != done.Response || done.Personality != "chill" {
+		t.Fatalf("done = %+v, streamed %q; want the streamed chill reply", done, tokens.String())
+	}
+	saved, err := f.server.repos.Conversations.ListByUser(context.Background(), 1)
+	if err != nil || len(saved) == 0 || saved[len(saved)-1].Response != done.Response {
+		t.Fatalf("saved conversations = %+v, %v; want the streamed reply last", saved, err)
+	}
+}
+
+func TestBuddyChatReturnsAIFields(t *testing.T) {
+	f := newTestFixture(t)
+
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"I keep failing this test","mood":"mentor"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, field := range []string{"response", "personality", "detected_emotion", "suggested_actions", "xp_reward", "confidence_score"} {
+		if _, ok := got[field]; !ok {
+			t.Errorf("response missing %q: %s", field, rec.Body)
+		}
+	}
+	if got["personality"] != "mentor" || got["xp_reward"].(float64) <= 0 {
+		t.Fatalf("personality/xp_reward = %v/%v, want mentor with a reward", got["personality"], got["xp_reward"])
+	}
+}
+
+func TestBuddyChatAwardsXP(t *testing.T) {
+	f := newTestFixture(t)
+	ctx := context.Background()
+
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"I finally got it working!"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var reply BuddyChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if reply.XPReward <= 0 || reply.TotalXP != 750+reply.XPReward {
+		t.Fatalf("xp_reward = %d, total_xp = %d; want the reward added to 750", reply.XPReward, reply.TotalXP)
+	}
+
+	user, _ := f.server.repos.Users.GetByID(ctx, 1)
+	if user.XP != reply.TotalXP {
+		t.Fatalf("user xp = %d, want %d", user.XP, reply.TotalXP)
+	}
+	ledger, _, err := f.server.repos.XP.ListByUser(ctx, 1, 10, 0)
+	if err != nil || len(ledger) == 0 {
+		t.Fatalf("ledger = %+v, %v", ledger, err)
+	}
+	if tx := ledger[0]; tx.SourceType != XPSourceAIChain || tx.Amount != reply.XPReward || tx.SourceID == nil || *tx.SourceID != reply.ID {
+		t.Fatalf("latest transaction = %+v, want the chat reward for message %d", tx, reply.ID)
+	}
+}
+
+func TestBuddyChatXPIsCappedPerDay(t *testing.T) {
+	f := newTestFixture(t)
+	f.server.conversations.config.DailyXP = 50
+	ctx := context.Background()
+
+	credited := 0
+	for i := 0; i < 5; i++ {
+		rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"I finally got it working!"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("chat %d: status = %d, body %s", i, rec.Code, rec.Body)
+		}
+		reply := decodeJSON[BuddyChatResponse](t, rec.Body.Bytes())
+		credited += reply.XPReward
+	}
+	if credited != 50 {
+		t.Fatalf("xp reported over five chats = %d, want the daily cap 50", credited)
+	}
+	if user, _ := f.server.repos.Users.GetByID(ctx, 1); user.XP != 750+50 {
+		t.Fatalf("user xp = %d, want 800", user.XP)
+	}
+}
+
+func TestBuddyPersonalityPreference(t *testing.T) {
+	f := newTestFixture(t)
+
+	if rec := f.do("PUT", "/api/v1/users/2/buddy/personality", "other", `{"personality":"focused"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("level 1 picking focused: status = %d, want 400", rec.Code)
+	}
+	if rec := f.do("PUT", "/api/v1/users/1/buddy/personality", "owner", `{"locked":true}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("locking no personality: status = %d, want 400", rec.Code)
+	}
+	if rec := f.do("PUT", "/api/v1/users/1/buddy/personality", "owner", `{"personality":"chill","locked":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("lock chill: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"I keep failing this test"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chat: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var got BuddyChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Personality != string(ai.PersonalityChill) {
+		t.Errorf("personality = %s, want the locked chill", got.Personality)
+	}
+	if reasoning, _ := got.Context["personality_reasoning"].(string); !strings.Contains(reasoning, "locked") {
+		t.Errorf("personality_reasoning = %q, want it to mention the lock", reasoning)
+	}
+}
+
+func TestBuddyChatWithCustomPersonality(t *testing.T) {
+	f := newTestFixture(t)
+	server, _ := useFakeLLM(t, f, LoadUsageConfig())
+	f.server.ai.personalities = f.server.repos.Personalities
+
+	if rec := f.do("PUT", "/api/v1/users/1/buddy/personality", "owner", `{"personality":"socratic_tutor","locked":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("choose socratic_tutor: status = %d, body %s", rec.Code, rec.Body)
+	}
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"why does my loop never end?"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"personality":"socratic_tutor"`) {
+		t.Fatalf("chat status = %d, body %s; want the socratic tutor", rec.Code, rec.Body)
+	}
+	requests := server.Requests()
+	if system := requests[len(requests)-1].Messages[0].Content; !strings.Contains(system, "As a socratic tutor,") {
+		t.Fatalf("system prompt = %q, want the tutor's prefix", system)
+	}
+
+	var listed []PersonalityInfo
+	rec = f.do("GET", "/api/v1/buddy/personalities", "other", "")
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode personalities: %v", err)
+	}
+	keys := map[string]bool{}
+	for _, personality := range listed {
+		keys[personality.Key] = personality.Unlocked
+	}
+	if len(listed) != 5 || !keys["socratic_tutor"] || keys["focused"] {
+		t.Fatalf("personalities = %+v; want the built-ins and the shared tutor, with focused still locked", listed)
+	}
+}
+
+func TestPersonalityKeysArePerOwner(t *testing.T) {
+	f := newTestFixture(t)
+	f.server.ai.personalities = f.server.repos.Personalities
+	body := `{"name":"Code Reviewer","traits":{"patience":8},"prompt_modifiers":{"prefix":"As a reviewer,"},"unlock_level":1}`
+
+	rec := f.do("POST", "/api/v1/buddy/personalities", "other", body)
+	var created CustomPersonality
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Key != "code_reviewer" || created.OwnerID != 2 {
+		t.Fatalf("create status = %d, personality %+v; want bob's own code_reviewer", rec.Code, created)
+	}
+	if rec := f.do("POST", "/api/v1/buddy/personalities", "owner", body); rec.Code != http.StatusConflict {
+		t.Fatalf("owner reusing own name: status = %d, want 409", rec.Code)
+	}
+
+	if rec := f.do("PUT", "/api/v1/users/2/buddy/personality", "other", `{"personality":"code_reviewer"}`); rec.Code != http.StatusOK {
+		t.Fatalf("choose code_reviewer: status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := f.do("DELETE", "/api/v1/buddy/personalities/code_reviewer", "owner", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("owner deletes own: status = %d", rec.Code)
+	}
+	mine, err := f.server.repos.Personalities.Get(context.Background(), 2, "code_reviewer")
+	if err != nil || mine.ID != created.ID {
+		t.Fatalf("Get(bob, code_reviewer) = %+v, %v; want bob's personality untouched", mine, err)
+	}
+	if bob, _ := f.server.repos.Users.GetByID(context.Background(), 2); bob.BuddyPersonality != "code_reviewer" {
+		t.Fatalf("bob's personality = %q, want his choice kept", bob.BuddyPersonality)
+	}
+}
+
+func TestLoadAICoreFailsFastOnInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt_templates.json")
+	config := `{"prompt_templates": {}, "personality_configs": {}, "chain_configurations": {"problem_solving": {"steps": [{"name": "analyze", "template_id": "step_by_step_solution"}]}}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAICore(nil, path); !errors.Is(err, ai.ErrInvalidConfig) || !strings.Contains(err.Error(), "step_by_step_solution") {
+		t.Fatalf("LoadAICore() error = %v, want ErrInvalidConfig naming step_by_step_solution", err)
+	}
+	if core, err := LoadAICore(nil, ""); err != nil || !core.UsesMock() {
+		t.Fatalf("LoadAICore(embedded) = %v, %v", core, err)
+	}
+}
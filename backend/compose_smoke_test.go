@@ -0,0 +1,80 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ai "learning-buddy-ai"
+)
+
+// TestComposeDatabaseSmoke runs a learner's first requests against the database
+// docker-compose creates, so a schema missing a migration fails here instead of
+// at login. Start it with `docker compose up -d postgres` and run
+//
+//	DB_HOST=localhost DB_PASSWORD=buddy_pass go test -tags=integration -run TestComposeDatabaseSmoke .
+func TestComposeDatabaseSmoke(t *testing.T) {
+	cfg, ok := LoadDatabaseConfig()
+	if !ok {
+		t.Skip("DB_HOST not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, err := OpenDatabase(ctx, cfg)
+	if err != nil {
+		t.Fatalf("OpenDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	repos := NewPostgresRepositories(db)
+	auth := NewAuthService(repos.Users, repos.RefreshTokens, []byte("smoke-secret"))
+	router := NewServer(repos, auth, NewAIService(ai.NewAICore(""))).Router()
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	username := fmt.Sprintf("smoke%d", time.Now().UnixNano())
+	credentials := fmt.Sprintf(`{"username":%q,"email":"%s@example.com","password":"password123"}`, username, username)
+	if rec := do("POST", "/api/v1/auth/register", "", credentials); rec.Code != http.StatusCreated {
+		t.Fatalf("register: status = %d, body %s", rec.Code, rec.Body)
+	}
+	rec := do("POST", "/api/v1/auth/login", "", credentials)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var login authResponse
+	if err := json.NewDecoder(rec.Body).Decode(&login); err != nil || login.TokenPair == nil || login.User == nil {
+		t.Fatalf("login body = %+v, %v; want tokens and the user", login, err)
+	}
+
+	token, userID := login.AccessToken, login.User.ID
+	for _, path := range []string{
+		fmt.Sprintf("/api/v1/users/%d", userID),
+		fmt.Sprintf("/api/v1/users/%d/quests", userID),
+		fmt.Sprintf("/api/v1/users/%d/badges", userID),
+		fmt.Sprintf("/api/v1/users/%d/xp/history", userID),
+		fmt.Sprintf("/api/v1/users/%d/streak", userID),
+		fmt.Sprintf("/api/v1/users/%d/threads", userID),
+		"/api/v1/buddy/personalities",
+	} {
+		if rec := do("GET", path, token, ""); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status = %d, body %s", path, rec.Code, rec.Body)
+		}
+	}
+	if rec := do("PUT", fmt.Sprintf("/api/v1/users/%d/buddy/personality", userID), token, `{"personality":"chill","locked":true}`); rec.Code != http.StatusOK {
+		t.Errorf("set buddy personality: status = %d, body %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	ai "learning-buddy-ai"
+)
+
+// maxThreadTitleLength caps thread titles taken from the opening message
+const maxThreadTitleLength = 60
+
+// ConversationThread groups a learner's chat turns with the buddy. Summary is
+// the rolling memory note of turns up to SummarizedThrough, which are no
+// longer sent to the model verbatim.
+type ConversationThread struct {
+	ID                int            `json:"id"`
+	UserID            int            `json:"user_id"`
+	Title             string         `json:"title"`
+	Summary           string         `json:"summary"`
+	SummarizedThrough int            `json:"-"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Messages          []BuddyMessage `json:"messages,omitempty"`
+}
+
+// ConversationConfig bounds the history sent with each chat and the XP chats earn
+type ConversationConfig struct {
+	HistoryTurns  int // turns sent verbatim before older ones are summarized
+	HistoryTokens int // token budget for the memory note and verbatim turns
+	DailyXP       int // XP chat replies may credit per local day; zero credits none
+}
+
+// LoadConversationConfig reads the history and XP limits from the environment
+func LoadConversationConfig() ConversationConfig {
+	return ConversationConfig{
+		HistoryTurns:  getEnvInt("BUDDY_HISTORY_TURNS", 10),
+		HistoryTokens: getEnvInt("BUDDY_HISTORY_TOKENS", 1500),
+		DailyXP:       getEnvInt("BUDDY_CHAT_DAILY_XP", 100),
+	}
+}
+
+// errSummaryMock is returned by SummarizeConversation when the quota keeps the user off the LLM
+var errSummaryMock = errors.New("llm summaries are unavailable for this user")
+
+// Summarizer folds a user's chat turns into a rolling memory note. It is
+// satisfied by *AIService.
+type Summarizer interface {
+	SummarizeConversation(ctx context.Context, user *User, previous string, turns []ai.Message) (string, error)
+}
+
+// SummarizeConversation asks the LLM to fold turns into the memory note and
+// records the tokens against the user. Users the quota keeps off the LLM get
+// errSummaryMock; their threads keep the note they have.
+func (s *AIService) SummarizeConversation(ctx context.Context, user *User, previous string, turns []ai.Message) (string, error) {
+	llmCtx, decision := s.decide(ctx, user)
+	if decision.UseMock {
+		return "", errSummaryMock
+	}
+	summary, response, err := s.core.SummarizeConversation(llmCtx, previous, turns)
+	if err != nil {
+		return "", err
+	}
+	if response != nil {
+		s.recordUsage(ctx, user, response, decision)
+	}
+	return summary, nil
+}
+
+// Open returns the caller's thread, or starts a new one titled after the
+// first message when threadID is 0. Another user's thread is ErrNotFound.
+func (s *ConversationService) Open(ctx context.Context, userID, threadID int, firstMessage string) (*ConversationThread, error) {
+	if threadID != 0 {
+		thread, err := s.conversations.GetThread(ctx, threadID)
+		if err != nil {
+			return nil, err
+		}
+		if thread.UserID != userID {
+			return nil, ErrNotFound
+		}
+		return thread, nil
+	}
+
+	now := s.now()
+	thread := &ConversationThread{UserID: userID, Title: threadTitle(firstMessage), CreatedAt: now, UpdatedAt: now}
+	if err := s.conversations.CreateThread(ctx, thread); err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+// History assembles the thread's memory note and most recent turns for the next
+// request, within the configured token budget
+func (s *ConversationService) History(ctx context.Context, thread *ConversationThread) ([]ai.Message, error) {
+	messages, err := s.conversations.ListThreadMessages(ctx, thread.ID)
+	if err != nil {
+		return nil, err
+	}
+	recent := unsummarized(messages, thread.SummarizedThrough)
+	if len(recent) > s.config.HistoryTurns {
+		recent = recent[len(recent)-s.config.HistoryTurns:]
+	}
+	return ai.ConversationHistory(thread.Summary, chatTurns(recent), s.config.HistoryTokens), nil
+}
+
+// Record saves a chat turn to the thread
+func (s *ConversationService) Record(ctx context.Context, thread *ConversationThread, message *BuddyMessage) error {
+	message.ThreadID = thread.ID
+	return s.conversations.Create(ctx, message)
+}
+
+// Compact folds older turns into the thread's memory note once more than
+// HistoryTurns are unsummarized, keeping the newest half verbatim. Users
+// without LLM summaries are left with the recent turns only.
+func (s *ConversationService) Compact(ctx context.Context, user *User, threadID int) error {
+	thread, err := s.conversations.GetThread(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	messages, err := s.conversations.ListThreadMessages(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	recent := unsummarized(messages, thread.SummarizedThrough)
+	if len(recent) <= s.config.HistoryTurns {
+		return nil
+	}
+
+	keep := s.config.HistoryTurns / 2
+	if keep < 1 {
+		keep = 1
+	}
+	old := recent[:len(recent)-keep]
+	summary, err := s.summarizer.SummarizeConversation(ctx, user, thread.Summary, chatTurns(old))
+	if errors.Is(err, errSummaryMock) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.conversations.UpdateSummary(ctx, threadID, summary, old[len(old)-1].ID)
+}
+
+// Thread returns a thread with its turns
+func (s *ConversationService) Thread(ctx context.Context, threadID int) (*ConversationThread, error) {
+	thread, err := s.conversations.GetThread(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.Messages, err = s.conversations.ListThreadMessages(ctx, threadID); err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+// unsummarized returns the turns saved after the one with ID through
+func unsummarized(messages []BuddyMessage, through int) []BuddyMessage {
+	for i, message := range messages {
+		if message.ID > through {
+			return messages[i:]
+		}
+	}
+	return nil
+}
+
+// chatTurns converts saved turns into alternating user and assistant messages
+func chatTurns(messages []BuddyMessage) []ai.Message {
+	turns := make([]ai.Message, 0, 2*len(messages))
+	for _, message := range messages {
+		turns = append(turns,
+			ai.Message{Role: "user", Content: message.Message},
+			ai.Message{Role: "assistant", Content: message.Response})
+	}
+	return turns
+}
+
+// threadTitle shortens the opening message of a thread into its title
+func threadTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if title == "" {
+		return "New conversation"
+	}
+	if utf8.RuneCountInString(title) > maxThreadTitleLength {
+		title = string([]rune(title)[:maxThreadTitleLength-3]) + "..."
+	}
+	return title
+}
+
+func (s *Server) getThreadsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	threads, err := s.repos.Conversations.ListThreads(r.Context(), userID)
+	if err != nil {
+		log.Printf("list threads for user %d: %v", userID, err)
+		http.Error(w, "Failed to load threads", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threads)
+}
+
+func (s *Server) getThreadHandler(w http.ResponseWriter, r *http.Request) {
+	threadID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	thread, err := s.conversations.Thread(r.Context(), threadID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("get thread %d: %v", threadID, err)
+		http.Error(w, "Failed to load thread", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thread)
+}
+
+func (s *Server) deleteThreadHandler(w http.ResponseWriter, r *http.Request) {
+	threadID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	err := s.repos.Conversations.DeleteThread(r.Context(), threadID)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Thread not found", http.StatusNotFound)
+	default:
+		log.Printf("delete thread %d: %v", threadID, err)
+		http.Error(w, "Failed to delete thread", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	ai "learning-buddy-ai"
+)
+
+func TestBuddyChatContinuesThread(t *testing.T) {
+	f := newTestFixture(t)
+
+	var first BuddyChatResponse
+	rec := f.do("POST", "/api/v1/buddy/chat", "other", `{"message":"How do recursive functions end?"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first chat status = %d, body %s", rec.Code, rec.Body)
+	}
+	json.NewDecoder(rec.Body).Decode(&first)
+	if first.ThreadID == 0 || first.Context["history_messages"] != float64(0) {
+		t.Fatalf("first reply = %+v, want a new thread with no history", first)
+	}
+
+	var second BuddyChatResponse
+	rec = f.do("POST", "/api/v1/buddy/chat", "other", fmt.Sprintf(`{"message":"And what if it never does?","thread_id":%d}`, first.ThreadID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second chat status = %d, body %s", rec.Code, rec.Body)
+	}
+	json.NewDecoder(rec.Body).Decode(&second)
+	if second.ThreadID != first.ThreadID || second.Context["history_messages"] != float64(2) {
+		t.Fatalf("second reply = %+v, want thread %d with the first turn as history", second, first.ThreadID)
+	}
+
+	var thread ConversationThread
+	rec = f.do("GET", fmt.Sprintf("/api/v1/threads/%d", first.ThreadID), "other", "")
+	json.NewDecoder(rec.Body).Decode(&thread)
+	if thread.Title != "How do recursive functions end?" || len(thread.Messages) != 2 {
+		t.Fatalf("thread = %+v, want both turns under the opening title", thread)
+	}
+}
+
+func TestGetThreadHandlerReportsMissingThread(t *testing.T) {
+	f := newTestFixture(t)
+
+	// a thread deleted after requireThreadAccess let the request through
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/threads/999", nil), map[string]string{"id": "999"})
+	rec := httptest.NewRecorder()
+	f.server.getThreadHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestConversationCompactSummarizesOldTurns(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	summarizer, _ := newFakeLLMService(t)
+	service := NewConversationService(repos.Conversations, summarizer, ConversationConfig{HistoryTurns: 4, HistoryTokens: 1500})
+
+	thread, err := service.Open(ctx, 2, 0, "turn 1")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		message := &BuddyMessage{UserID: 2, Message: fmt.Sprintf("turn %d", i), Response: "ok"}
+		if err := service.Record(ctx, thread, message); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if err := service.Compact(ctx, &User{ID: 2}, thread.ID); err != nil {
+			t.Fatalf("Compact() error = %v", err)
+		}
+	}
+
+	thread, err = service.Thread(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("Thread() error = %v", err)
+	}
+	if !strings.Contains(thread.Summary, "turn 1") || !strings.Contains(thread.Summary, "turn 3") || strings.Contains(thread.Summary, "turn 4") {
+		t.Fatalf("summary = %q, want turns 1-3 folded in and 4-5 kept verbatim", thread.Summary)
+	}
+
+	history, err := service.History(ctx, thread)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(history) != 5 || history[0].Role != "system" || history[1].Content != "turn 4" {
+		t.Fatalf("history = %+v, want the memory note then turns 4 and 5", history)
+	}
+}
+
+func TestConversationCompactSkipsMockUsers(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	service := NewConversationService(repos.Conversations, NewAIService(ai.NewAICore("")), ConversationConfig{HistoryTurns: 1, HistoryTokens: 1500})
+
+	thread, err := service.Open(ctx, 2, 0, "turn 1")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := service.Record(ctx, thread, &BuddyMessage{UserID: 2, Message: fmt.Sprintf("turn %d", i), Response: "ok"}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if err := service.Compact(ctx, &User{ID: 2}, thread.ID); err != nil {
+			t.Fatalf("Compact() error = %v", err)
+		}
+	}
+
+	thread, err = service.Thread(ctx, thread.ID)
+	if err != nil || thread.Summary != "" || thread.SummarizedThrough != 0 {
+		t.Fatalf("thread = %+v, %v; want no memory note without an LLM", thread, err)
+	}
+}
+
+func TestOpenRejectsOtherUsersThread(t *testing.T) {
+	repos := NewMemoryRepositories()
+	service := NewConversationService(repos.Conversations, NewAIService(ai.NewAICore("")), LoadConversationConfig())
+
+	if _, err := service.Open(context.Background(), 2, 1, "hi"); err != ErrNotFound {
+		t.Fatalf("Open(other user's thread) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// EventType identifies a domain event
+type EventType string
+
+// Quest lifecycle events
+const (
+	EventQuestProgressed    EventType = "quest.progressed"
+	EventQuestTaskCompleted EventType = "quest.task_completed"
+	EventQuestCompleted     EventType = "quest.completed"
+	EventQuestPaused        EventType = "quest.paused"
+	EventQuestResumed       EventType = "quest.resumed"
+	EventQuestFailed        EventType = "quest.failed"
+)
+
+// XP ledger events
+const (
+	EventXPAwarded EventType = "xp.awarded"
+	EventLevelUp   EventType = "xp.level_up"
+)
+
+// Streak events
+const (
+	EventStreakExtended EventType = "streak.extended"
+)
+
+// Badge events
+const (
+	EventBadgeEarned EventType = "badge.earned"
+)
+
+// DIY project events
+const (
+	EventProjectStarted            EventType = "project.started"
+	EventProjectMilestoneCompleted EventType = "project.milestone_completed"
+	EventProjectCompleted          EventType = "project.completed"
+	EventProjectAbandoned          EventType = "project.abandoned"
+)
+
+// Event is a domain event published after a state change has been persisted
+type Event struct {
+	Type       EventType         `json:"type"`
+	UserID     int               `json:"user_id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Quest      *Quest            `json:"quest,omitempty"`
+	Task       *QuestTask        `json:"task,omitempty"`
+	XP         *XPAward          `json:"xp,omitempty"`
+	Badge      *Badge            `json:"badge,omitempty"`
+	Streak     *StreakState      `json:"streak,omitempty"`
+	Project    *Project          `json:"project,omitempty"`
+	Milestone  *ProjectMilestone `json:"milestone,omitempty"`
+}
+
+// EventHandler reacts to a domain event
+type EventHandler func(ctx context.Context, event Event) error
+
+// EventBus is a synchronous in-process publish/subscribe bus for domain events
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[EventType][]EventHandler
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[EventType][]EventHandler)}
+}
+
+// Subscribe registers a handler for one or more event types
+func (b *EventBus) Subscribe(handler EventHandler, types ...EventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, eventType := range types {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+	}
+}
+
+// Publish delivers an event to every subscribed handler in registration order.
+// Handler errors are logged and do not stop delivery to other handlers.
+func (b *EventBus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := append([]EventHandler(nil), b.handlers[event.Type]...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			log.Printf("event %s for user %d: %v", event.Type, event.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FocusStatus is the lifecycle state of a focus session
+type FocusStatus string
+
+const (
+	FocusStatusRunning     FocusStatus = "running"
+	FocusStatusPaused      FocusStatus = "paused"
+	FocusStatusCompleted   FocusStatus = "completed"
+	FocusStatusInvalidated FocusStatus = "invalidated"
+)
+
+// focusQuestType is the quest type whose progress counts focused minutes
+const focusQuestType = "focus"
+
+var (
+	// ErrInvalidFocusSession is returned when a focus session request is out of range
+	// or names a quest that cannot take focused minutes
+	ErrInvalidFocusSession = errors.New("invalid focus session")
+	// ErrInvalidFocusTransition is returned when pausing a paused session or resuming a running one
+	ErrInvalidFocusTransition = errors.New("invalid focus session transition")
+	// ErrFocusSessionClosed is returned when changing a completed or invalidated session
+	ErrFocusSessionClosed = errors.New("focus session is closed")
+)
+
+// FocusConfig controls focus session lengths and heartbeat verification
+type FocusConfig struct {
+	DefaultMinutes int
+	MinMinutes     int
+	MaxMinutes     int
+	// HeartbeatGap is the longest silence between heartbeats still counted as focused time
+	HeartbeatGap time.Duration
+	// MaxGaps is how many gaps a session survives; one more invalidates it
+	MaxGaps int
+}
+
+// LoadFocusConfig reads the focus session configuration from the environment
+func LoadFocusConfig() FocusConfig {
+	return FocusConfig{
+		DefaultMinutes: getEnvInt("FOCUS_DEFAULT_MINUTES", 25),
+		MinMinutes:     getEnvInt("FOCUS_MIN_MINUTES", 5),
+		MaxMinutes:     getEnvInt("FOCUS_MAX_MINUTES", 120),
+		HeartbeatGap:   time.Duration(getEnvInt("FOCUS_HEARTBEAT_GAP_SECONDS", 120)) * time.Second,
+		MaxGaps:        getEnvInt("FOCUS_MAX_GAPS", 2),
+	}
+}
+
+// FocusSession is a Pomodoro-style timer. Only the server clock counts:
+// FocusedSeconds grows by the time between heartbeats while the session runs.
+type FocusSession struct {
+	ID              int         `json:"id"`
+	UserID          int         `json:"user_id"`
+	QuestID         *int        `json:"quest_id,omitempty"`
+	PlannedMinutes  int         `json:"planned_minutes"`
+	Status          FocusStatus `json:"status"`
+	StartedAt       time.Time   `json:"started_at"`
+	LastHeartbeatAt time.Time   `json:"last_heartbeat_at"`
+	PausedAt        *time.Time  `json:"paused_at,omitempty"`
+	PausedSeconds   int         `json:"paused_seconds"`
+	FocusedSeconds  int         `json:"focused_seconds"`
+	Gaps            int         `json:"gaps"`
+	VerifiedMinutes int         `json:"verified_minutes"`
+	InvalidReason   string      `json:"invalid_reason,omitempty"`
+	EndedAt         *time.Time  `json:"ended_at,omitempty"`
+}
+
+// Closed reports whether the session has completed or been invalidated
+func (f *FocusSession) Closed() bool {
+	return f.Status == FocusStatusCompleted || f.Status == FocusStatusInvalidated
+}
+
+// Full reports whether the session verified all of its planned minutes
+func (f *FocusSession) Full() bool {
+	return f.Status == FocusStatusCompleted && f.VerifiedMinutes >= f.PlannedMinutes
+}
+
+// FocusService methods
+
+// Start begins a focus session of the given length, or the default length
+// when minutes is zero. A session left active is invalidated. A quest, when
+// given, must be one of the user's active focus quests.
+func (s *FocusService) Start(ctx context.Context, userID, minutes int, questID *int) (*FocusSession, error) {
+	if minutes == 0 {
+		minutes = s.config.DefaultMinutes
+	}
+	if minutes < s.config.MinMinutes || minutes > s.config.MaxMinutes {
+		return nil, fmt.Errorf("%w: planned_minutes must be between %d and %d", ErrInvalidFocusSession, s.config.MinMinutes, s.config.MaxMinutes)
+	}
+	if questID != nil {
+		quest, err := s.quests.Get(ctx, *questID)
+		if errors.Is(err, ErrNotFound) || (err == nil && quest.UserID != userID) {
+			return nil, fmt.Errorf("%w: quest %d not found", ErrInvalidFocusSession, *questID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if quest.Type != focusQuestType || quest.Status != QuestStatusActive {
+			return nil, fmt.Errorf("%w: quest %d is not an active focus quest", ErrInvalidFocusSession, *questID)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	active, err := s.sessions.GetActive(ctx, userID)
+	switch {
+	case err == nil:
+		s.invalidate(active, now, "replaced by a new focus session")
+		if err := s.sessions.Update(ctx, active); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	session := &FocusSession{
+		UserID:          userID,
+		QuestID:         questID,
+		PlannedMinutes:  minutes,
+		Status:          FocusStatusRunning,
+		StartedAt:       now,
+		LastHeartbeatAt: now,
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Get returns one of the user's focus sessions. Other users' sessions are ErrNotFound.
+func (s *FocusService) Get(ctx context.Context, userID, sessionID int) (*FocusSession, error) {
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+// Heartbeat credits the time since the last heartbeat of a running session.
+// A session that reaches its planned minutes completes.
+func (s *FocusService) Heartbeat(ctx context.Context, userID, sessionID int) (*FocusSession, error) {
+	return s.update(ctx, userID, sessionID, func(session *FocusSession, now time.Time) error {
+		if session.Status == FocusStatusRunning && session.FocusedSeconds >= session.PlannedMinutes*60 {
+			s.complete(session, now)
+		}
+		return nil
+	})
+}
+
+// Pause stops the clock on a running session
+func (s *FocusService) Pause(ctx context.Context, userID, sessionID int) (*FocusSession, error) {
+	return s.update(ctx, userID, sessionID, func(session *FocusSession, now time.Time) error {
+		if session.Status != FocusStatusRunning {
+			return fmt.Errorf("%w: session is %s", ErrInvalidFocusTransition, session.Status)
+		}
+		session.Status = FocusStatusPaused
+		session.PausedAt = &now
+		return nil
+	})
+}
+
+// Resume restarts the clock on a paused session. Paused time is not focused time.
+func (s *FocusService) Resume(ctx context.Context, userID, sessionID int) (*FocusSession, error) {
+	return s.update(ctx, userID, sessionID, func(session *FocusSession, now time.Time) error {
+		if session.Status != FocusStatusPaused {
+			return fmt.Errorf("%w: session is %s", ErrInvalidFocusTransition, session.Status)
+		}
+		session.PausedSeconds += int(now.Sub(*session.PausedAt).Seconds())
+		session.Status = FocusStatusRunning
+		session.PausedAt = nil
+		session.LastHeartbeatAt = now
+		return nil
+	})
+}
+
+// Complete ends a session early or on time with the minutes verified so far
+func (s *FocusService) Complete(ctx context.Context, userID, sessionID int) (*FocusSession, error) {
+	return s.update(ctx, userID, sessionID, func(session *FocusSession, now time.Time) error {
+		s.complete(session, now)
+		return nil
+	})
+}
+
+// update loads an open session, credits its time since the last heartbeat,
+// applies change and persists the result. A session that completes is credited
+// once the lock is released. A session invalidated by its gaps is saved and
+// returned without applying change.
+func (s *FocusService) update(ctx context.Context, userID, sessionID int, change func(session *FocusSession, now time.Time) error) (*FocusSession, error) {
+	s.mu.Lock()
+	session, err := s.Get(ctx, userID, sessionID)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if session.Closed() {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: session is %s", ErrFocusSessionClosed, session.Status)
+	}
+
+	now := s.now()
+	s.accrue(session, now)
+	if session.Status != FocusStatusInvalidated {
+		if err := change(session, now); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	err = s.sessions.Update(ctx, session)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Status == FocusStatusCompleted {
+		s.credit(ctx, session)
+	}
+	return session, nil
+}
+
+// accrue adds the time since the last heartbeat of a running session to its
+// focused time, up to the planned minutes. A silence longer than HeartbeatGap
+// is not credited and counts as a gap; more than MaxGaps invalidate the session.
+func (s *FocusService) accrue(session *FocusSession, now time.Time) {
+	if session.Status != FocusStatusRunning {
+		return
+	}
+	elapsed := now.Sub(session.LastHeartbeatAt)
+	session.LastHeartbeatAt = now
+	if elapsed > s.config.HeartbeatGap {
+		session.Gaps++
+		if session.Gaps > s.config.MaxGaps {
+			s.invalidate(session, now, fmt.Sprintf("%d heartbeat gaps longer than %s", session.Gaps, s.config.HeartbeatGap))
+		}
+		return
+	}
+	if elapsed > 0 {
+		session.FocusedSeconds += int(elapsed.Seconds())
+	}
+	if planned := session.PlannedMinutes * 60; session.FocusedSeconds > planned {
+		session.FocusedSeconds = planned
+	}
+}
+
+// complete closes a session with the whole minutes it verified
+func (s *FocusService) complete(session *FocusSession, now time.Time) {
+	session.Status = FocusStatusCompleted
+	session.VerifiedMinutes = session.FocusedSeconds / 60
+	session.PausedAt = nil
+	session.EndedAt = &now
+}
+
+// invalidate closes a session without crediting it
+func (s *FocusService) invalidate(session *FocusSession, now time.Time, reason string) {
+	session.Status = FocusStatusInvalidated
+	session.InvalidReason = reason
+	session.PausedAt = nil
+	session.EndedAt = &now
+}
+
+// credit adds a completed session's verified minutes to the progress of its
+// quest, or of every active focus quest when it has none. Sessions that
+// verified all their planned minutes count towards the focus_sessions stat.
+func (s *FocusService) credit(ctx context.Context, session *FocusSession) {
+	if session.VerifiedMinutes > 0 {
+		quests, err := s.focusQuests(ctx, session)
+		if err != nil {
+			log.Printf("list focus quests for user %d: %v", session.UserID, err)
+		}
+		for _, quest := range quests {
+			if _, err := s.quests.AddProgress(ctx, quest.ID, session.VerifiedMinutes); err != nil {
+				log.Printf("credit focus session %d to quest %d: %v", session.ID, quest.ID, err)
+			}
+		}
+	}
+
+	if session.Full() && s.badges != nil {
+		if err := s.badges.RecordActivity(ctx, session.UserID, StatFocusSessions, 1); err != nil {
+			log.Printf("record %s for user %d: %v", StatFocusSessions, session.UserID, err)
+		}
+	}
+}
+
+// focusQuests returns the active focus quests a completed session credits
+func (s *FocusService) focusQuests(ctx context.Context, session *FocusSession) ([]Quest, error) {
+	if session.QuestID != nil {
+		quest, err := s.quests.Get(ctx, *session.QuestID)
+		if err != nil {
+			return nil, err
+		}
+		if quest.Status != QuestStatusActive {
+			return nil, nil
+		}
+		return []Quest{*quest}, nil
+	}
+
+	quests, err := s.questRepo.ListByUser(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var focus []Quest
+	for _, quest := range quests {
+		if quest.Type == focusQuestType && quest.Status == QuestStatusActive {
+			focus = append(focus, quest)
+		}
+	}
+	return focus, nil
+}
+
+// Focus session handlers
+
+// writeFocusError maps focus session errors to HTTP responses
+func writeFocusError(w http.ResponseWriter, sessionID int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Focus session not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidFocusSession):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidFocusTransition), errors.Is(err, ErrFocusSessionClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("focus session %d: %v", sessionID, err)
+		http.Error(w, "Failed to update focus session", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) startFocusSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		PlannedMinutes int  `json:"planned_minutes"`
+		QuestID        *int `json:"quest_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.focus.Start(r.Context(), userID, req.PlannedMinutes, req.QuestID)
+	if err != nil {
+		writeFocusError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// focusSessionHandler handles the focus session routes, whose {id} is the
+// user and {sessionId} the focus session
+func (s *Server) focusSessionHandler(action func(ctx context.Context, userID, sessionID int) (*FocusSession, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		sessionID, err := strconv.Atoi(vars["sessionId"])
+		if err != nil {
+			http.Error(w, "Invalid focus session ID", http.StatusBadRequest)
+			return
+		}
+
+		session, err := action(r.Context(), userID, sessionID)
+		if err != nil {
+			writeFocusError(w, sessionID, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFocusSessionCreditsQuestOnCompletion(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	server, repos := newClockedServer(t, &now)
+	service := server.focus
+	ctx := context.Background()
+
+	session, err := service.Start(ctx, 1, 10, intPtr(2))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if seeded, _ := repos.FocusSessions.GetByID(ctx, 1); seeded.Status != FocusStatusInvalidated {
+		t.Fatalf("seeded session = %+v, want it invalidated by the new session", seeded)
+	}
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		if session, err = service.Heartbeat(ctx, 1, session.ID); err != nil {
+			t.Fatalf("Heartbeat() error = %v", err)
+		}
+	}
+	if !session.Full() || session.VerifiedMinutes != 10 || session.EndedAt == nil {
+		t.Fatalf("session = %+v, want it completed with 10 verified minutes", session)
+	}
+
+	quest, _ := repos.Quests.GetByID(ctx, 2)
+	if quest.Progress != quest.Total {
+		t.Fatalf("focus quest progress = %d/%d, want it full", quest.Progress, quest.Total)
+	}
+	if totals, _ := repos.Activity.Totals(ctx, 1, time.Time{}); totals[StatFocusSessions] != 1 {
+		t.Fatalf("%s = %d, want 1", StatFocusSessions, totals[StatFocusSessions])
+	}
+
+	if _, err := service.Heartbeat(ctx, 1, session.ID); !errors.Is(err, ErrFocusSessionClosed) {
+		t.Fatalf("heartbeat after completion: error = %v, want ErrFocusSessionClosed", err)
+	}
+}
+
+func TestFocusSessionPauseExcludesPausedTime(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	server, repos := newClockedServer(t, &now)
+	service := server.focus
+	ctx := context.Background()
+
+	session, err := service.Start(ctx, 1, 0, nil)
+	if err != nil || session.PlannedMinutes != 25 {
+		t.Fatalf("Start() = %+v, %v; want the default 25 minutes", session, err)
+	}
+	step := func(d time.Duration, action func(ctx context.Context, userID, sessionID int) (*FocusSession, error)) {
+		t.Helper()
+		now = now.Add(d)
+		if session, err = action(ctx, 1, session.ID); err != nil {
+			t.Fatalf("action at %s: %v", now.Format(time.Kitchen), err)
+		}
+	}
+	step(time.Minute, service.Heartbeat)
+	step(time.Minute, service.Pause)
+	if _, err := service.Pause(ctx, 1, session.ID); !errors.Is(err, ErrInvalidFocusTransition) {
+		t.Fatalf("pausing twice: error = %v, want ErrInvalidFocusTransition", err)
+	}
+	step(30*time.Minute, service.Resume)
+	step(time.Minute, service.Heartbeat)
+	step(30*time.Second, service.Complete)
+
+	if session.Status != FocusStatusCompleted || session.PausedSeconds != 1800 || session.FocusedSeconds != 210 ||
+		session.VerifiedMinutes != 3 || session.Gaps != 0 || session.Full() {
+		t.Fatalf("session = %+v, want 3 verified minutes without the pause", session)
+	}
+
+	// Without a quest the minutes go to every active focus quest
+	quest, _ := repos.Quests.GetByID(ctx, 2)
+	if quest.Progress != 10 {
+		t.Fatalf("focus quest progress = %d, want 7+3", quest.Progress)
+	}
+	if totals, _ := repos.Activity.Totals(ctx, 1, time.Time{}); totals[StatFocusSessions] != 0 {
+		t.Fatalf("%s = %d for a short session, want 0", StatFocusSessions, totals[StatFocusSessions])
+	}
+}
+
+func TestFocusSessionGapsInvalidate(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	server, repos := newClockedServer(t, &now)
+	service := server.focus
+	ctx := context.Background()
+
+	session, err := service.Start(ctx, 1, 25, intPtr(2))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for i, gap := range []time.Duration{time.Minute, 5 * time.Minute, time.Minute, 3 * time.Minute, 10 * time.Minute} {
+		now = now.Add(gap)
+		if session, err = service.Heartbeat(ctx, 1, session.ID); err != nil {
+			t.Fatalf("Heartbeat(%d) error = %v", i, err)
+		}
+	}
+	if session.Status != FocusStatusInvalidated || session.Gaps != 3 || session.FocusedSeconds != 120 || session.InvalidReason == "" {
+		t.Fatalf("session = %+v, want it invalidated by its third gap", session)
+	}
+	if _, err := service.Complete(ctx, 1, session.ID); !errors.Is(err, ErrFocusSessionClosed) {
+		t.Fatalf("completing an invalidated session: error = %v, want ErrFocusSessionClosed", err)
+	}
+	if quest, _ := repos.Quests.GetByID(ctx, 2); quest.Progress != 7 {
+		t.Fatalf("focus quest progress = %d, want it untouched", quest.Progress)
+	}
+}
+
+func TestFocusSessionStartValidation(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	server, _ := newClockedServer(t, &now)
+	service := server.focus
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		userID  int
+		minutes int
+		questID *int
+	}{
+		{"too short", 1, 4, nil},
+		{"too long", 1, 121, nil},
+		{"not a focus quest", 1, 25, intPtr(1)},
+		{"another user's quest", 2, 25, intPtr(2)},
+		{"missing quest", 1, 25, intPtr(99)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Start(ctx, tt.userID, tt.minutes, tt.questID); !errors.Is(err, ErrInvalidFocusSession) {
+				t.Fatalf("Start() error = %v, want ErrInvalidFocusSession", err)
+			}
+		})
+	}
+	if _, err := service.Get(ctx, 2, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of another user's session: error = %v, want ErrNotFound", err)
+	}
+}
@@ -6,3 +6,13 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/rs/cors v1.11.1
 )
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.31.0
+)
+
+require learning-buddy-ai v0.0.0
+
+replace learning-buddy-ai => ../ai-core
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	ai "learning-buddy-ai"
+)
+
+// newClockedServer returns a server on fresh memory repositories whose services
+// read the time from now, which the test moves by hand. Focus limits are pinned
+// so the environment cannot change them.
+func newClockedServer(t *testing.T, now *time.Time) (*Server, *Repositories) {
+	t.Helper()
+	repos := NewMemoryRepositories()
+	server := NewServer(repos, nil, NewAIService(ai.NewAICore("")))
+
+	clock := func() time.Time { return *now }
+	server.quests.now = clock
+	server.badges.now = clock
+	server.streaks.now = clock
+	server.conversations.now = clock
+	server.learningPaths.now = clock
+	server.projects.now = clock
+	server.sessions.now = clock
+	server.focus.now = clock
+	server.focus.config = FocusConfig{DefaultMinutes: 25, MinMinutes: 5, MaxMinutes: 120, HeartbeatGap: 2 * time.Minute, MaxGaps: 2}
+	return server, repos
+}
+
+// decodeJSON decodes a response body into a T, failing the test when it does not parse
+func decodeJSON[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("decode %T: %v", value, err)
+	}
+	return value
+}
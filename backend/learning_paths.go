@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// ErrInvalidLearningPath is returned when a learning path is missing required fields
+	ErrInvalidLearningPath = errors.New("invalid learning path")
+	// ErrUnknownPrerequisite is returned when a prerequisite names no learning path
+	ErrUnknownPrerequisite = errors.New("unknown prerequisite")
+	// ErrPrerequisiteCycle is returned when prerequisites would no longer form a DAG
+	ErrPrerequisiteCycle = errors.New("learning path prerequisites form a cycle")
+	// ErrPrerequisitesIncomplete is returned when enrolling before finishing the prerequisites
+	ErrPrerequisitesIncomplete = errors.New("prerequisites not completed")
+	// ErrLearningPathInactive is returned when enrolling in a retired path
+	ErrLearningPathInactive = errors.New("learning path is not active")
+	// ErrLearningPathCompleted is returned when changing progress on a completed path
+	ErrLearningPathCompleted = errors.New("learning path already completed")
+	// ErrLastStep is returned when advancing past the last step; complete the path instead
+	ErrLastStep = errors.New("already on the last step")
+	// ErrStepsRemaining is returned when completing a path before reaching its last step
+	ErrStepsRemaining = errors.New("learning path has steps remaining")
+)
+
+// LearningPath is an ordered series of steps. Prerequisites name other paths by title.
+type LearningPath struct {
+	ID             int       `json:"id"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Difficulty     int       `json:"difficulty_level"`
+	EstimatedHours int       `json:"estimated_hours"`
+	Prerequisites  []string  `json:"prerequisites"`
+	Tags           []string  `json:"tags"`
+	Steps          []string  `json:"steps"`
+	Active         bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LearningPathProgress is a user's enrollment in a learning path. CurrentStep
+// is the 1-based step the user is working on.
+type LearningPathProgress struct {
+	ID                 int        `json:"id"`
+	UserID             int        `json:"user_id"`
+	PathID             int        `json:"learning_path_id"`
+	ProgressPercentage int        `json:"progress_percentage"`
+	CurrentStep        int        `json:"current_step"`
+	StartedAt          time.Time  `json:"started_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+}
+
+// PathRecommendation is the learning path a user should take next and why
+type PathRecommendation struct {
+	Path        LearningPath `json:"path"`
+	MatchedTags []string     `json:"matched_tags"`
+	Reason      string       `json:"reason"`
+}
+
+// validate checks the fields a learning path needs
+func (p *LearningPath) validate() error {
+	switch {
+	case strings.TrimSpace(p.Title) == "":
+		return fmt.Errorf("%w: title is required", ErrInvalidLearningPath)
+	case p.Difficulty < 1 || p.Difficulty > 5:
+		return fmt.Errorf("%w: difficulty_level must be between 1 and 5", ErrInvalidLearningPath)
+	case p.EstimatedHours < 0:
+		return fmt.Errorf("%w: estimated_hours cannot be negative", ErrInvalidLearningPath)
+	case len(p.Steps) == 0:
+		return fmt.Errorf("%w: at least one step is required", ErrInvalidLearningPath)
+	}
+	return nil
+}
+
+// checkPrerequisites verifies that every prerequisite names a path and that
+// together they form a DAG. A cycle is reported as "A -> B -> A".
+func checkPrerequisites(paths []LearningPath) error {
+	byTitle := make(map[string]*LearningPath, len(paths))
+	titles := make([]string, 0, len(paths))
+	for i := range paths {
+		byTitle[paths[i].Title] = &paths[i]
+		titles = append(titles, paths[i].Title)
+	}
+	sort.Strings(titles)
+	for _, title := range titles {
+		for _, prerequisite := range byTitle[title].Prerequisites {
+			if _, ok := byTitle[prerequisite]; !ok {
+				return fmt.Errorf("%w: %q needs %q", ErrUnknownPrerequisite, title, prerequisite)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(paths))
+	var stack []string
+	var visit func(title string) error
+	visit = func(title string) error {
+		switch state[title] {
+		case done:
+			return nil
+		case visiting:
+			for i := range stack {
+				if stack[i] == title {
+					cycle := append(append([]string{}, stack[i:]...), title)
+					return fmt.Errorf("%w: %s", ErrPrerequisiteCycle, strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		state[title] = visiting
+		stack = append(stack, title)
+		for _, prerequisite := range byTitle[title].Prerequisites {
+			if err := visit(prerequisite); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[title] = done
+		return nil
+	}
+	for _, title := range titles {
+		if err := visit(title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LearningPathService methods
+
+// List returns the active learning paths
+func (l *LearningPathService) List(ctx context.Context) ([]LearningPath, error) {
+	paths, err := l.paths.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := []LearningPath{}
+	for _, path := range paths {
+		if path.Active {
+			active = append(active, path)
+		}
+	}
+	return active, nil
+}
+
+// Get returns a learning path
+func (l *LearningPathService) Get(ctx context.Context, pathID int) (*LearningPath, error) {
+	return l.paths.GetByID(ctx, pathID)
+}
+
+// Create adds a learning path. Its prerequisites must name existing paths
+// without closing a cycle.
+func (l *LearningPathService) Create(ctx context.Context, path *LearningPath) error {
+	if err := path.validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paths, err := l.paths.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range paths {
+		if existing.Title == path.Title {
+			return fmt.Errorf("%w: %q", ErrDuplicate, path.Title)
+		}
+	}
+	if err := checkPrerequisites(append(paths, *path)); err != nil {
+		return err
+	}
+	path.CreatedAt = l.now()
+	return l.paths.Create(ctx, path)
+}
+
+// Update replaces a learning path, rechecking the prerequisite graph
+func (l *LearningPathService) Update(ctx context.Context, path *LearningPath) error {
+	if err := path.validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paths, err := l.paths.List(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range paths {
+		switch {
+		case paths[i].ID == path.ID:
+			path.CreatedAt = paths[i].CreatedAt
+			paths[i] = *path
+			found = true
+		case paths[i].Title == path.Title:
+			return fmt.Errorf("%w: %q", ErrDuplicate, path.Title)
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if err := checkPrerequisites(paths); err != nil {
+		return err
+	}
+	return l.paths.Update(ctx, path)
+}
+
+// Progress returns a user's enrollments
+func (l *LearningPathService) Progress(ctx context.Context, userID int) ([]LearningPathProgress, error) {
+	return l.paths.ListProgress(ctx, userID)
+}
+
+// Enroll starts a user on a path once they have completed its prerequisites
+func (l *LearningPathService) Enroll(ctx context.Context, userID, pathID int) (*LearningPathProgress, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, err := l.paths.GetByID(ctx, pathID)
+	if err != nil {
+		return nil, err
+	}
+	if !path.Active {
+		return nil, ErrLearningPathInactive
+	}
+	completed, err := l.completedTitles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, prerequisite := range path.Prerequisites {
+		if !completed[prerequisite] {
+			missing = append(missing, prerequisite)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: finish %s first", ErrPrerequisitesIncomplete, strings.Join(missing, ", "))
+	}
+
+	progress := &LearningPathProgress{UserID: userID, PathID: pathID, CurrentStep: 1, StartedAt: l.now()}
+	if err := l.paths.CreateProgress(ctx, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// Advance finishes the user's current step and moves to the next one
+func (l *LearningPathService) Advance(ctx context.Context, userID, pathID int) (*LearningPathProgress, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, progress, err := l.loadProgress(ctx, userID, pathID)
+	if err != nil {
+		return nil, err
+	}
+	if progress.CurrentStep >= len(path.Steps) {
+		return nil, fmt.Errorf("%w: complete the path instead", ErrLastStep)
+	}
+	progress.CurrentStep++
+	progress.ProgressPercentage = (progress.CurrentStep - 1) * 100 / len(path.Steps)
+	if err := l.paths.UpdateProgress(ctx, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// Complete finishes the last step and with it the path
+func (l *LearningPathService) Complete(ctx context.Context, userID, pathID int) (*LearningPathProgress, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, progress, err := l.loadProgress(ctx, userID, pathID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining := len(path.Steps) - progress.CurrentStep; remaining > 0 {
+		return nil, fmt.Errorf("%w: %d steps to go", ErrStepsRemaining, remaining)
+	}
+	now := l.now()
+	progress.CompletedAt = &now
+	progress.ProgressPercentage = 100
+	if err := l.paths.UpdateProgress(ctx, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// Recommend picks the next path for a user: an active path they have not
+// started whose prerequisites they have completed. Paths sharing the most tags
+// with their completed paths come first, then those building on more
+// prerequisites, then easier ones. It returns ErrNotFound when none is left.
+func (l *LearningPathService) Recommend(ctx context.Context, userID int) (*PathRecommendation, error) {
+	paths, err := l.paths.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := l.paths.ListProgress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	started := map[int]bool{}
+	completed := map[int]bool{}
+	for _, progress := range enrollments {
+		started[progress.PathID] = true
+		completed[progress.PathID] = progress.CompletedAt != nil
+	}
+	completedTitles := map[string]bool{}
+	completedTags := map[string]bool{}
+	for _, path := range paths {
+		if completed[path.ID] {
+			completedTitles[path.Title] = true
+			for _, tag := range path.Tags {
+				completedTags[tag] = true
+			}
+		}
+	}
+
+	var best *PathRecommendation
+	for _, path := range paths {
+		if !path.Active || started[path.ID] || !allIn(path.Prerequisites, completedTitles) {
+			continue
+		}
+		candidate := &PathRecommendation{Path: path, MatchedTags: []string{}}
+		for _, tag := range path.Tags {
+			if completedTags[tag] {
+				candidate.MatchedTags = append(candidate.MatchedTags, tag)
+			}
+		}
+		if best == nil || betterRecommendation(candidate, best) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+
+	var reasons []string
+	if len(best.Path.Prerequisites) > 0 {
+		reasons = append(reasons, "builds on "+strings.Join(best.Path.Prerequisites, ", "))
+	}
+	if len(best.MatchedTags) > 0 {
+		reasons = append(reasons, "shares "+strings.Join(best.MatchedTags, ", ")+" with paths you completed")
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, fmt.Sprintf("a good place to start at difficulty %d", best.Path.Difficulty))
+	}
+	best.Reason = strings.Join(reasons, "; ")
+	return best, nil
+}
+
+// betterRecommendation orders candidates by matched tags, prerequisites,
+// difficulty and finally ID
+func betterRecommendation(a, b *PathRecommendation) bool {
+	if len(a.MatchedTags) != len(b.MatchedTags) {
+		return len(a.MatchedTags) > len(b.MatchedTags)
+	}
+	if len(a.Path.Prerequisites) != len(b.Path.Prerequisites) {
+		return len(a.Path.Prerequisites) > len(b.Path.Prerequisites)
+	}
+	if a.Path.Difficulty != b.Path.Difficulty {
+		return a.Path.Difficulty < b.Path.Difficulty
+	}
+	return a.Path.ID < b.Path.ID
+}
+
+// allIn reports whether every value is in the set
+func allIn(values []string, set map[string]bool) bool {
+	for _, value := range values {
+		if !set[value] {
+			return false
+		}
+	}
+	return true
+}
+
+// completedTitles returns the titles of the paths a user has completed
+func (l *LearningPathService) completedTitles(ctx context.Context, userID int) (map[string]bool, error) {
+	enrollments, err := l.paths.ListProgress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	titles := map[string]bool{}
+	for _, progress := range enrollments {
+		if progress.CompletedAt == nil {
+			continue
+		}
+		path, err := l.paths.GetByID(ctx, progress.PathID)
+		if err != nil {
+			return nil, err
+		}
+		titles[path.Title] = true
+	}
+	return titles, nil
+}
+
+// loadProgress fetches a path and the user's unfinished enrollment in it. Callers must hold l.mu.
+func (l *LearningPathService) loadProgress(ctx context.Context, userID, pathID int) (*LearningPath, *LearningPathProgress, error) {
+	path, err := l.paths.GetByID(ctx, pathID)
+	if err != nil {
+		return nil, nil, err
+	}
+	progress, err := l.paths.GetProgress(ctx, userID, pathID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if progress.CompletedAt != nil {
+		return nil, nil, ErrLearningPathCompleted
+	}
+	return path, progress, nil
+}
+
+// Learning path handlers
+
+// writeLearningPathError maps learning path errors to HTTP responses
+func writeLearningPathError(w http.ResponseWriter, pathID int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Learning path or enrollment not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidLearningPath), errors.Is(err, ErrUnknownPrerequisite), errors.Is(err, ErrPrerequisiteCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrDuplicate):
+		http.Error(w, "Learning path already exists or user already enrolled", http.StatusConflict)
+	case errors.Is(err, ErrPrerequisitesIncomplete), errors.Is(err, ErrLearningPathInactive), errors.Is(err, ErrLearningPathCompleted),
+		errors.Is(err, ErrLastStep), errors.Is(err, ErrStepsRemaining):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("learning path %d: %v", pathID, err)
+		http.Error(w, "Failed to update learning path", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) listLearningPathsHandler(w http.ResponseWriter, r *http.Request) {
+	paths, err := s.learningPaths.List(r.Context())
+	if err != nil {
+		writeLearningPathError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paths)
+}
+
+func (s *Server) getLearningPathHandler(w http.ResponseWriter, r *http.Request) {
+	pathID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid learning path ID", http.StatusBadRequest)
+		return
+	}
+
+	path, err := s.learningPaths.Get(r.Context(), pathID)
+	if err != nil {
+		writeLearningPathError(w, pathID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(path)
+}
+
+// decodeLearningPath reads a learning path from the request body. New paths are active.
+func decodeLearningPath(r *http.Request) (*LearningPath, error) {
+	path := &LearningPath{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(path); err != nil {
+		return nil, err
+	}
+	path.Title = strings.TrimSpace(path.Title)
+	if path.Prerequisites == nil {
+		path.Prerequisites = []string{}
+	}
+	if path.Tags == nil {
+		path.Tags = []string{}
+	}
+	return path, nil
+}
+
+func (s *Server) createLearningPathHandler(w http.ResponseWriter, r *http.Request) {
+	path, err := decodeLearningPath(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.learningPaths.Create(r.Context(), path); err != nil {
+		writeLearningPathError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(path)
+}
+
+func (s *Server) updateLearningPathHandler(w http.ResponseWriter, r *http.Request) {
+	pathID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid learning path ID", http.StatusBadRequest)
+		return
+	}
+	path, err := decodeLearningPath(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	path.ID = pathID
+
+	if err := s.learningPaths.Update(r.Context(), path); err != nil {
+		writeLearningPathError(w, pathID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(path)
+}
+
+func (s *Server) getLearningProgressHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := s.learningPaths.Progress(r.Context(), userID)
+	if err != nil {
+		log.Printf("list learning progress for user %d: %v", userID, err)
+		http.Error(w, "Failed to load learning progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+func (s *Server) recommendLearningPathHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	recommendation, err := s.learningPaths.Recommend(r.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "No learning path left to recommend", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("recommend learning path for user %d: %v", userID, err)
+		http.Error(w, "Failed to recommend a learning path", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recommendation)
+}
+
+// learningProgressHandler handles the enroll, advance and complete routes,
+// whose {id} is the user and {pathId} the learning path
+func (s *Server) learningProgressHandler(action func(ctx context.Context, userID, pathID int) (*LearningPathProgress, error), status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		pathID, err := strconv.Atoi(vars["pathId"])
+		if err != nil {
+			http.Error(w, "Invalid learning path ID", http.StatusBadRequest)
+			return
+		}
+
+		progress, err := action(r.Context(), userID, pathID)
+		if err != nil {
+			writeLearningPathError(w, pathID, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(progress)
+	}
+}
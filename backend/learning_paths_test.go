@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLearningPathService returns a learning path service on the seeded
+// paths with a fixed clock. User 1 has completed JavaScript Fundamentals and
+// is on step 2 of React Development.
+func newTestLearningPathService(t *testing.T) *LearningPathService {
+	t.Helper()
+	service := NewLearningPathService(NewMemoryRepositories().LearningPaths)
+	service.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return service
+}
+
+func TestCheckPrerequisites(t *testing.T) {
+	path := func(title string, prerequisites ...string) LearningPath {
+		return LearningPath{Title: title, Prerequisites: prerequisites}
+	}
+	tests := []struct {
+		name  string
+		paths []LearningPath
+		want  error
+		cycle string
+	}{
+		{"diamond is a DAG", []LearningPath{path("A"), path("B", "A"), path("C", "A"), path("D", "B", "C")}, nil, ""},
+		{"unknown prerequisite", []LearningPath{path("A", "Z")}, ErrUnknownPrerequisite, ""},
+		{"self loop", []LearningPath{path("A", "A")}, ErrPrerequisiteCycle, "A -> A"},
+		{"three-path cycle", []LearningPath{path("A", "C"), path("B", "A"), path("C", "B"), path("D")}, ErrPrerequisiteCycle, "A -> C -> B -> A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPrerequisites(tt.paths)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Fatalf("checkPrerequisites() = %v, want %v", err, tt.want)
+			}
+			if tt.cycle != "" && !strings.HasSuffix(err.Error(), tt.cycle) {
+				t.Fatalf("error = %q, want the cycle %q", err, tt.cycle)
+			}
+		})
+	}
+}
+
+func TestLearningPathCreateRejectsCycles(t *testing.T) {
+	service := newTestLearningPathService(t)
+	ctx := context.Background()
+
+	devops := &LearningPath{Title: "DevOps", Difficulty: 3, Prerequisites: []string{"Full-Stack Development"}, Steps: []string{"CI"}, Active: true}
+	if err := service.Create(ctx, devops); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	js, _ := service.Get(ctx, 1)
+	js.Prerequisites = []string{"DevOps"}
+	err := service.Update(ctx, js)
+	if !errors.Is(err, ErrPrerequisiteCycle) {
+		t.Fatalf("Update() error = %v, want ErrPrerequisiteCycle", err)
+	}
+	if stored, _ := service.Get(ctx, 1); len(stored.Prerequisites) != 0 {
+		t.Fatalf("rejected update was stored: %+v", stored)
+	}
+
+	dup := &LearningPath{Title: "DevOps", Difficulty: 1, Steps: []string{"CI"}}
+	if err := service.Create(ctx, dup); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("duplicate title: error = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestLearningPathProgressFlow(t *testing.T) {
+	service := newTestLearningPathService(t)
+	ctx := context.Background()
+
+	if _, err := service.Enroll(ctx, 1, 5); !errors.Is(err, ErrPrerequisitesIncomplete) || !strings.Contains(err.Error(), "React Development, Backend with Node.js, Database Design") {
+		t.Fatalf("Enroll(Full-Stack) error = %v, want the three missing prerequisites", err)
+	}
+
+	var progress *LearningPathProgress
+	var err error
+	for step := 3; step <= 4; step++ {
+		if progress, err = service.Advance(ctx, 1, 2); err != nil || progress.CurrentStep != step {
+			t.Fatalf("Advance() = %+v, %v; want step %d", progress, err, step)
+		}
+	}
+	if progress.ProgressPercentage != 75 {
+		t.Fatalf("progress = %d%%, want 75%% on the last of four steps", progress.ProgressPercentage)
+	}
+	if _, err := service.Advance(ctx, 1, 2); !errors.Is(err, ErrLastStep) {
+		t.Fatalf("Advance() past the last step: error = %v, want ErrLastStep", err)
+	}
+	if progress, err = service.Complete(ctx, 1, 2); err != nil || progress.CompletedAt == nil || progress.ProgressPercentage != 100 {
+		t.Fatalf("Complete() = %+v, %v; want a completed path", progress, err)
+	}
+	if _, err := service.Complete(ctx, 1, 2); !errors.Is(err, ErrLearningPathCompleted) {
+		t.Fatalf("Complete() twice: error = %v, want ErrLearningPathCompleted", err)
+	}
+}
+
+func TestLearningPathRecommend(t *testing.T) {
+	service := newTestLearningPathService(t)
+	ctx := context.Background()
+
+	// React is under way, so Node.js is the other path building on JavaScript
+	got, err := service.Recommend(ctx, 1)
+	if err != nil || got.Path.Title != "Backend with Node.js" || !reflect.DeepEqual(got.MatchedTags, []string{"javascript"}) {
+		t.Fatalf("Recommend() = %+v, %v; want Node.js via the javascript tag", got, err)
+	}
+	if !strings.Contains(got.Reason, "builds on JavaScript Fundamentals") {
+		t.Fatalf("reason = %q", got.Reason)
+	}
+
+	// A new learner starts with the easiest path that has no prerequisites
+	if got, err = service.Recommend(ctx, 2); err != nil || got.Path.Title != "JavaScript Fundamentals" {
+		t.Fatalf("Recommend() for a new learner = %+v, %v; want JavaScript Fundamentals", got, err)
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,25 +19,54 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Level    int    `json:"level"`
-	XP       int    `json:"xp"`
-	Streak   int    `json:"streak"`
-	Mood     string `json:"mood"`
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	Plan         string `json:"plan"`
+	Level        int    `json:"level"`
+	XP           int    `json:"xp"`
+	Streak       int    `json:"streak"`
+	MaxStreak    int    `json:"max_streak"`
+	Mood         string `json:"mood"`
+	Timezone     string `json:"timezone"`
+	// BuddyPersonality is the personality the user picked; PersonalityLocked
+	// keeps the buddy on it regardless of the detected emotion
+	BuddyPersonality  string `json:"buddy_personality"`
+	PersonalityLocked bool   `json:"personality_locked"`
+	// LearningStyle is visual, auditory, kinesthetic or balanced;
+	// PreferredDifficulty runs from 1 to 5
+	LearningStyle       string `json:"learning_style"`
+	PreferredDifficulty int    `json:"preferred_difficulty"`
 }
 
 // Quest represents a learning quest
 type Quest struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Progress    int    `json:"progress"`
-	Total       int    `json:"total"`
-	XP          int    `json:"xp"`
-	Type        string `json:"type"`
-	UserID      int    `json:"user_id"`
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Progress    int         `json:"progress"`
+	Total       int         `json:"total"`
+	XP          int         `json:"xp"`
+	Type        string      `json:"type"`
+	UserID      int         `json:"user_id"`
+	Difficulty  int         `json:"difficulty"`
+	Status      QuestStatus `json:"status"`
+	DueDate     *time.Time  `json:"due_date,omitempty"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+	Tasks       []QuestTask `json:"tasks,omitempty"`
+}
+
+// QuestTask represents a sub-task within a quest
+type QuestTask struct {
+	ID          int        `json:"id"`
+	QuestID     int        `json:"quest_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	OrderIndex  int        `json:"order_index"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 // Badge represents an achievement badge
@@ -47,39 +81,88 @@ type Badge struct {
 
 // BuddyMessage represents AI buddy conversation
 type BuddyMessage struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Message   string    `json:"message"`
-	Response  string    `json:"response"`
-	Mood      string    `json:"mood"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
-// Mock data for demonstration
-var mockUsers = []User{
-	{ID: 1, Username: "alex", Email: "alex@example.com", Level: 5, XP: 750, Streak: 7, Mood: "focused"},
+	ID        int                    `json:"id"`
+	UserID    int                    `json:"user_id"`
+	ThreadID  int                    `json:"thread_id,omitempty"`
+	Message   string                 `json:"message"`
+	Response  string                 `json:"response"`
+	Mood      string                 `json:"mood"`
+	Context   map[string]interface{} `json:"context,omitempty"` // how the reply was generated
+	Timestamp time.Time              `json:"timestamp"`
 }
 
-var mockQuests = []Quest{
-	{ID: 1, Title: "Fix 3 bugs", Description: "Debug and fix 3 code issues", Progress: 2, Total: 3, XP: 150, Type: "code", UserID: 1},
-	{ID: 2, Title: "10 min focus session", Description: "Complete a focused work session", Progress: 7, Total: 10, XP: 50, Type: "focus", UserID: 1},
-	{ID: 3, Title: "Learn new concept", Description: "Study and understand a new programming concept", Progress: 0, Total: 1, XP: 200, Type: "learn", UserID: 1},
+// BuddyChatResponse is a saved buddy message together with the AI analysis behind the reply
+type BuddyChatResponse struct {
+	BuddyMessage
+	Personality      string   `json:"personality"`
+	DetectedEmotion  string   `json:"detected_emotion"`
+	SuggestedActions []string `json:"suggested_actions"`
+	XPReward         int      `json:"xp_reward"`
+	// TotalXP is the user's XP after the reward was credited; it is omitted when nothing was awarded
+	TotalXP         int     `json:"total_xp,omitempty"`
+	ConfidenceScore float64 `json:"confidence_score"`
 }
 
-var mockBadges = []Badge{
-	{ID: 1, Name: "Code Warrior", Description: "Fixed 10 bugs", Icon: "⚔️", Earned: true, UserID: 1},
-	{ID: 2, Name: "Focus Master", Description: "Completed 50 focus sessions", Icon: "🎯", Earned: true, UserID: 1},
-	{ID: 3, Name: "Streak Champion", Description: "Maintained 30-day streak", Icon: "🔥", Earned: false, UserID: 1},
-	{ID: 4, Name: "Bug Hunter", Description: "Found and reported 5 bugs", Icon: "🐛", Earned: true, UserID: 1},
+// Server holds the dependencies shared by the HTTP handlers
+type Server struct {
+	repos         *Repositories
+	auth          *AuthService
+	policy        *Policy
+	events        *EventBus
+	quests        *QuestService
+	xp            *XPService
+	badges        *BadgeService
+	streaks       *StreakService
+	ai            *AIService
+	conversations *ConversationService
+	usage         *UsageService
+	learningPaths *LearningPathService
+	projects      *ProjectService
+	sessions      *SessionService
+	focus         *FocusService
+	generator     *QuestGenerator
 }
 
-var mockMessages = []BuddyMessage{
-	{ID: 1, UserID: 1, Message: "I'm feeling stuck on this problem", Response: "I understand! Let's break it down into smaller steps. What specific part is challenging you?", Mood: "mentor", Timestamp: time.Now()},
+// NewServer creates a server backed by the given repositories
+func NewServer(repos *Repositories, auth *AuthService, aiService *AIService) *Server {
+	events := NewEventBus()
+	xp := NewXPService(repos.XP, repos.Users, events)
+	badges := NewBadgeService(repos.Badges, repos.Activity, repos.Users, xp, events)
+	streaks := NewStreakService(repos.Streaks, xp, events, LoadStreakConfig())
+	events.Subscribe(xp.HandleQuestCompleted, EventQuestCompleted)
+	events.Subscribe(badges.HandleEvent, EventQuestCompleted, EventXPAwarded, EventStreakExtended)
+	events.Subscribe(xp.HandleProjectMilestoneCompleted, EventProjectMilestoneCompleted)
+	events.Subscribe(streaks.HandleEvent, EventQuestTaskCompleted, EventQuestCompleted, EventProjectMilestoneCompleted)
+	usage := NewUsageService(repos.Usage, repos.Users, LoadUsageConfig())
+	aiService.usage = usage
+	aiService.moods = repos.Moods
+	aiService.personalities = repos.Personalities
+
+	quests := NewQuestService(repos.Quests, events)
+
+	return &Server{
+		repos:         repos,
+		auth:          auth,
+		policy:        NewPolicy(repos.Quests, repos.Conversations, repos.Sessions),
+		events:        events,
+		quests:        quests,
+		xp:            xp,
+		badges:        badges,
+		streaks:       streaks,
+		ai:            aiService,
+		conversations: NewConversationService(repos.Conversations, aiService, LoadConversationConfig()),
+		usage:         usage,
+		learningPaths: NewLearningPathService(repos.LearningPaths),
+		projects:      NewProjectService(repos.Projects, repos.Users, aiService, events),
+		sessions:      NewSessionService(repos.Sessions, repos.Users, repos.Quests, badges),
+		focus:         NewFocusService(repos.FocusSessions, quests, repos.Quests, badges, LoadFocusConfig()),
+		generator:     NewQuestGenerator(repos.Quests, repos.Users, repos.Sessions, aiService),
+	}
 }
 
 // API Handlers
 
-func getUserHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -87,18 +170,22 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, user := range mockUsers {
-		if user.ID == userID {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(user)
-			return
-		}
+	user, err := s.repos.Users.GetByID(r.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("get user %d: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
 	}
 
-	http.Error(w, "User not found", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
 }
 
-func getUserQuestsHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getUserQuestsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -106,18 +193,18 @@ func getUserQuestsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userQuests []Quest
-	for _, quest := range mockQuests {
-		if quest.UserID == userID {
-			userQuests = append(userQuests, quest)
-		}
+	userQuests, err := s.repos.Quests.ListByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("list quests for user %d: %v", userID, err)
+		http.Error(w, "Failed to load quests", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userQuests)
 }
 
-func getUserBadgesHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getUserBadgesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -125,18 +212,18 @@ func getUserBadgesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userBadges []Badge
-	for _, badge := range mockBadges {
-		if badge.UserID == userID {
-			userBadges = append(userBadges, badge)
-		}
+	userBadges, err := s.repos.Badges.ListByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("list badges for user %d: %v", userID, err)
+		http.Error(w, "Failed to load badges", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userBadges)
 }
 
-func updateQuestProgressHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) updateQuestProgressHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	questID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -153,80 +240,33 @@ func updateQuestProgressHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for i, quest := range mockQuests {
-		if quest.ID == questID {
-			mockQuests[i].Progress = updateData.Progress
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(mockQuests[i])
-			return
-		}
+	quest, err := s.quests.UpdateProgress(r.Context(), questID, updateData.Progress)
+	if err != nil {
+		writeQuestError(w, questID, err)
+		return
 	}
 
-	http.Error(w, "Quest not found", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quest)
 }
 
-func buddyChatHandler(w http.ResponseWriter, r *http.Request) {
-	var chatRequest struct {
-		UserID  int    `json:"user_id"`
-		Message string `json:"message"`
-		Mood    string `json:"mood"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&chatRequest); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *Server) getUserConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Simple mock AI response based on mood
-	responses := map[string][]string{
-		"mentor": {
-			"Let me help you understand this concept better. What specific part would you like me to explain?",
-			"Great question! This is a common challenge. Let's approach it step by step.",
-			"I can see you're working hard. Remember, learning is a process - take your time.",
-		},
-		"cheerleader": {
-			"You're doing amazing! Keep up the great work! 🎉",
-			"I believe in you! You've got this! 💪",
-			"Every step forward is progress. You're crushing it! ⭐",
-		},
-		"chill": {
-			"No worries, we'll figure this out together. Take a deep breath.",
-			"Hey, no pressure. Let's just explore this at your own pace.",
-			"All good! Sometimes the best solutions come when we're relaxed.",
-		},
-		"focused": {
-			"Let's dive deep into this problem. What data do we have?",
-			"Time to focus. What's the core issue we need to solve?",
-			"Good, you're in the zone. Let's tackle this systematically.",
-		},
-	}
-
-	mood := chatRequest.Mood
-	if mood == "" {
-		mood = "mentor"
-	}
-
-	responseList, exists := responses[mood]
-	if !exists {
-		responseList = responses["mentor"]
-	}
-
-	// Simple response selection (in real app, this would use OpenAI)
-	response := responseList[len(chatRequest.Message)%len(responseList)]
-
-	buddyResponse := BuddyMessage{
-		ID:        len(mockMessages) + 1,
-		UserID:    chatRequest.UserID,
-		Message:   chatRequest.Message,
-		Response:  response,
-		Mood:      mood,
-		Timestamp: time.Now(),
+	conversations, err := s.repos.Conversations.ListByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("list conversations for user %d: %v", userID, err)
+		http.Error(w, "Failed to load conversations", http.StatusInternalServerError)
+		return
 	}
 
-	mockMessages = append(mockMessages, buddyResponse)
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(buddyResponse)
+	json.NewEncoder(w).Encode(conversations)
 }
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -239,25 +279,127 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func main() {
+// Router registers every API route. Routes other than health and auth require
+// a bearer token, and routes on user-owned data go through the ownership policy.
+func (s *Server) Router() *mux.Router {
 	r := mux.NewRouter()
 
 	// API Routes
 	api := r.PathPrefix("/api/v1").Subrouter()
-	
+
 	// Health check
 	api.HandleFunc("/health", healthCheckHandler).Methods("GET")
-	
+
+	// Auth routes
+	api.HandleFunc("/auth/register", s.registerHandler).Methods("POST")
+	api.HandleFunc("/auth/login", s.loginHandler).Methods("POST")
+	api.HandleFunc("/auth/refresh", s.refreshHandler).Methods("POST")
+	api.HandleFunc("/auth/logout", s.logoutHandler).Methods("POST")
+	api.HandleFunc("/login", s.loginHandler).Methods("POST") // used by the frontend login form
+
+	// Authenticated routes
+	protected := api.NewRoute().Subrouter()
+	protected.Use(s.auth.RequireAuth)
+
 	// User routes
-	api.HandleFunc("/users/{id}", getUserHandler).Methods("GET")
-	api.HandleFunc("/users/{id}/quests", getUserQuestsHandler).Methods("GET")
-	api.HandleFunc("/users/{id}/badges", getUserBadgesHandler).Methods("GET")
-	
+	protected.HandleFunc("/users/{id}", s.requireUserAccess(s.getUserHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/quests", s.requireUserAccess(s.getUserQuestsHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/quests/generate", s.requireUserAccess(s.generateQuestHandler)).Methods("POST")
+	protected.HandleFunc("/users/{id}/badges", s.requireUserAccess(s.getUserBadgesHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/conversations", s.requireUserAccess(s.getUserConversationsHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/xp/history", s.requireUserAccess(s.getXPHistoryHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/streak", s.requireUserAccess(s.getStreakHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/buddy/personality", s.requireUserAccess(s.updateBuddyPersonalityHandler)).Methods("PUT")
+
 	// Quest routes
-	api.HandleFunc("/quests/{id}/progress", updateQuestProgressHandler).Methods("PUT")
-	
+	protected.HandleFunc("/quests/{id}", s.requireQuestAccess(s.getQuestHandler)).Methods("GET")
+	protected.HandleFunc("/quests/{id}/progress", s.requireQuestAccess(s.updateQuestProgressHandler)).Methods("PUT")
+	protected.HandleFunc("/quests/{id}/status", s.requireQuestAccess(s.updateQuestStatusHandler)).Methods("PUT")
+	protected.HandleFunc("/quests/{id}/tasks", s.requireQuestAccess(s.getQuestTasksHandler)).Methods("GET")
+	protected.HandleFunc("/quests/{id}/tasks/{taskId}/complete", s.requireQuestAccess(s.completeQuestTaskHandler)).Methods("POST")
+
 	// AI Buddy routes
-	api.HandleFunc("/buddy/chat", buddyChatHandler).Methods("POST")
+	protected.HandleFunc("/buddy/chat", s.buddyChatHandler).Methods("POST")
+	protected.HandleFunc("/buddy/chat/stream", s.buddyChatStreamHandler).Methods("GET", "POST")
+	protected.HandleFunc("/users/{id}/threads", s.requireUserAccess(s.getThreadsHandler)).Methods("GET")
+	protected.HandleFunc("/threads/{id}", s.requireThreadAccess(s.getThreadHandler)).Methods("GET")
+	protected.HandleFunc("/threads/{id}", s.requireThreadAccess(s.deleteThreadHandler)).Methods("DELETE")
+	protected.HandleFunc("/buddy/personalities", s.listPersonalitiesHandler).Methods("GET")
+	protected.HandleFunc("/buddy/personalities", s.createPersonalityHandler).Methods("POST")
+	protected.HandleFunc("/buddy/personalities/{key}", s.deletePersonalityHandler).Methods("DELETE")
+
+	// Learning path routes
+	protected.HandleFunc("/learning-paths", s.listLearningPathsHandler).Methods("GET")
+	protected.HandleFunc("/learning-paths", s.requireAdmin(s.createLearningPathHandler)).Methods("POST")
+	protected.HandleFunc("/learning-paths/{id}", s.getLearningPathHandler).Methods("GET")
+	protected.HandleFunc("/learning-paths/{id}", s.requireAdmin(s.updateLearningPathHandler)).Methods("PUT")
+	protected.HandleFunc("/users/{id}/learning-paths", s.requireUserAccess(s.getLearningProgressHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/learning-paths/recommended", s.requireUserAccess(s.recommendLearningPathHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/learning-paths/{pathId}/enroll", s.requireUserAccess(s.learningProgressHandler(s.learningPaths.Enroll, http.StatusCreated))).Methods("POST")
+	protected.HandleFunc("/users/{id}/learning-paths/{pathId}/advance", s.requireUserAccess(s.learningProgressHandler(s.learningPaths.Advance, http.StatusOK))).Methods("POST")
+	protected.HandleFunc("/users/{id}/learning-paths/{pathId}/complete", s.requireUserAccess(s.learningProgressHandler(s.learningPaths.Complete, http.StatusOK))).Methods("POST")
+
+	// Learning sessions
+	protected.HandleFunc("/sessions/start", s.startSessionHandler).Methods("POST")
+	protected.HandleFunc("/sessions/{id}/events", s.requireSessionAccess(s.recordSessionEventHandler)).Methods("POST")
+	protected.HandleFunc("/sessions/{id}/end", s.requireSessionAccess(s.endSessionHandler)).Methods("POST")
+
+	// Focus sessions
+	protected.HandleFunc("/users/{id}/focus-sessions", s.requireUserAccess(s.startFocusSessionHandler)).Methods("POST")
+	protected.HandleFunc("/users/{id}/focus-sessions/{sessionId}", s.requireUserAccess(s.focusSessionHandler(s.focus.Get))).Methods("GET")
+	protected.HandleFunc("/users/{id}/focus-sessions/{sessionId}/heartbeat", s.requireUserAccess(s.focusSessionHandler(s.focus.Heartbeat))).Methods("POST")
+	protected.HandleFunc("/users/{id}/focus-sessions/{sessionId}/pause", s.requireUserAccess(s.focusSessionHandler(s.focus.Pause))).Methods("POST")
+	protected.HandleFunc("/users/{id}/focus-sessions/{sessionId}/resume", s.requireUserAccess(s.focusSessionHandler(s.focus.Resume))).Methods("POST")
+	protected.HandleFunc("/users/{id}/focus-sessions/{sessionId}/complete", s.requireUserAccess(s.focusSessionHandler(s.focus.Complete))).Methods("POST")
+
+	// DIY projects
+	protected.HandleFunc("/users/{id}/projects", s.requireUserAccess(s.getProjectsHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/projects", s.requireUserAccess(s.generateProjectHandler)).Methods("POST")
+	protected.HandleFunc("/users/{id}/projects/{projectId}", s.requireUserAccess(s.getProjectHandler)).Methods("GET")
+	protected.HandleFunc("/users/{id}/projects/{projectId}/status", s.requireUserAccess(s.updateProjectStatusHandler)).Methods("PUT")
+	protected.HandleFunc("/users/{id}/projects/{projectId}/milestones/{milestone}/complete", s.requireUserAccess(s.completeProjectMilestoneHandler)).Methods("POST")
+
+	// Admin routes
+	protected.HandleFunc("/admin/ai-usage", s.requireAdmin(s.getAIUsageHandler)).Methods("GET")
+
+	return r
+}
+
+func main() {
+	repos := NewMemoryRepositories()
+	if dbConfig, ok := LoadDatabaseConfig(); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		db, err := OpenDatabase(ctx, dbConfig)
+		cancel()
+		if err != nil {
+			log.Fatalf("database: %v", err)
+		}
+		defer db.Close()
+		repos = NewPostgresRepositories(db)
+		fmt.Printf("🗄️  Using PostgreSQL at %s:%s/%s\n", dbConfig.Host, dbConfig.Port, dbConfig.Name)
+	} else {
+		fmt.Println("🗄️  DB_HOST not set, using in-memory demo data")
+	}
+	auth := NewAuthService(repos.Users, repos.RefreshTokens, LoadJWTSecret())
+	promptConfigPath := getEnv("PROMPT_TEMPLATES_PATH", "")
+	provider, err := LoadLLMProvider()
+	if err != nil {
+		log.Fatalf("llm provider: %v", err)
+	}
+	core, err := LoadAICore(provider, promptConfigPath)
+	if err != nil {
+		log.Fatalf("ai core: %v", err)
+	}
+	core.MockStreamDelay = 40 * time.Millisecond
+	fmt.Printf("🤖 LLM provider: %s\n", core.Provider.Name())
+	if promptConfigPath != "" {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go WatchPromptConfig(context.Background(), core, promptConfigPath, reload)
+		fmt.Printf("🧠 Prompt templates from %s (reload with SIGHUP)\n", promptConfigPath)
+	}
+	s := NewServer(repos, auth, NewAIService(core))
+	go s.quests.RunOverdueSweeper(context.Background(), time.Minute)
 
 	// Setup CORS
 	c := cors.New(cors.Options{
@@ -266,16 +408,17 @@ func main() {
 		AllowedHeaders: []string{"*"},
 	})
 
-	handler := c.Handler(r)
+	handler := c.Handler(s.Router())
 
 	port := "8080"
 	fmt.Printf("🚀 Learning Buddy Backend starting on port %s\n", port)
 	fmt.Printf("📊 Health check: http://localhost:%s/api/v1/health\n", port)
+	fmt.Printf("🔐 Auth API: http://localhost:%s/api/v1/auth/login\n", port)
 	fmt.Printf("👤 User API: http://localhost:%s/api/v1/users/1\n", port)
 	fmt.Printf("🎯 Quests API: http://localhost:%s/api/v1/users/1/quests\n", port)
 	fmt.Printf("🏆 Badges API: http://localhost:%s/api/v1/users/1/badges\n", port)
 	fmt.Printf("🤖 Buddy Chat: http://localhost:%s/api/v1/buddy/chat\n", port)
+	fmt.Printf("📡 Buddy Chat stream: http://localhost:%s/api/v1/buddy/chat/stream\n", port)
 
 	log.Fatal(http.ListenAndServe("0.0.0.0:"+port, handler))
 }
-
@@ -0,0 +1,1161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewMemoryRepositories creates in-memory repositories seeded with demo data.
+// They are used when no database is configured and in tests.
+func NewMemoryRepositories() *Repositories {
+	users := &memoryUserRepository{users: []User{
+		{ID: 1, Username: "alex", Email: "alex@example.com", Role: RoleLearner, Plan: PlanFree, Level: 5, XP: 750, Streak: 7, MaxStreak: 7, Mood: "focused", Timezone: "America/New_York", BuddyPersonality: "mentor", LearningStyle: "balanced", PreferredDifficulty: 2},
+	}}
+	seededAt := time.Now()
+
+	return &Repositories{
+		Users: users,
+		Quests: &memoryQuestRepository{quests: []Quest{
+			{ID: 1, Title: "Fix 3 bugs", Description: "Debug and fix 3 code issues", Progress: 2, Total: 3, XP: 150, Type: "code", UserID: 1, Difficulty: 2, Status: QuestStatusActive, Tasks: []QuestTask{
+				{ID: 1, QuestID: 1, Title: "Identify first bug", Description: "Find the first bug in the codebase", OrderIndex: 1, Completed: true},
+				{ID: 2, QuestID: 1, Title: "Fix first bug", Description: "Implement a solution for the first bug", OrderIndex: 2, Completed: true},
+				{ID: 3, QuestID: 1, Title: "Identify second bug", Description: "Find the second bug in the codebase", OrderIndex: 3},
+			}},
+			{ID: 2, Title: "10 min focus session", Description: "Complete a focused work session", Progress: 7, Total: 10, XP: 50, Type: "focus", UserID: 1, Difficulty: 1, Status: QuestStatusActive, Tasks: []QuestTask{
+				{ID: 4, QuestID: 2, Title: "Set up workspace", Description: "Prepare your workspace for focused work", OrderIndex: 1, Completed: true},
+				{ID: 5, QuestID: 2, Title: "Start focus timer", Description: "Begin the 10-minute focus session", OrderIndex: 2, Completed: true},
+				{ID: 6, QuestID: 2, Title: "Complete focus work", Description: "Work without distractions for the full session", OrderIndex: 3},
+			}},
+			{ID: 3, Title: "Learn new concept", Description: "Study and understand a new programming concept", Progress: 0, Total: 1, XP: 200, Type: "learn", UserID: 1, Difficulty: 3, Status: QuestStatusActive},
+		}},
+		Badges: &memoryBadgeRepository{
+			definitions: []BadgeDefinition{
+				{ID: 1, Name: "Code Warrior", Description: "Fixed 10 bugs in your code", Icon: "⚔️", Category: "achievement", Requirements: json.RawMessage(`{"bugs_fixed": 10}`), XPReward: 100, Rarity: "common"},
+				{ID: 2, Name: "Focus Master", Description: "Completed 50 focus sessions", Icon: "🎯", Category: "achievement", Requirements: json.RawMessage(`{"focus_sessions": 50}`), XPReward: 200, Rarity: "rare"},
+				{ID: 3, Name: "Streak Champion", Description: "Maintained a 30-day learning streak", Icon: "🔥", Category: "streak", Requirements: json.RawMessage(`{"max_streak": 30}`), XPReward: 500, Rarity: "epic"},
+				{ID: 4, Name: "Bug Hunter", Description: "Found and reported 5 bugs", Icon: "🐛", Category: "achievement", Requirements: json.RawMessage(`{"bugs_reported": 5}`), XPReward: 75, Rarity: "common"},
+				{ID: 5, Name: "Level Achiever", Description: "Reached level 10", Icon: "⭐", Category: "achievement", Requirements: json.RawMessage(`{"level": 10}`), XPReward: 300, Rarity: "rare"},
+				{ID: 6, Name: "XP Collector", Description: "Earned 5000 total XP", Icon: "💎", Category: "achievement", Requirements: json.RawMessage(`{"total_xp": 5000}`), XPReward: 250, Rarity: "rare"},
+				{ID: 7, Name: "Quest Completer", Description: "Completed 25 quests", Icon: "🏆", Category: "achievement", Requirements: json.RawMessage(`{"quests_completed": 25}`), XPReward: 400, Rarity: "epic"},
+				{ID: 8, Name: "Learning Machine", Description: "Learned 15 new concepts", Icon: "🧠", Category: "skill", Requirements: json.RawMessage(`{"concepts_learned": 15}`), XPReward: 350, Rarity: "rare"},
+				{ID: 9, Name: "Early Bird", Description: "Started learning before 8 AM", Icon: "🌅", Category: "achievement", Requirements: json.RawMessage(`{"early_sessions": 10}`), XPReward: 50, Rarity: "common"},
+				{ID: 10, Name: "Night Owl", Description: "Learned after 10 PM", Icon: "🦉", Category: "achievement", Requirements: json.RawMessage(`{"night_sessions": 10}`), XPReward: 50, Rarity: "common"},
+				{ID: 11, Name: "Social Learner", Description: "Helped 5 other learners", Icon: "🤝", Category: "social", Requirements: json.RawMessage(`{"helped_users": 5}`), XPReward: 150, Rarity: "common"},
+				{ID: 12, Name: "Perfectionist", Description: "Completed 10 quests with 100% accuracy", Icon: "💯", Category: "achievement", Requirements: json.RawMessage(`{"perfect_quests": 10}`), XPReward: 300, Rarity: "epic"},
+			},
+			earned: map[int]map[int]time.Time{
+				1: {1: seededAt, 2: seededAt, 4: seededAt},
+			},
+		},
+		Conversations: &memoryConversationRepository{
+			messages: []BuddyMessage{
+				{ID: 1, UserID: 1, ThreadID: 1, Message: "I'm feeling stuck on this problem", Response: "I understand! Let's break it down into smaller steps. What specific part is challenging you?", Mood: "mentor", Timestamp: time.Now()},
+			},
+			threads: map[int]ConversationThread{
+				1: {ID: 1, UserID: 1, Title: "I'm feeling stuck on this problem", CreatedAt: seededAt, UpdatedAt: seededAt},
+			},
+			lastMessageID: 1,
+			lastThreadID:  1,
+		},
+		RefreshTokens: &memoryRefreshTokenRepository{tokens: make(map[string]RefreshToken)},
+		Activity:      &memoryActivityRepository{},
+		Streaks: &memoryStreakRepository{
+			users:        users,
+			lastActivity: map[int]time.Time{1: seededAt.Add(-24 * time.Hour)},
+			freezeTokens: map[int]int{1: 1},
+		},
+		Usage:         &memoryUsageRepository{},
+		Moods:         &memoryMoodLogRepository{},
+		Personalities: &memoryPersonalityRepository{users: users},
+		LearningPaths: &memoryLearningPathRepository{
+			paths: []LearningPath{
+				{ID: 1, Title: "JavaScript Fundamentals", Description: "Learn the basics of JavaScript programming", Difficulty: 1, EstimatedHours: 20, Prerequisites: []string{}, Tags: []string{"javascript", "programming", "beginner"}, Steps: []string{"Variables and types", "Control flow", "Functions and scope", "Arrays and objects"}, Active: true, CreatedAt: seededAt},
+				{ID: 2, Title: "React Development", Description: "Build modern web applications with React", Difficulty: 2, EstimatedHours: 30, Prerequisites: []string{"JavaScript Fundamentals"}, Tags: []string{"react", "frontend", "javascript"}, Steps: []string{"Components and JSX", "State and props", "Hooks", "Routing and data fetching"}, Active: true, CreatedAt: seededAt},
+				{ID: 3, Title: "Backend with Node.js", Description: "Create server-side applications with Node.js", Difficulty: 2, EstimatedHours: 25, Prerequisites: []string{"JavaScript Fundamentals"}, Tags: []string{"nodejs", "backend", "javascript"}, Steps: []string{"Modules and npm", "HTTP servers with Express", "Files and streams", "Build a REST API"}, Active: true, CreatedAt: seededAt},
+				{ID: 4, Title: "Database Design", Description: "Learn to design and work with databases", Difficulty: 2, EstimatedHours: 15, Prerequisites: []string{}, Tags: []string{"database", "sql", "design"}, Steps: []string{"Relational modeling", "SQL queries", "Normalization", "Indexes and performance"}, Active: true, CreatedAt: seededAt},
+				{ID: 5, Title: "Full-Stack Development", Description: "Complete full-stack web development", Difficulty: 3, EstimatedHours: 50, Prerequisites: []string{"React Development", "Backend with Node.js", "Database Design"}, Tags: []string{"fullstack", "web", "advanced"}, Steps: []string{"Plan the application", "Build the API", "Build the frontend", "Deploy and monitor"}, Active: true, CreatedAt: seededAt},
+			},
+			progress: []LearningPathProgress{
+				{ID: 1, UserID: 1, PathID: 1, ProgressPercentage: 100, CurrentStep: 4, StartedAt: seededAt, CompletedAt: &seededAt},
+				{ID: 2, UserID: 1, PathID: 2, ProgressPercentage: 25, CurrentStep: 2, StartedAt: seededAt},
+			},
+		},
+		Projects: &memoryProjectRepository{projects: []Project{
+			{ID: 1, UserID: 1, Title: "Build a Personal Habit Tracker", Description: "A small web app that records daily habits and charts your streaks.", ProjectType: "coding", Difficulty: 2, EstimatedHours: 10, Status: ProjectStatusInProgress,
+				Skills: []string{"HTML forms", "local storage", "charts"}, Resources: []string{"MDN Web Docs", "Chart.js documentation"}, SuccessMetrics: []string{"Check-ins survive a page reload"},
+				Milestones: []ProjectMilestone{
+					{Title: "Sketch the data model", Description: "List the habits and what a daily check-in stores.", Week: 1, XPReward: 40, Completed: true, CompletedAt: &seededAt},
+					{Title: "Build the check-in form", Description: "Save check-ins in local storage.", Week: 1, XPReward: 60},
+					{Title: "Chart your streaks", Description: "Draw a weekly chart of completed habits.", Week: 2, XPReward: 80},
+				},
+				CreatedAt: seededAt, StartedAt: &seededAt},
+		}},
+		Sessions: &memoryUserSessionRepository{sessions: []UserSession{
+			{ID: 1, UserID: 1, StartedAt: seededAt.Add(-25 * time.Minute), TasksCompleted: 3, TasksFailed: 1, Retries: 1, ResponseSeconds: 420, TimedTasks: 4, LastEventAt: seededAt.Add(-5 * time.Minute)},
+		}},
+		FocusSessions: &memoryFocusSessionRepository{sessions: []FocusSession{
+			{ID: 1, UserID: 1, QuestID: intPtr(2), PlannedMinutes: 25, Status: FocusStatusRunning, StartedAt: seededAt.Add(-time.Minute), LastHeartbeatAt: seededAt, FocusedSeconds: 60},
+		}},
+		XP: &memoryXPRepository{users: users, transactions: []XPTransaction{
+			{ID: 1, UserID: 1, Amount: 100, SourceType: XPSourceQuest, SourceID: intPtr(1), Description: "Completed quest task: Identify first bug", CreatedAt: seededAt},
+			{ID: 2, UserID: 1, Amount: 50, SourceType: XPSourceQuest, SourceID: intPtr(2), Description: "Completed quest task: Set up workspace", CreatedAt: seededAt},
+			{ID: 3, UserID: 1, Amount: 25, SourceType: XPSourceStreak, Description: "Daily streak bonus", CreatedAt: seededAt},
+			{ID: 4, UserID: 1, Amount: 75, SourceType: XPSourceBadge, SourceID: intPtr(1), Description: "Earned Code Warrior badge", CreatedAt: seededAt},
+			{ID: 5, UserID: 1, Amount: 200, SourceType: XPSourceBadge, SourceID: intPtr(2), Description: "Earned Focus Master badge", CreatedAt: seededAt},
+			{ID: 6, UserID: 1, Amount: 75, SourceType: XPSourceBadge, SourceID: intPtr(4), Description: "Earned Bug Hunter badge", CreatedAt: seededAt},
+		}},
+	}
+}
+
+// memoryUserRepository is an in-memory UserRepository
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users []User
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id int) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.ID == id {
+			u := user
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			u := user
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			u := user
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maxID := 0
+	for _, existing := range r.users {
+		if existing.Username == user.Username || strings.EqualFold(existing.Email, user.Email) {
+			return ErrDuplicate
+		}
+		if existing.ID > maxID {
+			maxID = existing.ID
+		}
+	}
+	user.ID = maxID + 1
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *memoryUserRepository) SetBuddyPersonality(ctx context.Context, userID int, personality string, locked bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].ID == userID {
+			r.users[i].BuddyPersonality = personality
+			r.users[i].PersonalityLocked = locked
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryQuestRepository is an in-memory QuestRepository
+type memoryQuestRepository struct {
+	mu     sync.RWMutex
+	quests []Quest
+}
+
+// cloneQuest copies a quest so callers never share task slices with the store
+func cloneQuest(quest Quest) *Quest {
+	q := quest
+	q.Tasks = append([]QuestTask(nil), quest.Tasks...)
+	return &q
+}
+
+func (r *memoryQuestRepository) GetByID(ctx context.Context, id int) (*Quest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, quest := range r.quests {
+		if quest.ID == id {
+			return cloneQuest(quest), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryQuestRepository) ListByUser(ctx context.Context, userID int) ([]Quest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var userQuests []Quest
+	for _, quest := range r.quests {
+		if quest.UserID == userID {
+			userQuests = append(userQuests, *cloneQuest(quest))
+		}
+	}
+	return userQuests, nil
+}
+
+func (r *memoryQuestRepository) ListOverdue(ctx context.Context, now time.Time) ([]Quest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var overdue []Quest
+	for _, quest := range r.quests {
+		if quest.IsOverdue(now) {
+			overdue = append(overdue, *cloneQuest(quest))
+		}
+	}
+	return overdue, nil
+}
+
+func (r *memoryQuestRepository) Create(ctx context.Context, quest *Quest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taskID := 0
+	for _, existing := range r.quests {
+		for _, task := range existing.Tasks {
+			if task.ID > taskID {
+				taskID = task.ID
+			}
+		}
+	}
+	quest.ID = len(r.quests) + 1
+	for i := range quest.Tasks {
+		taskID++
+		quest.Tasks[i].ID = taskID
+		quest.Tasks[i].QuestID = quest.ID
+	}
+	r.quests = append(r.quests, *cloneQuest(*quest))
+	return nil
+}
+
+func (r *memoryQuestRepository) Update(ctx context.Context, quest *Quest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.quests {
+		if existing.ID == quest.ID {
+			updated := *cloneQuest(*quest)
+			updated.Tasks = existing.Tasks
+			r.quests[i] = updated
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryQuestRepository) AddProgress(ctx context.Context, questID, amount int, at time.Time) (*Quest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.quests {
+		quest := &r.quests[i]
+		if quest.ID != questID || quest.Status != QuestStatusActive {
+			continue
+		}
+		quest.Progress += amount
+		if quest.Total > 0 && quest.Progress >= quest.Total {
+			quest.Progress = quest.Total
+			quest.Status = QuestStatusCompleted
+			quest.CompletedAt = &at
+		}
+		return cloneQuest(*quest), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryQuestRepository) CompleteTask(ctx context.Context, questID, taskID int, at time.Time) (*QuestTask, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, quest := range r.quests {
+		if quest.ID != questID {
+			continue
+		}
+		for j, task := range quest.Tasks {
+			if task.ID != taskID {
+				continue
+			}
+			if task.Completed {
+				return nil, ErrTaskAlreadyCompleted
+			}
+			completedAt := at
+			r.quests[i].Tasks[j].Completed = true
+			r.quests[i].Tasks[j].CompletedAt = &completedAt
+			t := r.quests[i].Tasks[j]
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// memoryBadgeRepository is an in-memory BadgeRepository
+type memoryBadgeRepository struct {
+	mu          sync.RWMutex
+	definitions []BadgeDefinition
+	earned      map[int]map[int]time.Time // user ID -> badge ID -> earned at
+}
+
+func (r *memoryBadgeRepository) ListByUser(ctx context.Context, userID int) ([]Badge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	badges := make([]Badge, 0, len(r.definitions))
+	for _, d := range r.definitions {
+		_, earned := r.earned[userID][d.ID]
+		badges = append(badges, Badge{ID: d.ID, Name: d.Name, Description: d.Description, Icon: d.Icon, Earned: earned, UserID: userID})
+	}
+	return badges, nil
+}
+
+func (r *memoryBadgeRepository) ListDefinitions(ctx context.Context) ([]BadgeDefinition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]BadgeDefinition(nil), r.definitions...), nil
+}
+
+func (r *memoryBadgeRepository) Award(ctx context.Context, userID, badgeID int, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.earned[userID][badgeID]; ok {
+		return false, nil
+	}
+	if r.earned[userID] == nil {
+		r.earned[userID] = make(map[int]time.Time)
+	}
+	r.earned[userID][badgeID] = at
+	return true, nil
+}
+
+// memoryStreakRepository is an in-memory StreakRepository that keeps the
+// in-memory users' streak counters in sync
+type memoryStreakRepository struct {
+	mu           sync.Mutex
+	users        *memoryUserRepository
+	lastActivity map[int]time.Time
+	freezeTokens map[int]int
+}
+
+func (r *memoryStreakRepository) Get(ctx context.Context, userID int) (*StreakState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, err := r.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	state := &StreakState{
+		UserID:       userID,
+		Current:      user.Streak,
+		Max:          user.MaxStreak,
+		FreezeTokens: r.freezeTokens[userID],
+		Timezone:     user.Timezone,
+	}
+	if last, ok := r.lastActivity[userID]; ok {
+		state.LastActivity = &last
+	}
+	return state, nil
+}
+
+func (r *memoryStreakRepository) Save(ctx context.Context, state *StreakState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for i := range r.users.users {
+		if r.users.users[i].ID == state.UserID {
+			r.users.users[i].Streak = state.Current
+			r.users.users[i].MaxStreak = state.Max
+			if state.LastActivity != nil {
+				r.lastActivity[state.UserID] = *state.LastActivity
+			}
+			r.freezeTokens[state.UserID] = state.FreezeTokens
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryActivityRepository is an in-memory ActivityRepository
+type memoryActivityRepository struct {
+	mu      sync.RWMutex
+	entries []activityEntry
+}
+
+// activityEntry is one row of the in-memory activity log
+type activityEntry struct {
+	userID     int
+	stat       string
+	amount     int
+	occurredAt time.Time
+}
+
+func (r *memoryActivityRepository) Record(ctx context.Context, userID int, stat string, amount int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, activityEntry{userID: userID, stat: stat, amount: amount, occurredAt: at})
+	return nil
+}
+
+func (r *memoryActivityRepository) Totals(ctx context.Context, userID int, since time.Time) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	totals := make(map[string]int)
+	for _, entry := range r.entries {
+		if entry.userID == userID && !entry.occurredAt.Before(since) {
+			totals[entry.stat] += entry.amount
+		}
+	}
+	return totals, nil
+}
+
+// memoryConversationRepository is an in-memory ConversationRepository
+type memoryConversationRepository struct {
+	mu            sync.RWMutex
+	messages      []BuddyMessage
+	threads       map[int]ConversationThread
+	lastMessageID int
+	lastThreadID  int
+}
+
+func (r *memoryConversationRepository) Create(ctx context.Context, message *BuddyMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastMessageID++
+	message.ID = r.lastMessageID
+	r.messages = append(r.messages, *message)
+	if thread, ok := r.threads[message.ThreadID]; ok {
+		thread.UpdatedAt = message.Timestamp
+		r.threads[thread.ID] = thread
+	}
+	return nil
+}
+
+func (r *memoryConversationRepository) ListByUser(ctx context.Context, userID int) ([]BuddyMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var userMessages []BuddyMessage
+	for _, message := range r.messages {
+		if message.UserID == userID {
+			userMessages = append(userMessages, message)
+		}
+	}
+	return userMessages, nil
+}
+
+func (r *memoryConversationRepository) CreateThread(ctx context.Context, thread *ConversationThread) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.threads == nil {
+		r.threads = make(map[int]ConversationThread)
+	}
+	r.lastThreadID++
+	thread.ID = r.lastThreadID
+	r.threads[thread.ID] = *thread
+	return nil
+}
+
+func (r *memoryConversationRepository) GetThread(ctx context.Context, threadID int) (*ConversationThread, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	thread, ok := r.threads[threadID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &thread, nil
+}
+
+func (r *memoryConversationRepository) ListThreads(ctx context.Context, userID int) ([]ConversationThread, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	threads := []ConversationThread{}
+	for _, thread := range r.threads {
+		if thread.UserID == userID {
+			threads = append(threads, thread)
+		}
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		if !threads[i].UpdatedAt.Equal(threads[j].UpdatedAt) {
+			return threads[i].UpdatedAt.After(threads[j].UpdatedAt)
+		}
+		return threads[i].ID > threads[j].ID
+	})
+	return threads, nil
+}
+
+func (r *memoryConversationRepository) ListThreadMessages(ctx context.Context, threadID int) ([]BuddyMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []BuddyMessage{}
+	for _, message := range r.messages {
+		if message.ThreadID == threadID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryConversationRepository) UpdateSummary(ctx context.Context, threadID int, summary string, summarizedThrough int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	thread, ok := r.threads[threadID]
+	if !ok {
+		return ErrNotFound
+	}
+	thread.Summary = summary
+	thread.SummarizedThrough = summarizedThrough
+	r.threads[threadID] = thread
+	return nil
+}
+
+func (r *memoryConversationRepository) DeleteThread(ctx context.Context, threadID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.threads[threadID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.threads, threadID)
+	kept := r.messages[:0]
+	for _, message := range r.messages {
+		if message.ThreadID != threadID {
+			kept = append(kept, message)
+		}
+	}
+	r.messages = kept
+	return nil
+}
+
+// memoryRefreshTokenRepository is an in-memory RefreshTokenRepository
+type memoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+func (r *memoryRefreshTokenRepository) Create(ctx context.Context, token RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tokens[token.ID]; exists {
+		return ErrDuplicate
+	}
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) Revoke(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, exists := r.tokens[id]
+	if !exists || token.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+// memoryXPRepository is an in-memory XPRepository that updates the in-memory users
+type memoryXPRepository struct {
+	mu           sync.Mutex
+	users        *memoryUserRepository
+	transactions []XPTransaction
+}
+
+func (r *memoryXPRepository) Record(ctx context.Context, tx *XPTransaction, levelFor func(totalXP int) int) (*User, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+	return r.record(tx, levelFor)
+}
+
+func (r *memoryXPRepository) RecordCapped(ctx context.Context, tx *XPTransaction, since time.Time, limit int, levelFor func(totalXP int) int) (*User, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	remaining := limit
+	for _, existing := range r.transactions {
+		if existing.UserID == tx.UserID && existing.SourceType == tx.SourceType && !existing.CreatedAt.Before(since) {
+			remaining -= existing.Amount
+		}
+	}
+	if remaining <= 0 {
+		return nil, 0, ErrXPCapReached
+	}
+	if tx.Amount > remaining {
+		tx.Amount = remaining
+	}
+	return r.record(tx, levelFor)
+}
+
+// record appends tx and updates the user's XP; callers hold both locks
+func (r *memoryXPRepository) record(tx *XPTransaction, levelFor func(totalXP int) int) (*User, int, error) {
+	if tx.SourceType == XPSourceBadge && tx.SourceID != nil {
+		for _, existing := range r.transactions {
+			if existing.UserID == tx.UserID && existing.SourceType == XPSourceBadge && existing.SourceID != nil && *existing.SourceID == *tx.SourceID {
+				return nil, 0, ErrDuplicate
+			}
+		}
+	}
+	for i, user := range r.users.users {
+		if user.ID != tx.UserID {
+			continue
+		}
+		total := user.XP + tx.Amount
+		if total < 0 {
+			total = 0
+		}
+		r.users.users[i].XP = total
+		r.users.users[i].Level = levelFor(total)
+
+		tx.ID = len(r.transactions) + 1
+		tx.CreatedAt = time.Now()
+		r.transactions = append(r.transactions, *tx)
+
+		u := r.users.users[i]
+		return &u, user.Level, nil
+	}
+	return nil, 0, ErrNotFound
+}
+
+func (r *memoryXPRepository) CreditedSources(ctx context.Context, userID int, sourceType string) (map[int]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credited := make(map[int]bool)
+	for _, tx := range r.transactions {
+		if tx.UserID == userID && tx.SourceType == sourceType && tx.SourceID != nil {
+			credited[*tx.SourceID] = true
+		}
+	}
+	return credited, nil
+}
+
+func (r *memoryXPRepository) ListByUser(ctx context.Context, userID, limit, offset int) ([]XPTransaction, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var userTransactions []XPTransaction
+	for i := len(r.transactions) - 1; i >= 0; i-- {
+		if r.transactions[i].UserID == userID {
+			userTransactions = append(userTransactions, r.transactions[i])
+		}
+	}
+
+	total := len(userTransactions)
+	if offset >= total {
+		return []XPTransaction{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return userTransactions[offset:end], total, nil
+}
+
+// memoryUsageRepository is an in-memory UsageRepository
+type memoryUsageRepository struct {
+	mu      sync.Mutex
+	records []AIUsage
+	lastID  int
+}
+
+func (r *memoryUsageRepository) Record(ctx context.Context, usage *AIUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	usage.ID = r.lastID
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	r.records = append(r.records, *usage)
+	return nil
+}
+
+func (r *memoryUsageRepository) TotalTokens(ctx context.Context, userID int, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := 0
+	for _, record := range r.records {
+		if record.UserID == userID && !record.CreatedAt.Before(since) {
+			total += record.TotalTokens
+		}
+	}
+	return total, nil
+}
+
+func (r *memoryUsageRepository) Summarize(ctx context.Context, from, to time.Time) ([]UsageSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct {
+		userID     int
+		templateID string
+		model      string
+	}
+	index := map[key]int{}
+	summaries := []UsageSummary{}
+	for _, record := range r.records {
+		if record.CreatedAt.Before(from) || !record.CreatedAt.Before(to) {
+			continue
+		}
+		k := key{record.UserID, record.TemplateID, record.Model}
+		i, ok := index[k]
+		if !ok {
+			i = len(summaries)
+			index[k] = i
+			summaries = append(summaries, UsageSummary{UserID: record.UserID, TemplateID: record.TemplateID, Model: record.Model})
+		}
+		summaries[i].Calls++
+		summaries[i].TotalTokens += record.TotalTokens
+		summaries[i].CostUSD += record.CostUSD
+	}
+	return summaries, nil
+}
+
+// memoryMoodLogRepository is an in-memory MoodLogRepository
+type memoryMoodLogRepository struct {
+	mu      sync.Mutex
+	entries []MoodDetectionLog
+	lastID  int
+}
+
+func (r *memoryMoodLogRepository) Record(ctx context.Context, entry *MoodDetectionLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	entry.ID = r.lastID
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+// memoryPersonalityRepository is an in-memory PersonalityRepository
+type memoryPersonalityRepository struct {
+	mu            sync.RWMutex
+	personalities []CustomPersonality
+	lastID        int
+	users         *memoryUserRepository // whose preferences Delete clears
+}
+
+func (r *memoryPersonalityRepository) Create(ctx context.Context, personality *CustomPersonality) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.personalities {
+		if existing.OwnerID == personality.OwnerID && existing.Key == personality.Key {
+			return ErrDuplicate
+		}
+	}
+	r.lastID++
+	personality.ID = r.lastID
+	personality.CreatedAt = time.Now()
+	r.personalities = append(r.personalities, *personality)
+	return nil
+}
+
+func (r *memoryPersonalityRepository) ListVisible(ctx context.Context, userID int) ([]CustomPersonality, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	visible := []CustomPersonality{}
+	for _, personality := range r.personalities {
+		if personality.Shared || personality.OwnerID == userID {
+			visible = append(visible, personality)
+		}
+	}
+	return visible, nil
+}
+
+func (r *memoryPersonalityRepository) Get(ctx context.Context, userID int, key string) (*CustomPersonality, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if personality := r.visible(userID, key); personality != nil {
+		p := *personality
+		return &p, nil
+	}
+	return nil, ErrNotFound
+}
+
+// visible returns the personality the user sees under key, preferring their
+// own to a shared one; r.mu must be held
+func (r *memoryPersonalityRepository) visible(userID int, key string) *CustomPersonality {
+	var shared *CustomPersonality
+	for i, personality := range r.personalities {
+		switch {
+		case personality.Key != key:
+		case personality.OwnerID == userID:
+			return &r.personalities[i]
+		case personality.Shared && shared == nil:
+			shared = &r.personalities[i]
+		}
+	}
+	return shared
+}
+
+func (r *memoryPersonalityRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, personality := range r.personalities {
+		if personality.ID != id {
+			continue
+		}
+		r.users.mu.Lock()
+		defer r.users.mu.Unlock()
+		for j, user := range r.users.users {
+			if user.BuddyPersonality != personality.Key {
+				continue
+			}
+			// users with their own personality under the key keep it
+			if chosen := r.visible(user.ID, personality.Key); chosen != nil && chosen.ID == id {
+				r.users.users[j].BuddyPersonality = ""
+			}
+		}
+
+		r.personalities = append(r.personalities[:i], r.personalities[i+1:]...)
+		return nil
+	}
+	return ErrNotFound
+}
+
+// memoryLearningPathRepository is an in-memory LearningPathRepository
+type memoryLearningPathRepository struct {
+	mu       sync.RWMutex
+	paths    []LearningPath
+	progress []LearningPathProgress
+}
+
+func (r *memoryLearningPathRepository) List(ctx context.Context) ([]LearningPath, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]LearningPath{}, r.paths...), nil
+}
+
+func (r *memoryLearningPathRepository) GetByID(ctx context.Context, id int) (*LearningPath, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, path := range r.paths {
+		if path.ID == id {
+			p := path
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryLearningPathRepository) Create(ctx context.Context, path *LearningPath) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.paths {
+		if existing.Title == path.Title {
+			return ErrDuplicate
+		}
+	}
+	path.ID = len(r.paths) + 1
+	r.paths = append(r.paths, *path)
+	return nil
+}
+
+func (r *memoryLearningPathRepository) Update(ctx context.Context, path *LearningPath) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.paths {
+		if r.paths[i].ID == path.ID {
+			r.paths[i] = *path
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryLearningPathRepository) ListProgress(ctx context.Context, userID int) ([]LearningPathProgress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	progress := []LearningPathProgress{}
+	for _, p := range r.progress {
+		if p.UserID == userID {
+			progress = append(progress, p)
+		}
+	}
+	return progress, nil
+}
+
+func (r *memoryLearningPathRepository) GetProgress(ctx context.Context, userID, pathID int) (*LearningPathProgress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.progress {
+		if p.UserID == userID && p.PathID == pathID {
+			progress := p
+			return &progress, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryLearningPathRepository) CreateProgress(ctx context.Context, progress *LearningPathProgress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.progress {
+		if p.UserID == progress.UserID && p.PathID == progress.PathID {
+			return ErrDuplicate
+		}
+	}
+	progress.ID = len(r.progress) + 1
+	r.progress = append(r.progress, *progress)
+	return nil
+}
+
+func (r *memoryLearningPathRepository) UpdateProgress(ctx context.Context, progress *LearningPathProgress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.progress {
+		if r.progress[i].ID == progress.ID {
+			r.progress[i] = *progress
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryProjectRepository is an in-memory ProjectRepository
+type memoryProjectRepository struct {
+	mu       sync.RWMutex
+	projects []Project
+}
+
+// copyProject returns a copy that shares no milestones with the stored project
+func copyProject(project Project) Project {
+	project.Milestones = append([]ProjectMilestone(nil), project.Milestones...)
+	return project
+}
+
+func (r *memoryProjectRepository) ListByUser(ctx context.Context, userID int) ([]Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	projects := []Project{}
+	for i := len(r.projects) - 1; i >= 0; i-- {
+		if r.projects[i].UserID == userID {
+			projects = append(projects, copyProject(r.projects[i]))
+		}
+	}
+	return projects, nil
+}
+
+func (r *memoryProjectRepository) GetByID(ctx context.Context, id int) (*Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, project := range r.projects {
+		if project.ID == id {
+			p := copyProject(project)
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryProjectRepository) Create(ctx context.Context, project *Project) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	project.ID = len(r.projects) + 1
+	r.projects = append(r.projects, copyProject(*project))
+	return nil
+}
+
+func (r *memoryProjectRepository) Update(ctx context.Context, project *Project) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.projects {
+		if r.projects[i].ID == project.ID {
+			r.projects[i] = copyProject(*project)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryUserSessionRepository is an in-memory SessionRepository
+type memoryUserSessionRepository struct {
+	mu       sync.RWMutex
+	sessions []UserSession
+}
+
+func (r *memoryUserSessionRepository) Create(ctx context.Context, session *UserSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session.ID = len(r.sessions) + 1
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *memoryUserSessionRepository) GetByID(ctx context.Context, id int) (*UserSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, session := range r.sessions {
+		if session.ID == id {
+			s := session
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserSessionRepository) GetOpen(ctx context.Context, userID int) (*UserSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := len(r.sessions) - 1; i >= 0; i-- {
+		if session := r.sessions[i]; session.UserID == userID && !session.Ended() {
+			return &session, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserSessionRepository) Recent(ctx context.Context, userID, limit int) ([]UserSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []UserSession{}
+	for i := len(r.sessions) - 1; i >= 0 && len(sessions) < limit; i-- {
+		if r.sessions[i].UserID == userID {
+			sessions = append(sessions, r.sessions[i])
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memoryUserSessionRepository) Update(ctx context.Context, session *UserSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.sessions {
+		if r.sessions[i].ID == session.ID {
+			r.sessions[i] = *session
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryFocusSessionRepository is an in-memory FocusSessionRepository
+type memoryFocusSessionRepository struct {
+	mu       sync.RWMutex
+	sessions []FocusSession
+}
+
+func (r *memoryFocusSessionRepository) Create(ctx context.Context, session *FocusSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session.ID = len(r.sessions) + 1
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *memoryFocusSessionRepository) GetByID(ctx context.Context, id int) (*FocusSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, session := range r.sessions {
+		if session.ID == id {
+			s := session
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryFocusSessionRepository) GetActive(ctx context.Context, userID int) (*FocusSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := len(r.sessions) - 1; i >= 0; i-- {
+		if session := r.sessions[i]; session.UserID == userID && !session.Closed() {
+			return &session, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryFocusSessionRepository) Update(ctx context.Context, session *FocusSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.sessions {
+		if r.sessions[i].ID == session.ID {
+			r.sessions[i] = *session
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryUserRepositoryRejectsDuplicates(t *testing.T) {
+	repo := NewMemoryRepositories().Users
+	ctx := context.Background()
+
+	user := &User{Username: "carol", Email: "carol@example.com"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if user.ID == 0 {
+		t.Fatal("Create() left the ID unset")
+	}
+
+	tests := map[string]*User{
+		"username":          {Username: "carol", Email: "other@example.com"},
+		"email, other case": {Username: "caroline", Email: "Carol@Example.com"},
+		"seeded user":       {Username: "alex", Email: "alex2@example.com"},
+	}
+	for name, duplicate := range tests {
+		if err := repo.Create(ctx, duplicate); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("%s: Create() error = %v, want ErrDuplicate", name, err)
+		}
+	}
+
+	got, err := repo.GetByEmail(ctx, "CAROL@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("GetByEmail() = %+v, %v; want carol", got, err)
+	}
+	if _, err := repo.GetByUsername(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByUsername(nobody) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRefreshTokenRepositoryRevokesOnce(t *testing.T) {
+	repo := NewMemoryRepositories().RefreshTokens
+	ctx := context.Background()
+	token := RefreshToken{ID: "token-1", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	if err := repo.Create(ctx, token); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.Create(ctx, token); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Create(same ID) error = %v, want ErrDuplicate", err)
+	}
+	if err := repo.Revoke(ctx, token.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err := repo.Revoke(ctx, token.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Revoke(revoked) error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Revoke(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Revoke(unknown) error = %v, want ErrNotFound", err)
+	}
+}
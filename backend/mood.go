@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	ai "learning-buddy-ai"
+)
+
+// MoodDetectionLog is an emotion detected for a user and what it was based on
+type MoodDetectionLog struct {
+	ID         int                    `json:"id"`
+	UserID     int                    `json:"user_id"`
+	SessionID  *int                   `json:"session_id,omitempty"`
+	Mood       string                 `json:"detected_mood"`
+	Confidence float64                `json:"confidence_score"`
+	Factors    map[string]interface{} `json:"factors"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// logMood records an emotion detection in mood_detection_logs. Failures are
+// logged rather than returned so they never block a reply.
+func (s *AIService) logMood(ctx context.Context, user *User, detection *ai.EmotionDetection) {
+	if s.moods == nil {
+		return
+	}
+	entry := &MoodDetectionLog{
+		UserID:     user.ID,
+		Mood:       string(detection.Emotion),
+		Confidence: math.Round(detection.Confidence*100) / 100,
+		Factors:    detection.Factors(),
+	}
+	if err := s.moods.Record(ctx, entry); err != nil {
+		log.Printf("log mood for user %d: %v", user.ID, err)
+	}
+}
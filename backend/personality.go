@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	ai "learning-buddy-ai"
+)
+
+// defaultBuddyPersonality is the personality new users start with
+const defaultBuddyPersonality = string(ai.PersonalityMentor)
+
+// maxCustomPersonalities is how many personalities a learner may create
+const maxCustomPersonalities = 10
+
+// CustomPersonality is a user-defined buddy personality. Learners' are private;
+// admins' are shared with every learner.
+type CustomPersonality struct {
+	ID      int    `json:"id"`
+	Key     string `json:"key"`
+	OwnerID int    `json:"owner_id"`
+	Shared  bool   `json:"shared"`
+	ai.PersonalityConfig
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PersonalityInfo is a personality as listed to a learner
+type PersonalityInfo struct {
+	Key string `json:"key"`
+	ai.PersonalityConfig
+	Unlocked bool `json:"unlocked"`
+}
+
+// coreFor returns the AI core with the custom personalities the user can see.
+// If they cannot be loaded the user gets the configured personalities only.
+func (s *AIService) coreFor(ctx context.Context, user *User) BuddyAI {
+	if s.personalities == nil {
+		return s.core
+	}
+	personalities, err := s.personalities.ListVisible(ctx, user.ID)
+	if err != nil {
+		log.Printf("load custom personalities for user %d: %v", user.ID, err)
+		return s.core
+	}
+	if len(personalities) == 0 {
+		return s.core
+	}
+	custom := make(map[ai.BuddyPersonality]ai.PersonalityConfig, len(personalities))
+	for _, personality := range personalities {
+		key := ai.BuddyPersonality(personality.Key)
+		if _, taken := custom[key]; taken && personality.OwnerID != user.ID {
+			continue // the user's own personality wins over a shared one with its key
+		}
+		custom[key] = personality.PersonalityConfig
+	}
+	return s.core.WithPersonalities(custom)
+}
+
+// listPersonalitiesHandler lists the configured personalities and the custom
+// ones the caller can see, flagging those unlocked at the caller's level
+func (s *Server) listPersonalitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := UserIDFromContext(r.Context())
+	user, err := s.repos.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("load user %d: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	core := s.ai.coreFor(r.Context(), user)
+	personalities := []PersonalityInfo{}
+	for _, key := range core.Personalities() {
+		config, _ := core.Personality(key)
+		personalities = append(personalities, PersonalityInfo{
+			Key:               string(key),
+			PersonalityConfig: config,
+			Unlocked:          user.Level >= config.UnlockLevel,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(personalities)
+}
+
+// createPersonalityHandler creates a custom personality from its name, traits,
+// response style, prompt modifiers and unlock level. Its key is derived from the name.
+func (s *Server) createPersonalityHandler(w http.ResponseWriter, r *http.Request) {
+	var config ai.PersonalityConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	config.Name = strings.TrimSpace(config.Name)
+	config.Custom = false
+	if err := ai.ValidateCustomPersonality(config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := PrincipalFromContext(r.Context())
+	personality := &CustomPersonality{
+		Key:               string(ai.PersonalityKey(config.Name)),
+		OwnerID:           principal.UserID,
+		Shared:            principal.IsAdmin(),
+		PersonalityConfig: config,
+	}
+	if _, exists := s.ai.core.Personality(ai.BuddyPersonality(personality.Key)); exists {
+		http.Error(w, fmt.Sprintf("%s is a built-in personality", personality.Key), http.StatusConflict)
+		return
+	}
+	// Keys only clash with personalities the caller can see, so another
+	// learner's private names are neither blocked nor revealed
+	visible, err := s.repos.Personalities.ListVisible(r.Context(), principal.UserID)
+	if err != nil {
+		log.Printf("list personalities of user %d: %v", principal.UserID, err)
+		http.Error(w, "Failed to create personality", http.StatusInternalServerError)
+		return
+	}
+	owned := 0
+	for _, p := range visible {
+		if p.Key == personality.Key {
+			http.Error(w, fmt.Sprintf("A personality named %s already exists", personality.Key), http.StatusConflict)
+			return
+		}
+		if p.OwnerID == principal.UserID {
+			owned++
+		}
+	}
+	if !principal.IsAdmin() && owned >= maxCustomPersonalities {
+		http.Error(w, fmt.Sprintf("You can create at most %d personalities", maxCustomPersonalities), http.StatusConflict)
+		return
+	}
+
+	err = s.repos.Personalities.Create(r.Context(), personality)
+	if errors.Is(err, ErrDuplicate) {
+		http.Error(w, fmt.Sprintf("A personality named %s already exists", personality.Key), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("create personality %s: %v", personality.Key, err)
+		http.Error(w, "Failed to create personality", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(personality)
+}
+
+// deletePersonalityHandler deletes the custom personality the caller sees under
+// the key; admins pass owner_id to reach a learner's private one. Only its
+// creator or an admin may delete it; learners who chose it lose their preference.
+func (s *Server) deletePersonalityHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	principal, _ := PrincipalFromContext(r.Context())
+	ownerID := principal.UserID
+	if raw := r.URL.Query().Get("owner_id"); raw != "" {
+		var err error
+		if ownerID, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	personality, err := s.repos.Personalities.Get(r.Context(), ownerID, key)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Personality not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("get personality %s: %v", key, err)
+		http.Error(w, "Failed to load personality", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.policy.AuthorizeUser(principal, personality.OwnerID); err != nil {
+		if !personality.Shared {
+			// Another learner's private personality is not visible at all
+			http.Error(w, "Personality not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusForbidden, "forbidden", "Only its creator or an admin can delete this personality")
+		return
+	}
+
+	if err := s.repos.Personalities.Delete(r.Context(), personality.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("delete personality %s: %v", key, err)
+		http.Error(w, "Failed to delete personality", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// buddyPersonalityRequest is the body of PUT /users/{id}/buddy/personality.
+// An empty Personality clears the preference.
+type buddyPersonalityRequest struct {
+	Personality string `json:"personality"`
+	Locked      bool   `json:"locked"`
+}
+
+// updateBuddyPersonalityHandler sets the user's preferred buddy personality
+// and whether it is locked. The personality must be one the user can see and
+// unlocked at the user's level.
+func (s *Server) updateBuddyPersonalityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var req buddyPersonalityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.repos.Users.GetByID(r.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("get user %d: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Personality == "" {
+		if req.Locked {
+			http.Error(w, "Choose a personality to lock", http.StatusBadRequest)
+			return
+		}
+	} else {
+		config, ok := s.ai.coreFor(r.Context(), user).Personality(ai.BuddyPersonality(req.Personality))
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown personality %q", req.Personality), http.StatusBadRequest)
+			return
+		}
+		if user.Level < config.UnlockLevel {
+			http.Error(w, fmt.Sprintf("%s unlocks at level %d", config.Name, config.UnlockLevel), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.repos.Users.SetBuddyPersonality(r.Context(), userID, req.Personality, req.Locked); err != nil {
+		log.Printf("set buddy personality of user %d: %v", userID, err)
+		http.Error(w, "Failed to update buddy personality", http.StatusInternalServerError)
+		return
+	}
+	user.BuddyPersonality, user.PersonalityLocked = req.Personality, req.Locked
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// User roles
+const (
+	RoleLearner = "learner"
+	RoleAdmin   = "admin"
+)
+
+// ErrForbidden is returned when a principal may not act on a resource
+var ErrForbidden = errors.New("forbidden")
+
+// ErrorResponse is the JSON body returned for authentication and authorization failures
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// writeError writes a JSON error body with the given status code
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: code, Message: message})
+}
+
+// Policy decides whether a principal may read or change a resource.
+// Learners may only act on their own data; admins may act on anyone's.
+type Policy struct {
+	quests        QuestRepository
+	conversations ConversationRepository
+	sessions      SessionRepository
+}
+
+// NewPolicy creates a new ownership policy
+func NewPolicy(quests QuestRepository, conversations ConversationRepository, sessions SessionRepository) *Policy {
+	return &Policy{quests: quests, conversations: conversations, sessions: sessions}
+}
+
+// AuthorizeUser checks that the principal may act on data owned by userID
+func (p *Policy) AuthorizeUser(principal Principal, userID int) error {
+	if principal.IsAdmin() || principal.UserID == userID {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// AuthorizeQuest checks that the principal may act on a quest. It returns
+// ErrNotFound when the quest does not exist.
+func (p *Policy) AuthorizeQuest(ctx context.Context, principal Principal, questID int) error {
+	if principal.IsAdmin() {
+		return nil
+	}
+	quest, err := p.quests.GetByID(ctx, questID)
+	if err != nil {
+		return err
+	}
+	return p.AuthorizeUser(principal, quest.UserID)
+}
+
+// AuthorizeThread checks that the principal may act on a conversation thread.
+// It returns ErrNotFound when the thread does not exist.
+func (p *Policy) AuthorizeThread(ctx context.Context, principal Principal, threadID int) error {
+	thread, err := p.conversations.GetThread(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	return p.AuthorizeUser(principal, thread.UserID)
+}
+
+// AuthorizeSession checks that the principal may act on a learning session.
+// It returns ErrNotFound when the session does not exist.
+func (p *Policy) AuthorizeSession(ctx context.Context, principal Principal, sessionID int) error {
+	session, err := p.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return p.AuthorizeUser(principal, session.UserID)
+}
+
+// requireUserAccess guards routes whose {id} variable is the ID of the owning user
+func (s *Server) requireUserAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		principal, _ := PrincipalFromContext(r.Context())
+		if err := s.policy.AuthorizeUser(principal, userID); err != nil {
+			writeError(w, http.StatusForbidden, "forbidden", "You do not have access to this user's data")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdmin guards routes only admins may use
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		if !principal.IsAdmin() {
+			writeError(w, http.StatusForbidden, "forbidden", "This endpoint requires the admin role")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireQuestAccess guards routes whose {id} variable is a quest ID
+func (s *Server) requireQuestAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		questID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid quest ID", http.StatusBadRequest)
+			return
+		}
+
+		principal, _ := PrincipalFromContext(r.Context())
+		err = s.policy.AuthorizeQuest(r.Context(), principal, questID)
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Quest not found", http.StatusNotFound)
+		case errors.Is(err, ErrForbidden):
+			writeError(w, http.StatusForbidden, "forbidden", "You do not have access to this quest")
+		default:
+			log.Printf("authorize quest %d: %v", questID, err)
+			http.Error(w, "Failed to load quest", http.StatusInternalServerError)
+		}
+	}
+}
+
+// requireThreadAccess guards routes whose {id} variable is a conversation thread ID
+func (s *Server) requireThreadAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		threadID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid thread ID", http.StatusBadRequest)
+			return
+		}
+
+		principal, _ := PrincipalFromContext(r.Context())
+		err = s.policy.AuthorizeThread(r.Context(), principal, threadID)
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Thread not found", http.StatusNotFound)
+		case errors.Is(err, ErrForbidden):
+			writeError(w, http.StatusForbidden, "forbidden", "You do not have access to this thread")
+		default:
+			log.Printf("authorize thread %d: %v", threadID, err)
+			http.Error(w, "Failed to load thread", http.StatusInternalServerError)
+		}
+	}
+}
+
+// requireSessionAccess guards routes whose {id} variable is a learning session ID
+func (s *Server) requireSessionAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+
+		principal, _ := PrincipalFromContext(r.Context())
+		err = s.policy.AuthorizeSession(r.Context(), principal, sessionID)
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		case errors.Is(err, ErrForbidden):
+			writeError(w, http.StatusForbidden, "forbidden", "You do not have access to this session")
+		default:
+			log.Printf("authorize session %d: %v", sessionID, err)
+			http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		}
+	}
+}
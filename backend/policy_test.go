@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	ai "learning-buddy-ai"
+)
+
+type testFixture struct {
+	server *Server
+	router *mux.Router
+	tokens map[string]*TokenPair // keyed by caller name
+}
+
+// newTestFixture builds a server on the in-memory repositories with three callers:
+// "owner" (seeded user 1), "other" (a second learner) and "admin". The owner has
+// a private "code_reviewer" personality and the admin a shared "socratic_tutor".
+func newTestFixture(t *testing.T) *testFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	repos := NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.RefreshTokens, []byte("test-secret"))
+	auth.passwordCost = bcrypt.MinCost
+	server := NewServer(repos, auth, NewAIService(ai.NewAICore("")))
+
+	owner, err := repos.Users.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("load seeded user: %v", err)
+	}
+	other, err := auth.Register(ctx, "bob", "bob@example.com", "password123")
+	if err != nil {
+		t.Fatalf("register other: %v", err)
+	}
+	admin := &User{Username: "root", Email: "root@example.com", Role: RoleAdmin}
+	if err := repos.Users.Create(ctx, admin); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+
+	for _, personality := range []*CustomPersonality{
+		{Key: "code_reviewer", OwnerID: owner.ID, PersonalityConfig: testPersonality("Code Reviewer")},
+		{Key: "socratic_tutor", OwnerID: admin.ID, Shared: true, PersonalityConfig: testPersonality("Socratic Tutor")},
+	} {
+		if err := repos.Personalities.Create(ctx, personality); err != nil {
+			t.Fatalf("create personality %s: %v", personality.Key, err)
+		}
+	}
+
+	f := &testFixture{server: server, router: server.Router(), tokens: map[string]*TokenPair{}}
+	for name, user := range map[string]*User{"owner": owner, "other": other, "admin": admin} {
+		pair, err := auth.IssueTokens(ctx, user)
+		if err != nil {
+			t.Fatalf("issue tokens for %s: %v", name, err)
+		}
+		f.tokens[name] = pair
+	}
+	return f
+}
+
+func (f *testFixture) do(method, path, caller, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if caller != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[caller].AccessToken)
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// testPersonality returns a valid custom personality config
+func testPersonality(name string) ai.PersonalityConfig {
+	return ai.PersonalityConfig{
+		Name:            name,
+		Traits:          map[string]int{"patience": 8},
+		PromptModifiers: map[string]string{"prefix": "As a " + strings.ToLower(name) + ","},
+		UnlockLevel:     1,
+	}
+}
+
+type routeCase struct {
+	name   string
+	method string
+	route  string // route template as registered in Router
+	path   string
+	caller string // "" for anonymous
+	body   func(f *testFixture) string
+	want   int
+}
+
+func staticBody(body string) func(*testFixture) string {
+	return func(*testFixture) string { return body }
+}
+
+func refreshBody(caller string) func(*testFixture) string {
+	return func(f *testFixture) string {
+		return `{"refresh_token":"` + f.tokens[caller].RefreshToken + `"}`
+	}
+}
+
+var routeCases = []routeCase{
+	{"health is public", "GET", "/api/v1/health", "/api/v1/health", "", nil, http.StatusOK},
+
+	{"register is public", "POST", "/api/v1/auth/register", "/api/v1/auth/register", "", staticBody(`{"username":"carol","email":"carol@example.com","password":"password123"}`), http.StatusCreated},
+	{"register with short password", "POST", "/api/v1/auth/register", "/api/v1/auth/register", "", staticBody(`{"username":"carol","email":"carol@example.com","password":"short"}`), http.StatusBadRequest},
+	{"login is public", "POST", "/api/v1/auth/login", "/api/v1/auth/login", "", staticBody(`{"username":"bob","password":"password123"}`), http.StatusOK},
+	{"login rejects bad password", "POST", "/api/v1/auth/login", "/api/v1/auth/login", "", staticBody(`{"username":"bob","password":"wrong-password"}`), http.StatusUnauthorized},
+	{"frontend login alias", "POST", "/api/v1/login", "/api/v1/login", "", staticBody(`{"username":"bob@example.com","password":"password123"}`), http.StatusOK},
+	{"refresh is public", "POST", "/api/v1/auth/refresh", "/api/v1/auth/refresh", "", refreshBody("other"), http.StatusOK},
+	{"logout is public", "POST", "/api/v1/auth/logout", "/api/v1/auth/logout", "", refreshBody("other"), http.StatusNoContent},
+
+	{"anonymous user read", "GET", "/api/v1/users/{id}", "/api/v1/users/1", "", nil, http.StatusUnauthorized},
+	{"owner reads self", "GET", "/api/v1/users/{id}", "/api/v1/users/1", "owner", nil, http.StatusOK},
+	{"learner reads other user", "GET", "/api/v1/users/{id}", "/api/v1/users/1", "other", nil, http.StatusForbidden},
+	{"admin reads any user", "GET", "/api/v1/users/{id}", "/api/v1/users/1", "admin", nil, http.StatusOK},
+
+	{"anonymous quests read", "GET", "/api/v1/users/{id}/quests", "/api/v1/users/1/quests", "", nil, http.StatusUnauthorized},
+	{"owner reads own quests", "GET", "/api/v1/users/{id}/quests", "/api/v1/users/1/quests", "owner", nil, http.StatusOK},
+	{"learner reads other quests", "GET", "/api/v1/users/{id}/quests", "/api/v1/users/1/quests", "other", nil, http.StatusForbidden},
+	{"admin reads any quests", "GET", "/api/v1/users/{id}/quests", "/api/v1/users/1/quests", "admin", nil, http.StatusOK},
+
+	{"anonymous badges read", "GET", "/api/v1/users/{id}/badges", "/api/v1/users/1/badges", "", nil, http.StatusUnauthorized},
+	{"owner reads own badges", "GET", "/api/v1/users/{id}/badges", "/api/v1/users/1/badges", "owner", nil, http.StatusOK},
+	{"learner reads other badges", "GET", "/api/v1/users/{id}/badges", "/api/v1/users/1/badges", "other", nil, http.StatusForbidden},
+	{"admin reads any badges", "GET", "/api/v1/users/{id}/badges", "/api/v1/users/1/badges", "admin", nil, http.StatusOK},
+
+	{"anonymous conversations read", "GET", "/api/v1/users/{id}/conversations", "/api/v1/users/1/conversations", "", nil, http.StatusUnauthorized},
+	{"owner reads own conversations", "GET", "/api/v1/users/{id}/conversations", "/api/v1/users/1/conversations", "owner", nil, http.StatusOK},
+	{"learner reads other conversations", "GET", "/api/v1/users/{id}/conversations", "/api/v1/users/1/conversations", "other", nil, http.StatusForbidden},
+	{"admin reads any conversations", "GET", "/api/v1/users/{id}/conversations", "/api/v1/users/1/conversations", "admin", nil, http.StatusOK},
+	{"anonymous xp history read", "GET", "/api/v1/users/{id}/xp/history", "/api/v1/users/1/xp/history", "", nil, http.StatusUnauthorized},
+	{"owner reads own xp history", "GET", "/api/v1/users/{id}/xp/history", "/api/v1/users/1/xp/history", "owner", nil, http.StatusOK},
+	{"learner reads other xp history", "GET", "/api/v1/users/{id}/xp/history", "/api/v1/users/1/xp/history", "other", nil, http.StatusForbidden},
+	{"admin reads any xp history", "GET", "/api/v1/users/{id}/xp/history", "/api/v1/users/1/xp/history", "admin", nil, http.StatusOK},
+	{"anonymous streak read", "GET", "/api/v1/users/{id}/streak", "/api/v1/users/1/streak", "", nil, http.StatusUnauthorized},
+	{"owner reads own streak", "GET", "/api/v1/users/{id}/streak", "/api/v1/users/1/streak", "owner", nil, http.StatusOK},
+	{"learner reads other streak", "GET", "/api/v1/users/{id}/streak", "/api/v1/users/1/streak", "other", nil, http.StatusForbidden},
+	{"admin reads any streak", "GET", "/api/v1/users/{id}/streak", "/api/v1/users/1/streak", "admin", nil, http.StatusOK},
+
+	{"anonymous buddy personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/1/buddy/personality", "", staticBody(`{"personality":"chill"}`), http.StatusUnauthorized},
+	{"owner sets own buddy personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/1/buddy/personality", "owner", staticBody(`{"personality":"focused","locked":true}`), http.StatusOK},
+	{"learner sets other buddy personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/1/buddy/personality", "other", staticBody(`{"personality":"chill"}`), http.StatusForbidden},
+	{"admin sets any buddy personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/1/buddy/personality", "admin", staticBody(`{"personality":"chill"}`), http.StatusOK},
+	{"unknown buddy personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/1/buddy/personality", "owner", staticBody(`{"personality":"grumpy"}`), http.StatusBadRequest},
+
+	{"anonymous personalities read", "GET", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "", nil, http.StatusUnauthorized},
+	{"learner lists personalities", "GET", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", nil, http.StatusOK},
+	{"anonymous personality create", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "", staticBody(`{"name":"Pirate"}`), http.StatusUnauthorized},
+	{"learner creates personality", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", staticBody(`{"name":"Pirate Coach","traits":{"humor":9},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusCreated},
+	{"admin creates shared personality", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "admin", staticBody(`{"name":"Pirate Coach","traits":{"humor":9},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusCreated},
+	{"invalid personality", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", staticBody(`{"name":"Pirate Coach","traits":{"humor":11},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusBadRequest},
+	{"personality shadows built-in", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", staticBody(`{"name":"Mentor","traits":{"humor":9},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusConflict},
+	{"personality name taken", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", staticBody(`{"name":"Socratic tutor","traits":{"humor":9},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusConflict},
+	{"anonymous personality delete", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer", "", nil, http.StatusUnauthorized},
+	{"owner deletes own personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer", "owner", nil, http.StatusNoContent},
+	{"learner deletes other private personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer", "other", nil, http.StatusNotFound},
+	{"learner deletes shared personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/socratic_tutor", "owner", nil, http.StatusForbidden},
+	{"admin deletes any personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer?owner_id=1", "admin", nil, http.StatusNoContent},
+	{"admin deletes unseen personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer", "admin", nil, http.StatusNotFound},
+	{"learner deletes private personality by owner", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/code_reviewer?owner_id=1", "other", nil, http.StatusNotFound},
+	{"learner reuses other private name", "POST", "/api/v1/buddy/personalities", "/api/v1/buddy/personalities", "other", staticBody(`{"name":"Code Reviewer","traits":{"humor":9},"prompt_modifiers":{"prefix":"Arr,"},"unlock_level":1}`), http.StatusCreated},
+	{"missing personality", "DELETE", "/api/v1/buddy/personalities/{key}", "/api/v1/buddy/personalities/pirate", "admin", nil, http.StatusNotFound},
+	{"set another learner's private personality", "PUT", "/api/v1/users/{id}/buddy/personality", "/api/v1/users/2/buddy/personality", "other", staticBody(`{"personality":"code_reviewer"}`), http.StatusBadRequest},
+
+	{"anonymous quest update", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/1/progress", "", staticBody(`{"progress":3}`), http.StatusUnauthorized},
+	{"owner updates own quest", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/1/progress", "owner", staticBody(`{"progress":3}`), http.StatusOK},
+	{"learner updates other quest", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/1/progress", "other", staticBody(`{"progress":3}`), http.StatusForbidden},
+	{"admin updates any quest", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/1/progress", "admin", staticBody(`{"progress":3}`), http.StatusOK},
+	{"missing quest", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/999/progress", "other", staticBody(`{"progress":3}`), http.StatusNotFound},
+	{"progress out of range", "PUT", "/api/v1/quests/{id}/progress", "/api/v1/quests/1/progress", "owner", staticBody(`{"progress":4}`), http.StatusBadRequest},
+
+	{"anonymous quest read", "GET", "/api/v1/quests/{id}", "/api/v1/quests/1", "", nil, http.StatusUnauthorized},
+	{"owner reads own quest", "GET", "/api/v1/quests/{id}", "/api/v1/quests/1", "owner", nil, http.StatusOK},
+	{"learner reads other quest", "GET", "/api/v1/quests/{id}", "/api/v1/quests/1", "other", nil, http.StatusForbidden},
+	{"admin reads any quest", "GET", "/api/v1/quests/{id}", "/api/v1/quests/1", "admin", nil, http.StatusOK},
+
+	{"anonymous quest status", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "", staticBody(`{"status":"paused"}`), http.StatusUnauthorized},
+	{"owner pauses own quest", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "owner", staticBody(`{"status":"paused"}`), http.StatusOK},
+	{"learner pauses other quest", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "other", staticBody(`{"status":"paused"}`), http.StatusForbidden},
+	{"admin pauses any quest", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "admin", staticBody(`{"status":"paused"}`), http.StatusOK},
+	{"unknown quest status", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "owner", staticBody(`{"status":"archived"}`), http.StatusBadRequest},
+	{"disallowed quest transition", "PUT", "/api/v1/quests/{id}/status", "/api/v1/quests/1/status", "owner", staticBody(`{"status":"active"}`), http.StatusConflict},
+
+	{"anonymous tasks read", "GET", "/api/v1/quests/{id}/tasks", "/api/v1/quests/1/tasks", "", nil, http.StatusUnauthorized},
+	{"owner reads own tasks", "GET", "/api/v1/quests/{id}/tasks", "/api/v1/quests/1/tasks", "owner", nil, http.StatusOK},
+	{"learner reads other tasks", "GET", "/api/v1/quests/{id}/tasks", "/api/v1/quests/1/tasks", "other", nil, http.StatusForbidden},
+	{"admin reads any tasks", "GET", "/api/v1/quests/{id}/tasks", "/api/v1/quests/1/tasks", "admin", nil, http.StatusOK},
+
+	{"anonymous task completion", "POST", "/api/v1/quests/{id}/tasks/{taskId}/complete", "/api/v1/quests/1/tasks/3/complete", "", nil, http.StatusUnauthorized},
+	{"owner completes own task", "POST", "/api/v1/quests/{id}/tasks/{taskId}/complete", "/api/v1/quests/1/tasks/3/complete", "owner", nil, http.StatusOK},
+	{"learner completes other task", "POST", "/api/v1/quests/{id}/tasks/{taskId}/complete", "/api/v1/quests/1/tasks/3/complete", "other", nil, http.StatusForbidden},
+	{"admin completes any task", "POST", "/api/v1/quests/{id}/tasks/{taskId}/complete", "/api/v1/quests/1/tasks/3/complete", "admin", nil, http.StatusOK},
+	{"task completed twice", "POST", "/api/v1/quests/{id}/tasks/{taskId}/complete", "/api/v1/quests/1/tasks/1/complete", "owner", nil, http.StatusConflict},
+
+	{"anonymous buddy chat", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "", staticBody(`{"message":"hi"}`), http.StatusUnauthorized},
+	{"learner chats as self", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "other", staticBody(`{"message":"hi"}`), http.StatusOK},
+	{"anonymous buddy stream", "GET", "/api/v1/buddy/chat/stream", "/api/v1/buddy/chat/stream?message=hi", "", nil, http.StatusUnauthorized},
+	{"learner streams chat via query", "GET", "/api/v1/buddy/chat/stream", "/api/v1/buddy/chat/stream?message=hi", "other", nil, http.StatusOK},
+	{"learner streams chat via body", "POST", "/api/v1/buddy/chat/stream", "/api/v1/buddy/chat/stream", "other", staticBody(`{"message":"hi"}`), http.StatusOK},
+	{"stream needs a message", "POST", "/api/v1/buddy/chat/stream", "/api/v1/buddy/chat/stream", "other", staticBody(`{}`), http.StatusBadRequest},
+	{"stream rejects a blank message", "GET", "/api/v1/buddy/chat/stream", "/api/v1/buddy/chat/stream?message=%20%20", "other", nil, http.StatusBadRequest},
+	{"chat needs a message", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "other", staticBody(`{}`), http.StatusBadRequest},
+	{"chat rejects a blank message", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "other", staticBody(`{"message":" \n\t "}`), http.StatusBadRequest},
+	{"continue own thread", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "owner", staticBody(`{"message":"still stuck","thread_id":1}`), http.StatusOK},
+	{"continue other thread", "POST", "/api/v1/buddy/chat", "/api/v1/buddy/chat", "other", staticBody(`{"message":"hi","thread_id":1}`), http.StatusNotFound},
+
+	{"anonymous threads read", "GET", "/api/v1/users/{id}/threads", "/api/v1/users/1/threads", "", nil, http.StatusUnauthorized},
+	{"owner reads own threads", "GET", "/api/v1/users/{id}/threads", "/api/v1/users/1/threads", "owner", nil, http.StatusOK},
+	{"learner reads other threads", "GET", "/api/v1/users/{id}/threads", "/api/v1/users/1/threads", "other", nil, http.StatusForbidden},
+	{"admin reads any threads", "GET", "/api/v1/users/{id}/threads", "/api/v1/users/1/threads", "admin", nil, http.StatusOK},
+
+	{"anonymous thread read", "GET", "/api/v1/threads/{id}", "/api/v1/threads/1", "", nil, http.StatusUnauthorized},
+	{"owner reads own thread", "GET", "/api/v1/threads/{id}", "/api/v1/threads/1", "owner", nil, http.StatusOK},
+	{"learner reads other thread", "GET", "/api/v1/threads/{id}", "/api/v1/threads/1", "other", nil, http.StatusForbidden},
+	{"admin reads any thread", "GET", "/api/v1/threads/{id}", "/api/v1/threads/1", "admin", nil, http.StatusOK},
+	{"missing thread", "GET", "/api/v1/threads/{id}", "/api/v1/threads/999", "owner", nil, http.StatusNotFound},
+
+	{"anonymous thread delete", "DELETE", "/api/v1/threads/{id}", "/api/v1/threads/1", "", nil, http.StatusUnauthorized},
+	{"owner deletes own thread", "DELETE", "/api/v1/threads/{id}", "/api/v1/threads/1", "owner", nil, http.StatusNoContent},
+	{"learner deletes other thread", "DELETE", "/api/v1/threads/{id}", "/api/v1/threads/1", "other", nil, http.StatusForbidden},
+	{"admin deletes any thread", "DELETE", "/api/v1/threads/{id}", "/api/v1/threads/1", "admin", nil, http.StatusNoContent},
+
+	{"anonymous learning paths read", "GET", "/api/v1/learning-paths", "/api/v1/learning-paths", "", nil, http.StatusUnauthorized},
+	{"learner browses learning paths", "GET", "/api/v1/learning-paths", "/api/v1/learning-paths", "other", nil, http.StatusOK},
+	{"anonymous learning path read", "GET", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/5", "", nil, http.StatusUnauthorized},
+	{"learner reads learning path", "GET", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/5", "other", nil, http.StatusOK},
+	{"missing learning path", "GET", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/99", "other", nil, http.StatusNotFound},
+	{"anonymous learning path create", "POST", "/api/v1/learning-paths", "/api/v1/learning-paths", "", staticBody(`{"title":"Go Basics","difficulty_level":1,"estimated_hours":10,"tags":["go"],"steps":["Tour of Go"]}`), http.StatusUnauthorized},
+	{"learner creates learning path", "POST", "/api/v1/learning-paths", "/api/v1/learning-paths", "other", staticBody(`{"title":"Go Basics","difficulty_level":1,"estimated_hours":10,"tags":["go"],"steps":["Tour of Go"]}`), http.StatusForbidden},
+	{"admin creates learning path", "POST", "/api/v1/learning-paths", "/api/v1/learning-paths", "admin", staticBody(`{"title":"Go Basics","difficulty_level":1,"estimated_hours":10,"tags":["go"],"steps":["Tour of Go"]}`), http.StatusCreated},
+	{"unknown prerequisite", "POST", "/api/v1/learning-paths", "/api/v1/learning-paths", "admin", staticBody(`{"title":"Go Web","difficulty_level":2,"prerequisites":["Go Basics"],"steps":["net/http"]}`), http.StatusBadRequest},
+	{"anonymous learning path update", "PUT", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/4", "", staticBody(`{"title":"Database Design","difficulty_level":3,"steps":["SQL"]}`), http.StatusUnauthorized},
+	{"learner updates learning path", "PUT", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/4", "other", staticBody(`{"title":"Database Design","difficulty_level":3,"steps":["SQL"]}`), http.StatusForbidden},
+	{"admin updates learning path", "PUT", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/4", "admin", staticBody(`{"title":"Database Design","difficulty_level":3,"steps":["SQL"]}`), http.StatusOK},
+	{"prerequisite cycle", "PUT", "/api/v1/learning-paths/{id}", "/api/v1/learning-paths/1", "admin", staticBody(`{"title":"JavaScript Fundamentals","difficulty_level":1,"prerequisites":["Full-Stack Development"],"steps":["Variables"]}`), http.StatusBadRequest},
+
+	{"anonymous learning progress read", "GET", "/api/v1/users/{id}/learning-paths", "/api/v1/users/1/learning-paths", "", nil, http.StatusUnauthorized},
+	{"owner reads own learning progress", "GET", "/api/v1/users/{id}/learning-paths", "/api/v1/users/1/learning-paths", "owner", nil, http.StatusOK},
+	{"learner reads other learning progress", "GET", "/api/v1/users/{id}/learning-paths", "/api/v1/users/1/learning-paths", "other", nil, http.StatusForbidden},
+	{"admin reads any learning progress", "GET", "/api/v1/users/{id}/learning-paths", "/api/v1/users/1/learning-paths", "admin", nil, http.StatusOK},
+	{"anonymous path recommendation", "GET", "/api/v1/users/{id}/learning-paths/recommended", "/api/v1/users/1/learning-paths/recommended", "", nil, http.StatusUnauthorized},
+	{"owner reads own recommendation", "GET", "/api/v1/users/{id}/learning-paths/recommended", "/api/v1/users/1/learning-paths/recommended", "owner", nil, http.StatusOK},
+	{"learner reads other recommendation", "GET", "/api/v1/users/{id}/learning-paths/recommended", "/api/v1/users/1/learning-paths/recommended", "other", nil, http.StatusForbidden},
+	{"admin reads any recommendation", "GET", "/api/v1/users/{id}/learning-paths/recommended", "/api/v1/users/1/learning-paths/recommended", "admin", nil, http.StatusOK},
+
+	{"anonymous enroll", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/1/learning-paths/3/enroll", "", nil, http.StatusUnauthorized},
+	{"owner enrolls", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/1/learning-paths/3/enroll", "owner", nil, http.StatusCreated},
+	{"learner enrolls other user", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/1/learning-paths/3/enroll", "other", nil, http.StatusForbidden},
+	{"admin enrolls any user", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/1/learning-paths/3/enroll", "admin", nil, http.StatusCreated},
+	{"enroll without prerequisites", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/2/learning-paths/2/enroll", "other", nil, http.StatusConflict},
+	{"enroll twice", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/enroll", "/api/v1/users/1/learning-paths/2/enroll", "owner", nil, http.StatusConflict},
+	{"anonymous advance", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/2/advance", "", nil, http.StatusUnauthorized},
+	{"owner advances", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/2/advance", "owner", nil, http.StatusOK},
+	{"learner advances other user", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/2/advance", "other", nil, http.StatusForbidden},
+	{"admin advances any user", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/2/advance", "admin", nil, http.StatusOK},
+	{"advance without enrolling", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/4/advance", "owner", nil, http.StatusNotFound},
+	{"advance completed path", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/advance", "/api/v1/users/1/learning-paths/1/advance", "owner", nil, http.StatusConflict},
+	{"anonymous path completion", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/complete", "/api/v1/users/1/learning-paths/2/complete", "", nil, http.StatusUnauthorized},
+	{"owner completes with steps left", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/complete", "/api/v1/users/1/learning-paths/2/complete", "owner", nil, http.StatusConflict},
+	{"learner completes other user path", "POST", "/api/v1/users/{id}/learning-paths/{pathId}/complete", "/api/v1/users/1/learning-paths/2/complete", "other", nil, http.StatusForbidden},
+
+	{"anonymous session start", "POST", "/api/v1/sessions/start", "/api/v1/sessions/start", "", nil, http.StatusUnauthorized},
+	{"learner starts session", "POST", "/api/v1/sessions/start", "/api/v1/sessions/start", "other", nil, http.StatusCreated},
+	{"anonymous session event", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/1/events", "", staticBody(`{"type":"retry"}`), http.StatusUnauthorized},
+	{"owner records session event", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/1/events", "owner", staticBody(`{"type":"task_completed"}`), http.StatusOK},
+	{"learner records other session event", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/1/events", "other", staticBody(`{"type":"retry"}`), http.StatusForbidden},
+	{"admin records any session event", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/1/events", "admin", staticBody(`{"type":"idle"}`), http.StatusOK},
+	{"unknown session event", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/1/events", "owner", staticBody(`{"type":"coffee"}`), http.StatusBadRequest},
+	{"missing session", "POST", "/api/v1/sessions/{id}/events", "/api/v1/sessions/99/events", "owner", staticBody(`{"type":"retry"}`), http.StatusNotFound},
+	{"anonymous session end", "POST", "/api/v1/sessions/{id}/end", "/api/v1/sessions/1/end", "", nil, http.StatusUnauthorized},
+	{"owner ends session", "POST", "/api/v1/sessions/{id}/end", "/api/v1/sessions/1/end", "owner", nil, http.StatusOK},
+	{"learner ends other session", "POST", "/api/v1/sessions/{id}/end", "/api/v1/sessions/1/end", "other", nil, http.StatusForbidden},
+
+	{"anonymous projects read", "GET", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "", nil, http.StatusUnauthorized},
+	{"owner reads own projects", "GET", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "owner", nil, http.StatusOK},
+	{"learner reads other projects", "GET", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "other", nil, http.StatusForbidden},
+	{"admin reads any projects", "GET", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "admin", nil, http.StatusOK},
+	{"anonymous project plan", "POST", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "", staticBody(`{"learning_goals":"HTTP clients"}`), http.StatusUnauthorized},
+	{"owner plans project", "POST", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "owner", staticBody(`{"learning_goals":"HTTP clients"}`), http.StatusCreated},
+	{"learner plans project for other user", "POST", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "other", staticBody(`{"learning_goals":"HTTP clients"}`), http.StatusForbidden},
+	{"project plan without goals", "POST", "/api/v1/users/{id}/projects", "/api/v1/users/1/projects", "owner", staticBody(`{"interests":"weather"}`), http.StatusBadRequest},
+	{"anonymous project read", "GET", "/api/v1/users/{id}/projects/{projectId}", "/api/v1/users/1/projects/1", "", nil, http.StatusUnauthorized},
+	{"owner reads own project", "GET", "/api/v1/users/{id}/projects/{projectId}", "/api/v1/users/1/projects/1", "owner", nil, http.StatusOK},
+	{"learner reads other project", "GET", "/api/v1/users/{id}/projects/{projectId}", "/api/v1/users/1/projects/1", "other", nil, http.StatusForbidden},
+	{"project under wrong user", "GET", "/api/v1/users/{id}/projects/{projectId}", "/api/v1/users/2/projects/1", "other", nil, http.StatusNotFound},
+	{"anonymous project status", "PUT", "/api/v1/users/{id}/projects/{projectId}/status", "/api/v1/users/1/projects/1/status", "", staticBody(`{"status":"abandoned"}`), http.StatusUnauthorized},
+	{"owner abandons project", "PUT", "/api/v1/users/{id}/projects/{projectId}/status", "/api/v1/users/1/projects/1/status", "owner", staticBody(`{"status":"abandoned"}`), http.StatusOK},
+	{"learner abandons other project", "PUT", "/api/v1/users/{id}/projects/{projectId}/status", "/api/v1/users/1/projects/1/status", "other", staticBody(`{"status":"abandoned"}`), http.StatusForbidden},
+	{"complete project early", "PUT", "/api/v1/users/{id}/projects/{projectId}/status", "/api/v1/users/1/projects/1/status", "owner", staticBody(`{"status":"completed"}`), http.StatusConflict},
+	{"anonymous milestone", "POST", "/api/v1/users/{id}/projects/{projectId}/milestones/{milestone}/complete", "/api/v1/users/1/projects/1/milestones/2/complete", "", nil, http.StatusUnauthorized},
+	{"owner completes milestone", "POST", "/api/v1/users/{id}/projects/{projectId}/milestones/{milestone}/complete", "/api/v1/users/1/projects/1/milestones/2/complete", "owner", nil, http.StatusOK},
+	{"learner completes other milestone", "POST", "/api/v1/users/{id}/projects/{projectId}/milestones/{milestone}/complete", "/api/v1/users/1/projects/1/milestones/2/complete", "other", nil, http.StatusForbidden},
+	{"milestone completed twice", "POST", "/api/v1/users/{id}/projects/{projectId}/milestones/{milestone}/complete", "/api/v1/users/1/projects/1/milestones/1/complete", "owner", nil, http.StatusConflict},
+	{"missing milestone", "POST", "/api/v1/users/{id}/projects/{projectId}/milestones/{milestone}/complete", "/api/v1/users/1/projects/1/milestones/9/complete", "owner", nil, http.StatusNotFound},
+
+	{"anonymous focus start", "POST", "/api/v1/users/{id}/focus-sessions", "/api/v1/users/1/focus-sessions", "", staticBody(`{"planned_minutes":25}`), http.StatusUnauthorized},
+	{"owner starts focus session", "POST", "/api/v1/users/{id}/focus-sessions", "/api/v1/users/1/focus-sessions", "owner", staticBody(`{"planned_minutes":25,"quest_id":2}`), http.StatusCreated},
+	{"learner starts focus session for other user", "POST", "/api/v1/users/{id}/focus-sessions", "/api/v1/users/1/focus-sessions", "other", staticBody(`{"planned_minutes":25}`), http.StatusForbidden},
+	{"focus session too long", "POST", "/api/v1/users/{id}/focus-sessions", "/api/v1/users/1/focus-sessions", "owner", staticBody(`{"planned_minutes":600}`), http.StatusBadRequest},
+	{"focus session on non-focus quest", "POST", "/api/v1/users/{id}/focus-sessions", "/api/v1/users/1/focus-sessions", "owner", staticBody(`{"quest_id":1}`), http.StatusBadRequest},
+	{"anonymous focus session read", "GET", "/api/v1/users/{id}/focus-sessions/{sessionId}", "/api/v1/users/1/focus-sessions/1", "", nil, http.StatusUnauthorized},
+	{"owner reads own focus session", "GET", "/api/v1/users/{id}/focus-sessions/{sessionId}", "/api/v1/users/1/focus-sessions/1", "owner", nil, http.StatusOK},
+	{"learner reads other focus session", "GET", "/api/v1/users/{id}/focus-sessions/{sessionId}", "/api/v1/users/1/focus-sessions/1", "other", nil, http.StatusForbidden},
+	{"focus session under wrong user", "GET", "/api/v1/users/{id}/focus-sessions/{sessionId}", "/api/v1/users/2/focus-sessions/1", "other", nil, http.StatusNotFound},
+	{"anonymous focus heartbeat", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/heartbeat", "/api/v1/users/1/focus-sessions/1/heartbeat", "", nil, http.StatusUnauthorized},
+	{"owner sends focus heartbeat", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/heartbeat", "/api/v1/users/1/focus-sessions/1/heartbeat", "owner", nil, http.StatusOK},
+	{"learner sends other focus heartbeat", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/heartbeat", "/api/v1/users/1/focus-sessions/1/heartbeat", "other", nil, http.StatusForbidden},
+	{"anonymous focus pause", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/pause", "/api/v1/users/1/focus-sessions/1/pause", "", nil, http.StatusUnauthorized},
+	{"owner pauses focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/pause", "/api/v1/users/1/focus-sessions/1/pause", "owner", nil, http.StatusOK},
+	{"learner pauses other focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/pause", "/api/v1/users/1/focus-sessions/1/pause", "other", nil, http.StatusForbidden},
+	{"anonymous focus resume", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/resume", "/api/v1/users/1/focus-sessions/1/resume", "", nil, http.StatusUnauthorized},
+	{"resume running focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/resume", "/api/v1/users/1/focus-sessions/1/resume", "owner", nil, http.StatusConflict},
+	{"learner resumes other focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/resume", "/api/v1/users/1/focus-sessions/1/resume", "other", nil, http.StatusForbidden},
+	{"anonymous focus complete", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/complete", "/api/v1/users/1/focus-sessions/1/complete", "", nil, http.StatusUnauthorized},
+	{"owner completes focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/complete", "/api/v1/users/1/focus-sessions/1/complete", "owner", nil, http.StatusOK},
+	{"learner completes other focus session", "POST", "/api/v1/users/{id}/focus-sessions/{sessionId}/complete", "/api/v1/users/1/focus-sessions/1/complete", "other", nil, http.StatusForbidden},
+
+	{"anonymous quest generation", "POST", "/api/v1/users/{id}/quests/generate", "/api/v1/users/1/quests/generate", "", staticBody(`{}`), http.StatusUnauthorized},
+	{"owner generates quest", "POST", "/api/v1/users/{id}/quests/generate", "/api/v1/users/1/quests/generate", "owner", staticBody(`{"preferences":["test"]}`), http.StatusCreated},
+	{"learner generates quest for other user", "POST", "/api/v1/users/{id}/quests/generate", "/api/v1/users/1/quests/generate", "other", staticBody(`{}`), http.StatusForbidden},
+	{"admin generates quest for any user", "POST", "/api/v1/users/{id}/quests/generate", "/api/v1/users/1/quests/generate", "admin", staticBody(`{}`), http.StatusCreated},
+	{"quest generation unknown type", "POST", "/api/v1/users/{id}/quests/generate", "/api/v1/users/1/quests/generate", "owner", staticBody(`{"preferences":["poetry"]}`), http.StatusBadRequest},
+
+	{"anonymous ai usage", "GET", "/api/v1/admin/ai-usage", "/api/v1/admin/ai-usage", "", nil, http.StatusUnauthorized},
+	{"owner reads ai usage", "GET", "/api/v1/admin/ai-usage", "/api/v1/admin/ai-usage", "owner", nil, http.StatusForbidden},
+	{"learner reads ai usage", "GET", "/api/v1/admin/ai-usage", "/api/v1/admin/ai-usage", "other", nil, http.StatusForbidden},
+	{"admin reads ai usage", "GET", "/api/v1/admin/ai-usage", "/api/v1/admin/ai-usage?from=2026-10-01&to=2026-10-31", "admin", nil, http.StatusOK},
+	{"ai usage bad date", "GET", "/api/v1/admin/ai-usage", "/api/v1/admin/ai-usage?from=october", "admin", nil, http.StatusBadRequest},
+}
+
+func TestRoutePolicy(t *testing.T) {
+	for _, tc := range routeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newTestFixture(t)
+			body := ""
+			if tc.body != nil {
+				body = tc.body(f)
+			}
+
+			rec := f.do(tc.method, tc.path, tc.caller, body)
+			if rec.Code != tc.want {
+				t.Fatalf("%s %s as %q: status = %d, want %d (body %q)", tc.method, tc.path, tc.caller, rec.Code, tc.want, rec.Body.String())
+			}
+
+			// Policy and middleware rejections share the JSON error body
+			if tc.want == http.StatusForbidden || rec.Header().Get("WWW-Authenticate") != "" {
+				var errBody ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&errBody); err != nil {
+					t.Fatalf("error body is not JSON: %v", err)
+				}
+				if errBody.Error == "" || errBody.Message == "" {
+					t.Fatalf("error body = %+v, want error and message", errBody)
+				}
+			}
+		})
+	}
+}
+
+// TestRoutePolicyCoversEveryRoute fails when a route is registered without a policy case
+func TestRoutePolicyCoversEveryRoute(t *testing.T) {
+	covered := map[string]bool{}
+	for _, tc := range routeCases {
+		covered[tc.method+" "+tc.route] = true
+	}
+
+	f := newTestFixture(t)
+	err := f.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // path prefixes and subrouters
+		}
+		for _, method := range methods {
+			if !covered[method+" "+template] {
+				t.Errorf("route %s %s has no policy test case", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+func TestPolicyAuthorizeUser(t *testing.T) {
+	policy := NewPolicy(nil, nil, nil)
+	tests := []struct {
+		name      string
+		principal Principal
+		userID    int
+		wantErr   error
+	}{
+		{"owner", Principal{UserID: 1, Role: RoleLearner}, 1, nil},
+		{"other learner", Principal{UserID: 2, Role: RoleLearner}, 1, ErrForbidden},
+		{"admin", Principal{UserID: 3, Role: RoleAdmin}, 1, nil},
+		{"no role", Principal{UserID: 2}, 1, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.AuthorizeUser(tt.principal, tt.userID); err != tt.wantErr {
+				t.Fatalf("AuthorizeUser() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,1499 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DatabaseConfig holds PostgreSQL connection settings
+type DatabaseConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+}
+
+// LoadDatabaseConfig reads the DB_* environment variables used by docker-compose.
+// It returns false when DB_HOST is not set so callers can fall back to in-memory storage.
+func LoadDatabaseConfig() (DatabaseConfig, bool) {
+	cfg := DatabaseConfig{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     getEnv("DB_PORT", "5432"),
+		User:     getEnv("DB_USER", "buddy_user"),
+		Password: os.Getenv("DB_PASSWORD"),
+		Name:     getEnv("DB_NAME", "learning_buddy"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	}
+	return cfg, cfg.Host != ""
+}
+
+// DSN returns the lib/pq connection string for the config
+func (c DatabaseConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s", c.Host, c.Port, c.User, c.Name, c.SSLMode)
+	if c.Password != "" {
+		dsn += fmt.Sprintf(" password=%s", c.Password)
+	}
+	return dsn
+}
+
+// OpenDatabase connects to PostgreSQL and verifies the connection
+func OpenDatabase(ctx context.Context, cfg DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// NewPostgresRepositories creates repositories backed by the schema in db/init.sql
+func NewPostgresRepositories(db *sql.DB) *Repositories {
+	return &Repositories{
+		Users:         &postgresUserRepository{db: db},
+		Quests:        &postgresQuestRepository{db: db},
+		Badges:        &postgresBadgeRepository{db: db},
+		Conversations: &postgresConversationRepository{db: db},
+		RefreshTokens: &postgresRefreshTokenRepository{db: db},
+		XP:            &postgresXPRepository{db: db},
+		Activity:      &postgresActivityRepository{db: db},
+		Streaks:       &postgresStreakRepository{db: db},
+		Usage:         &postgresUsageRepository{db: db},
+		Moods:         &postgresMoodLogRepository{db: db},
+		Personalities: &postgresPersonalityRepository{db: db},
+		LearningPaths: &postgresLearningPathRepository{db: db},
+		Projects:      &postgresProjectRepository{db: db},
+		Sessions:      &postgresUserSessionRepository{db: db},
+		FocusSessions: &postgresFocusSessionRepository{db: db},
+	}
+}
+
+// postgresUserRepository is a UserRepository backed by the users table
+type postgresUserRepository struct {
+	db *sql.DB
+}
+
+const userColumns = `id, username, email, password_hash, role, plan, level, total_xp, current_streak, max_streak, buddy_mood, COALESCE(timezone, 'UTC'),
+	COALESCE((SELECT personality_key FROM buddy_personalities WHERE id = users.buddy_personality_id), ''), buddy_personality_locked,
+	COALESCE(learning_style, 'balanced'), COALESCE(preferred_difficulty, 2)`
+
+// scanUser scans a row selected with userColumns
+func scanUser(row interface{ Scan(...interface{}) error }, user *User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role, &user.Plan, &user.Level, &user.XP, &user.Streak, &user.MaxStreak, &user.Mood, &user.Timezone,
+		&user.BuddyPersonality, &user.PersonalityLocked, &user.LearningStyle, &user.PreferredDifficulty)
+}
+
+func (r *postgresUserRepository) getBy(ctx context.Context, column string, value interface{}) (*User, error) {
+	var user User
+	err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+column+` = $1`, value), &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user by %s: %w", column, err)
+	}
+	return &user, nil
+}
+
+func (r *postgresUserRepository) GetByID(ctx context.Context, id int) (*User, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+func (r *postgresUserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return r.getBy(ctx, "username", username)
+}
+
+func (r *postgresUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.getBy(ctx, "LOWER(email)", strings.ToLower(email))
+}
+
+func (r *postgresUserRepository) Create(ctx context.Context, user *User) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO users (username, email, password_hash, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, plan, level, total_xp, current_streak, max_streak, buddy_mood, COALESCE(timezone, 'UTC'),
+		          COALESCE((SELECT personality_key FROM buddy_personalities WHERE id = buddy_personality_id), ''), buddy_personality_locked,
+		          COALESCE(learning_style, 'balanced'), COALESCE(preferred_difficulty, 2)`,
+		user.Username, user.Email, user.PasswordHash, user.Role).
+		Scan(&user.ID, &user.Plan, &user.Level, &user.XP, &user.Streak, &user.MaxStreak, &user.Mood, &user.Timezone,
+			&user.BuddyPersonality, &user.PersonalityLocked, &user.LearningStyle, &user.PreferredDifficulty)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) SetBuddyPersonality(ctx context.Context, userID int, personality string, locked bool) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET buddy_personality_id = (
+		        SELECT id FROM buddy_personalities
+		        WHERE personality_key = $2 AND (created_by IS NULL OR created_by = $1 OR shared)
+		        ORDER BY created_by = $1 DESC NULLS LAST
+		        LIMIT 1),
+		    buddy_personality_locked = $3
+		WHERE id = $1`, userID, personality, locked)
+	if err != nil {
+		return fmt.Errorf("failed to set buddy personality of user %d: %w", userID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// postgresQuestRepository is a QuestRepository backed by the quests table
+type postgresQuestRepository struct {
+	db *sql.DB
+}
+
+const questColumns = `id, user_id, title, COALESCE(description, ''), quest_type, completed_tasks, total_tasks, xp_reward,
+	difficulty, status, due_date, completed_at`
+
+func scanQuest(row interface{ Scan(...interface{}) error }, quest *Quest) error {
+	var dueDate, completedAt sql.NullTime
+	err := row.Scan(&quest.ID, &quest.UserID, &quest.Title, &quest.Description, &quest.Type, &quest.Progress, &quest.Total, &quest.XP,
+		&quest.Difficulty, &quest.Status, &dueDate, &completedAt)
+	if err != nil {
+		return err
+	}
+	quest.DueDate = nullTimePtr(dueDate)
+	quest.CompletedAt = nullTimePtr(completedAt)
+	return nil
+}
+
+// nullTimePtr converts a nullable timestamp column to a *time.Time
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (r *postgresQuestRepository) GetByID(ctx context.Context, id int) (*Quest, error) {
+	var quest Quest
+	err := scanQuest(r.db.QueryRowContext(ctx, `SELECT `+questColumns+` FROM quests WHERE id = $1`, id), &quest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quest %d: %w", id, err)
+	}
+
+	tasks, err := r.listTasks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	quest.Tasks = tasks
+	return &quest, nil
+}
+
+func (r *postgresQuestRepository) listTasks(ctx context.Context, questID int) ([]QuestTask, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, quest_id, title, COALESCE(description, ''), order_index, is_completed, completed_at
+		FROM quest_tasks WHERE quest_id = $1 ORDER BY order_index, id`, questID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks for quest %d: %w", questID, err)
+	}
+	defer rows.Close()
+
+	var tasks []QuestTask
+	for rows.Next() {
+		var task QuestTask
+		var completedAt sql.NullTime
+		if err := rows.Scan(&task.ID, &task.QuestID, &task.Title, &task.Description, &task.OrderIndex, &task.Completed, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quest task: %w", err)
+		}
+		task.CompletedAt = nullTimePtr(completedAt)
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *postgresQuestRepository) listQuests(ctx context.Context, query string, args ...interface{}) ([]Quest, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quests: %w", err)
+	}
+	defer rows.Close()
+
+	var quests []Quest
+	for rows.Next() {
+		var quest Quest
+		if err := scanQuest(rows, &quest); err != nil {
+			return nil, fmt.Errorf("failed to scan quest: %w", err)
+		}
+		quests = append(quests, quest)
+	}
+	return quests, rows.Err()
+}
+
+func (r *postgresQuestRepository) ListByUser(ctx context.Context, userID int) ([]Quest, error) {
+	return r.listQuests(ctx, `SELECT `+questColumns+` FROM quests WHERE user_id = $1 ORDER BY id`, userID)
+}
+
+func (r *postgresQuestRepository) ListOverdue(ctx context.Context, now time.Time) ([]Quest, error) {
+	return r.listQuests(ctx, `
+		SELECT `+questColumns+` FROM quests
+		WHERE status IN ('active', 'paused') AND due_date IS NOT NULL AND due_date < $1
+		ORDER BY due_date`, now)
+}
+
+func (r *postgresQuestRepository) Create(ctx context.Context, quest *Quest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO quests (user_id, title, description, quest_type, difficulty, total_tasks, completed_tasks, xp_reward, status, due_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		quest.UserID, quest.Title, quest.Description, quest.Type, quest.Difficulty, quest.Total, quest.Progress, quest.XP, quest.Status, quest.DueDate).
+		Scan(&quest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create quest: %w", err)
+	}
+	for i := range quest.Tasks {
+		task := &quest.Tasks[i]
+		task.QuestID = quest.ID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO quest_tasks (quest_id, title, description, order_index)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			task.QuestID, task.Title, task.Description, task.OrderIndex).
+			Scan(&task.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create quest task: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresQuestRepository) Update(ctx context.Context, quest *Quest) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE quests SET completed_tasks = $2, status = $3, due_date = $4, completed_at = $5
+		WHERE id = $1`,
+		quest.ID, quest.Progress, quest.Status, quest.DueDate, quest.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update quest %d: %w", quest.ID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresQuestRepository) AddProgress(ctx context.Context, questID, amount int, at time.Time) (*Quest, error) {
+	// SET expressions read the row as it was before the update
+	var quest Quest
+	err := scanQuest(r.db.QueryRowContext(ctx, `
+		UPDATE quests SET
+			completed_tasks = LEAST(completed_tasks + $2, total_tasks),
+			status = CASE WHEN total_tasks > 0 AND completed_tasks + $2 >= total_tasks THEN 'completed' ELSE status END,
+			completed_at = CASE WHEN total_tasks > 0 AND completed_tasks + $2 >= total_tasks THEN $3 ELSE completed_at END
+		WHERE id = $1 AND status = 'active'
+		RETURNING `+questColumns, questID, amount, at), &quest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add progress to quest %d: %w", questID, err)
+	}
+
+	tasks, err := r.listTasks(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	quest.Tasks = tasks
+	return &quest, nil
+}
+
+func (r *postgresQuestRepository) CompleteTask(ctx context.Context, questID, taskID int, at time.Time) (*QuestTask, error) {
+	var task QuestTask
+	var completedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE quest_tasks SET is_completed = TRUE, completed_at = $3
+		WHERE id = $2 AND quest_id = $1 AND NOT is_completed
+		RETURNING id, quest_id, title, COALESCE(description, ''), order_index, is_completed, completed_at`,
+		questID, taskID, at).
+		Scan(&task.ID, &task.QuestID, &task.Title, &task.Description, &task.OrderIndex, &task.Completed, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM quest_tasks WHERE id = $2 AND quest_id = $1)`, questID, taskID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to load quest task %d: %w", taskID, err)
+		}
+		if exists {
+			return nil, ErrTaskAlreadyCompleted
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete quest task %d: %w", taskID, err)
+	}
+	task.CompletedAt = nullTimePtr(completedAt)
+	return &task, nil
+}
+
+// postgresBadgeRepository is a BadgeRepository backed by the badges and user_badges tables
+type postgresBadgeRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresBadgeRepository) ListByUser(ctx context.Context, userID int) ([]Badge, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT b.id, b.name, COALESCE(b.description, ''), COALESCE(b.icon, ''), ub.id IS NOT NULL
+		FROM badges b
+		LEFT JOIN user_badges ub ON ub.badge_id = b.id AND ub.user_id = $1
+		ORDER BY b.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list badges for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var badges []Badge
+	for rows.Next() {
+		badge := Badge{UserID: userID}
+		if err := rows.Scan(&badge.ID, &badge.Name, &badge.Description, &badge.Icon, &badge.Earned); err != nil {
+			return nil, fmt.Errorf("failed to scan badge: %w", err)
+		}
+		badges = append(badges, badge)
+	}
+	return badges, rows.Err()
+}
+
+func (r *postgresBadgeRepository) ListDefinitions(ctx context.Context) ([]BadgeDefinition, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, COALESCE(description, ''), COALESCE(icon, ''), COALESCE(category, ''),
+		       COALESCE(requirements, '{}'::jsonb), COALESCE(xp_reward, 0), COALESCE(rarity, 'common')
+		FROM badges ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list badge definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var definitions []BadgeDefinition
+	for rows.Next() {
+		var d BadgeDefinition
+		var requirements []byte
+		if err := rows.Scan(&d.ID, &d.Name, &d.Description, &d.Icon, &d.Category, &requirements, &d.XPReward, &d.Rarity); err != nil {
+			return nil, fmt.Errorf("failed to scan badge definition: %w", err)
+		}
+		d.Requirements = json.RawMessage(requirements)
+		definitions = append(definitions, d)
+	}
+	return definitions, rows.Err()
+}
+
+func (r *postgresBadgeRepository) Award(ctx context.Context, userID, badgeID int, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_badges (user_id, badge_id, earned_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, badge_id) DO NOTHING`, userID, badgeID, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to award badge %d to user %d: %w", badgeID, userID, err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to award badge %d to user %d: %w", badgeID, userID, err)
+	}
+	return inserted == 1, nil
+}
+
+// postgresConversationRepository is a ConversationRepository backed by buddy_conversations
+type postgresConversationRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresConversationRepository) Create(ctx context.Context, message *BuddyMessage) error {
+	var contextJSON []byte
+	if message.Context != nil {
+		var err error
+		if contextJSON, err = json.Marshal(message.Context); err != nil {
+			return fmt.Errorf("failed to encode conversation context: %w", err)
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO buddy_conversations (user_id, thread_id, user_message, buddy_response, buddy_mood, context, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		message.UserID, nullableID(message.ThreadID), message.Message, message.Response, message.Mood, contextJSON, message.Timestamp).
+		Scan(&message.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	if message.ThreadID != 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE buddy_threads SET updated_at = $2 WHERE id = $1`, message.ThreadID, message.Timestamp); err != nil {
+			return fmt.Errorf("failed to touch thread %d: %w", message.ThreadID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresConversationRepository) ListByUser(ctx context.Context, userID int) ([]BuddyMessage, error) {
+	return r.listMessages(ctx, "user_id", userID)
+}
+
+func (r *postgresConversationRepository) ListThreadMessages(ctx context.Context, threadID int) ([]BuddyMessage, error) {
+	return r.listMessages(ctx, "thread_id", threadID)
+}
+
+// listMessages returns the turns whose column equals id, oldest first
+func (r *postgresConversationRepository) listMessages(ctx context.Context, column string, id int) ([]BuddyMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, thread_id, user_message, buddy_response, buddy_mood, context, created_at
+		FROM buddy_conversations WHERE `+column+` = $1 ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations by %s %d: %w", column, id, err)
+	}
+	defer rows.Close()
+
+	messages := []BuddyMessage{}
+	for rows.Next() {
+		var message BuddyMessage
+		var threadID sql.NullInt64
+		var contextJSON []byte
+		if err := rows.Scan(&message.ID, &message.UserID, &threadID, &message.Message, &message.Response, &message.Mood, &contextJSON, &message.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		message.ThreadID = int(threadID.Int64)
+		if len(contextJSON) > 0 {
+			if err := json.Unmarshal(contextJSON, &message.Context); err != nil {
+				return nil, fmt.Errorf("failed to decode context of conversation %d: %w", message.ID, err)
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (r *postgresConversationRepository) CreateThread(ctx context.Context, thread *ConversationThread) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO buddy_threads (user_id, title, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		thread.UserID, thread.Title, thread.CreatedAt, thread.UpdatedAt).
+		Scan(&thread.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create thread: %w", err)
+	}
+	return nil
+}
+
+const threadColumns = `id, user_id, title, summary, summarized_through, created_at, updated_at`
+
+// scanThread scans a row selected with threadColumns
+func scanThread(row interface{ Scan(...interface{}) error }, thread *ConversationThread) error {
+	return row.Scan(&thread.ID, &thread.UserID, &thread.Title, &thread.Summary, &thread.SummarizedThrough, &thread.CreatedAt, &thread.UpdatedAt)
+}
+
+// nullableID stores 0 as NULL in optional foreign key columns
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (r *postgresConversationRepository) GetThread(ctx context.Context, threadID int) (*ConversationThread, error) {
+	var thread ConversationThread
+	err := scanThread(r.db.QueryRowContext(ctx, `SELECT `+threadColumns+` FROM buddy_threads WHERE id = $1`, threadID), &thread)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread %d: %w", threadID, err)
+	}
+	return &thread, nil
+}
+
+func (r *postgresConversationRepository) ListThreads(ctx context.Context, userID int) ([]ConversationThread, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+threadColumns+` FROM buddy_threads
+		WHERE user_id = $1 ORDER BY updated_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	threads := []ConversationThread{}
+	for rows.Next() {
+		var thread ConversationThread
+		if err := scanThread(rows, &thread); err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		threads = append(threads, thread)
+	}
+	return threads, rows.Err()
+}
+
+func (r *postgresConversationRepository) UpdateSummary(ctx context.Context, threadID int, summary string, summarizedThrough int) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE buddy_threads SET summary = $2, summarized_through = $3 WHERE id = $1`,
+		threadID, summary, summarizedThrough)
+	if err != nil {
+		return fmt.Errorf("failed to update summary of thread %d: %w", threadID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresConversationRepository) DeleteThread(ctx context.Context, threadID int) error {
+	// Turns go with the thread through ON DELETE CASCADE
+	result, err := r.db.ExecContext(ctx, `DELETE FROM buddy_threads WHERE id = $1`, threadID)
+	if err != nil {
+		return fmt.Errorf("failed to delete thread %d: %w", threadID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresRefreshTokenRepository is a RefreshTokenRepository backed by refresh_tokens
+type postgresRefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresRefreshTokenRepository) Create(ctx context.Context, token RefreshToken) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, expires_at) VALUES ($1, $2, $3)`,
+		token.ID, token.UserID, token.ExpiresAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresRefreshTokenRepository) Revoke(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresXPRepository is an XPRepository backed by xp_transactions and users
+type postgresXPRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresXPRepository) Record(ctx context.Context, xpTx *XPTransaction, levelFor func(totalXP int) int) (*User, int, error) {
+	return r.record(ctx, xpTx, nil, levelFor)
+}
+
+func (r *postgresXPRepository) RecordCapped(ctx context.Context, xpTx *XPTransaction, since time.Time, limit int, levelFor func(totalXP int) int) (*User, int, error) {
+	return r.record(ctx, xpTx, func(tx *sql.Tx) error {
+		var credited int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(amount), 0) FROM xp_transactions
+			WHERE user_id = $1 AND source_type = $2 AND created_at >= $3`,
+			xpTx.UserID, xpTx.SourceType, since).Scan(&credited)
+		if err != nil {
+			return fmt.Errorf("failed to sum %s XP for user %d: %w", xpTx.SourceType, xpTx.UserID, err)
+		}
+		if credited >= limit {
+			return ErrXPCapReached
+		}
+		if xpTx.Amount > limit-credited {
+			xpTx.Amount = limit - credited
+		}
+		return nil
+	}, levelFor)
+}
+
+// record inserts xpTx and updates the user's XP in one database transaction.
+// check, when set, runs once the user's row is locked and may adjust xpTx.
+func (r *postgresXPRepository) record(ctx context.Context, xpTx *XPTransaction, check func(tx *sql.Tx) error, levelFor func(totalXP int) int) (*User, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin XP transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user User
+	err = scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1 FOR UPDATE`, xpTx.UserID), &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to lock user %d: %w", xpTx.UserID, err)
+	}
+	if check != nil {
+		if err := check(tx); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO xp_transactions (user_id, amount, source_type, source_id, description)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		xpTx.UserID, xpTx.Amount, xpTx.SourceType, xpTx.SourceID, xpTx.Description).
+		Scan(&xpTx.ID, &xpTx.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, 0, ErrDuplicate
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to insert XP transaction: %w", err)
+	}
+
+	previousLevel := user.Level
+	user.XP += xpTx.Amount
+	if user.XP < 0 {
+		user.XP = 0
+	}
+	user.Level = levelFor(user.XP)
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET total_xp = $2, level = $3 WHERE id = $1`, user.ID, user.XP, user.Level); err != nil {
+		return nil, 0, fmt.Errorf("failed to update user %d XP: %w", user.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit XP transaction: %w", err)
+	}
+	return &user, previousLevel, nil
+}
+
+func (r *postgresXPRepository) CreditedSources(ctx context.Context, userID int, sourceType string) (map[int]bool, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT source_id FROM xp_transactions
+		WHERE user_id = $1 AND source_type = $2 AND source_id IS NOT NULL`, userID, sourceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s credits for user %d: %w", sourceType, userID, err)
+	}
+	defer rows.Close()
+
+	credited := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan %s credit: %w", sourceType, err)
+		}
+		credited[id] = true
+	}
+	return credited, rows.Err()
+}
+
+func (r *postgresXPRepository) ListByUser(ctx context.Context, userID, limit, offset int) ([]XPTransaction, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM xp_transactions WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count XP transactions for user %d: %w", userID, err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, amount, source_type, source_id, COALESCE(description, ''), created_at
+		FROM xp_transactions WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list XP transactions for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	transactions := []XPTransaction{}
+	for rows.Next() {
+		var t XPTransaction
+		var sourceID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Amount, &t.SourceType, &sourceID, &t.Description, &t.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan XP transaction: %w", err)
+		}
+		if sourceID.Valid {
+			t.SourceID = intPtr(int(sourceID.Int64))
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, total, rows.Err()
+}
+
+// postgresActivityRepository is an ActivityRepository backed by user_activity
+type postgresActivityRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresActivityRepository) Record(ctx context.Context, userID int, stat string, amount int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_activity (user_id, stat, amount, occurred_at)
+		VALUES ($1, $2, $3, $4)`, userID, stat, amount, at)
+	if err != nil {
+		return fmt.Errorf("failed to record %s activity for user %d: %w", stat, userID, err)
+	}
+	return nil
+}
+
+func (r *postgresActivityRepository) Totals(ctx context.Context, userID int, since time.Time) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT stat, SUM(amount)
+		FROM user_activity
+		WHERE user_id = $1 AND occurred_at >= $2
+		GROUP BY stat`, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total activity for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var stat string
+		var total int
+		if err := rows.Scan(&stat, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan activity total: %w", err)
+		}
+		totals[stat] = total
+	}
+	return totals, rows.Err()
+}
+
+// postgresStreakRepository is a StreakRepository backed by the users table
+type postgresStreakRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresStreakRepository) Get(ctx context.Context, userID int) (*StreakState, error) {
+	state := &StreakState{UserID: userID}
+	var lastActivity sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT current_streak, max_streak, last_activity, streak_freeze_tokens, COALESCE(timezone, 'UTC')
+		FROM users WHERE id = $1`, userID).
+		Scan(&state.Current, &state.Max, &lastActivity, &state.FreezeTokens, &state.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load streak for user %d: %w", userID, err)
+	}
+	state.LastActivity = nullTimePtr(lastActivity)
+	return state, nil
+}
+
+func (r *postgresStreakRepository) Save(ctx context.Context, state *StreakState) error {
+	var lastActivity interface{}
+	if state.LastActivity != nil {
+		lastActivity = state.LastActivity.UTC()
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET current_streak = $2, max_streak = $3, last_activity = COALESCE($4, last_activity), streak_freeze_tokens = $5
+		WHERE id = $1`,
+		state.UserID, state.Current, state.Max, lastActivity, state.FreezeTokens)
+	if err != nil {
+		return fmt.Errorf("failed to save streak for user %d: %w", state.UserID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresUsageRepository is a UsageRepository backed by the ai_usage table
+type postgresUsageRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresUsageRepository) Record(ctx context.Context, usage *AIUsage) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO ai_usage (user_id, template_id, provider, model, prompt_tokens,
+		                      completion_tokens, total_tokens, cost_usd, degraded, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		usage.UserID, usage.TemplateID, usage.Provider, usage.Model, usage.PromptTokens,
+		usage.CompletionTokens, usage.TotalTokens, usage.CostUSD, usage.Degraded, usage.CreatedAt).
+		Scan(&usage.ID)
+}
+
+func (r *postgresUsageRepository) TotalTokens(ctx context.Context, userID int, since time.Time) (int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(total_tokens), 0) FROM ai_usage
+		WHERE user_id = $1 AND created_at >= $2`, userID, since).Scan(&total)
+	return total, err
+}
+
+func (r *postgresUsageRepository) Summarize(ctx context.Context, from, to time.Time) ([]UsageSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, template_id, model, COUNT(*), COALESCE(SUM(total_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM ai_usage
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY user_id, template_id, model
+		ORDER BY user_id, template_id, model`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []UsageSummary{}
+	for rows.Next() {
+		var summary UsageSummary
+		if err := rows.Scan(&summary.UserID, &summary.TemplateID, &summary.Model, &summary.Calls, &summary.TotalTokens, &summary.CostUSD); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// postgresMoodLogRepository is a MoodLogRepository backed by the mood_detection_logs table
+type postgresMoodLogRepository struct {
+	db *sql.DB
+}
+
+func (r *postgresMoodLogRepository) Record(ctx context.Context, entry *MoodDetectionLog) error {
+	factors, err := json.Marshal(entry.Factors)
+	if err != nil {
+		return fmt.Errorf("encode mood factors: %w", err)
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO mood_detection_logs (user_id, session_id, detected_mood, confidence_score, factors)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		entry.UserID, entry.SessionID, entry.Mood, entry.Confidence, factors).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// postgresPersonalityRepository is a PersonalityRepository backed by the
+// buddy_personalities rows that have a creator
+type postgresPersonalityRepository struct {
+	db *sql.DB
+}
+
+const personalityColumns = `id, personality_key, created_by, shared, name, COALESCE(description, ''), traits, response_style, prompt_modifiers,
+	unlock_level, COALESCE(color_theme, ''), created_at`
+
+// scanPersonality scans a row selected with personalityColumns
+func scanPersonality(row interface{ Scan(...interface{}) error }, personality *CustomPersonality) error {
+	var traits, responseStyle, modifiers []byte
+	err := row.Scan(&personality.ID, &personality.Key, &personality.OwnerID, &personality.Shared, &personality.Name, &personality.Description,
+		&traits, &responseStyle, &modifiers, &personality.UnlockLevel, &personality.ColorTheme, &personality.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		data []byte
+		dest interface{}
+	}{{traits, &personality.Traits}, {responseStyle, &personality.ResponseStyle}, {modifiers, &personality.PromptModifiers}} {
+		if len(field.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(field.data, field.dest); err != nil {
+			return fmt.Errorf("decode personality %s: %w", personality.Key, err)
+		}
+	}
+	return nil
+}
+
+func (r *postgresPersonalityRepository) Create(ctx context.Context, personality *CustomPersonality) error {
+	traits, err := json.Marshal(personality.Traits)
+	if err != nil {
+		return fmt.Errorf("encode traits: %w", err)
+	}
+	responseStyle, err := json.Marshal(personality.ResponseStyle)
+	if err != nil {
+		return fmt.Errorf("encode response style: %w", err)
+	}
+	modifiers, err := json.Marshal(personality.PromptModifiers)
+	if err != nil {
+		return fmt.Errorf("encode prompt modifiers: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO buddy_personalities (personality_key, created_by, shared, name, description, traits, response_style, prompt_modifiers, unlock_level, color_theme)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, NULLIF($10, ''))
+		RETURNING id, created_at`,
+		personality.Key, personality.OwnerID, personality.Shared, personality.Name, personality.Description,
+		traits, responseStyle, modifiers, personality.UnlockLevel, personality.ColorTheme).
+		Scan(&personality.ID, &personality.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *postgresPersonalityRepository) ListVisible(ctx context.Context, userID int) ([]CustomPersonality, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+personalityColumns+`
+		FROM buddy_personalities
+		WHERE created_by IS NOT NULL AND (shared OR created_by = $1)
+		ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	personalities := []CustomPersonality{}
+	for rows.Next() {
+		var personality CustomPersonality
+		if err := scanPersonality(rows, &personality); err != nil {
+			return nil, err
+		}
+		personalities = append(personalities, personality)
+	}
+	return personalities, rows.Err()
+}
+
+func (r *postgresPersonalityRepository) Get(ctx context.Context, userID int, key string) (*CustomPersonality, error) {
+	var personality CustomPersonality
+	err := scanPersonality(r.db.QueryRowContext(ctx, `
+		SELECT `+personalityColumns+`
+		FROM buddy_personalities
+		WHERE created_by IS NOT NULL AND personality_key = $2 AND (shared OR created_by = $1)
+		ORDER BY created_by = $1 DESC, id
+		LIMIT 1`, userID, key), &personality)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &personality, nil
+}
+
+// Delete relies on users.buddy_personality_id being ON DELETE SET NULL
+func (r *postgresPersonalityRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM buddy_personalities WHERE id = $1 AND created_by IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresLearningPathRepository is a LearningPathRepository backed by the
+// learning_paths and user_learning_progress tables
+type postgresLearningPathRepository struct {
+	db *sql.DB
+}
+
+const learningPathColumns = `id, title, COALESCE(description, ''), COALESCE(difficulty_level, 1), COALESCE(estimated_hours, 0),
+	COALESCE(prerequisites, '[]'), COALESCE(tags, '[]'), steps, COALESCE(is_active, TRUE), created_at`
+
+// scanLearningPath scans a row selected with learningPathColumns
+func scanLearningPath(row interface{ Scan(...interface{}) error }, path *LearningPath) error {
+	var prerequisites, tags, steps []byte
+	err := row.Scan(&path.ID, &path.Title, &path.Description, &path.Difficulty, &path.EstimatedHours,
+		&prerequisites, &tags, &steps, &path.Active, &path.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		data []byte
+		dest *[]string
+	}{{prerequisites, &path.Prerequisites}, {tags, &path.Tags}, {steps, &path.Steps}} {
+		if err := json.Unmarshal(field.data, field.dest); err != nil {
+			return fmt.Errorf("decode learning path %d: %w", path.ID, err)
+		}
+	}
+	return nil
+}
+
+// learningPathJSON encodes a path's prerequisites, tags and steps
+func learningPathJSON(path *LearningPath) (prerequisites, tags, steps []byte, err error) {
+	if prerequisites, err = json.Marshal(path.Prerequisites); err != nil {
+		return nil, nil, nil, err
+	}
+	if tags, err = json.Marshal(path.Tags); err != nil {
+		return nil, nil, nil, err
+	}
+	if steps, err = json.Marshal(path.Steps); err != nil {
+		return nil, nil, nil, err
+	}
+	return prerequisites, tags, steps, nil
+}
+
+func (r *postgresLearningPathRepository) List(ctx context.Context) ([]LearningPath, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+learningPathColumns+` FROM learning_paths ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []LearningPath{}
+	for rows.Next() {
+		var path LearningPath
+		if err := scanLearningPath(rows, &path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+func (r *postgresLearningPathRepository) GetByID(ctx context.Context, id int) (*LearningPath, error) {
+	var path LearningPath
+	err := scanLearningPath(r.db.QueryRowContext(ctx, `SELECT `+learningPathColumns+` FROM learning_paths WHERE id = $1`, id), &path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &path, nil
+}
+
+func (r *postgresLearningPathRepository) Create(ctx context.Context, path *LearningPath) error {
+	prerequisites, tags, steps, err := learningPathJSON(path)
+	if err != nil {
+		return fmt.Errorf("encode learning path: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO learning_paths (title, description, difficulty_level, estimated_hours, prerequisites, tags, steps, is_active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		path.Title, path.Description, path.Difficulty, path.EstimatedHours, prerequisites, tags, steps, path.Active, path.CreatedAt).
+		Scan(&path.ID)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *postgresLearningPathRepository) Update(ctx context.Context, path *LearningPath) error {
+	prerequisites, tags, steps, err := learningPathJSON(path)
+	if err != nil {
+		return fmt.Errorf("encode learning path: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE learning_paths
+		SET title = $2, description = $3, difficulty_level = $4, estimated_hours = $5, prerequisites = $6, tags = $7, steps = $8, is_active = $9
+		WHERE id = $1`,
+		path.ID, path.Title, path.Description, path.Difficulty, path.EstimatedHours, prerequisites, tags, steps, path.Active)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const learningProgressColumns = `id, user_id, learning_path_id, progress_percentage, current_step, started_at, completed_at`
+
+// scanLearningProgress scans a row selected with learningProgressColumns
+func scanLearningProgress(row interface{ Scan(...interface{}) error }, progress *LearningPathProgress) error {
+	var completedAt sql.NullTime
+	if err := row.Scan(&progress.ID, &progress.UserID, &progress.PathID, &progress.ProgressPercentage, &progress.CurrentStep,
+		&progress.StartedAt, &completedAt); err != nil {
+		return err
+	}
+	if completedAt.Valid {
+		progress.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
+
+func (r *postgresLearningPathRepository) ListProgress(ctx context.Context, userID int) ([]LearningPathProgress, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+learningProgressColumns+`
+		FROM user_learning_progress
+		WHERE user_id = $1
+		ORDER BY started_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []LearningPathProgress{}
+	for rows.Next() {
+		var p LearningPathProgress
+		if err := scanLearningProgress(rows, &p); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+func (r *postgresLearningPathRepository) GetProgress(ctx context.Context, userID, pathID int) (*LearningPathProgress, error) {
+	var progress LearningPathProgress
+	err := scanLearningProgress(r.db.QueryRowContext(ctx, `
+		SELECT `+learningProgressColumns+`
+		FROM user_learning_progress
+		WHERE user_id = $1 AND learning_path_id = $2`, userID, pathID), &progress)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (r *postgresLearningPathRepository) CreateProgress(ctx context.Context, progress *LearningPathProgress) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO user_learning_progress (user_id, learning_path_id, progress_percentage, current_step, started_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		progress.UserID, progress.PathID, progress.ProgressPercentage, progress.CurrentStep, progress.StartedAt).
+		Scan(&progress.ID)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *postgresLearningPathRepository) UpdateProgress(ctx context.Context, progress *LearningPathProgress) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_learning_progress
+		SET progress_percentage = $2, current_step = $3, completed_at = $4
+		WHERE id = $1`,
+		progress.ID, progress.ProgressPercentage, progress.CurrentStep, progress.CompletedAt)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresProjectRepository is a ProjectRepository backed by the diy_projects table.
+// Skills, resources, success metrics and milestones are JSONB arrays.
+type postgresProjectRepository struct {
+	db *sql.DB
+}
+
+const projectColumns = `id, user_id, title, COALESCE(description, ''), COALESCE(project_type, ''), COALESCE(difficulty, 1),
+	COALESCE(estimated_hours, 0), COALESCE(status, 'planning'), skills, COALESCE(resources, '[]'), success_metrics,
+	COALESCE(milestones, '[]'), created_at, started_at, completed_at`
+
+// scanProject scans a row selected with projectColumns
+func scanProject(row interface{ Scan(...interface{}) error }, project *Project) error {
+	var skills, resources, metrics, milestones []byte
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(&project.ID, &project.UserID, &project.Title, &project.Description, &project.ProjectType, &project.Difficulty,
+		&project.EstimatedHours, &project.Status, &skills, &resources, &metrics, &milestones, &project.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		data []byte
+		dest interface{}
+	}{{skills, &project.Skills}, {resources, &project.Resources}, {metrics, &project.SuccessMetrics}, {milestones, &project.Milestones}} {
+		if err := json.Unmarshal(field.data, field.dest); err != nil {
+			return fmt.Errorf("decode project %d: %w", project.ID, err)
+		}
+	}
+	if startedAt.Valid {
+		project.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		project.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
+
+// projectJSON encodes a project's skills, resources, success metrics and milestones
+func projectJSON(project *Project) (skills, resources, metrics, milestones []byte, err error) {
+	if skills, err = json.Marshal(project.Skills); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if resources, err = json.Marshal(project.Resources); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if metrics, err = json.Marshal(project.SuccessMetrics); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if milestones, err = json.Marshal(project.Milestones); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return skills, resources, metrics, milestones, nil
+}
+
+func (r *postgresProjectRepository) ListByUser(ctx context.Context, userID int) ([]Project, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+projectColumns+`
+		FROM diy_projects
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		var project Project
+		if err := scanProject(rows, &project); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+func (r *postgresProjectRepository) GetByID(ctx context.Context, id int) (*Project, error) {
+	var project Project
+	err := scanProject(r.db.QueryRowContext(ctx, `SELECT `+projectColumns+` FROM diy_projects WHERE id = $1`, id), &project)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *postgresProjectRepository) Create(ctx context.Context, project *Project) error {
+	skills, resources, metrics, milestones, err := projectJSON(project)
+	if err != nil {
+		return fmt.Errorf("encode project: %w", err)
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO diy_projects (user_id, title, description, project_type, difficulty, estimated_hours, status,
+			skills, resources, success_metrics, milestones, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
+		project.UserID, project.Title, project.Description, project.ProjectType, project.Difficulty, project.EstimatedHours,
+		project.Status, skills, resources, metrics, milestones, project.CreatedAt).
+		Scan(&project.ID)
+}
+
+func (r *postgresProjectRepository) Update(ctx context.Context, project *Project) error {
+	_, _, _, milestones, err := projectJSON(project)
+	if err != nil {
+		return fmt.Errorf("encode project: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE diy_projects
+		SET status = $2, milestones = $3, started_at = $4, completed_at = $5
+		WHERE id = $1`,
+		project.ID, project.Status, milestones, project.StartedAt, project.CompletedAt)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresUserSessionRepository is a SessionRepository backed by the user_sessions table
+type postgresUserSessionRepository struct {
+	db *sql.DB
+}
+
+const userSessionColumns = `id, user_id, session_start, session_end, COALESCE(duration_minutes, 0), COALESCE(tasks_completed, 0),
+	COALESCE(tasks_failed, 0), COALESCE(retries, 0), idle_events, response_seconds, timed_tasks,
+	COALESCE(last_event_at, session_start), COALESCE(mood_detected, '')`
+
+// scanUserSession scans a row selected with userSessionColumns
+func scanUserSession(row interface{ Scan(...interface{}) error }, session *UserSession) error {
+	var endedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserID, &session.StartedAt, &endedAt, &session.DurationMinutes, &session.TasksCompleted,
+		&session.TasksFailed, &session.Retries, &session.IdleEvents, &session.ResponseSeconds, &session.TimedTasks,
+		&session.LastEventAt, &session.MoodDetected); err != nil {
+		return err
+	}
+	if endedAt.Valid {
+		session.EndedAt = &endedAt.Time
+	}
+	return nil
+}
+
+func (r *postgresUserSessionRepository) Create(ctx context.Context, session *UserSession) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO user_sessions (user_id, session_start, last_event_at)
+		VALUES ($1, $2, $3)
+		RETURNING id`,
+		session.UserID, session.StartedAt, session.LastEventAt).
+		Scan(&session.ID)
+}
+
+func (r *postgresUserSessionRepository) GetByID(ctx context.Context, id int) (*UserSession, error) {
+	var session UserSession
+	err := scanUserSession(r.db.QueryRowContext(ctx, `SELECT `+userSessionColumns+` FROM user_sessions WHERE id = $1`, id), &session)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *postgresUserSessionRepository) GetOpen(ctx context.Context, userID int) (*UserSession, error) {
+	var session UserSession
+	err := scanUserSession(r.db.QueryRowContext(ctx, `
+		SELECT `+userSessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1 AND session_end IS NULL
+		ORDER BY session_start DESC, id DESC
+		LIMIT 1`, userID), &session)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *postgresUserSessionRepository) Recent(ctx context.Context, userID, limit int) ([]UserSession, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userSessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1
+		ORDER BY session_start DESC, id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []UserSession{}
+	for rows.Next() {
+		var session UserSession
+		if err := scanUserSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *postgresUserSessionRepository) Update(ctx context.Context, session *UserSession) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_sessions
+		SET session_end = $2, duration_minutes = $3, tasks_completed = $4, tasks_failed = $5, retries = $6,
+			idle_events = $7, response_seconds = $8, timed_tasks = $9, last_event_at = $10, mood_detected = NULLIF($11, '')
+		WHERE id = $1`,
+		session.ID, session.EndedAt, session.DurationMinutes, session.TasksCompleted, session.TasksFailed, session.Retries,
+		session.IdleEvents, session.ResponseSeconds, session.TimedTasks, session.LastEventAt, session.MoodDetected)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postgresFocusSessionRepository is a FocusSessionRepository backed by the focus_sessions table
+type postgresFocusSessionRepository struct {
+	db *sql.DB
+}
+
+const focusSessionColumns = `id, user_id, quest_id, planned_minutes, status, started_at, last_heartbeat_at, paused_at,
+	paused_seconds, focused_seconds, gaps, verified_minutes, COALESCE(invalid_reason, ''), ended_at`
+
+// scanFocusSession scans a row selected with focusSessionColumns
+func scanFocusSession(row interface{ Scan(...interface{}) error }, session *FocusSession) error {
+	var questID sql.NullInt64
+	var pausedAt, endedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserID, &questID, &session.PlannedMinutes, &session.Status, &session.StartedAt,
+		&session.LastHeartbeatAt, &pausedAt, &session.PausedSeconds, &session.FocusedSeconds, &session.Gaps,
+		&session.VerifiedMinutes, &session.InvalidReason, &endedAt); err != nil {
+		return err
+	}
+	if questID.Valid {
+		session.QuestID = intPtr(int(questID.Int64))
+	}
+	if pausedAt.Valid {
+		session.PausedAt = &pausedAt.Time
+	}
+	if endedAt.Valid {
+		session.EndedAt = &endedAt.Time
+	}
+	return nil
+}
+
+func (r *postgresFocusSessionRepository) Create(ctx context.Context, session *FocusSession) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO focus_sessions (user_id, quest_id, planned_minutes, status, started_at, last_heartbeat_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		session.UserID, session.QuestID, session.PlannedMinutes, session.Status, session.StartedAt, session.LastHeartbeatAt).
+		Scan(&session.ID)
+}
+
+func (r *postgresFocusSessionRepository) GetByID(ctx context.Context, id int) (*FocusSession, error) {
+	var session FocusSession
+	err := scanFocusSession(r.db.QueryRowContext(ctx, `SELECT `+focusSessionColumns+` FROM focus_sessions WHERE id = $1`, id), &session)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *postgresFocusSessionRepository) GetActive(ctx context.Context, userID int) (*FocusSession, error) {
+	var session FocusSession
+	err := scanFocusSession(r.db.QueryRowContext(ctx, `
+		SELECT `+focusSessionColumns+`
+		FROM focus_sessions
+		WHERE user_id = $1 AND status IN ('running', 'paused')
+		ORDER BY started_at DESC, id DESC
+		LIMIT 1`, userID), &session)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *postgresFocusSessionRepository) Update(ctx context.Context, session *FocusSession) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE focus_sessions
+		SET status = $2, last_heartbeat_at = $3, paused_at = $4, paused_seconds = $5, focused_seconds = $6,
+			gaps = $7, verified_minutes = $8, invalid_reason = NULLIF($9, ''), ended_at = $10
+		WHERE id = $1`,
+		session.ID, session.Status, session.LastHeartbeatAt, session.PausedAt, session.PausedSeconds, session.FocusedSeconds,
+		session.Gaps, session.VerifiedMinutes, session.InvalidReason, session.EndedAt)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
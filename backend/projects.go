@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ai "learning-buddy-ai"
+
+	"github.com/gorilla/mux"
+)
+
+// ProjectStatus is the lifecycle state of a DIY project
+type ProjectStatus string
+
+const (
+	ProjectStatusPlanning   ProjectStatus = "planning"
+	ProjectStatusInProgress ProjectStatus = "in_progress"
+	ProjectStatusCompleted  ProjectStatus = "completed"
+	ProjectStatusAbandoned  ProjectStatus = "abandoned"
+)
+
+// Limits and defaults for project requests
+const (
+	defaultProjectType   = "coding"
+	defaultProjectHours  = 5
+	maxProjectHours      = 40
+	defaultProjectWeeks  = 4
+	maxProjectWeeks      = 12
+	maxProjectFieldChars = 300
+	maxOpenProjects      = 3 // planning or in-progress projects a learner may have at once
+)
+
+// projectTypes are the project types diy_projects.project_type allows
+var projectTypes = map[string]bool{"coding": true, "design": true, "research": true, "writing": true}
+
+var (
+	// ErrInvalidProject is returned when a project request is missing or has out-of-range fields
+	ErrInvalidProject = errors.New("invalid project request")
+	// ErrInvalidProjectTransition is returned when a project cannot move to the requested status
+	ErrInvalidProjectTransition = errors.New("invalid project status transition")
+	// ErrProjectClosed is returned when checking off milestones of a completed or abandoned project
+	ErrProjectClosed = errors.New("project is closed")
+	// ErrMilestoneCompleted is returned when a milestone is checked off twice
+	ErrMilestoneCompleted = errors.New("milestone already completed")
+	// ErrMilestonesRemaining is returned when completing a project before all its milestones
+	ErrMilestonesRemaining = errors.New("project has milestones remaining")
+	// ErrTooManyProjects is returned when planning a project while maxOpenProjects are open
+	ErrTooManyProjects = errors.New("too many open projects")
+)
+
+// projectTransitions lists the statuses each status may move to.
+// Completed and abandoned are terminal.
+var projectTransitions = map[ProjectStatus][]ProjectStatus{
+	ProjectStatusPlanning:   {ProjectStatusInProgress, ProjectStatusAbandoned},
+	ProjectStatusInProgress: {ProjectStatusCompleted, ProjectStatusAbandoned},
+}
+
+// Project is a DIY learning project planned by the AI core
+type Project struct {
+	ID             int                `json:"id"`
+	UserID         int                `json:"user_id"`
+	Title          string             `json:"title"`
+	Description    string             `json:"description"`
+	ProjectType    string             `json:"project_type"`
+	Difficulty     int                `json:"difficulty"` // 1 (beginner) to 5 (expert)
+	EstimatedHours int                `json:"estimated_hours"`
+	Status         ProjectStatus      `json:"status"`
+	Skills         []string           `json:"skills"`
+	Resources      []string           `json:"resources"`
+	SuccessMetrics []string           `json:"success_metrics"`
+	Milestones     []ProjectMilestone `json:"milestones"`
+	CreatedAt      time.Time          `json:"created_at"`
+	StartedAt      *time.Time         `json:"started_at,omitempty"`
+	CompletedAt    *time.Time         `json:"completed_at,omitempty"`
+}
+
+// ProjectMilestone is one checkable step of a project. Checking it off awards
+// XPReward, unless it is checked off before its week has started.
+type ProjectMilestone struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Week        int        `json:"week"`
+	XPReward    int        `json:"xp_reward"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ProjectRequest describes the project a learner wants planned. It fills the
+// diy_project_generator template together with the learner's level and past projects.
+type ProjectRequest struct {
+	Interests          string `json:"interests"`
+	ProjectType        string `json:"project_type"`
+	AvailableHours     int    `json:"available_hours"` // per week
+	LearningGoals      string `json:"learning_goals"`
+	AvailableResources string `json:"available_resources"`
+	TimelineWeeks      int    `json:"timeline_weeks"`
+}
+
+// ProjectPlanner generates project plans and reports whether a plan is the
+// mock's. It is satisfied by *AIService.
+type ProjectPlanner interface {
+	PlanProject(ctx context.Context, user *User, variables map[string]interface{}) (plan *ai.ProjectPlan, mock bool, err error)
+}
+
+// CanTransition reports whether the project may move to the given status
+func (p *Project) CanTransition(to ProjectStatus) bool {
+	for _, allowed := range projectTransitions[p.Status] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the project is still planned or in progress
+func (p *Project) IsOpen() bool {
+	return p.Status == ProjectStatusPlanning || p.Status == ProjectStatusInProgress
+}
+
+// WeekStarts returns when the given plan week starts, counted from the project's creation
+func (p *Project) WeekStarts(week int) time.Time {
+	if week < 1 {
+		week = 1
+	}
+	return p.CreatedAt.AddDate(0, 0, 7*(week-1))
+}
+
+// MilestonesRemaining counts the milestones not yet checked off
+func (p *Project) MilestonesRemaining() int {
+	remaining := 0
+	for _, milestone := range p.Milestones {
+		if !milestone.Completed {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// normalize fills in defaults and checks the request's fields
+func (req *ProjectRequest) normalize() error {
+	req.Interests = strings.TrimSpace(req.Interests)
+	req.LearningGoals = strings.TrimSpace(req.LearningGoals)
+	req.AvailableResources = strings.TrimSpace(req.AvailableResources)
+	req.ProjectType = strings.ToLower(strings.TrimSpace(req.ProjectType))
+	if req.ProjectType == "" {
+		req.ProjectType = defaultProjectType
+	}
+	if req.AvailableHours == 0 {
+		req.AvailableHours = defaultProjectHours
+	}
+	if req.TimelineWeeks == 0 {
+		req.TimelineWeeks = defaultProjectWeeks
+	}
+
+	switch {
+	case req.LearningGoals == "":
+		return fmt.Errorf("%w: learning_goals is required", ErrInvalidProject)
+	case !projectTypes[req.ProjectType]:
+		return fmt.Errorf("%w: project_type must be coding, design, research or writing", ErrInvalidProject)
+	case req.AvailableHours < 1 || req.AvailableHours > maxProjectHours:
+		return fmt.Errorf("%w: available_hours must be between 1 and %d", ErrInvalidProject, maxProjectHours)
+	case req.TimelineWeeks < 1 || req.TimelineWeeks > maxProjectWeeks:
+		return fmt.Errorf("%w: timeline_weeks must be between 1 and %d", ErrInvalidProject, maxProjectWeeks)
+	}
+	for name, value := range map[string]string{"interests": req.Interests, "learning_goals": req.LearningGoals, "available_resources": req.AvailableResources} {
+		if len(value) > maxProjectFieldChars {
+			return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidProject, name, maxProjectFieldChars)
+		}
+	}
+	return nil
+}
+
+// skillLevel describes a user's level the way the project template expects
+func skillLevel(level int) string {
+	switch {
+	case level >= 15:
+		return "advanced"
+	case level >= 5:
+		return "intermediate"
+	default:
+		return "beginner"
+	}
+}
+
+// PlanProject generates a DIY project plan for the user. Users the quota keeps
+// off the LLM get the mock plan, as do LLM failures and replies that never
+// matched the template's output schema.
+func (s *AIService) PlanProject(ctx context.Context, user *User, variables map[string]interface{}) (*ai.ProjectPlan, bool, error) {
+	llmCtx, decision := s.decide(ctx, user)
+	if !decision.UseMock {
+		plan, response, err := s.core.PlanProject(llmCtx, variables)
+		if err == nil {
+			s.recordUsage(ctx, user, response, decision)
+			return plan, false, nil
+		}
+		log.Printf("llm project plan for user %d, falling back to mock: %v", user.ID, err)
+	}
+	plan, _, err := s.core.MockPlanProject(ctx, variables)
+	return plan, true, err
+}
+
+// ProjectService methods
+
+// List returns a user's projects, newest first
+func (s *ProjectService) List(ctx context.Context, userID int) ([]Project, error) {
+	return s.projects.ListByUser(ctx, userID)
+}
+
+// Get returns one of the user's projects. Other users' projects are ErrNotFound.
+func (s *ProjectService) Get(ctx context.Context, userID, projectID int) (*Project, error) {
+	project, err := s.projects.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return project, nil
+}
+
+// Generate plans a project for the user through the AI core and saves it in
+// the planning status. A learner has at most maxOpenProjects open projects,
+// and milestones of a mock plan award no XP, since anyone can request one.
+func (s *ProjectService) Generate(ctx context.Context, userID int, req ProjectRequest) (*Project, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := s.roomForProject(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	previous := previousProjects(projects)
+
+	interests, resources := req.Interests, req.AvailableResources
+	if interests == "" {
+		interests = "open to suggestions"
+	}
+	if resources == "" {
+		resources = "a computer with internet access"
+	}
+	plan, mock, err := s.planner.PlanProject(ctx, user, map[string]interface{}{
+		"skill_level":         skillLevel(user.Level),
+		"interests":           interests,
+		"available_time":      req.AvailableHours,
+		"project_type":        req.ProjectType,
+		"learning_goals":      req.LearningGoals,
+		"available_resources": resources,
+		"previous_projects":   previous,
+		"timeline":            req.TimelineWeeks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plan project: %w", err)
+	}
+
+	project := &Project{
+		UserID:         userID,
+		Title:          plan.Title,
+		Description:    plan.Description,
+		ProjectType:    req.ProjectType,
+		Difficulty:     plan.Difficulty,
+		EstimatedHours: plan.EstimatedHours,
+		Status:         ProjectStatusPlanning,
+		Skills:         nonNilStrings(plan.Skills),
+		Resources:      nonNilStrings(plan.Resources),
+		SuccessMetrics: nonNilStrings(plan.SuccessMetrics),
+		Milestones:     make([]ProjectMilestone, 0, len(plan.Milestones)),
+		CreatedAt:      s.now(),
+	}
+	for _, milestone := range plan.Milestones {
+		reward := milestone.XPReward
+		if mock {
+			reward = 0
+		}
+		project.Milestones = append(project.Milestones, ProjectMilestone{
+			Title:       milestone.Title,
+			Description: milestone.Description,
+			Week:        milestone.Week,
+			XPReward:    reward,
+		})
+	}
+
+	// Check again under the lock: other plans may have been saved while this one was generated
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.roomForProject(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := s.projects.Create(ctx, project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// CompleteMilestone checks off a milestone, numbered from 1. The first
+// milestone starts a planning project and the last one completes it. A
+// milestone checked off before its week starts earns no XP.
+func (s *ProjectService) CompleteMilestone(ctx context.Context, userID, projectID, number int) (*Project, error) {
+	s.mu.Lock()
+	defer s.flushEvents(ctx)
+	defer s.mu.Unlock()
+
+	project, err := s.Get(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project.Status == ProjectStatusCompleted || project.Status == ProjectStatusAbandoned {
+		return nil, fmt.Errorf("%w: project is %s", ErrProjectClosed, project.Status)
+	}
+	if number < 1 || number > len(project.Milestones) {
+		return nil, ErrNotFound
+	}
+	milestone := &project.Milestones[number-1]
+	if milestone.Completed {
+		return nil, fmt.Errorf("%w: %s", ErrMilestoneCompleted, milestone.Title)
+	}
+
+	if project.Status == ProjectStatusPlanning {
+		if err := s.transition(ctx, project, ProjectStatusInProgress); err != nil {
+			return nil, err
+		}
+	}
+	now := s.now()
+	milestone.Completed = true
+	milestone.CompletedAt = &now
+	if now.Before(project.WeekStarts(milestone.Week)) {
+		milestone.XPReward = 0 // checked off ahead of the plan
+	}
+	if err := s.projects.Update(ctx, project); err != nil {
+		return nil, err
+	}
+	done := *milestone
+	s.publish(EventProjectMilestoneCompleted, project, &done)
+
+	if project.MilestonesRemaining() == 0 {
+		if err := s.transition(ctx, project, ProjectStatusCompleted); err != nil {
+			return nil, err
+		}
+	}
+	return project, nil
+}
+
+// Transition moves a project to a new status if the state machine allows it.
+// A project completes only once every milestone is checked off.
+func (s *ProjectService) Transition(ctx context.Context, userID, projectID int, to ProjectStatus) (*Project, error) {
+	s.mu.Lock()
+	defer s.flushEvents(ctx)
+	defer s.mu.Unlock()
+
+	project, err := s.Get(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if to == ProjectStatusCompleted && project.CanTransition(to) {
+		if remaining := project.MilestonesRemaining(); remaining > 0 {
+			return nil, fmt.Errorf("%w: %d of %d left", ErrMilestonesRemaining, remaining, len(project.Milestones))
+		}
+	}
+	if err := s.transition(ctx, project, to); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// roomForProject returns the user's projects, or ErrTooManyProjects when
+// maxOpenProjects of them are open
+func (s *ProjectService) roomForProject(ctx context.Context, userID int) ([]Project, error) {
+	projects, err := s.projects.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	open := 0
+	for _, project := range projects {
+		if project.IsOpen() {
+			open++
+		}
+	}
+	if open >= maxOpenProjects {
+		return nil, fmt.Errorf("%w: finish or abandon one of your %d open projects first", ErrTooManyProjects, open)
+	}
+	return projects, nil
+}
+
+// previousProjects lists the titles of the completed projects for the planner
+func previousProjects(projects []Project) string {
+	var titles []string
+	for _, project := range projects {
+		if project.Status == ProjectStatusCompleted {
+			titles = append(titles, project.Title)
+		}
+	}
+	if len(titles) == 0 {
+		return "none"
+	}
+	return strings.Join(titles, ", ")
+}
+
+// transition validates, persists and publishes a status change. Callers must hold s.mu.
+func (s *ProjectService) transition(ctx context.Context, project *Project, to ProjectStatus) error {
+	if !project.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidProjectTransition, project.Status, to)
+	}
+
+	from, startedAt, completedAt := project.Status, project.StartedAt, project.CompletedAt
+	now := s.now()
+	project.Status = to
+	switch to {
+	case ProjectStatusInProgress:
+		project.StartedAt = &now
+	case ProjectStatusCompleted, ProjectStatusAbandoned:
+		project.CompletedAt = &now
+	}
+	if err := s.projects.Update(ctx, project); err != nil {
+		project.Status, project.StartedAt, project.CompletedAt = from, startedAt, completedAt
+		return err
+	}
+
+	switch to {
+	case ProjectStatusInProgress:
+		s.publish(EventProjectStarted, project, nil)
+	case ProjectStatusCompleted:
+		s.publish(EventProjectCompleted, project, nil)
+	case ProjectStatusAbandoned:
+		s.publish(EventProjectAbandoned, project, nil)
+	}
+	return nil
+}
+
+// publish queues an event for delivery once s.mu is released. Callers must hold s.mu.
+func (s *ProjectService) publish(eventType EventType, project *Project, milestone *ProjectMilestone) {
+	snapshot := *project
+	snapshot.Milestones = append([]ProjectMilestone(nil), project.Milestones...)
+	s.pending = append(s.pending, Event{
+		Type:       eventType,
+		UserID:     project.UserID,
+		OccurredAt: s.now(),
+		Project:    &snapshot,
+		Milestone:  milestone,
+	})
+}
+
+// flushEvents delivers queued events outside the lock so subscribers may call back into the service
+func (s *ProjectService) flushEvents(ctx context.Context) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if s.events == nil {
+		return
+	}
+	for _, event := range pending {
+		s.events.Publish(ctx, event)
+	}
+}
+
+// nonNilStrings returns values, or an empty slice if it is nil, so it encodes as []
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// Project handlers
+
+// writeProjectError maps project errors to HTTP responses
+func writeProjectError(w http.ResponseWriter, projectID int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Project or milestone not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidProject):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidProjectTransition), errors.Is(err, ErrProjectClosed), errors.Is(err, ErrMilestoneCompleted),
+		errors.Is(err, ErrMilestonesRemaining), errors.Is(err, ErrTooManyProjects):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("project %d: %v", projectID, err)
+		http.Error(w, "Failed to update project", http.StatusInternalServerError)
+	}
+}
+
+// projectVars reads the {id} user and {projectId} project route variables
+func projectVars(w http.ResponseWriter, r *http.Request) (userID, projectID int, ok bool) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	projectID, err = strconv.Atoi(vars["projectId"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, projectID, true
+}
+
+func (s *Server) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	projects, err := s.projects.List(r.Context(), userID)
+	if err != nil {
+		log.Printf("list projects for user %d: %v", userID, err)
+		http.Error(w, "Failed to load projects", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+func (s *Server) generateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	project, err := s.projects.Generate(r.Context(), userID, req)
+	if err != nil {
+		writeProjectError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (s *Server) getProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, ok := projectVars(w, r)
+	if !ok {
+		return
+	}
+
+	project, err := s.projects.Get(r.Context(), userID, projectID)
+	if err != nil {
+		writeProjectError(w, projectID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+func (s *Server) updateProjectStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, ok := projectVars(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status ProjectStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	project, err := s.projects.Transition(r.Context(), userID, projectID, req.Status)
+	if err != nil {
+		writeProjectError(w, projectID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+func (s *Server) completeProjectMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, ok := projectVars(w, r)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(mux.Vars(r)["milestone"])
+	if err != nil {
+		http.Error(w, "Invalid milestone number", http.StatusBadRequest)
+		return
+	}
+
+	project, err := s.projects.CompleteMilestone(r.Context(), userID, projectID, number)
+	if err != nil {
+		writeProjectError(w, projectID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	ai "learning-buddy-ai"
+	"learning-buddy-ai/fakeopenai"
+)
+
+const llmProjectPlan = `{"title":"Weather CLI","description":"Fetch and print forecasts.","difficulty":2,"estimated_hours":8,` +
+	`"skills":["HTTP","JSON"],"milestones":[{"title":"Call the API","description":"Print raw JSON.","week":1,"xp_reward":50},` +
+	`{"title":"Format the forecast","description":"Print a table.","week":2,"xp_reward":70}],` +
+	`"resources":["API docs"],"success_metrics":["Prints tomorrow's forecast"]}`
+
+func TestProjectMilestonesAwardXP(t *testing.T) {
+	f := newTestFixture(t)
+	server, _ := useFakeLLM(t, f, LoadUsageConfig())
+	server.SetReply(func(fakeopenai.Request) string { return llmProjectPlan })
+	clock := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	f.server.projects.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	plan := func() (Project, string) {
+		t.Helper()
+		rec := f.do("POST", "/api/v1/users/1/projects", "owner", `{"learning_goals":"HTTP clients"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("plan status = %d: %s", rec.Code, rec.Body)
+		}
+		project := decodeJSON[Project](t, rec.Body.Bytes())
+		return project, "/api/v1/users/1/projects/" + strconv.Itoa(project.ID) + "/milestones/"
+	}
+	complete := func(path string, number int) Project {
+		t.Helper()
+		rec := f.do("POST", path+strconv.Itoa(number)+"/complete", "owner", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("milestone %d status = %d: %s", number, rec.Code, rec.Body)
+		}
+		return decodeJSON[Project](t, rec.Body.Bytes())
+	}
+	projectXP := func(projectID int) (xp, awards int) {
+		history, _ := f.server.xp.History(ctx, 1, 50, 0)
+		for _, tx := range history.Transactions {
+			if tx.SourceType == XPSourceProject && tx.SourceID != nil && *tx.SourceID == projectID {
+				xp += tx.Amount
+				awards++
+			}
+		}
+		return xp, awards
+	}
+
+	onSchedule, path := plan()
+	if project := complete(path, 1); project.Status != ProjectStatusInProgress || project.StartedAt == nil {
+		t.Fatalf("after the first milestone project = %+v, want in_progress", project)
+	}
+	clock = clock.AddDate(0, 0, 7) // week 2 starts
+	if project := complete(path, 2); project.Status != ProjectStatusCompleted || project.CompletedAt == nil || project.Milestones[1].XPReward != 70 {
+		t.Fatalf("after the last milestone project = %+v, want completed with the week 2 reward", project)
+	}
+	if xp, awards := projectXP(onSchedule.ID); awards != 2 || xp != 120 {
+		t.Fatalf("on-schedule project xp = %d in %d awards, want 120 in 2", xp, awards)
+	}
+	if rec := f.do("POST", path+"1/complete", "owner", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("milestone on completed project status = %d, want 409", rec.Code)
+	}
+
+	ahead, path := plan()
+	if project := complete(path, 2); project.Milestones[1].XPReward != 0 || !project.Milestones[1].Completed {
+		t.Fatalf("week 2 milestone checked off in week 1 = %+v, want it completed without XP", project.Milestones[1])
+	}
+	complete(path, 1)
+	if xp, awards := projectXP(ahead.ID); awards != 1 || xp != 50 {
+		t.Fatalf("ahead-of-plan project xp = %d in %d awards, want only the week 1 reward 50", xp, awards)
+	}
+}
+
+func TestProjectMockPlansAwardNoXP(t *testing.T) {
+	f := newTestFixture(t)
+	ctx := context.Background()
+
+	rec := f.do("POST", "/api/v1/users/2/projects", "other", `{"learning_goals":"web storage","interests":"habits"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("plan status = %d: %s", rec.Code, rec.Body)
+	}
+	project := decodeJSON[Project](t, rec.Body.Bytes())
+	if project.Status != ProjectStatusPlanning || project.ProjectType != defaultProjectType || len(project.Milestones) != 3 || project.UserID != 2 {
+		t.Fatalf("planned project = %+v, want the mock plan in planning", project)
+	}
+	for _, milestone := range project.Milestones {
+		if milestone.XPReward != 0 {
+			t.Fatalf("mock milestone %+v carries XP", milestone)
+		}
+	}
+
+	f.server.projects.now = func() time.Time { return project.CreatedAt.AddDate(0, 0, 7*maxProjectWeeks) }
+	for number := 1; number <= 3; number++ {
+		if rec := f.do("POST", "/api/v1/users/2/projects/"+strconv.Itoa(project.ID)+"/milestones/"+strconv.Itoa(number)+"/complete", "other", ""); rec.Code != http.StatusOK {
+			t.Fatalf("milestone %d status = %d: %s", number, rec.Code, rec.Body)
+		}
+	}
+	history, _ := f.server.xp.History(ctx, 2, 50, 0)
+	for _, tx := range history.Transactions {
+		if tx.SourceType == XPSourceProject {
+			t.Fatalf("mock project credited %+v", tx)
+		}
+	}
+}
+
+func TestProjectOpenLimit(t *testing.T) {
+	repos := NewMemoryRepositories()
+	service := NewProjectService(repos.Projects, repos.Users, NewAIService(ai.NewAICore("")), nil)
+	ctx := context.Background()
+
+	var last *Project
+	for i := 1; i < maxOpenProjects; i++ { // the seeded project is open
+		project, err := service.Generate(ctx, 1, ProjectRequest{LearningGoals: "charts"})
+		if err != nil {
+			t.Fatalf("Generate(%d) error = %v", i, err)
+		}
+		last = project
+	}
+	if _, err := service.Generate(ctx, 1, ProjectRequest{LearningGoals: "charts"}); !errors.Is(err, ErrTooManyProjects) {
+		t.Fatalf("Generate() past the limit error = %v, want ErrTooManyProjects", err)
+	}
+	if _, err := service.Transition(ctx, 1, last.ID, ProjectStatusAbandoned); err != nil {
+		t.Fatalf("Transition(abandoned) error = %v", err)
+	}
+	if _, err := service.Generate(ctx, 1, ProjectRequest{LearningGoals: "charts"}); err != nil {
+		t.Fatalf("Generate() after abandoning one error = %v", err)
+	}
+}
+
+func TestProjectTransitions(t *testing.T) {
+	repos := NewMemoryRepositories()
+	service := NewProjectService(repos.Projects, repos.Users, NewAIService(ai.NewAICore("")), nil)
+	ctx := context.Background()
+
+	project, err := service.Generate(ctx, 1, ProjectRequest{LearningGoals: "charts", ProjectType: "design", TimelineWeeks: 2})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if _, err := service.Transition(ctx, 1, project.ID, ProjectStatusCompleted); !errors.Is(err, ErrInvalidProjectTransition) {
+		t.Fatalf("planning -> completed: error = %v, want ErrInvalidProjectTransition", err)
+	}
+	if _, err := service.Transition(ctx, 2, project.ID, ProjectStatusAbandoned); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("another user's project: error = %v, want ErrNotFound", err)
+	}
+	if abandoned, err := service.Transition(ctx, 1, project.ID, ProjectStatusAbandoned); err != nil || abandoned.CompletedAt == nil {
+		t.Fatalf("Transition(abandoned) = %+v, %v", abandoned, err)
+	}
+	if _, err := service.CompleteMilestone(ctx, 1, project.ID, 1); !errors.Is(err, ErrProjectClosed) {
+		t.Fatalf("milestone on abandoned project: error = %v, want ErrProjectClosed", err)
+	}
+
+	for _, req := range []ProjectRequest{
+		{},
+		{LearningGoals: "charts", ProjectType: "knitting"},
+		{LearningGoals: "charts", TimelineWeeks: 13},
+		{LearningGoals: "charts", AvailableHours: -1},
+	} {
+		if _, err := service.Generate(ctx, 1, req); !errors.Is(err, ErrInvalidProject) {
+			t.Errorf("Generate(%+v) error = %v, want ErrInvalidProject", req, err)
+		}
+	}
+}
+
+func TestProjectPlanUsesLLM(t *testing.T) {
+	f := newTestFixture(t)
+	server, usage := useFakeLLM(t, f, LoadUsageConfig())
+	server.SetReply(func(fakeopenai.Request) string { return llmProjectPlan })
+
+	rec := f.do("POST", "/api/v1/users/1/projects", "owner", `{"learning_goals":"HTTP clients","interests":"weather","project_type":"coding","available_hours":3,"timeline_weeks":2}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("plan status = %d: %s", rec.Code, rec.Body)
+	}
+	if project := decodeJSON[Project](t, rec.Body.Bytes()); project.Title != "Weather CLI" || len(project.Milestones) != 2 || project.Milestones[1].XPReward != 70 {
+		t.Fatalf("project = %+v, want the LLM plan", project)
+	}
+	prompt := server.Requests()[0].Messages
+	content := prompt[len(prompt)-1].Content
+	for _, want := range []string{"Skill Level: intermediate", "Interests: weather", "Available Time: 3 hours", "Previous Projects: none", "over 2 weeks"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, content)
+		}
+	}
+	if len(usage.records) != 1 || usage.records[0].TemplateID != "diy_project_generator" {
+		t.Fatalf("usage records = %+v, want the plan accounted", usage.records)
+	}
+
+	server.SetReply(func(fakeopenai.Request) string { return `{"title":"Weather CLI"}` })
+	rec = f.do("POST", "/api/v1/users/1/projects", "owner", `{"learning_goals":"HTTP clients"}`)
+	if project := decodeJSON[Project](t, rec.Body.Bytes()); rec.Code != http.StatusCreated || project.Title != "Build a Personal Habit Tracker" {
+		t.Fatalf("status = %d, project %+v; want the mock plan after an unusable reply", rec.Code, project)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	ai "learning-buddy-ai"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// targetSuccessRate is the share of finished quests the generator aims for;
+	// a learner more than successMargin above it gets harder quests, below it easier ones
+	targetSuccessRate = 0.7
+	successMargin     = 0.1
+	// questHistorySize is how many of the latest finished quests calibrate difficulty,
+	// and minCalibrationQuests how many it takes before they count
+	questHistorySize     = 10
+	minCalibrationQuests = 3
+	// recentQuestWindow is how many of the latest quests are not repeated
+	recentQuestWindow = 5
+	// focusMinutesPerDifficulty sizes generated focus quests, whose progress counts minutes
+	focusMinutesPerDifficulty = 15
+	minQuestXP                = 25
+)
+
+// ErrInvalidQuestRequest is returned when a quest generation request names an unknown quest type
+var ErrInvalidQuestRequest = errors.New("invalid quest request")
+
+// errQuestPlanMock is returned by PlanQuest when the quota keeps the user off the LLM
+var errQuestPlanMock = errors.New("llm quest plans are unavailable for this user")
+
+// questTypes are the quest types the generator can produce
+var questTypes = map[string]bool{"code": true, "focus": true, "learn": true, "debug": true, "test": true}
+
+// learningStyleTypes lists the quest types that suit each learning style.
+// Balanced learners have no leaning.
+var learningStyleTypes = map[string][]string{
+	"visual":      {"learn", "debug"},
+	"auditory":    {"learn", "focus"},
+	"kinesthetic": {"code", "test", "debug"},
+}
+
+// strugglingEmotions lower the next quest's difficulty by one
+var strugglingEmotions = map[ai.EmotionState]bool{
+	ai.EmotionFrustrated: true,
+	ai.EmotionConfused:   true,
+	ai.EmotionTired:      true,
+}
+
+// questTemplate is a catalog quest before it is calibrated for a learner
+type questTemplate struct {
+	Title       string
+	Description string
+	Type        string
+	Difficulty  int
+	XP          int
+	Tasks       []string
+}
+
+// questCatalog is read-only; generated quests copy what they use from it
+var questCatalog = []questTemplate{
+	{"Debug Detective", "Find and fix bugs in your code", "code", 2, 150, []string{"Identify bug", "Write test case", "Fix issue", "Verify fix"}},
+	{"Focus Flow", "Complete a focused work session", "focus", 1, 75, []string{"Set timer", "Eliminate distractions", "Work continuously", "Review progress"}},
+	{"Concept Conqueror", "Master a new programming concept", "learn", 3, 200, []string{"Research concept", "Find examples", "Practice implementation", "Teach someone else"}},
+	{"Quick Study", "Get a first feel for an unfamiliar topic", "learn", 1, 100, []string{"Read an introduction", "Run one example", "Write a three-line summary"}},
+	{"Test Pilot", "Cover an untested function with tests", "test", 2, 120, []string{"Pick an untested function", "List its edge cases", "Write table-driven tests", "Make them pass"}},
+	{"Bug Hunt", "Track down a bug from report to regression test", "debug", 3, 180, []string{"Reproduce the bug", "Narrow down the cause", "Fix it", "Add a regression test"}},
+	{"Feature Builder", "Ship a small feature end to end", "code", 3, 220, []string{"Write down what done looks like", "Build the smallest version", "Handle the error cases", "Demo it"}},
+	{"Deep Work Block", "Protect a long block of uninterrupted work", "focus", 3, 150, []string{"Plan the block", "Silence notifications", "Work without switching tasks", "Log what you finished"}},
+	{"Refactor Run", "Untangle a complex function without changing what it does", "code", 4, 250, []string{"Find a complex function", "Pin its behavior with tests", "Split it into small pieces", "Review the diff"}},
+	{"Property Tester", "Check an invariant against generated inputs", "test", 4, 260, []string{"Name an invariant", "Write an input generator", "Run a property test", "Shrink a failing case"}},
+	{"Performance Profiler", "Find and remove a real bottleneck", "debug", 5, 320, []string{"Measure a baseline", "Profile the hot path", "Optimize the worst offender", "Measure again"}},
+	{"System Design Sketch", "Design a service before building it", "learn", 5, 350, []string{"Gather requirements", "Draw the components", "Walk through a failure", "Get feedback on the design"}},
+}
+
+// QuestRequest asks for a generated quest. Preferences are quest types; an
+// empty list allows them all. Custom asks the LLM for a quest instead of the catalog.
+type QuestRequest struct {
+	Preferences []string `json:"preferences"`
+	Custom      bool     `json:"custom"`
+}
+
+// QuestPlanner generates custom quests. It is satisfied by *AIService.
+type QuestPlanner interface {
+	PlanQuest(ctx context.Context, user *User, variables map[string]interface{}) (*ai.QuestPlan, error)
+}
+
+// normalize lowercases the request's preferences and checks they are quest types
+func (req *QuestRequest) normalize() error {
+	for i, preference := range req.Preferences {
+		preference = strings.ToLower(strings.TrimSpace(preference))
+		if !questTypes[preference] {
+			return fmt.Errorf("%w: preferences must be code, focus, learn, debug or test", ErrInvalidQuestRequest)
+		}
+		req.Preferences[i] = preference
+	}
+	return nil
+}
+
+// PlanQuest asks the LLM for a custom quest. Users the quota keeps off the LLM
+// get errQuestPlanMock; the generator then falls back to its catalog.
+func (s *AIService) PlanQuest(ctx context.Context, user *User, variables map[string]interface{}) (*ai.QuestPlan, error) {
+	llmCtx, decision := s.decide(ctx, user)
+	if decision.UseMock {
+		return nil, errQuestPlanMock
+	}
+	plan, response, err := s.core.PlanQuest(llmCtx, variables)
+	if err != nil {
+		return nil, err
+	}
+	s.recordUsage(ctx, user, response, decision)
+	return plan, nil
+}
+
+// preferredDifficulty returns the user's preferred difficulty, or one derived
+// from their level when they never set it
+func preferredDifficulty(user *User) int {
+	if user.PreferredDifficulty > 0 {
+		return clampDifficulty(user.PreferredDifficulty)
+	}
+	return clampDifficulty(1 + (user.Level-1)/5)
+}
+
+// learningStyle returns the user's learning style, balanced when unset
+func learningStyle(user *User) string {
+	if user.LearningStyle == "" {
+		return "balanced"
+	}
+	return user.LearningStyle
+}
+
+func clampDifficulty(difficulty int) int {
+	switch {
+	case difficulty < 1:
+		return 1
+	case difficulty > 5:
+		return 5
+	}
+	return difficulty
+}
+
+// successRate returns the share of the latest finished quests that were
+// completed rather than failed, and how many finished quests it covers.
+// quests must be in ID order.
+func successRate(quests []Quest) (float64, int) {
+	completed, finished := 0, 0
+	for i := len(quests) - 1; i >= 0 && finished < questHistorySize; i-- {
+		switch quests[i].Status {
+		case QuestStatusCompleted:
+			completed++
+			finished++
+		case QuestStatusFailed:
+			finished++
+		}
+	}
+	if finished == 0 {
+		return 0, 0
+	}
+	return float64(completed) / float64(finished), finished
+}
+
+// calibrateDifficulty moves the preferred difficulty one step towards the
+// target success rate, and one step down for a learner who is struggling
+func calibrateDifficulty(preferred int, rate float64, finished int, emotion ai.EmotionState) int {
+	difficulty := preferred
+	if finished >= minCalibrationQuests {
+		switch {
+		case rate > targetSuccessRate+successMargin:
+			difficulty++
+		case rate < targetSuccessRate-successMargin:
+			difficulty--
+		}
+	}
+	if strugglingEmotions[emotion] {
+		difficulty--
+	}
+	return clampDifficulty(difficulty)
+}
+
+// pickTemplate returns the catalog quest closest to the target difficulty.
+// Preferred types are required, types that suit the learning style win ties,
+// and the titles in recent are skipped unless nothing else is left.
+func pickTemplate(target int, preferences []string, style string, recent map[string]bool) questTemplate {
+	allowed := func(questType string) bool {
+		return len(preferences) == 0 || slices.Contains(preferences, questType)
+	}
+	suits := map[string]bool{}
+	for _, questType := range learningStyleTypes[style] {
+		suits[questType] = true
+	}
+
+	var candidates []questTemplate
+	for _, template := range questCatalog {
+		if allowed(template.Type) && !recent[template.Title] {
+			candidates = append(candidates, template)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, template := range questCatalog {
+			if allowed(template.Type) {
+				candidates = append(candidates, template)
+			}
+		}
+	}
+
+	score := func(template questTemplate) int {
+		distance := template.Difficulty - target
+		if distance < 0 {
+			distance = -distance
+		}
+		score := -2 * distance
+		if suits[template.Type] {
+			score++
+		}
+		return score
+	}
+	// Stable, so equal scores keep catalog order
+	sort.SliceStable(candidates, func(i, j int) bool { return score(candidates[i]) > score(candidates[j]) })
+	return candidates[0]
+}
+
+// recentTitles returns the titles of the user's active quests and of their
+// latest quests. quests must be in ID order.
+func recentTitles(quests []Quest) map[string]bool {
+	titles := map[string]bool{}
+	for i, quest := range quests {
+		if quest.Status == QuestStatusActive || quest.Status == QuestStatusPaused || i >= len(quests)-recentQuestWindow {
+			titles[quest.Title] = true
+		}
+	}
+	return titles
+}
+
+// newQuest builds an active quest from generated fields. Focus quests count
+// minutes; the rest count their tasks.
+func newQuest(userID int, title, description, questType string, difficulty, xp int, tasks []string) *Quest {
+	quest := &Quest{
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		Type:        questType,
+		Difficulty:  difficulty,
+		XP:          xp,
+		Status:      QuestStatusActive,
+		Total:       len(tasks),
+		Tasks:       make([]QuestTask, 0, len(tasks)),
+	}
+	if questType == focusQuestType {
+		quest.Total = difficulty * focusMinutesPerDifficulty
+	}
+	for i, task := range tasks {
+		quest.Tasks = append(quest.Tasks, QuestTask{Title: task, OrderIndex: i + 1})
+	}
+	return quest
+}
+
+// QuestGenerator methods
+
+// Generate creates and saves a quest for the user. Its difficulty starts from
+// the user's preference and is calibrated by their recent results and mood. A
+// custom request asks the LLM and falls back to the catalog when it is
+// unavailable or its quest does not fit the request.
+func (g *QuestGenerator) Generate(ctx context.Context, userID int, req QuestRequest) (*Quest, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	user, err := g.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := g.quests.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].ID < history[j].ID })
+	emotion, err := g.recentEmotion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, finished := successRate(history)
+	target := calibrateDifficulty(preferredDifficulty(user), rate, finished, emotion)
+	recent := recentTitles(history)
+
+	var quest *Quest
+	if req.Custom && g.planner != nil {
+		quest = g.customQuest(ctx, user, req, target, rate, finished, emotion, recent)
+	}
+	if quest == nil {
+		template := pickTemplate(target, req.Preferences, learningStyle(user), recent)
+		xp := template.XP + (target-template.Difficulty)*50
+		if xp < minQuestXP {
+			xp = minQuestXP
+		}
+		quest = newQuest(userID, template.Title, template.Description, template.Type, target, xp, template.Tasks)
+	}
+
+	if err := g.quests.Create(ctx, quest); err != nil {
+		return nil, err
+	}
+	return quest, nil
+}
+
+// customQuest asks the planner for a quest. It returns nil when the planner
+// fails or its quest ignores the preferences, repeats a recent quest or strays
+// more than one step from the target difficulty.
+func (g *QuestGenerator) customQuest(ctx context.Context, user *User, req QuestRequest, target int, rate float64, finished int, emotion ai.EmotionState, recent map[string]bool) *Quest {
+	preferred := "any"
+	if len(req.Preferences) > 0 {
+		preferred = strings.Join(req.Preferences, ", ")
+	}
+	successText := "no finished quests yet"
+	if finished > 0 {
+		successText = fmt.Sprintf("%.0f%% of the last %d quests", rate*100, finished)
+	}
+	recentList := make([]string, 0, len(recent))
+	for title := range recent {
+		recentList = append(recentList, title)
+	}
+	sort.Strings(recentList)
+	recentText := "none"
+	if len(recentList) > 0 {
+		recentText = strings.Join(recentList, "; ")
+	}
+	if emotion == "" {
+		emotion = "unknown"
+	}
+
+	plan, err := g.planner.PlanQuest(ctx, user, map[string]interface{}{
+		"user_level":        user.Level,
+		"learning_style":    learningStyle(user),
+		"target_difficulty": target,
+		"preferred_types":   preferred,
+		"success_rate":      successText,
+		"emotional_state":   emotion,
+		"recent_quests":     recentText,
+	})
+	if err != nil {
+		if !errors.Is(err, errQuestPlanMock) {
+			log.Printf("llm quest for user %d, falling back to the catalog: %v", user.ID, err)
+		}
+		return nil
+	}
+
+	distance := plan.Difficulty - target
+	switch {
+	case len(req.Preferences) > 0 && !slices.Contains(req.Preferences, plan.Type):
+		log.Printf("llm quest for user %d is a %s quest, not one of %v; using the catalog", user.ID, plan.Type, req.Preferences)
+		return nil
+	case recent[plan.Title]:
+		log.Printf("llm quest for user %d repeats %q; using the catalog", user.ID, plan.Title)
+		return nil
+	case distance > 1 || distance < -1:
+		log.Printf("llm quest for user %d has difficulty %d, target %d; using the catalog", user.ID, plan.Difficulty, target)
+		return nil
+	}
+	return newQuest(user.ID, plan.Title, plan.Description, plan.Type, plan.Difficulty, plan.XP, plan.Tasks)
+}
+
+// recentEmotion returns the mood detected for the user's latest ended session
+func (g *QuestGenerator) recentEmotion(ctx context.Context, userID int) (ai.EmotionState, error) {
+	if g.sessions == nil {
+		return "", nil
+	}
+	sessions, err := g.sessions.Recent(ctx, userID, behaviorSessions)
+	if err != nil {
+		return "", err
+	}
+	for _, session := range sessions {
+		if session.MoodDetected != "" {
+			return ai.EmotionState(session.MoodDetected), nil
+		}
+	}
+	return "", nil
+}
+
+// Quest generator handlers
+
+func (s *Server) generateQuestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req QuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	quest, err := s.generator.Generate(r.Context(), userID, req)
+	switch {
+	case errors.Is(err, ErrInvalidQuestRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("generate quest for user %d: %v", userID, err)
+		http.Error(w, "Failed to generate quest", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quest)
+}
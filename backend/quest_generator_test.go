@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ai "learning-buddy-ai"
+	"learning-buddy-ai/fakeopenai"
+)
+
+func TestCalibrateDifficulty(t *testing.T) {
+	tests := []struct {
+		name      string
+		preferred int
+		rate      float64
+		finished  int
+		emotion   ai.EmotionState
+		want      int
+	}{
+		{"no history", 2, 0, 0, "", 2},
+		{"too little history", 2, 1, 2, "", 2},
+		{"on target", 2, 0.7, 10, "", 2},
+		{"too easy", 2, 0.9, 10, "", 3},
+		{"too hard", 2, 0.4, 5, "", 1},
+		{"struggling", 3, 0.7, 10, ai.EmotionFrustrated, 2},
+		{"too easy but tired", 3, 1, 4, ai.EmotionTired, 3},
+		{"capped", 5, 1, 10, ai.EmotionExcited, 5},
+		{"floored", 1, 0, 10, ai.EmotionConfused, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calibrateDifficulty(tt.preferred, tt.rate, tt.finished, tt.emotion); got != tt.want {
+				t.Fatalf("calibrateDifficulty(%d, %v, %d, %q) = %d, want %d", tt.preferred, tt.rate, tt.finished, tt.emotion, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      int
+		preferences []string
+		style       string
+		recent      []string
+		want        string
+	}{
+		{"closest difficulty in catalog order", 2, nil, "balanced", nil, "Debug Detective"},
+		{"learning style breaks ties", 2, nil, "kinesthetic", nil, "Debug Detective"},
+		{"style picks among equals", 3, nil, "visual", nil, "Concept Conqueror"},
+		{"preferences are required", 1, []string{"test"}, "balanced", nil, "Test Pilot"},
+		{"recent quests are skipped", 2, []string{"test"}, "balanced", []string{"Test Pilot"}, "Property Tester"},
+		{"repeats when nothing else is left", 2, []string{"test"}, "balanced", []string{"Test Pilot", "Property Tester"}, "Test Pilot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recent := map[string]bool{}
+			for _, title := range tt.recent {
+				recent[title] = true
+			}
+			if got := pickTemplate(tt.target, tt.preferences, tt.style, recent); got.Title != tt.want {
+				t.Fatalf("pickTemplate() = %q, want %q", got.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateQuestCalibratesFromHistory(t *testing.T) {
+	repos := NewMemoryRepositories()
+	generator := NewServer(repos, nil, NewAIService(ai.NewAICore(""))).generator
+	ctx := context.Background()
+	user := &User{Username: "sam", Email: "sam@example.com", Role: RoleLearner, Level: 1, PreferredDifficulty: 2, LearningStyle: "kinesthetic"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	catalog := make([]questTemplate, len(questCatalog))
+	for i, template := range questCatalog {
+		template.Tasks = append([]string(nil), template.Tasks...)
+		catalog[i] = template
+	}
+
+	// Four quests completed in a row: the next one should be harder
+	for i := 0; i < 4; i++ {
+		quest, err := generator.Generate(ctx, user.ID, QuestRequest{Preferences: []string{"Code"}})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		quest.Status, quest.Progress = QuestStatusCompleted, quest.Total
+		if err := repos.Quests.Update(ctx, quest); err != nil {
+			t.Fatalf("complete quest: %v", err)
+		}
+	}
+
+	quest, err := generator.Generate(ctx, user.ID, QuestRequest{Preferences: []string{"code"}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if quest.Type != "code" || quest.Difficulty != 3 || quest.Status != QuestStatusActive || quest.UserID != user.ID {
+		t.Fatalf("quest = %+v, want an active difficulty 3 code quest", quest)
+	}
+	// Every code quest is recent by now, so the closest one repeats
+	if quest.Title != "Feature Builder" || quest.XP != 220 {
+		t.Fatalf("quest = %q worth %d XP, want Feature Builder at its catalog XP", quest.Title, quest.XP)
+	}
+	stored, err := repos.Quests.GetByID(ctx, quest.ID)
+	if err != nil || len(stored.Tasks) != quest.Total || stored.Tasks[0].ID == 0 || stored.Tasks[0].QuestID != quest.ID || stored.Tasks[0].OrderIndex != 1 {
+		t.Fatalf("stored quest = %+v, %v; want it saved with ordered tasks", stored, err)
+	}
+	if !reflect.DeepEqual(catalog, questCatalog) {
+		t.Fatal("Generate() changed the shared quest catalog")
+	}
+
+	// A frustrating session pulls the difficulty back down
+	now := time.Now()
+	if err := repos.Sessions.Create(ctx, &UserSession{UserID: user.ID, StartedAt: now, EndedAt: &now, LastEventAt: now, MoodDetected: string(ai.EmotionFrustrated)}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	focus, err := generator.Generate(ctx, user.ID, QuestRequest{Preferences: []string{"focus"}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if focus.Difficulty != 2 || focus.Total != 2*focusMinutesPerDifficulty || len(focus.Tasks) != 4 {
+		t.Fatalf("focus quest = %+v, want difficulty 2 counting 30 minutes", focus)
+	}
+
+	if _, err := generator.Generate(ctx, user.ID, QuestRequest{Preferences: []string{"poetry"}}); !errors.Is(err, ErrInvalidQuestRequest) {
+		t.Fatalf("Generate() with an unknown type: error = %v, want ErrInvalidQuestRequest", err)
+	}
+}
+
+func TestGenerateQuestUsesLLM(t *testing.T) {
+	f := newTestFixture(t)
+	server, usage := useFakeLLM(t, f, LoadUsageConfig())
+	reply := `{"title":"Table tests","description":"Cover a parser.","type":"test","difficulty":2,"xp":140,"tasks":["List cases","Write the table","Fix failures"]}`
+	server.SetReply(func(fakeopenai.Request) string { return reply })
+
+	rec := f.do("POST", "/api/v1/users/1/quests/generate", "owner", `{"preferences":["test"],"custom":true}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("generate status = %d: %s", rec.Code, rec.Body)
+	}
+	if quest := decodeJSON[Quest](t, rec.Body.Bytes()); quest.Title != "Table tests" || quest.XP != 140 || quest.Total != 3 || len(quest.Tasks) != 3 {
+		t.Fatalf("quest = %+v, want the LLM quest", quest)
+	}
+	prompt := server.Requests()[0].Messages
+	content := prompt[len(prompt)-1].Content
+	for _, want := range []string{"Target Difficulty: 2/5", "Preferred Quest Types: test", "Recent Success Rate: no finished quests yet", "10 min focus session"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, content)
+		}
+	}
+	if len(usage.records) != 1 || usage.records[0].TemplateID != "quest_generator" {
+		t.Fatalf("usage records = %+v, want the quest accounted", usage.records)
+	}
+
+	// The same quest again is a repeat, so the catalog answers instead
+	rec = f.do("POST", "/api/v1/users/1/quests/generate", "owner", `{"preferences":["test"],"custom":true}`)
+	if quest := decodeJSON[Quest](t, rec.Body.Bytes()); rec.Code != http.StatusCreated || quest.Title != "Test Pilot" {
+		t.Fatalf("status = %d, quest %+v; want the catalog quest after a repeated LLM quest", rec.Code, quest)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// QuestStatus is the lifecycle state of a quest
+type QuestStatus string
+
+const (
+	QuestStatusActive    QuestStatus = "active"
+	QuestStatusPaused    QuestStatus = "paused"
+	QuestStatusCompleted QuestStatus = "completed"
+	QuestStatusFailed    QuestStatus = "failed"
+)
+
+var (
+	// ErrInvalidQuestStatus is returned when a requested status is not a quest status
+	ErrInvalidQuestStatus = errors.New("invalid quest status")
+	// ErrInvalidTransition is returned when a quest cannot move to the requested status
+	ErrInvalidTransition = errors.New("invalid quest status transition")
+	// ErrQuestNotActive is returned when progress is reported on a quest that is not active
+	ErrQuestNotActive = errors.New("quest is not active")
+	// ErrInvalidProgress is returned when progress is outside 0..Total
+	ErrInvalidProgress = errors.New("progress out of range")
+	// ErrTaskAlreadyCompleted is returned when a quest task is completed twice
+	ErrTaskAlreadyCompleted = errors.New("quest task already completed")
+)
+
+// questTransitions lists the statuses each status may move to.
+// Completed and failed are terminal.
+var questTransitions = map[QuestStatus][]QuestStatus{
+	QuestStatusActive: {QuestStatusPaused, QuestStatusCompleted, QuestStatusFailed},
+	QuestStatusPaused: {QuestStatusActive, QuestStatusFailed},
+}
+
+// Valid reports whether the status is one of the quest statuses
+func (s QuestStatus) Valid() bool {
+	switch s {
+	case QuestStatusActive, QuestStatusPaused, QuestStatusCompleted, QuestStatusFailed:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether the quest may move to the given status
+func (q *Quest) CanTransition(to QuestStatus) bool {
+	for _, allowed := range questTransitions[q.Status] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOverdue reports whether an unfinished quest has passed its due date
+func (q *Quest) IsOverdue(now time.Time) bool {
+	if q.DueDate == nil || !q.DueDate.Before(now) {
+		return false
+	}
+	return q.Status == QuestStatusActive || q.Status == QuestStatusPaused
+}
+
+// QuestService methods for the quest lifecycle
+
+// Get returns a quest with its tasks, failing it first if it is overdue
+func (q *QuestService) Get(ctx context.Context, questID int) (*Quest, error) {
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	return q.load(ctx, questID)
+}
+
+// UpdateProgress sets a quest's progress. Progress must be between 0 and Total,
+// and reaching Total completes the quest.
+func (q *QuestService) UpdateProgress(ctx context.Context, questID, progress int) (*Quest, error) {
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	quest, err := q.load(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	if quest.Status != QuestStatusActive {
+		return nil, fmt.Errorf("%w: quest is %s", ErrQuestNotActive, quest.Status)
+	}
+	if progress < 0 || progress > quest.Total {
+		return nil, fmt.Errorf("%w: progress must be between 0 and %d", ErrInvalidProgress, quest.Total)
+	}
+
+	quest.Progress = progress
+	if err := q.quests.Update(ctx, quest); err != nil {
+		return nil, err
+	}
+	q.publish(EventQuestProgressed, quest, nil)
+
+	return q.completeIfDone(ctx, quest)
+}
+
+// AddProgress advances an active quest's progress by amount, capped at Total.
+// The repository adds and completes in one atomic step, so concurrent updates
+// from any process are not lost and only the one reaching Total completes the quest.
+func (q *QuestService) AddProgress(ctx context.Context, questID, amount int) (*Quest, error) {
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	quest, err := q.load(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	if quest.Status != QuestStatusActive {
+		return nil, fmt.Errorf("%w: quest is %s", ErrQuestNotActive, quest.Status)
+	}
+	if amount < 0 {
+		return nil, fmt.Errorf("%w: progress can only be added", ErrInvalidProgress)
+	}
+
+	quest, err = q.quests.AddProgress(ctx, questID, amount, q.now())
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: quest is no longer active", ErrQuestNotActive)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.publish(EventQuestProgressed, quest, nil)
+	if quest.Status == QuestStatusCompleted {
+		q.publish(EventQuestCompleted, quest, nil)
+	}
+	return quest, nil
+}
+
+// CompleteTask marks one of a quest's tasks as done and advances its progress by one
+func (q *QuestService) CompleteTask(ctx context.Context, questID, taskID int) (*Quest, error) {
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	quest, err := q.load(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	if quest.Status != QuestStatusActive {
+		return nil, fmt.Errorf("%w: quest is %s", ErrQuestNotActive, quest.Status)
+	}
+
+	task, err := q.quests.CompleteTask(ctx, questID, taskID, q.now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range quest.Tasks {
+		if quest.Tasks[i].ID == task.ID {
+			quest.Tasks[i] = *task
+		}
+	}
+
+	if quest.Progress < quest.Total {
+		quest.Progress++
+		if err := q.quests.Update(ctx, quest); err != nil {
+			return nil, err
+		}
+	}
+	q.publish(EventQuestTaskCompleted, quest, task)
+
+	return q.completeIfDone(ctx, quest)
+}
+
+// Transition moves a quest to a new status if the state machine allows it
+func (q *QuestService) Transition(ctx context.Context, questID int, to QuestStatus) (*Quest, error) {
+	if !to.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidQuestStatus, to)
+	}
+
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	quest, err := q.load(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.transition(ctx, quest, to); err != nil {
+		return nil, err
+	}
+	return quest, nil
+}
+
+// FailOverdue fails every active or paused quest whose due date has passed
+func (q *QuestService) FailOverdue(ctx context.Context) (int, error) {
+	q.mu.Lock()
+	defer q.flushEvents(ctx)
+	defer q.mu.Unlock()
+
+	overdue, err := q.quests.ListOverdue(ctx, q.now())
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for i := range overdue {
+		if err := q.transition(ctx, &overdue[i], QuestStatusFailed); err != nil {
+			return failed, err
+		}
+		failed++
+	}
+	return failed, nil
+}
+
+// RunOverdueSweeper fails overdue quests every interval until ctx is cancelled
+func (q *QuestService) RunOverdueSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := q.FailOverdue(ctx); err != nil {
+				log.Printf("fail overdue quests: %v", err)
+			} else if n > 0 {
+				log.Printf("failed %d overdue quests", n)
+			}
+		}
+	}
+}
+
+// load fetches a quest and lazily fails it if its due date has passed.
+// Callers must hold q.mu.
+func (q *QuestService) load(ctx context.Context, questID int) (*Quest, error) {
+	quest, err := q.quests.GetByID(ctx, questID)
+	if err != nil {
+		return nil, err
+	}
+	if quest.IsOverdue(q.now()) {
+		if err := q.transition(ctx, quest, QuestStatusFailed); err != nil {
+			return nil, err
+		}
+	}
+	return quest, nil
+}
+
+// completeIfDone completes an active quest whose progress has reached its total
+func (q *QuestService) completeIfDone(ctx context.Context, quest *Quest) (*Quest, error) {
+	if quest.Status == QuestStatusActive && quest.Total > 0 && quest.Progress >= quest.Total {
+		if err := q.transition(ctx, quest, QuestStatusCompleted); err != nil {
+			return nil, err
+		}
+	}
+	return quest, nil
+}
+
+// transition validates, persists and publishes a status change. Callers must hold q.mu.
+func (q *QuestService) transition(ctx context.Context, quest *Quest, to QuestStatus) error {
+	if !quest.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, quest.Status, to)
+	}
+
+	from, completedAt, progress := quest.Status, quest.CompletedAt, quest.Progress
+	quest.Status = to
+	if to == QuestStatusCompleted {
+		now := q.now()
+		quest.CompletedAt = &now
+		quest.Progress = quest.Total
+	}
+	if err := q.quests.Update(ctx, quest); err != nil {
+		quest.Status, quest.CompletedAt, quest.Progress = from, completedAt, progress
+		return err
+	}
+
+	switch {
+	case to == QuestStatusCompleted:
+		q.publish(EventQuestCompleted, quest, nil)
+	case to == QuestStatusFailed:
+		q.publish(EventQuestFailed, quest, nil)
+	case to == QuestStatusPaused:
+		q.publish(EventQuestPaused, quest, nil)
+	case to == QuestStatusActive && from == QuestStatusPaused:
+		q.publish(EventQuestResumed, quest, nil)
+	}
+	return nil
+}
+
+// publish queues an event for delivery once q.mu is released. Callers must hold q.mu.
+func (q *QuestService) publish(eventType EventType, quest *Quest, task *QuestTask) {
+	snapshot := *quest
+	q.pending = append(q.pending, Event{
+		Type:       eventType,
+		UserID:     quest.UserID,
+		OccurredAt: q.now(),
+		Quest:      &snapshot,
+		Task:       task,
+	})
+}
+
+// flushEvents delivers queued events outside the lock so subscribers may call back into the service
+func (q *QuestService) flushEvents(ctx context.Context) {
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	if q.events == nil {
+		return
+	}
+	for _, event := range pending {
+		q.events.Publish(ctx, event)
+	}
+}
+
+// Quest handlers
+
+// writeQuestError maps quest lifecycle errors to HTTP responses
+func writeQuestError(w http.ResponseWriter, questID int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Quest or task not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidProgress), errors.Is(err, ErrInvalidQuestStatus):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrQuestNotActive), errors.Is(err, ErrTaskAlreadyCompleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("quest %d: %v", questID, err)
+		http.Error(w, "Failed to update quest", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) getQuestHandler(w http.ResponseWriter, r *http.Request) {
+	questID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid quest ID", http.StatusBadRequest)
+		return
+	}
+
+	quest, err := s.quests.Get(r.Context(), questID)
+	if err != nil {
+		writeQuestError(w, questID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quest)
+}
+
+func (s *Server) getQuestTasksHandler(w http.ResponseWriter, r *http.Request) {
+	questID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid quest ID", http.StatusBadRequest)
+		return
+	}
+
+	quest, err := s.quests.Get(r.Context(), questID)
+	if err != nil {
+		writeQuestError(w, questID, err)
+		return
+	}
+
+	tasks := quest.Tasks
+	if tasks == nil {
+		tasks = []QuestTask{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+func (s *Server) completeQuestTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid quest ID", http.StatusBadRequest)
+		return
+	}
+	taskID, err := strconv.Atoi(vars["taskId"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	quest, err := s.quests.CompleteTask(r.Context(), questID, taskID)
+	if err != nil {
+		writeQuestError(w, questID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quest)
+}
+
+func (s *Server) updateQuestStatusHandler(w http.ResponseWriter, r *http.Request) {
+	questID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid quest ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status QuestStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	quest, err := s.quests.Transition(r.Context(), questID, req.Status)
+	if err != nil {
+		writeQuestError(w, questID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quest)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestQuestService returns a quest service on seeded in-memory data with a fixed
+// clock, plus a pointer to the list of event types it publishes.
+func newTestQuestService(t *testing.T) (*QuestService, QuestRepository, *[]EventType) {
+	t.Helper()
+	repo := NewMemoryRepositories().Quests
+	events := NewEventBus()
+	var published []EventType
+	events.Subscribe(func(ctx context.Context, event Event) error {
+		published = append(published, event.Type)
+		return nil
+	}, EventQuestProgressed, EventQuestTaskCompleted, EventQuestCompleted, EventQuestPaused, EventQuestResumed, EventQuestFailed)
+
+	service := NewQuestService(repo, events)
+	service.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return service, repo, &published
+}
+
+func TestQuestUpdateProgressAutoCompletes(t *testing.T) {
+	service, _, published := newTestQuestService(t)
+	ctx := context.Background()
+
+	quest, err := service.UpdateProgress(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("UpdateProgress() error = %v", err)
+	}
+	if quest.Status != QuestStatusCompleted || quest.CompletedAt == nil {
+		t.Fatalf("quest = %+v, want completed with completed_at", quest)
+	}
+	want := []EventType{EventQuestProgressed, EventQuestCompleted}
+	if !reflect.DeepEqual(*published, want) {
+		t.Fatalf("events = %v, want %v", *published, want)
+	}
+
+	if _, err := service.UpdateProgress(ctx, 1, 2); !errors.Is(err, ErrQuestNotActive) {
+		t.Fatalf("progress on completed quest: error = %v, want ErrQuestNotActive", err)
+	}
+}
+
+func TestQuestUpdateProgressBounds(t *testing.T) {
+	service, _, _ := newTestQuestService(t)
+	for _, progress := range []int{-1, 4} {
+		if _, err := service.UpdateProgress(context.Background(), 1, progress); !errors.Is(err, ErrInvalidProgress) {
+			t.Errorf("UpdateProgress(%d) error = %v, want ErrInvalidProgress", progress, err)
+		}
+	}
+}
+
+func TestQuestAddProgressIsAtomic(t *testing.T) {
+	service := NewQuestService(NewMemoryRepositories().Quests, NewEventBus())
+	ctx := context.Background()
+
+	// quest 2 starts at 7 of 10; concurrent additions must not overwrite each other
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.AddProgress(ctx, 2, 1); err != nil {
+				t.Errorf("AddProgress() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	quest, err := service.Get(ctx, 2)
+	if err != nil || quest.Progress != 9 || quest.Status != QuestStatusActive {
+		t.Fatalf("quest = %+v, %v; want both additions at 9 of 10", quest, err)
+	}
+
+	quest, err = service.AddProgress(ctx, 2, 5)
+	if err != nil || quest.Progress != 10 || quest.Status != QuestStatusCompleted {
+		t.Fatalf("quest = %+v, %v; want progress capped at 10 and the quest completed", quest, err)
+	}
+	if _, err := service.AddProgress(ctx, 3, -1); !errors.Is(err, ErrInvalidProgress) {
+		t.Fatalf("AddProgress(-1) error = %v, want ErrInvalidProgress", err)
+	}
+}
+
+func TestQuestAddProgressIsAtomicAcrossServices(t *testing.T) {
+	repo := NewMemoryRepositories().Quests
+	ctx := context.Background()
+	var mu sync.Mutex
+	completions := 0
+	events := NewEventBus()
+	events.Subscribe(func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		completions++
+		return nil
+	}, EventQuestCompleted)
+
+	// Two services on one store stand in for two backend processes on one database.
+	// Quest 2 is at 7 of 10: six additions overshoot, and only one may complete it.
+	services := []*QuestService{NewQuestService(repo, events), NewQuestService(repo, events)}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(service *QuestService) {
+			defer wg.Done()
+			if _, err := service.AddProgress(ctx, 2, 1); err != nil && !errors.Is(err, ErrQuestNotActive) {
+				t.Errorf("AddProgress() error = %v", err)
+			}
+		}(services[i%2])
+	}
+	wg.Wait()
+
+	quest, err := repo.GetByID(ctx, 2)
+	if err != nil || quest.Progress != 10 || quest.Status != QuestStatusCompleted || quest.CompletedAt == nil {
+		t.Fatalf("quest = %+v, %v; want it completed at 10 of 10", quest, err)
+	}
+	if completions != 1 {
+		t.Fatalf("completion events = %d, want 1", completions)
+	}
+}
+
+func TestQuestCompleteTask(t *testing.T) {
+	service, _, published := newTestQuestService(t)
+	ctx := context.Background()
+
+	quest, err := service.CompleteTask(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if quest.Progress != 3 || quest.Status != QuestStatusCompleted {
+		t.Fatalf("quest progress/status = %d/%s, want 3/completed", quest.Progress, quest.Status)
+	}
+	if !quest.Tasks[2].Completed {
+		t.Fatalf("task 3 not marked completed")
+	}
+	want := []EventType{EventQuestTaskCompleted, EventQuestCompleted}
+	if !reflect.DeepEqual(*published, want) {
+		t.Fatalf("events = %v, want %v", *published, want)
+	}
+
+	if _, err := service.CompleteTask(ctx, 2, 4); !errors.Is(err, ErrTaskAlreadyCompleted) {
+		t.Fatalf("completing a done task: error = %v, want ErrTaskAlreadyCompleted", err)
+	}
+	if _, err := service.CompleteTask(ctx, 2, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("completing another quest's task: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestQuestTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []QuestStatus
+		wantErr error
+	}{
+		{"pause and resume", []QuestStatus{QuestStatusPaused, QuestStatusActive}, nil},
+		{"fail while paused", []QuestStatus{QuestStatusPaused, QuestStatusFailed}, nil},
+		{"complete directly", []QuestStatus{QuestStatusCompleted}, nil},
+		{"cannot complete while paused", []QuestStatus{QuestStatusPaused, QuestStatusCompleted}, ErrInvalidTransition},
+		{"completed is terminal", []QuestStatus{QuestStatusCompleted, QuestStatusActive}, ErrInvalidTransition},
+		{"failed is terminal", []QuestStatus{QuestStatusFailed, QuestStatusPaused}, ErrInvalidTransition},
+		{"unknown status", []QuestStatus{"archived"}, ErrInvalidQuestStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestQuestService(t)
+			var err error
+			for _, status := range tt.path {
+				if _, err = service.Transition(context.Background(), 3, status); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// failingQuestRepository fails every Update, like a database that is down
+type failingQuestRepository struct {
+	QuestRepository
+}
+
+func (r failingQuestRepository) Update(ctx context.Context, quest *Quest) error {
+	return errors.New("pq: connection refused")
+}
+
+func TestQuestTransitionRestoresQuestOnUpdateFailure(t *testing.T) {
+	service, repo, published := newTestQuestService(t)
+	ctx := context.Background()
+	quest, err := repo.GetByID(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	before := *quest
+
+	service.quests = failingQuestRepository{repo}
+	if err := service.transition(ctx, quest, QuestStatusCompleted); err == nil {
+		t.Fatal("transition() error = nil, want the update failure")
+	}
+	if quest.Status != before.Status || quest.CompletedAt != nil || quest.Progress != before.Progress {
+		t.Fatalf("quest = %+v, want it restored to %+v", quest, before)
+	}
+	if len(*published) != 0 {
+		t.Fatalf("events = %v, want none", *published)
+	}
+}
+
+func TestQuestFailOverdue(t *testing.T) {
+	service, repo, published := newTestQuestService(t)
+	ctx := context.Background()
+
+	past := service.now().Add(-time.Hour)
+	quest, _ := repo.GetByID(ctx, 2)
+	quest.DueDate = &past
+	if err := repo.Update(ctx, quest); err != nil {
+		t.Fatalf("set due date: %v", err)
+	}
+
+	failed, err := service.FailOverdue(ctx)
+	if err != nil || failed != 1 {
+		t.Fatalf("FailOverdue() = %d, %v, want 1, nil", failed, err)
+	}
+	quest, _ = service.Get(ctx, 2)
+	if quest.Status != QuestStatusFailed {
+		t.Fatalf("status = %s, want failed", quest.Status)
+	}
+	if !reflect.DeepEqual(*published, []EventType{EventQuestFailed}) {
+		t.Fatalf("events = %v, want [quest.failed]", *published)
+	}
+}
+
+func TestQuestLazilyFailsOverdueOnAccess(t *testing.T) {
+	service, repo, _ := newTestQuestService(t)
+	ctx := context.Background()
+
+	past := service.now().Add(-time.Minute)
+	quest, _ := repo.GetByID(ctx, 3)
+	quest.DueDate = &past
+	repo.Update(ctx, quest)
+
+	if _, err := service.UpdateProgress(ctx, 3, 1); !errors.Is(err, ErrQuestNotActive) {
+		t.Fatalf("progress on overdue quest: error = %v, want ErrQuestNotActive", err)
+	}
+}
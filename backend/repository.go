@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned by repositories when a unique constraint would be violated
+var ErrDuplicate = errors.New("record already exists")
+
+// UserRepository provides access to user records
+type UserRepository interface {
+	GetByID(ctx context.Context, id int) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Create(ctx context.Context, user *User) error
+	// SetBuddyPersonality stores the user's chosen personality and whether it is locked
+	SetBuddyPersonality(ctx context.Context, userID int, personality string, locked bool) error
+}
+
+// QuestRepository provides access to quests and their tasks
+type QuestRepository interface {
+	// GetByID returns a quest including its tasks
+	GetByID(ctx context.Context, id int) (*Quest, error)
+	ListByUser(ctx context.Context, userID int) ([]Quest, error)
+	// ListOverdue returns active or paused quests whose due date is before now
+	ListOverdue(ctx context.Context, now time.Time) ([]Quest, error)
+	// Create inserts a quest with its tasks and fills in their IDs
+	Create(ctx context.Context, quest *Quest) error
+	// Update persists a quest's progress, status and timestamps
+	Update(ctx context.Context, quest *Quest) error
+	// AddProgress adds amount to an active quest's progress, capped at its total,
+	// in one atomic step, and completes the quest at the given time when its
+	// progress reaches the total. It returns the updated quest, or ErrNotFound
+	// when no active quest has the ID.
+	AddProgress(ctx context.Context, questID, amount int, at time.Time) (*Quest, error)
+	// CompleteTask marks a task as completed. It returns ErrNotFound when the task
+	// does not belong to the quest and ErrTaskAlreadyCompleted when it is already done.
+	CompleteTask(ctx context.Context, questID, taskID int, at time.Time) (*QuestTask, error)
+}
+
+// BadgeRepository provides access to badges and the badges a user has earned
+type BadgeRepository interface {
+	// ListByUser returns every badge, flagging the ones the user has earned
+	ListByUser(ctx context.Context, userID int) ([]Badge, error)
+	// ListDefinitions returns every badge with its requirements
+	ListDefinitions(ctx context.Context) ([]BadgeDefinition, error)
+	// Award records that a user earned a badge. It is idempotent and reports
+	// whether the badge was newly awarded.
+	Award(ctx context.Context, userID, badgeID int, at time.Time) (bool, error)
+}
+
+// ActivityRepository is an append-only log of counted learner activity
+// (quests completed, early sessions, users helped, ...) used by badge rules
+type ActivityRepository interface {
+	Record(ctx context.Context, userID int, stat string, amount int, at time.Time) error
+	// Totals sums each stat's activity for a user since the given time
+	Totals(ctx context.Context, userID int, since time.Time) (map[string]int, error)
+}
+
+// ConversationRepository stores AI buddy conversations
+type ConversationRepository interface {
+	// Create saves a chat turn and marks its thread as updated
+	Create(ctx context.Context, message *BuddyMessage) error
+	ListByUser(ctx context.Context, userID int) ([]BuddyMessage, error)
+
+	CreateThread(ctx context.Context, thread *ConversationThread) error
+	// GetThread returns ErrNotFound when the thread does not exist
+	GetThread(ctx context.Context, threadID int) (*ConversationThread, error)
+	// ListThreads returns a user's threads, most recently updated first
+	ListThreads(ctx context.Context, userID int) ([]ConversationThread, error)
+	// ListThreadMessages returns a thread's turns, oldest first
+	ListThreadMessages(ctx context.Context, threadID int) ([]BuddyMessage, error)
+	// UpdateSummary stores the rolling memory note covering turns up to summarizedThrough
+	UpdateSummary(ctx context.Context, threadID int, summary string, summarizedThrough int) error
+	// DeleteThread removes a thread and its turns. It returns ErrNotFound when the thread does not exist.
+	DeleteThread(ctx context.Context, threadID int) error
+}
+
+// RefreshTokenRepository tracks issued refresh tokens so they can be revoked
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token RefreshToken) error
+	// Revoke marks an active token as revoked. It returns ErrNotFound when the
+	// token does not exist or was already revoked.
+	Revoke(ctx context.Context, id string) error
+}
+
+// XPRepository is the append-only XP ledger backed by xp_transactions
+type XPRepository interface {
+	// Record inserts a transaction and, in the same database transaction, adds its
+	// amount to the user's total XP and sets their level to levelFor(newTotal).
+	// It fills in the transaction's ID and CreatedAt and returns the updated user
+	// with the level they had before, read from the same locked row. A user is
+	// credited at most once per badge: a second badge transaction for the same
+	// source returns ErrDuplicate and changes nothing.
+	Record(ctx context.Context, tx *XPTransaction, levelFor func(totalXP int) int) (*User, int, error)
+	// RecordCapped records tx like Record, first trimming its amount so that the
+	// user's transactions of its source type since the given time add up to at
+	// most limit. It returns ErrXPCapReached, recording nothing, when none is left.
+	RecordCapped(ctx context.Context, tx *XPTransaction, since time.Time, limit int, levelFor func(totalXP int) int) (*User, int, error)
+	// CreditedSources returns the source IDs of the user's transactions of sourceType
+	CreditedSources(ctx context.Context, userID int, sourceType string) (map[int]bool, error)
+	// ListByUser returns a page of a user's transactions, newest first, and the total count
+	ListByUser(ctx context.Context, userID, limit, offset int) ([]XPTransaction, int, error)
+}
+
+// StreakRepository loads and saves users' streak counters
+type StreakRepository interface {
+	Get(ctx context.Context, userID int) (*StreakState, error)
+	// Save persists the current and max streak, last activity and freeze tokens
+	Save(ctx context.Context, state *StreakState) error
+}
+
+// MoodLogRepository records the emotions detected for users
+type MoodLogRepository interface {
+	// Record inserts a log entry and fills in its ID
+	Record(ctx context.Context, entry *MoodDetectionLog) error
+}
+
+// UsageRepository is an append-only log of LLM token usage
+type UsageRepository interface {
+	// Record inserts a usage row and fills in its ID
+	Record(ctx context.Context, usage *AIUsage) error
+	// TotalTokens sums a user's tokens since the given time
+	TotalTokens(ctx context.Context, userID int, since time.Time) (int, error)
+	// Summarize totals usage in [from, to) by user, template and model
+	Summarize(ctx context.Context, from, to time.Time) ([]UsageSummary, error)
+}
+
+// PersonalityRepository stores user-defined buddy personalities
+type PersonalityRepository interface {
+	// Create inserts a personality and fills in its ID and CreatedAt. Keys are
+	// unique per owner; it returns ErrDuplicate when the owner already uses the key.
+	Create(ctx context.Context, personality *CustomPersonality) error
+	// ListVisible returns the shared personalities and those the user created, oldest first
+	ListVisible(ctx context.Context, userID int) ([]CustomPersonality, error)
+	// Get returns the personality the user sees under key: their own, else a
+	// shared one. It returns ErrNotFound when there is neither.
+	Get(ctx context.Context, userID int, key string) (*CustomPersonality, error)
+	// Delete removes a personality; users who chose it lose their preference.
+	// It returns ErrNotFound when the personality does not exist.
+	Delete(ctx context.Context, id int) error
+}
+
+// LearningPathRepository provides access to learning paths and users' progress on them
+type LearningPathRepository interface {
+	// List returns every learning path, active or not, by ID
+	List(ctx context.Context) ([]LearningPath, error)
+	GetByID(ctx context.Context, id int) (*LearningPath, error)
+	// Create inserts a path and fills in its ID
+	Create(ctx context.Context, path *LearningPath) error
+	// Update replaces a path's fields. It returns ErrNotFound when the path does not exist.
+	Update(ctx context.Context, path *LearningPath) error
+
+	// ListProgress returns a user's enrollments, oldest first
+	ListProgress(ctx context.Context, userID int) ([]LearningPathProgress, error)
+	// GetProgress returns ErrNotFound when the user is not enrolled in the path
+	GetProgress(ctx context.Context, userID, pathID int) (*LearningPathProgress, error)
+	// CreateProgress enrolls a user and fills in the ID. It returns ErrDuplicate
+	// when the user is already enrolled.
+	CreateProgress(ctx context.Context, progress *LearningPathProgress) error
+	// UpdateProgress persists the current step, percentage and completion time
+	UpdateProgress(ctx context.Context, progress *LearningPathProgress) error
+}
+
+// ProjectRepository provides access to users' DIY projects
+type ProjectRepository interface {
+	// ListByUser returns a user's projects, newest first
+	ListByUser(ctx context.Context, userID int) ([]Project, error)
+	GetByID(ctx context.Context, id int) (*Project, error)
+	// Create inserts a project and fills in its ID
+	Create(ctx context.Context, project *Project) error
+	// Update persists a project's status, milestones and timestamps. It returns
+	// ErrNotFound when the project does not exist.
+	Update(ctx context.Context, project *Project) error
+}
+
+// SessionRepository stores learning sessions
+type SessionRepository interface {
+	// Create inserts a session and fills in its ID
+	Create(ctx context.Context, session *UserSession) error
+	GetByID(ctx context.Context, id int) (*UserSession, error)
+	// GetOpen returns the user's unended session, or ErrNotFound when there is none
+	GetOpen(ctx context.Context, userID int) (*UserSession, error)
+	// Recent returns up to limit of the user's latest sessions, newest first
+	Recent(ctx context.Context, userID, limit int) ([]UserSession, error)
+	// Update persists a session's counters, end and mood. It returns ErrNotFound
+	// when the session does not exist.
+	Update(ctx context.Context, session *UserSession) error
+}
+
+// FocusSessionRepository stores focus session timers
+type FocusSessionRepository interface {
+	// Create inserts a focus session and fills in its ID
+	Create(ctx context.Context, session *FocusSession) error
+	GetByID(ctx context.Context, id int) (*FocusSession, error)
+	// GetActive returns the user's running or paused session, or ErrNotFound when there is none
+	GetActive(ctx context.Context, userID int) (*FocusSession, error)
+	// Update persists a session's status, clock and verification fields. It
+	// returns ErrNotFound when the session does not exist.
+	Update(ctx context.Context, session *FocusSession) error
+}
+
+// Repositories groups every repository the HTTP handlers depend on
+type Repositories struct {
+	Users         UserRepository
+	Quests        QuestRepository
+	Badges        BadgeRepository
+	Conversations ConversationRepository
+	RefreshTokens RefreshTokenRepository
+	XP            XPRepository
+	Activity      ActivityRepository
+	Streaks       StreakRepository
+	Usage         UsageRepository
+	Moods         MoodLogRepository
+	Personalities PersonalityRepository
+	LearningPaths LearningPathRepository
+	Projects      ProjectRepository
+	Sessions      SessionRepository
+	FocusSessions FocusSessionRepository
+}
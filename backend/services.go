@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
 	"time"
-)
 
-// AuthService handles user authentication and authorization
-type AuthService struct {
-	// In a real app, this would connect to a database
-}
+	ai "learning-buddy-ai"
+)
 
 // UserService handles user-related operations
 type UserService struct {
@@ -17,28 +19,140 @@ type UserService struct {
 
 // QuestService handles quest and gamification logic
 type QuestService struct {
-	// In a real app, this would connect to a database
+	quests  QuestRepository
+	events  *EventBus
+	now     func() time.Time
+	mu      sync.Mutex // serializes quest read-modify-write cycles
+	pending []Event    // events queued while mu is held
+}
+
+// BuddyAI is the subset of the ai-core engine the backend depends on. buddyCore
+// adapts *ai.AICore to it.
+type BuddyAI interface {
+	AnalyzeEmotion(ctx context.Context, behavior ai.UserBehaviorData) (*ai.EmotionDetection, *ai.AIResponse, error)
+	SelectPersonality(req ai.PersonalityRequest) ai.PersonalityChoice
+	Personality(personality ai.BuddyPersonality) (ai.PersonalityConfig, bool)
+	GenerateResponseWithProvider(ctx context.Context, templateID string, variables map[string]interface{}, personality ai.BuddyPersonality, history []ai.Message) (*ai.AIResponse, error)
+	GenerateMockResponse(ctx context.Context, templateID string, variables map[string]interface{}, personality ai.BuddyPersonality) (*ai.AIResponse, error)
+	StreamResponseWithProvider(ctx context.Context, templateID string, variables map[string]interface{}, personality ai.BuddyPersonality, history []ai.Message, onToken func(string) error) (*ai.AIResponse, error)
+	StreamMockResponse(ctx context.Context, templateID string, variables map[string]interface{}, personality ai.BuddyPersonality, onToken func(string) error) (*ai.AIResponse, error)
+	PersonalityTemplateID(id string, personality ai.BuddyPersonality) string
+	PlanProject(ctx context.Context, variables map[string]interface{}) (*ai.ProjectPlan, *ai.AIResponse, error)
+	MockPlanProject(ctx context.Context, variables map[string]interface{}) (*ai.ProjectPlan, *ai.AIResponse, error)
+	PlanQuest(ctx context.Context, variables map[string]interface{}) (*ai.QuestPlan, *ai.AIResponse, error)
+	Personalities() []ai.BuddyPersonality
+	WithPersonalities(custom map[ai.BuddyPersonality]ai.PersonalityConfig) BuddyAI
+	SummarizeConversation(ctx context.Context, previous string, turns []ai.Message) (string, *ai.AIResponse, error)
+}
+
+// buddyCore is the ai-core engine as a BuddyAI
+type buddyCore struct {
+	*ai.AICore
+}
+
+// WithPersonalities returns a view of the engine that also knows the given personalities
+func (c buddyCore) WithPersonalities(custom map[ai.BuddyPersonality]ai.PersonalityConfig) BuddyAI {
+	return buddyCore{c.AICore.WithPersonalities(custom)}
 }
 
 // AIService handles AI buddy interactions
 type AIService struct {
-	// In a real app, this would connect to OpenAI API
-	OpenAIKey string
+	core   BuddyAI
+	useLLM bool
+	usage  *UsageService     // quotas and token accounting; nil disables both
+	moods  MoodLogRepository // where emotion detections are logged; nil skips logging
+	// personalities holds user-defined personalities; nil offers only the configured ones
+	personalities PersonalityRepository
+}
+
+// UsageService accounts LLM tokens per user and enforces plan quotas
+type UsageService struct {
+	usage  UsageRepository
+	users  UserRepository
+	config UsageConfig
+	now    func() time.Time
+}
+
+// ConversationService manages buddy chat threads and their memory
+type ConversationService struct {
+	conversations ConversationRepository
+	summarizer    Summarizer
+	config        ConversationConfig
+	now           func() time.Time
 }
 
 // XPService handles experience points and leveling
 type XPService struct {
-	// In a real app, this would connect to a database
+	ledger XPRepository
+	users  UserRepository
+	events *EventBus
 }
 
 // BadgeService handles achievement badges
 type BadgeService struct {
-	// In a real app, this would connect to a database
+	badges   BadgeRepository
+	activity ActivityRepository
+	users    UserRepository
+	xp       *XPService
+	events   *EventBus
+	now      func() time.Time
+}
+
+// StreakService tracks daily learning streaks
+type StreakService struct {
+	streaks StreakRepository
+	xp      *XPService
+	events  *EventBus
+	config  StreakConfig
+	now     func() time.Time
+	mu      sync.Mutex // serializes streak read-modify-write cycles
+}
+
+// LearningPathService manages learning paths, their prerequisites and users' progress
+type LearningPathService struct {
+	paths LearningPathRepository
+	now   func() time.Time
+	mu    sync.Mutex // serializes prerequisite checks and progress read-modify-write cycles
+}
+
+// ProjectService plans DIY projects and tracks their milestones
+type ProjectService struct {
+	projects ProjectRepository
+	users    UserRepository
+	planner  ProjectPlanner
+	events   *EventBus
+	now      func() time.Time
+	mu       sync.Mutex // serializes project read-modify-write cycles
+	pending  []Event    // events queued while mu is held
+}
+
+// QuestGenerator creates quests calibrated to each learner's history and preferences
+type QuestGenerator struct {
+	quests   QuestRepository
+	users    UserRepository
+	sessions SessionRepository
+	planner  QuestPlanner
 }
 
-// NewAuthService creates a new auth service instance
-func NewAuthService() *AuthService {
-	return &AuthService{}
+// SessionService tracks learning sessions and builds users' behavior from them
+type SessionService struct {
+	sessions SessionRepository
+	users    UserRepository
+	quests   QuestRepository
+	badges   *BadgeService
+	now      func() time.Time
+	mu       sync.Mutex // serializes session read-modify-write cycles
+}
+
+// FocusService runs focus session timers and credits their verified minutes
+type FocusService struct {
+	sessions  FocusSessionRepository
+	quests    *QuestService
+	questRepo QuestRepository
+	badges    *BadgeService
+	config    FocusConfig
+	now       func() time.Time
+	mu        sync.Mutex // serializes focus session read-modify-write cycles
 }
 
 // NewUserService creates a new user service instance
@@ -47,74 +161,299 @@ func NewUserService() *UserService {
 }
 
 // NewQuestService creates a new quest service instance
-func NewQuestService() *QuestService {
-	return &QuestService{}
+func NewQuestService(quests QuestRepository, events *EventBus) *QuestService {
+	return &QuestService{
+		quests: quests,
+		events: events,
+		now:    time.Now,
+	}
 }
 
-// NewAIService creates a new AI service instance
-func NewAIService(apiKey string) *AIService {
+// NewStreakService creates a new streak service instance
+func NewStreakService(streaks StreakRepository, xp *XPService, events *EventBus, config StreakConfig) *StreakService {
+	return &StreakService{
+		streaks: streaks,
+		xp:      xp,
+		events:  events,
+		config:  config,
+		now:     time.Now,
+	}
+}
+
+// NewAIService creates a new AI service instance. Replies come from the core's
+// LLM provider, or from the deterministic mock when it has none.
+func NewAIService(core *ai.AICore) *AIService {
 	return &AIService{
-		OpenAIKey: apiKey,
+		core:   buddyCore{core},
+		useLLM: !core.UsesMock(),
+	}
+}
+
+// NewLearningPathService creates a new learning path service instance
+func NewLearningPathService(paths LearningPathRepository) *LearningPathService {
+	return &LearningPathService{
+		paths: paths,
+		now:   time.Now,
+	}
+}
+
+// NewProjectService creates a new project service instance
+func NewProjectService(projects ProjectRepository, users UserRepository, planner ProjectPlanner, events *EventBus) *ProjectService {
+	return &ProjectService{
+		projects: projects,
+		users:    users,
+		planner:  planner,
+		events:   events,
+		now:      time.Now,
+	}
+}
+
+// NewQuestGenerator creates a new quest generator instance
+func NewQuestGenerator(quests QuestRepository, users UserRepository, sessions SessionRepository, planner QuestPlanner) *QuestGenerator {
+	return &QuestGenerator{
+		quests:   quests,
+		users:    users,
+		sessions: sessions,
+		planner:  planner,
+	}
+}
+
+// NewSessionService creates a new session service instance
+func NewSessionService(sessions SessionRepository, users UserRepository, quests QuestRepository, badges *BadgeService) *SessionService {
+	return &SessionService{
+		sessions: sessions,
+		users:    users,
+		quests:   quests,
+		badges:   badges,
+		now:      time.Now,
+	}
+}
+
+// NewFocusService creates a new focus service instance
+func NewFocusService(sessions FocusSessionRepository, quests *QuestService, questRepo QuestRepository, badges *BadgeService, config FocusConfig) *FocusService {
+	return &FocusService{
+		sessions:  sessions,
+		quests:    quests,
+		questRepo: questRepo,
+		badges:    badges,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// NewUsageService creates a new usage service instance
+func NewUsageService(usage UsageRepository, users UserRepository, config UsageConfig) *UsageService {
+	return &UsageService{
+		usage:  usage,
+		users:  users,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// NewConversationService creates a new conversation service instance
+func NewConversationService(conversations ConversationRepository, summarizer Summarizer, config ConversationConfig) *ConversationService {
+	return &ConversationService{
+		conversations: conversations,
+		summarizer:    summarizer,
+		config:        config,
+		now:           time.Now,
 	}
 }
 
 // NewXPService creates a new XP service instance
-func NewXPService() *XPService {
-	return &XPService{}
+func NewXPService(ledger XPRepository, users UserRepository, events *EventBus) *XPService {
+	return &XPService{
+		ledger: ledger,
+		users:  users,
+		events: events,
+	}
 }
 
 // NewBadgeService creates a new badge service instance
-func NewBadgeService() *BadgeService {
-	return &BadgeService{}
+func NewBadgeService(badges BadgeRepository, activity ActivityRepository, users UserRepository, xp *XPService, events *EventBus) *BadgeService {
+	return &BadgeService{
+		badges:   badges,
+		activity: activity,
+		users:    users,
+		xp:       xp,
+		events:   events,
+		now:      time.Now,
+	}
 }
 
 // AIService methods
 
-// GenerateResponse generates an AI response based on user input and mood
-func (ai *AIService) GenerateResponse(userMessage, mood string) (string, error) {
-	// This is a placeholder for OpenAI integration
-	// In a real implementation, you would:
-	// 1. Create a prompt based on the mood and user message
-	// 2. Call OpenAI API with the prompt
-	// 3. Process and return the response
-
-	// Mock responses for demonstration
-	responses := map[string][]string{
-		"mentor": {
-			"Let me guide you through this step by step. First, let's understand the core concept...",
-			"This is a great learning opportunity. Here's how I'd approach this problem...",
-			"I can see you're thinking deeply about this. Let me share some insights...",
-		},
-		"cheerleader": {
-			"You're absolutely crushing it! This question shows you're really engaged! 🎉",
-			"I love your curiosity! You're going to master this concept in no time! 💪",
-			"Way to go for asking great questions! You're on the right track! ⭐",
-		},
-		"chill": {
-			"No worries, this stuff can be tricky. Let's just take it one step at a time...",
-			"Hey, totally get why this might be confusing. Here's a simple way to think about it...",
-			"All good! This is actually pretty common. Here's what I'd suggest...",
-		},
-		"focused": {
-			"Let's analyze this systematically. The key factors we need to consider are...",
-			"Good question. Let's break down the problem into its core components...",
-			"Focusing on the essentials: here's the most efficient approach...",
-		},
+// chatPrompt is everything needed to generate one buddy reply
+type chatPrompt struct {
+	core        BuddyAI // the engine with the user's custom personalities
+	templateID  string
+	variables   map[string]interface{}
+	personality ai.BuddyPersonality
+	reasoning   string // why the personality policy chose personality
+	emotion     ai.EmotionState
+	confidence  float64
+}
+
+// GenerateResponse generates the buddy's reply to a chat message, with history
+// holding the earlier turns of the thread. A requested mood locks the buddy
+// personality for this reply; otherwise the personality policy picks it. The
+// prompt template comes from the emotion detected in the user's behavior. LLM
+// failures fall back to the mock.
+func (s *AIService) GenerateResponse(ctx context.Context, user *User, behavior ai.UserBehaviorData, history []ai.Message, message, mood string) (*ai.AIResponse, error) {
+	llmCtx, decision := s.decide(ctx, user)
+	prompt, err := s.chatPrompt(llmCtx, user, behavior, message, mood, decision)
+	if err != nil {
+		return nil, err
 	}
 
-	responseList, exists := responses[mood]
-	if !exists {
-		responseList = responses["mentor"]
+	var response *ai.AIResponse
+	if !decision.UseMock {
+		response, err = prompt.core.GenerateResponseWithProvider(llmCtx, prompt.templateID, prompt.variables, prompt.personality, history)
+		if err != nil {
+			log.Printf("llm reply for user %d, falling back to mock: %v", user.ID, err)
+		} else {
+			s.recordUsage(ctx, user, response, decision)
+		}
+	}
+	if response == nil {
+		if response, err = prompt.core.GenerateMockResponse(ctx, prompt.templateID, prompt.variables, prompt.personality); err != nil {
+			return nil, err
+		}
 	}
+	return prompt.finish(response), nil
+}
 
-	// Simple selection based on message length (in real app, use AI)
-	response := responseList[len(userMessage)%len(responseList)]
+// StreamResponse is GenerateResponse with each token passed to onToken as it is
+// generated. It falls back to the mock only if the LLM fails before the first token.
+func (s *AIService) StreamResponse(ctx context.Context, user *User, behavior ai.UserBehaviorData, history []ai.Message, message, mood string, onToken func(string) error) (*ai.AIResponse, error) {
+	llmCtx, decision := s.decide(ctx, user)
+	prompt, err := s.chatPrompt(llmCtx, user, behavior, message, mood, decision)
+	if err != nil {
+		return nil, err
+	}
 
-	return response, nil
+	var response *ai.AIResponse
+	if !decision.UseMock {
+		streamed := false
+		response, err = prompt.core.StreamResponseWithProvider(llmCtx, prompt.templateID, prompt.variables, prompt.personality, history, func(token string) error {
+			streamed = true
+			return onToken(token)
+		})
+		if err != nil {
+			if streamed {
+				return nil, err
+			}
+			log.Printf("llm stream for user %d, falling back to mock: %v", user.ID, err)
+		} else {
+			s.recordUsage(ctx, user, response, decision)
+		}
+	}
+	if response == nil {
+		if response, err = prompt.core.StreamMockResponse(ctx, prompt.templateID, prompt.variables, prompt.personality, onToken); err != nil {
+			return nil, err
+		}
+	}
+	return prompt.finish(response), nil
+}
+
+// chatPrompt detects the user's emotion and picks the personality, template
+// and variables for a reply. Users the quota keeps off the LLM get the rule detector.
+func (s *AIService) chatPrompt(ctx context.Context, user *User, behavior ai.UserBehaviorData, message, mood string, decision QuotaDecision) (*chatPrompt, error) {
+	detection := ai.DetectEmotionRules(behavior)
+	if !decision.UseMock {
+		var response *ai.AIResponse
+		var err error
+		if detection, response, err = s.core.AnalyzeEmotion(ctx, behavior); err != nil {
+			return nil, fmt.Errorf("detect emotion: %w", err)
+		}
+		if response != nil {
+			s.recordUsage(ctx, user, response, decision)
+		}
+	}
+	s.logMood(ctx, user, detection)
+	emotion, confidence := detection.Emotion, detection.Confidence
+	core := s.coreFor(ctx, user)
+	choice := choosePersonality(core, user, emotion, mood)
+	personality := choice.Personality
+
+	return &chatPrompt{
+		core:        core,
+		templateID:  chatTemplate(core, emotion, personality),
+		personality: personality,
+		reasoning:   choice.Reasoning,
+		emotion:     emotion,
+		confidence:  confidence,
+		variables: map[string]interface{}{
+			"personality":          personality,
+			"emotional_state":      emotion,
+			"user_level":           user.Level,
+			"streak_days":          user.Streak,
+			"learning_style":       learningStyle(user),
+			"preferred_difficulty": preferredDifficulty(user),
+			"available_time":       30,
+			"recent_topics":        message,
+			"problem_description":  message,
+			"current_situation":    message,
+			"attempted_solutions":  "not specified",
+			"error_messages":       "none",
+			"experience_level":     fmt.Sprintf("level %d", user.Level),
+			"available_resources":  "not specified",
+			"recent_challenges":    "not specified",
+			"user_goals":           "keep learning",
+			"stress_level":         5,
+			"time_constraints":     "not specified",
+			"past_successes":       fmt.Sprintf("reached level %d", user.Level),
+			"struggling_areas":     "not specified",
+			"recent_achievements":  fmt.Sprintf("a %d-day streak", user.Streak),
+			"interests":            "not specified",
+			"preferred_pace":       "steady",
+			"technical_background": fmt.Sprintf("level %d", user.Level),
+			"learning_objectives":  message,
+			"preferred_depth":      "moderate",
+		},
+	}, nil
+}
+
+// finish stamps the detected emotion and the personality reasoning onto a generated reply
+func (p *chatPrompt) finish(response *ai.AIResponse) *ai.AIResponse {
+	response.DetectedEmotion = p.emotion
+	response.Metadata["personality_reasoning"] = p.reasoning
+	if response.ConfidenceScore == 0 {
+		response.ConfidenceScore = p.confidence
+	}
+	return response
+}
+
+// choosePersonality runs the personality policy for a user. A requested mood
+// is treated as a locked preference for this reply only.
+func choosePersonality(core BuddyAI, user *User, emotion ai.EmotionState, mood string) ai.PersonalityChoice {
+	req := ai.PersonalityRequest{
+		Emotion:   emotion,
+		Level:     user.Level,
+		Preferred: ai.BuddyPersonality(user.BuddyPersonality),
+		Locked:    user.PersonalityLocked,
+	}
+	if mood != "" {
+		req.Preferred, req.Locked = ai.BuddyPersonality(mood), true
+	}
+	return core.SelectPersonality(req)
+}
+
+// chatTemplate picks the prompt template that suits the user's emotional state
+func chatTemplate(core BuddyAI, emotion ai.EmotionState, personality ai.BuddyPersonality) string {
+	switch emotion {
+	case ai.EmotionFrustrated, ai.EmotionConfused:
+		return "problem_solving_help"
+	case ai.EmotionTired:
+		return "motivation_boost"
+	default:
+		return core.PersonalityTemplateID("learning_suggestion_{personality}", personality)
+	}
 }
 
 // DetectMoodFromBehavior analyzes user behavior to suggest mood changes
-func (ai *AIService) DetectMoodFromBehavior(sessionDuration int, taskFailures int, retries int) string {
+func (s *AIService) DetectMoodFromBehavior(sessionDuration int, taskFailures int, retries int) string {
 	// Simple mood detection logic based on behavioral patterns
 	if taskFailures > 3 || retries > 5 {
 		return "mentor" // User needs guidance
@@ -175,83 +514,6 @@ func (xp *XPService) CalculateLevel(totalXP int) int {
 	return level
 }
 
-// QuestService methods
-
-// GenerateQuest creates a new quest based on user preferences and history
-func (q *QuestService) GenerateQuest(userLevel int, preferences []string) map[string]interface{} {
-	questTemplates := []map[string]interface{}{
-		{
-			"title":       "Debug Detective",
-			"description": "Find and fix bugs in your code",
-			"type":        "code",
-			"difficulty":  2,
-			"xp":          150,
-			"tasks":       []string{"Identify bug", "Write test case", "Fix issue", "Verify fix"},
-		},
-		{
-			"title":       "Focus Flow",
-			"description": "Complete a focused work session",
-			"type":        "focus",
-			"difficulty":  1,
-			"xp":          75,
-			"tasks":       []string{"Set timer", "Eliminate distractions", "Work continuously", "Review progress"},
-		},
-		{
-			"title":       "Concept Conqueror",
-			"description": "Master a new programming concept",
-			"type":        "learn",
-			"difficulty":  3,
-			"xp":          200,
-			"tasks":       []string{"Research concept", "Find examples", "Practice implementation", "Teach someone else"},
-		},
-	}
-
-	// Simple quest selection based on user level
-	questIndex := (userLevel - 1) % len(questTemplates)
-	quest := questTemplates[questIndex]
-
-	// Adjust difficulty based on user level
-	if userLevel > 5 {
-		quest["difficulty"] = quest["difficulty"].(int) + 1
-		quest["xp"] = quest["xp"].(int) + 50
-	}
-
-	return quest
-}
-
-// BadgeService methods
-
-// CheckBadgeEligibility checks if user has earned any new badges
-func (b *BadgeService) CheckBadgeEligibility(userStats map[string]int) []string {
-	var earnedBadges []string
-
-	badgeRequirements := map[string]map[string]int{
-		"Code Warrior":     {"bugs_fixed": 10},
-		"Focus Master":     {"focus_sessions": 50},
-		"Streak Champion":  {"max_streak": 30},
-		"Bug Hunter":       {"bugs_reported": 5},
-		"Level Achiever":   {"level": 10},
-		"XP Collector":     {"total_xp": 5000},
-		"Quest Completer":  {"quests_completed": 25},
-		"Learning Machine": {"concepts_learned": 15},
-	}
-
-	for badgeName, requirements := range badgeRequirements {
-		eligible := true
-		for stat, required := range requirements {
-			if userStats[stat] < required {
-				eligible = false
-				break
-			}
-		}
-		if eligible {
-			earnedBadges = append(earnedBadges, badgeName)
-		}
-	}
-
-	return earnedBadges
-}
-
 // Utility functions for JSON handling
 
 // ToJSON converts a struct to JSON string
@@ -272,3 +534,11 @@ func FromJSON(jsonStr string, v interface{}) error {
 func GetCurrentTimestamp() string {
 	return time.Now().Format(time.RFC3339)
 }
+
+// getEnv returns the value of an environment variable or a fallback when it is unset
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
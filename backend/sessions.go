@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	ai "learning-buddy-ai"
+
+	"github.com/gorilla/mux"
+)
+
+// SessionEventType is something that happened during a learning session
+type SessionEventType string
+
+const (
+	SessionTaskCompleted SessionEventType = "task_completed"
+	SessionTaskFailed    SessionEventType = "task_failed"
+	SessionRetry         SessionEventType = "retry"
+	SessionIdle          SessionEventType = "idle"
+)
+
+const (
+	// behaviorSessions is how many recent sessions the behavior builder reads
+	behaviorSessions = 5
+	// responseGapLimit is the longest gap between events counted as response
+	// time; longer gaps are the learner stepping away
+	responseGapLimit = 10 * time.Minute
+	// performanceShift is how far the completion rate must move between older
+	// and newer sessions to count as improving or declining
+	performanceShift = 0.1
+	// minTimeOfDayMinutes is the shortest session that counts towards the
+	// early_sessions and night_sessions badge stats
+	minTimeOfDayMinutes = 15
+)
+
+var (
+	// ErrInvalidSessionEvent is returned for an event type sessions do not track
+	ErrInvalidSessionEvent = errors.New("invalid session event")
+	// ErrSessionEnded is returned when recording events on or ending an ended session
+	ErrSessionEnded = errors.New("session already ended")
+)
+
+// UserSession is one learning session, stored in user_sessions
+type UserSession struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	StartedAt       time.Time  `json:"session_start"`
+	EndedAt         *time.Time `json:"session_end,omitempty"`
+	DurationMinutes int        `json:"duration_minutes"`
+	TasksCompleted  int        `json:"tasks_completed"`
+	TasksFailed     int        `json:"tasks_failed"`
+	Retries         int        `json:"retries"`
+	IdleEvents      int        `json:"idle_events"`
+	// ResponseSeconds sums the time learners took on TimedTasks of their tasks
+	ResponseSeconds int       `json:"response_seconds"`
+	TimedTasks      int       `json:"timed_tasks"`
+	LastEventAt     time.Time `json:"last_event_at"`
+	MoodDetected    string    `json:"mood_detected,omitempty"`
+}
+
+// Ended reports whether the session has ended
+func (s *UserSession) Ended() bool {
+	return s.EndedAt != nil
+}
+
+// minutesSince returns the whole minutes from start to now, rounded
+func minutesSince(start, now time.Time) int {
+	return int(math.Round(now.Sub(start).Minutes()))
+}
+
+// sessionTimeStat returns the badge stat a session started at the given local
+// time counts towards: early before 8 AM, night from 10 PM to 4 AM
+func sessionTimeStat(local time.Time) string {
+	switch hour := local.Hour(); {
+	case hour >= 4 && hour < 8:
+		return StatEarlySessions
+	case hour >= 22 || hour < 4:
+		return StatNightSessions
+	default:
+		return ""
+	}
+}
+
+// SessionService methods
+
+// Start opens a learning session for the user. An unended earlier session is
+// closed at its last event, as End would close it.
+func (s *SessionService) Start(ctx context.Context, userID int) (*UserSession, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open(ctx, user)
+}
+
+// RecordEvent counts an event in an open session. Completed and failed tasks
+// also add the time since the previous event to the response time, unless the
+// learner was away for longer than responseGapLimit.
+func (s *SessionService) RecordEvent(ctx context.Context, sessionID int, eventType SessionEventType) (*UserSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Ended() {
+		return nil, ErrSessionEnded
+	}
+
+	now := s.now()
+	gap := now.Sub(session.LastEventAt)
+	switch eventType {
+	case SessionTaskCompleted, SessionTaskFailed:
+		if eventType == SessionTaskCompleted {
+			session.TasksCompleted++
+		} else {
+			session.TasksFailed++
+		}
+		if gap >= 0 && gap <= responseGapLimit {
+			session.ResponseSeconds += int(gap.Seconds())
+			session.TimedTasks++
+		}
+	case SessionRetry:
+		session.Retries++
+	case SessionIdle:
+		session.IdleEvents++
+	default:
+		return nil, fmt.Errorf("%w: %q; use task_completed, task_failed, retry or idle", ErrInvalidSessionEvent, eventType)
+	}
+	session.LastEventAt = now
+
+	if err := s.sessions.Update(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// End closes a session, storing its duration and the mood the rule detector
+// reads from the user's behavior. A session of at least minTimeOfDayMinutes
+// started early in the morning or late at night, in the user's timezone, counts
+// towards the Early Bird or Night Owl badge, at most once per local day.
+func (s *SessionService) End(ctx context.Context, sessionID int) (*UserSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Ended() {
+		return nil, ErrSessionEnded
+	}
+	user, err := s.users.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.end(ctx, user, session, s.now()); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Behavior builds the user's UserBehaviorData from their last behaviorSessions
+// sessions. Duration, failures and retries describe the latest session;
+// completion rate and response time cover all of them, and the trend compares
+// the completion rate of the newer half with the older half. Users whose
+// sessions hold no tasks get a completion rate from their quests.
+func (s *SessionService) Behavior(ctx context.Context, user *User) (ai.UserBehaviorData, error) {
+	now := s.now()
+	behavior := ai.UserBehaviorData{
+		StreakDays:        user.Streak,
+		TimeOfDay:         timeOfDay(now.In(userLocation(user.Timezone))),
+		CompletionRate:    1,
+		RecentPerformance: "stable",
+	}
+
+	sessions, err := s.sessions.Recent(ctx, user.ID, behaviorSessions)
+	if err != nil {
+		return behavior, err
+	}
+	if len(sessions) == 0 {
+		return behavior, s.questCompletion(ctx, user.ID, &behavior)
+	}
+
+	latest := sessions[0]
+	behavior.SessionDuration = latest.DurationMinutes
+	if !latest.Ended() {
+		behavior.SessionDuration = minutesSince(latest.StartedAt, now)
+	}
+	behavior.TaskFailures = latest.TasksFailed
+	behavior.Retries = latest.Retries
+	behavior.LastActivity = latest.LastEventAt.Format(time.RFC3339)
+
+	var completed, failed, responseSeconds, timed int
+	var rates []float64 // per-session completion rates, newest first
+	for _, session := range sessions {
+		completed += session.TasksCompleted
+		failed += session.TasksFailed
+		responseSeconds += session.ResponseSeconds
+		timed += session.TimedTasks
+		if tasks := session.TasksCompleted + session.TasksFailed; tasks > 0 {
+			rates = append(rates, float64(session.TasksCompleted)/float64(tasks))
+		}
+	}
+	if completed+failed == 0 {
+		return behavior, s.questCompletion(ctx, user.ID, &behavior)
+	}
+	behavior.CompletionRate = float64(completed) / float64(completed+failed)
+	if timed > 0 {
+		behavior.ResponseTime = int(math.Round(float64(responseSeconds) / float64(timed)))
+	}
+	behavior.RecentPerformance = performanceTrend(rates)
+	return behavior, nil
+}
+
+// performanceTrend compares the mean completion rate of the newer half of the
+// sessions with the older half. rates are newest first; an odd middle session
+// is left out.
+func performanceTrend(rates []float64) string {
+	if len(rates) < 2 {
+		return "stable"
+	}
+	half := len(rates) / 2
+	mean := func(values []float64) float64 {
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		return total / float64(len(values))
+	}
+	switch shift := mean(rates[:half]) - mean(rates[len(rates)-half:]); {
+	case shift >= performanceShift:
+		return "improving"
+	case shift <= -performanceShift:
+		return "declining"
+	default:
+		return "stable"
+	}
+}
+
+// questCompletion sets the completion rate and failures from the user's
+// completed and failed quests
+func (s *SessionService) questCompletion(ctx context.Context, userID int, behavior *ai.UserBehaviorData) error {
+	quests, err := s.quests.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var completed, failed int
+	for _, quest := range quests {
+		switch quest.Status {
+		case QuestStatusCompleted:
+			completed++
+		case QuestStatusFailed:
+			failed++
+		}
+	}
+	behavior.TaskFailures = failed
+	if completed+failed > 0 {
+		behavior.CompletionRate = float64(completed) / float64(completed+failed)
+	}
+	return nil
+}
+
+// open creates a session for the user, first ending any session left open.
+// Callers must hold s.mu.
+func (s *SessionService) open(ctx context.Context, user *User) (*UserSession, error) {
+	previous, err := s.sessions.GetOpen(ctx, user.ID)
+	switch {
+	case err == nil:
+		if err := s.end(ctx, user, previous, previous.LastEventAt); err != nil {
+			return nil, fmt.Errorf("end session %d: %w", previous.ID, err)
+		}
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	now := s.now()
+	session := &UserSession{UserID: user.ID, StartedAt: now, LastEventAt: now}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// end closes a session at the given time. Callers must hold s.mu.
+func (s *SessionService) end(ctx context.Context, user *User, session *UserSession, at time.Time) error {
+	session.EndedAt = &at
+	session.DurationMinutes = minutesSince(session.StartedAt, at)
+	if err := s.sessions.Update(ctx, session); err != nil {
+		session.EndedAt = nil
+		return err
+	}
+	s.recordTimeOfDay(ctx, user, session)
+
+	behavior, err := s.Behavior(ctx, user)
+	if err != nil {
+		log.Printf("session behavior for user %d: %v", user.ID, err)
+		return nil
+	}
+	session.MoodDetected = string(ai.DetectEmotionRules(behavior).Emotion)
+	return s.sessions.Update(ctx, session)
+}
+
+// recordTimeOfDay counts an ended session towards its time-of-day badge stat.
+// Failures only delay a badge, so they are logged. Callers must hold s.mu.
+func (s *SessionService) recordTimeOfDay(ctx context.Context, user *User, session *UserSession) {
+	loc := userLocation(user.Timezone)
+	stat := sessionTimeStat(session.StartedAt.In(loc))
+	if stat == "" || s.badges == nil || session.DurationMinutes < minTimeOfDayMinutes {
+		return
+	}
+	if _, err := s.badges.RecordDailyActivity(ctx, user.ID, stat, loc); err != nil {
+		log.Printf("record %s for user %d: %v", stat, user.ID, err)
+	}
+}
+
+// Session handlers
+
+// writeSessionError maps session errors to HTTP responses
+func writeSessionError(w http.ResponseWriter, sessionID int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Session not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidSessionEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrSessionEnded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("session %d: %v", sessionID, err)
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) startSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := UserIDFromContext(r.Context())
+
+	session, err := s.sessions.Start(r.Context(), userID)
+	if err != nil {
+		writeSessionError(w, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (s *Server) recordSessionEventHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Type SessionEventType `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.sessions.RecordEvent(r.Context(), sessionID, req.Type)
+	if err != nil {
+		writeSessionError(w, sessionID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+func (s *Server) endSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.sessions.End(r.Context(), sessionID)
+	if err != nil {
+		writeSessionError(w, sessionID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
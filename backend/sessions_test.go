@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPerformanceTrend(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []float64 // newest first
+		want  string
+	}{
+		{"no history", nil, "stable"},
+		{"one session", []float64{0.2}, "stable"},
+		{"improving", []float64{0.9, 0.8, 0.5, 0.4}, "improving"},
+		{"declining", []float64{0.3, 0.9}, "declining"},
+		{"small shift is stable", []float64{0.75, 0.5, 0.7}, "stable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := performanceTrend(tt.rates); got != tt.want {
+				t.Fatalf("performanceTrend(%v) = %q, want %q", tt.rates, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionBehavior(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	server, repos := newClockedServer(t, &now)
+	service := server.sessions
+	ctx := context.Background()
+	user := &User{Username: "sam", Email: "sam@example.com", Role: RoleLearner, Streak: 2}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if behavior, err := service.Behavior(ctx, user); err != nil || behavior.CompletionRate != 1 || behavior.RecentPerformance != "stable" {
+		t.Fatalf("Behavior() without sessions = %+v, %v", behavior, err)
+	}
+
+	// An older session that went badly, then a better one still open
+	run := func(events ...SessionEventType) *UserSession {
+		session, err := service.Start(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		for _, event := range events {
+			now = now.Add(time.Minute)
+			if session, err = service.RecordEvent(ctx, session.ID, event); err != nil {
+				t.Fatalf("RecordEvent(%s) error = %v", event, err)
+			}
+		}
+		return session
+	}
+	first := run(SessionTaskFailed, SessionRetry, SessionTaskFailed, SessionTaskCompleted)
+	now = now.Add(time.Hour)
+	second := run(SessionTaskCompleted, SessionTaskCompleted, SessionRetry)
+	now = now.Add(20 * time.Minute) // away too long for this to count as response time
+	if _, err := service.RecordEvent(ctx, second.ID, SessionTaskFailed); err != nil {
+		t.Fatalf("RecordEvent() error = %v", err)
+	}
+
+	if stored, _ := repos.Sessions.GetByID(ctx, first.ID); !stored.Ended() || stored.DurationMinutes != 4 || stored.MoodDetected == "" {
+		t.Fatalf("first session = %+v, want it ended at its last event with a mood", stored)
+	}
+
+	now = now.Add(5 * time.Minute)
+	behavior, err := service.Behavior(ctx, user)
+	if err != nil {
+		t.Fatalf("Behavior() error = %v", err)
+	}
+	// 3 completed of 6 tasks; the timed tasks took 1 minute each
+	if behavior.SessionDuration != 28 || behavior.TaskFailures != 1 || behavior.Retries != 1 ||
+		behavior.CompletionRate != 0.5 || behavior.ResponseTime != 60 || behavior.StreakDays != 2 ||
+		behavior.TimeOfDay != "afternoon" || behavior.RecentPerformance != "improving" {
+		t.Fatalf("Behavior() = %+v", behavior)
+	}
+
+	ended, err := service.End(ctx, second.ID)
+	if err != nil || ended.DurationMinutes != 28 {
+		t.Fatalf("End() = %+v, %v; want a 28 minute session", ended, err)
+	}
+	if _, err := service.RecordEvent(ctx, second.ID, SessionRetry); !errors.Is(err, ErrSessionEnded) {
+		t.Fatalf("event on ended session: error = %v, want ErrSessionEnded", err)
+	}
+	if _, err := service.End(ctx, second.ID); !errors.Is(err, ErrSessionEnded) {
+		t.Fatalf("ending twice: error = %v, want ErrSessionEnded", err)
+	}
+}
+
+func TestSessionEndRecordsTimeOfDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		at       time.Time
+		minutes  int
+		want     string
+	}{
+		{"early in the user's timezone", "America/New_York", time.Date(2026, 3, 2, 11, 30, 0, 0, time.UTC), 20, StatEarlySessions},
+		{"night in the user's timezone", "Asia/Tokyo", time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC), 20, StatNightSessions},
+		{"past midnight", "", time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC), 15, StatNightSessions},
+		{"too short", "", time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC), 14, ""},
+		{"daytime", "", time.Date(2026, 3, 2, 11, 30, 0, 0, time.UTC), 20, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.at
+			server, repos := newClockedServer(t, &now)
+			service := server.sessions
+			ctx := context.Background()
+			user := &User{Username: "sam", Email: "sam@example.com", Role: RoleLearner, Timezone: tt.timezone}
+			if err := repos.Users.Create(ctx, user); err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+
+			session, err := service.Start(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if totals, _ := repos.Activity.Totals(ctx, user.ID, time.Time{}); totals[StatEarlySessions]+totals[StatNightSessions] != 0 {
+				t.Fatalf("Start() recorded %v, want nothing before the session ends", totals)
+			}
+			now = now.Add(time.Duration(tt.minutes) * time.Minute)
+			if _, err := service.End(ctx, session.ID); err != nil {
+				t.Fatalf("End() error = %v", err)
+			}
+			totals, _ := repos.Activity.Totals(ctx, user.ID, time.Time{})
+			for _, stat := range []string{StatEarlySessions, StatNightSessions} {
+				if want := map[bool]int{true: 1}[stat == tt.want]; totals[stat] != want {
+					t.Errorf("%s = %d, want %d", stat, totals[stat], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionTimeOfDayCountsOncePerDay(t *testing.T) {
+	now := time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC)
+	server, repos := newClockedServer(t, &now)
+	service := server.sessions
+	ctx := context.Background()
+	user := &User{Username: "sam", Email: "sam@example.com", Role: RoleLearner}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	early := func() {
+		t.Helper()
+		session, err := service.Start(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		now = now.Add(20 * time.Minute)
+		if _, err := service.RecordEvent(ctx, session.ID, SessionTaskCompleted); err != nil {
+			t.Fatalf("RecordEvent() error = %v", err)
+		}
+	}
+	// Each start closes the previous session at its last event, as End would
+	for i := 0; i < 3; i++ {
+		early()
+	}
+	if _, err := service.Start(ctx, user.ID); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if totals, _ := repos.Activity.Totals(ctx, user.ID, time.Time{}); totals[StatEarlySessions] != 1 {
+		t.Fatalf("early_sessions after three morning sessions in one day = %d, want 1", totals[StatEarlySessions])
+	}
+
+	now = time.Date(2026, 3, 3, 5, 0, 0, 0, time.UTC)
+	early()
+	if _, err := service.Start(ctx, user.ID); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if totals, _ := repos.Activity.Totals(ctx, user.ID, time.Time{}); totals[StatEarlySessions] != 2 {
+		t.Fatalf("early_sessions on the next day = %d, want 2", totals[StatEarlySessions])
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StreakState is a user's streak counters as stored on the users table
+type StreakState struct {
+	UserID       int        `json:"user_id"`
+	Current      int        `json:"current_streak"`
+	Max          int        `json:"max_streak"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+	FreezeTokens int        `json:"freeze_tokens"`
+	Timezone     string     `json:"timezone"`
+}
+
+// StreakUpdate describes what recording an activity did to a user's streak
+type StreakUpdate struct {
+	Streak            StreakState `json:"streak"`
+	NewDay            bool        `json:"new_day"`
+	FreezeTokensUsed  int         `json:"freeze_tokens_used"`
+	FreezeTokenEarned bool        `json:"freeze_token_earned"`
+	Bonus             *XPAward    `json:"bonus,omitempty"`
+}
+
+// StreakConfig controls streak rewards and freeze tokens
+type StreakConfig struct {
+	// DailyBonus is the XP credited the first time a user is active each day
+	DailyBonus int
+	// FreezeEarnDays is the number of streak days needed to earn a freeze token; zero disables earning
+	FreezeEarnDays int
+	// MaxFreezeTokens caps how many freeze tokens a user can hold
+	MaxFreezeTokens int
+}
+
+// LoadStreakConfig reads the streak configuration from the environment
+func LoadStreakConfig() StreakConfig {
+	return StreakConfig{
+		DailyBonus:      getEnvInt("STREAK_DAILY_BONUS", 25),
+		FreezeEarnDays:  getEnvInt("STREAK_FREEZE_EARN_DAYS", 7),
+		MaxFreezeTokens: getEnvInt("STREAK_MAX_FREEZE_TOKENS", 2),
+	}
+}
+
+// getEnvInt returns an integer environment variable or a fallback when it is unset or invalid
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// userLocation returns the user's time zone, falling back to UTC when it is unknown
+func userLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("unknown timezone %q, using UTC", timezone)
+		return time.UTC
+	}
+	return loc
+}
+
+// daysBetween returns the number of calendar days from a to b in loc
+func daysBetween(a, b time.Time, loc *time.Location) int {
+	ay, am, ad := a.In(loc).Date()
+	by, bm, bd := b.In(loc).Date()
+	from := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	to := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// StreakService methods
+
+// RecordActivity counts today as active for the user. The first activity of
+// each local calendar day extends the streak, spending freeze tokens to cover
+// missed days when it can, and credits the daily streak bonus.
+func (s *StreakService) RecordActivity(ctx context.Context, userID int) (*StreakUpdate, error) {
+	update, err := s.record(ctx, userID)
+	if err != nil || !update.NewDay {
+		return update, err
+	}
+
+	if s.config.DailyBonus > 0 {
+		description := fmt.Sprintf("Daily streak bonus (day %d)", update.Streak.Current)
+		if update.Bonus, err = s.xp.Award(ctx, userID, s.config.DailyBonus, XPSourceStreak, nil, description); err != nil {
+			return update, err
+		}
+	}
+	if s.events != nil {
+		s.events.Publish(ctx, Event{Type: EventStreakExtended, UserID: userID, OccurredAt: s.now(), Streak: &update.Streak})
+	}
+	return update, nil
+}
+
+// record updates the stored streak under the lock; rewards are handed out by the caller
+func (s *StreakService) record(ctx context.Context, userID int) (*StreakUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.streaks.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	update := &StreakUpdate{NewDay: true}
+	switch gap := s.gap(state, now); {
+	case state.Current == 0 || state.LastActivity == nil:
+		state.Current = 1
+	case gap <= 0:
+		// Same day, or a last activity on a later local day after a clock or
+		// timezone change; neither extends the streak nor spends tokens
+		update.NewDay = false
+	case gap == 1:
+		state.Current++
+	case gap-1 <= state.FreezeTokens:
+		update.FreezeTokensUsed = gap - 1
+		state.FreezeTokens -= gap - 1
+		state.Current++
+	default:
+		state.Current = 1
+	}
+
+	if update.NewDay {
+		if state.Current > state.Max {
+			state.Max = state.Current
+		}
+		if s.config.FreezeEarnDays > 0 && state.Current%s.config.FreezeEarnDays == 0 && state.FreezeTokens < s.config.MaxFreezeTokens {
+			state.FreezeTokens++
+			update.FreezeTokenEarned = true
+		}
+	}
+	state.LastActivity = &now
+
+	if err := s.streaks.Save(ctx, state); err != nil {
+		return nil, err
+	}
+	update.Streak = *state
+	return update, nil
+}
+
+// Status returns the user's streak as of now. A streak whose missed days can
+// no longer be covered by freeze tokens is reported as zero.
+func (s *StreakService) Status(ctx context.Context, userID int) (*StreakState, error) {
+	state, err := s.streaks.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.LastActivity != nil && s.gap(state, s.now())-1 > state.FreezeTokens {
+		state.Current = 0
+	}
+	return state, nil
+}
+
+// gap returns the number of local calendar days since the user's last activity
+func (s *StreakService) gap(state *StreakState, now time.Time) int {
+	if state.LastActivity == nil {
+		return 0
+	}
+	return daysBetween(*state.LastActivity, now, userLocation(state.Timezone))
+}
+
+// HandleEvent counts qualifying quest and project events as activity for the day
+func (s *StreakService) HandleEvent(ctx context.Context, event Event) error {
+	_, err := s.RecordActivity(ctx, event.UserID)
+	return err
+}
+
+// Streak handlers
+
+func (s *Server) getStreakHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	state, err := s.streaks.Status(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("streak for user %d: %v", userID, err)
+		http.Error(w, "Failed to load streak", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestStreakService returns a streak service on in-memory data whose clock
+// is read from *clock, with user 1 in America/New_York starting a fresh streak.
+func newTestStreakService(t *testing.T, config StreakConfig, clock *time.Time) (*StreakService, *Repositories) {
+	t.Helper()
+	repos := NewMemoryRepositories()
+	if err := repos.Streaks.Save(context.Background(), &StreakState{UserID: 1}); err != nil {
+		t.Fatalf("reset streak: %v", err)
+	}
+	service := NewStreakService(repos.Streaks, NewXPService(repos.XP, repos.Users, nil), nil, config)
+	service.now = func() time.Time { return *clock }
+	return service, repos
+}
+
+func TestStreakUsesUserTimezone(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	clock := time.Date(2026, 3, 1, 23, 30, 0, 0, newYork) // 04:30 UTC on March 2
+	service, _ := newTestStreakService(t, StreakConfig{DailyBonus: 25}, &clock)
+	ctx := context.Background()
+
+	steps := []struct {
+		at      time.Time
+		current int
+		newDay  bool
+	}{
+		{time.Date(2026, 3, 1, 23, 30, 0, 0, newYork), 1, true},
+		{time.Date(2026, 3, 1, 23, 59, 0, 0, newYork), 1, false}, // same local day, already a different UTC day
+		{time.Date(2026, 3, 2, 0, 5, 0, 0, newYork), 2, true},
+		{time.Date(2026, 3, 2, 19, 0, 0, 0, newYork), 2, false}, // 00:00 UTC March 3
+		{time.Date(2026, 3, 3, 8, 0, 0, 0, newYork), 3, true},
+	}
+	for i, step := range steps {
+		clock = step.at
+		update, err := service.RecordActivity(ctx, 1)
+		if err != nil {
+			t.Fatalf("step %d: RecordActivity() error = %v", i, err)
+		}
+		if update.Streak.Current != step.current || update.NewDay != step.newDay {
+			t.Fatalf("step %d: current/new day = %d/%v, want %d/%v", i, update.Streak.Current, update.NewDay, step.current, step.newDay)
+		}
+		if step.newDay && (update.Bonus == nil || update.Bonus.Transaction.SourceType != XPSourceStreak) {
+			t.Fatalf("step %d: bonus = %+v, want a streak transaction", i, update.Bonus)
+		}
+		if !step.newDay && update.Bonus != nil {
+			t.Fatalf("step %d: bonus credited twice in one day", i)
+		}
+	}
+}
+
+func TestStreakFreezeTokens(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	service, repos := newTestStreakService(t, StreakConfig{FreezeEarnDays: 3, MaxFreezeTokens: 1}, &clock)
+	ctx := context.Background()
+	user, _ := repos.Users.GetByID(ctx, 1)
+	user.Timezone = "UTC"
+	repos.Users.(*memoryUserRepository).users[0] = *user
+
+	day := func(d int) {
+		t.Helper()
+		clock = time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC)
+		if _, err := service.RecordActivity(ctx, 1); err != nil {
+			t.Fatalf("March %d: RecordActivity() error = %v", d, err)
+		}
+	}
+
+	day(1)
+	day(2)
+	day(3) // third day earns the only token allowed
+	status, _ := service.Status(ctx, 1)
+	if status.Current != 3 || status.FreezeTokens != 1 {
+		t.Fatalf("after 3 days: current/tokens = %d/%d, want 3/1", status.Current, status.FreezeTokens)
+	}
+
+	clock = time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)
+	if status, _ := service.Status(ctx, 1); status.Current != 3 {
+		t.Fatalf("one missed day covered by a token: current = %d, want 3", status.Current)
+	}
+	day(5) // March 4 is covered by the token
+	status, _ = service.Status(ctx, 1)
+	if status.Current != 4 || status.FreezeTokens != 0 || status.Max != 4 {
+		t.Fatalf("after freeze: current/tokens/max = %d/%d/%d, want 4/0/4", status.Current, status.FreezeTokens, status.Max)
+	}
+
+	clock = time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)
+	if status, _ := service.Status(ctx, 1); status.Current != 0 {
+		t.Fatalf("missed day without a token: current = %d, want 0", status.Current)
+	}
+	day(7)
+	status, _ = service.Status(ctx, 1)
+	if status.Current != 1 || status.Max != 4 {
+		t.Fatalf("after reset: current/max = %d/%d, want 1/4", status.Current, status.Max)
+	}
+	if user, _ := repos.Users.GetByID(ctx, 1); user.Streak != 1 || user.MaxStreak != 4 {
+		t.Fatalf("user streak/max = %d/%d, want 1/4", user.Streak, user.MaxStreak)
+	}
+}
+
+func TestStreakIgnoresActivityBeforeTheLastDay(t *testing.T) {
+	clock := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	service, repos := newTestStreakService(t, StreakConfig{DailyBonus: 25}, &clock)
+	ctx := context.Background()
+	if err := repos.Streaks.Save(ctx, &StreakState{UserID: 1, Current: 4, Max: 4, LastActivity: &clock, FreezeTokens: 2}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// The clock steps back two days, as after a device clock or timezone change
+	clock = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	update, err := service.RecordActivity(ctx, 1)
+	if err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	if update.NewDay || update.Bonus != nil || update.FreezeTokensUsed != 0 {
+		t.Fatalf("update = %+v, want the same day without a bonus or spent tokens", update)
+	}
+	if update.Streak.Current != 4 || update.Streak.FreezeTokens != 2 {
+		t.Fatalf("current/tokens = %d/%d, want 4/2", update.Streak.Current, update.Streak.FreezeTokens)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ai "learning-buddy-ai"
+)
+
+// Plans select a user's AI quotas
+const (
+	PlanFree = "free"
+	PlanPro  = "pro"
+)
+
+// usageDateLayout is the format of the admin usage report's from and to parameters
+const usageDateLayout = "2006-01-02"
+
+// AIUsage is one LLM call attributed to a user
+type AIUsage struct {
+	ID               int       `json:"id"`
+	UserID           int       `json:"user_id"`
+	TemplateID       string    `json:"template_id"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	Degraded         bool      `json:"degraded"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageSummary totals a user's calls to one template on one model
+type UsageSummary struct {
+	UserID      int     `json:"user_id"`
+	TemplateID  string  `json:"template_id"`
+	Model       string  `json:"model"`
+	Calls       int     `json:"calls"`
+	TotalTokens int     `json:"total_tokens"`
+	CostUSD     float64 `json:"cost_usd"`
+}
+
+// UserSpend is a user's line in the spend report
+type UserSpend struct {
+	UserID      int            `json:"user_id"`
+	Username    string         `json:"username"`
+	Plan        string         `json:"plan"`
+	Calls       int            `json:"calls"`
+	TotalTokens int            `json:"total_tokens"`
+	CostUSD     float64        `json:"cost_usd"`
+	Templates   []UsageSummary `json:"templates"`
+}
+
+// SpendReport is AI spend by user and template over a period
+type SpendReport struct {
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+	Calls       int         `json:"calls"`
+	TotalTokens int         `json:"total_tokens"`
+	CostUSD     float64     `json:"cost_usd"`
+	Users       []UserSpend `json:"users"`
+}
+
+// PlanQuota caps a plan's token use; zero means unlimited
+type PlanQuota struct {
+	DailyTokens   int
+	MonthlyTokens int
+}
+
+// ModelPrice is a model's list price in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// modelPrices are matched by longest prefix, so dated snapshots such as
+// gpt-4o-mini-2024-07-18 are priced like their family. Unknown models cost 0.
+var modelPrices = map[string]ModelPrice{
+	"gpt-4":             {Prompt: 30, Completion: 60},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"gpt-3.5-turbo":     {Prompt: 0.5, Completion: 1.5},
+	"claude-3-5-haiku":  {Prompt: 0.8, Completion: 4},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+}
+
+// UsageConfig holds the quotas of each plan and what to do once they are spent
+type UsageConfig struct {
+	Plans map[string]PlanQuota
+	// DegradedModel is the cheaper model used over quota; empty means the mock
+	DegradedModel string
+}
+
+// LoadUsageConfig reads plan quotas from the environment
+func LoadUsageConfig() UsageConfig {
+	return UsageConfig{
+		Plans: map[string]PlanQuota{
+			PlanFree: {
+				DailyTokens:   getEnvInt("AI_QUOTA_FREE_DAILY_TOKENS", 20000),
+				MonthlyTokens: getEnvInt("AI_QUOTA_FREE_MONTHLY_TOKENS", 300000),
+			},
+			PlanPro: {
+				DailyTokens:   getEnvInt("AI_QUOTA_PRO_DAILY_TOKENS", 200000),
+				MonthlyTokens: getEnvInt("AI_QUOTA_PRO_MONTHLY_TOKENS", 4000000),
+			},
+		},
+		DegradedModel: getEnv("AI_DEGRADED_MODEL", ""),
+	}
+}
+
+// QuotaDecision says how a user's next reply is generated
+type QuotaDecision struct {
+	OverQuota bool
+	Model     string // cheaper model to use, empty for the provider's default
+	UseMock   bool
+}
+
+// quota returns the quota of a plan, treating unknown plans as free
+func (c UsageConfig) quota(plan string) PlanQuota {
+	if quota, ok := c.Plans[plan]; ok {
+		return quota
+	}
+	return c.Plans[PlanFree]
+}
+
+// Decide checks the user's token use this UTC day and month against their plan.
+// Over quota, replies move to the degraded model or, without one, to the mock.
+func (s *UsageService) Decide(ctx context.Context, user *User) (QuotaDecision, error) {
+	quota := s.config.quota(user.Plan)
+	now := s.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	over := false
+	if quota.MonthlyTokens > 0 {
+		used, err := s.usage.TotalTokens(ctx, user.ID, month)
+		if err != nil {
+			return QuotaDecision{}, err
+		}
+		over = used >= quota.MonthlyTokens
+	}
+	if !over && quota.DailyTokens > 0 {
+		used, err := s.usage.TotalTokens(ctx, user.ID, day)
+		if err != nil {
+			return QuotaDecision{}, err
+		}
+		over = used >= quota.DailyTokens
+	}
+
+	if !over {
+		return QuotaDecision{}, nil
+	}
+	if s.config.DegradedModel != "" {
+		return QuotaDecision{OverQuota: true, Model: s.config.DegradedModel}, nil
+	}
+	return QuotaDecision{OverQuota: true, UseMock: true}, nil
+}
+
+// decide returns the context and quota decision for the user's next LLM call.
+// Without an LLM the decision is always the mock; a failed quota lookup lets the call through.
+func (s *AIService) decide(ctx context.Context, user *User) (context.Context, QuotaDecision) {
+	if !s.useLLM {
+		return ctx, QuotaDecision{UseMock: true}
+	}
+	if s.usage == nil {
+		return ctx, QuotaDecision{}
+	}
+	decision, err := s.usage.Decide(ctx, user)
+	if err != nil {
+		log.Printf("ai quota for user %d: %v", user.ID, err)
+		return ctx, QuotaDecision{}
+	}
+	if decision.Model != "" {
+		ctx = ai.WithModel(ctx, decision.Model)
+	}
+	return ctx, decision
+}
+
+// recordUsage accounts an LLM reply against the user. Replies the mock
+// fallback answered cost nothing and are not recorded.
+func (s *AIService) recordUsage(ctx context.Context, user *User, response *ai.AIResponse, decision QuotaDecision) {
+	if decision.OverQuota {
+		response.Metadata["degraded"] = "true"
+	}
+	if s.usage == nil || response.Metadata["provider"] == ai.ProviderMock {
+		return
+	}
+	if err := s.usage.Record(ctx, user.ID, response, decision.OverQuota); err != nil {
+		log.Printf("record ai usage for user %d: %v", user.ID, err)
+	}
+}
+
+// Record stores the tokens an LLM reply used against the user
+func (s *UsageService) Record(ctx context.Context, userID int, response *ai.AIResponse, degraded bool) error {
+	model := response.Metadata["model"]
+	return s.usage.Record(ctx, &AIUsage{
+		UserID:           userID,
+		TemplateID:       response.Metadata["template_id"],
+		Provider:         response.Metadata["provider"],
+		Model:            model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+		CostUSD:          estimateCost(model, response.Usage),
+		Degraded:         degraded,
+		CreatedAt:        s.now(),
+	})
+}
+
+// Report builds the spend report for calls in [from, to)
+func (s *UsageService) Report(ctx context.Context, from, to time.Time) (*SpendReport, error) {
+	summaries, err := s.usage.Summarize(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SpendReport{From: from, To: to, Users: []UserSpend{}}
+	byUser := map[int]*UserSpend{}
+	for _, summary := range summaries {
+		spend, ok := byUser[summary.UserID]
+		if !ok {
+			spend = &UserSpend{UserID: summary.UserID}
+			if user, err := s.users.GetByID(ctx, summary.UserID); err == nil {
+				spend.Username, spend.Plan = user.Username, user.Plan
+			}
+			byUser[summary.UserID] = spend
+		}
+		spend.Calls += summary.Calls
+		spend.TotalTokens += summary.TotalTokens
+		spend.CostUSD += summary.CostUSD
+		spend.Templates = append(spend.Templates, summary)
+
+		report.Calls += summary.Calls
+		report.TotalTokens += summary.TotalTokens
+		report.CostUSD += summary.CostUSD
+	}
+
+	for _, spend := range byUser {
+		sort.Slice(spend.Templates, func(i, j int) bool { return spend.Templates[i].CostUSD > spend.Templates[j].CostUSD })
+		report.Users = append(report.Users, *spend)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		if report.Users[i].CostUSD != report.Users[j].CostUSD {
+			return report.Users[i].CostUSD > report.Users[j].CostUSD
+		}
+		return report.Users[i].UserID < report.Users[j].UserID
+	})
+	return report, nil
+}
+
+// estimateCost prices usage at the model's list price
+func estimateCost(model string, usage ai.Usage) float64 {
+	price, matched := ModelPrice{}, ""
+	for prefix, candidate := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			price, matched = candidate, prefix
+		}
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// getAIUsageHandler reports AI spend by user and template. from and to are
+// dates (YYYY-MM-DD, to inclusive) and default to the current UTC month.
+func (s *Server) getAIUsageHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	query := r.URL.Query()
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			http.Error(w, "from must be a date like 2026-10-01", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			http.Error(w, "to must be a date like 2026-10-31", http.StatusBadRequest)
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.usage.Report(r.Context(), from, to)
+	if err != nil {
+		log.Printf("ai usage report %s-%s: %v", from.Format(usageDateLayout), to.Format(usageDateLayout), err)
+		http.Error(w, "Failed to load AI usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	ai "learning-buddy-ai"
+	"learning-buddy-ai/fakeopenai"
+)
+
+// useFakeLLM routes the fixture's buddy through the fake LLM with the given quotas
+func useFakeLLM(t *testing.T, f *testFixture, config UsageConfig) (*fakeopenai.Server, *memoryUsageRepository) {
+	t.Helper()
+	service, server := newFakeLLMService(t)
+	repo := &memoryUsageRepository{}
+	usage := NewUsageService(repo, f.server.repos.Users, config)
+	service.usage = usage
+	f.server.ai, f.server.usage = service, usage
+	f.server.projects.planner = service
+	f.server.generator.planner = service
+	f.server.conversations.summarizer = service
+	return server, repo
+}
+
+func TestBuddyChatRecordsUsage(t *testing.T) {
+	f := newTestFixture(t)
+	_, repo := useFakeLLM(t, f, LoadUsageConfig())
+
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"help me with recursion"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chat status = %d", rec.Code)
+	}
+	rec = f.do("POST", "/api/v1/buddy/chat/stream", "owner", `{"message":"and loops?"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stream status = %d", rec.Code)
+	}
+
+	if len(repo.records) != 2 {
+		t.Fatalf("usage records = %d, want 2", len(repo.records))
+	}
+	for _, record := range repo.records {
+		if record.UserID != 1 || record.Provider != ai.ProviderOpenAI || record.Model != "gpt-4" || record.TemplateID == "" {
+			t.Fatalf("record = %+v, want user 1 on openai gpt-4", record)
+		}
+		if record.TotalTokens == 0 || record.CostUSD <= 0 || record.Degraded {
+			t.Fatalf("record = %+v, want counted tokens and cost", record)
+		}
+	}
+}
+
+func TestEmotionAndSummaryRecordUsage(t *testing.T) {
+	t.Setenv("BUDDY_EMOTION_DETECTOR", ai.EmotionDetectorLLM)
+	f := newTestFixture(t)
+	server, repo := useFakeLLM(t, f, LoadUsageConfig())
+	server.SetReply(func(req fakeopenai.Request) string {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		if strings.Contains(prompt, "Task Failures:") {
+			return `{"emotional_state":"motivated","confidence":0.8,"indicators":["steady"],"recommended_personality":"mentor","reasoning":"steady"}`
+		}
+		return prompt
+	})
+	f.server.conversations.config.HistoryTurns = 1
+
+	var first BuddyChatResponse
+	rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"help me with recursion"}`)
+	json.NewDecoder(rec.Body).Decode(&first)
+	rec = f.do("POST", "/api/v1/buddy/chat", "owner", fmt.Sprintf(`{"message":"and loops?","thread_id":%d}`, first.ThreadID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chat status = %d", rec.Code)
+	}
+
+	templates := map[string]int{}
+	for _, record := range repo.records {
+		if record.UserID != 1 {
+			t.Fatalf("record = %+v, want user 1", record)
+		}
+		templates[record.TemplateID]++
+	}
+	if templates["emotion_detection"] != 2 || templates["conversation_summary"] != 1 {
+		t.Fatalf("records by template = %v, want both emotion detections and the summary", templates)
+	}
+}
+
+func TestOverQuotaDegrades(t *testing.T) {
+	quota := UsageConfig{Plans: map[string]PlanQuota{PlanFree: {DailyTokens: 100}}}
+	tests := []struct {
+		name          string
+		degradedModel string
+		wantRequests  int
+	}{
+		{"cheaper model", "gpt-4o-mini", 1},
+		{"mock", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFixture(t)
+			quota.DegradedModel = tt.degradedModel
+			server, repo := useFakeLLM(t, f, quota)
+			repo.Record(context.Background(), &AIUsage{UserID: 1, TemplateID: "motivation_boost", Model: "gpt-4", TotalTokens: 150, CreatedAt: time.Now()})
+
+			var reply BuddyChatResponse
+			rec := f.do("POST", "/api/v1/buddy/chat", "owner", `{"message":"one more question"}`)
+			json.NewDecoder(rec.Body).Decode(&reply)
+			if rec.Code != http.StatusOK || reply.Response == "" {
+				t.Fatalf("status = %d, reply %+v; want a reply over quota", rec.Code, reply)
+			}
+			if len(server.Requests()) != tt.wantRequests {
+				t.Fatalf("llm requests = %d, want %d", len(server.Requests()), tt.wantRequests)
+			}
+			if tt.wantRequests == 0 {
+				return
+			}
+			if model := server.Requests()[0].Model; model != tt.degradedModel {
+				t.Fatalf("model = %q, want %q", model, tt.degradedModel)
+			}
+			if last := repo.records[len(repo.records)-1]; !last.Degraded || last.Model != tt.degradedModel {
+				t.Fatalf("record = %+v, want a degraded call on %s", last, tt.degradedModel)
+			}
+		})
+	}
+
+	// Other users still have their quota
+	f := newTestFixture(t)
+	server, repo := useFakeLLM(t, f, quota)
+	repo.Record(context.Background(), &AIUsage{UserID: 1, TotalTokens: 150, CreatedAt: time.Now()})
+	if rec := f.do("POST", "/api/v1/buddy/chat", "other", `{"message":"hi"}`); rec.Code != http.StatusOK || len(server.Requests()) != 1 || server.Requests()[0].Model != "gpt-4" {
+		t.Fatalf("status = %d, requests %d; want the other user on the full model", rec.Code, len(server.Requests()))
+	}
+}
+
+func TestAIUsageReportGroupsByUserAndTemplate(t *testing.T) {
+	f := newTestFixture(t)
+	repo := f.server.repos.Usage
+	ctx := context.Background()
+	day := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	for _, usage := range []AIUsage{
+		{UserID: 1, TemplateID: "motivation_boost", Model: "gpt-4", TotalTokens: 100, CostUSD: 0.004, CreatedAt: day},
+		{UserID: 1, TemplateID: "motivation_boost", Model: "gpt-4", TotalTokens: 200, CostUSD: 0.008, CreatedAt: day},
+		{UserID: 1, TemplateID: "problem_solving", Model: "gpt-4", TotalTokens: 50, CostUSD: 0.002, CreatedAt: day},
+		{UserID: 2, TemplateID: "motivation_boost", Model: "gpt-4o-mini", TotalTokens: 400, CostUSD: 0.0001, CreatedAt: day},
+		{UserID: 2, TemplateID: "motivation_boost", Model: "gpt-4", TotalTokens: 999, CostUSD: 1, CreatedAt: day.AddDate(0, 1, 0)},
+	} {
+		usage := usage
+		repo.Record(ctx, &usage)
+	}
+
+	var report SpendReport
+	rec := f.do("GET", "/api/v1/admin/ai-usage?from=2026-10-01&to=2026-10-31", "admin", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	json.NewDecoder(rec.Body).Decode(&report)
+
+	if report.Calls != 4 || report.TotalTokens != 750 || len(report.Users) != 2 {
+		t.Fatalf("report = %+v, want 4 calls over two users in October", report)
+	}
+	top := report.Users[0]
+	if top.UserID != 1 || top.Username != "alex" || top.Calls != 3 || len(top.Templates) != 2 || top.Templates[0].TemplateID != "motivation_boost" {
+		t.Fatalf("top spender = %+v, want user 1 with two templates", top)
+	}
+	if math.Abs(top.CostUSD-0.014) > 1e-9 {
+		t.Fatalf("cost = %f, want 0.014", top.CostUSD)
+	}
+}
+
+func TestEstimateCostMatchesLongestPrefix(t *testing.T) {
+	usage := ai.Usage{PromptTokens: 1000000, CompletionTokens: 1000000}
+	tests := map[string]float64{
+		"gpt-4":                  90,
+		"gpt-4o-mini-2024-07-18": 0.75,
+		"gpt-4o":                 12.5,
+		"llama3":                 0,
+	}
+	for model, want := range tests {
+		if got := estimateCost(model, usage); math.Abs(got-want) > 1e-9 {
+			t.Errorf("estimateCost(%q) = %f, want %f", model, got, want)
+		}
+	}
+}
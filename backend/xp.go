@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// XP transaction sources, stored in xp_transactions.source_type
+const (
+	XPSourceQuest   = "quest"
+	XPSourceBadge   = "badge"
+	XPSourceStreak  = "streak"
+	XPSourceAIChain = "ai_chain"
+	XPSourceProject = "project"
+)
+
+// Pagination limits for the XP history endpoint
+const (
+	defaultXPHistoryLimit = 20
+	maxXPHistoryLimit     = 100
+)
+
+var (
+	// ErrZeroXP is returned when an award of zero XP is requested
+	ErrZeroXP = errors.New("xp amount must be non-zero")
+	// ErrXPCapReached is returned when a capped award finds the cap used up
+	ErrXPCapReached = errors.New("xp cap reached")
+)
+
+// XPTransaction is a signed entry in a user's XP ledger
+type XPTransaction struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Amount      int       `json:"amount"`
+	SourceType  string    `json:"source_type"`
+	SourceID    *int      `json:"source_id,omitempty"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// XPAward is the outcome of recording an XP transaction
+type XPAward struct {
+	Transaction XPTransaction `json:"transaction"`
+	TotalXP     int           `json:"total_xp"`
+	Level       int           `json:"level"`
+	LeveledUp   bool          `json:"leveled_up"`
+}
+
+// XPHistory is one page of a user's XP ledger
+type XPHistory struct {
+	Transactions []XPTransaction `json:"transactions"`
+	Total        int             `json:"total"`
+	Limit        int             `json:"limit"`
+	Offset       int             `json:"offset"`
+}
+
+// intPtr returns a pointer to v
+func intPtr(v int) *int {
+	return &v
+}
+
+// XPService ledger methods
+
+// Award records a signed XP transaction and updates the user's total XP and
+// level in the same database transaction. Negative amounts deduct XP; the
+// total never drops below zero.
+func (xp *XPService) Award(ctx context.Context, userID, amount int, sourceType string, sourceID *int, description string) (*XPAward, error) {
+	if amount == 0 {
+		return nil, ErrZeroXP
+	}
+	return xp.record(ctx, &XPTransaction{UserID: userID, Amount: amount, SourceType: sourceType, SourceID: sourceID, Description: description}, xp.ledger.Record)
+}
+
+// AwardCapped credits a positive amount like Award, trimmed so that the user's
+// credits of sourceType since the given time stay within limit. It returns
+// ErrXPCapReached when the cap is already used up.
+func (xp *XPService) AwardCapped(ctx context.Context, userID, amount int, sourceType string, sourceID *int, description string, since time.Time, limit int) (*XPAward, error) {
+	if amount <= 0 {
+		return nil, ErrZeroXP
+	}
+	return xp.record(ctx, &XPTransaction{UserID: userID, Amount: amount, SourceType: sourceType, SourceID: sourceID, Description: description},
+		func(ctx context.Context, tx *XPTransaction, levelFor func(int) int) (*User, int, error) {
+			return xp.ledger.RecordCapped(ctx, tx, since, limit, levelFor)
+		})
+}
+
+// record writes tx to the ledger with the given method and publishes the award
+func (xp *XPService) record(ctx context.Context, tx *XPTransaction, write func(context.Context, *XPTransaction, func(int) int) (*User, int, error)) (*XPAward, error) {
+	user, previousLevel, err := write(ctx, tx, xp.CalculateLevel)
+	if err != nil {
+		return nil, fmt.Errorf("record %s xp for user %d: %w", tx.SourceType, tx.UserID, err)
+	}
+
+	award := &XPAward{
+		Transaction: *tx,
+		TotalXP:     user.XP,
+		Level:       user.Level,
+		LeveledUp:   user.Level > previousLevel,
+	}
+	if xp.events != nil {
+		xp.events.Publish(ctx, Event{Type: EventXPAwarded, UserID: tx.UserID, OccurredAt: tx.CreatedAt, XP: award})
+		if award.LeveledUp {
+			xp.events.Publish(ctx, Event{Type: EventLevelUp, UserID: tx.UserID, OccurredAt: tx.CreatedAt, XP: award})
+		}
+	}
+	return award, nil
+}
+
+// History returns a page of a user's XP transactions, newest first
+func (xp *XPService) History(ctx context.Context, userID, limit, offset int) (*XPHistory, error) {
+	transactions, total, err := xp.ledger.ListByUser(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &XPHistory{Transactions: transactions, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// Credited returns the IDs of the sources of sourceType the user has been credited for
+func (xp *XPService) Credited(ctx context.Context, userID int, sourceType string) (map[int]bool, error) {
+	return xp.ledger.CreditedSources(ctx, userID, sourceType)
+}
+
+// HandleQuestCompleted credits a completed quest's XP reward. Quests without
+// a fixed reward earn CalculateXPGain for their type, difficulty and the
+// user's current streak.
+func (xp *XPService) HandleQuestCompleted(ctx context.Context, event Event) error {
+	quest := event.Quest
+	if quest == nil {
+		return nil
+	}
+
+	amount := quest.XP
+	if amount == 0 {
+		user, err := xp.users.GetByID(ctx, event.UserID)
+		if err != nil {
+			return err
+		}
+		amount = xp.CalculateXPGain(quest.Type, quest.Difficulty, user.Streak)
+	}
+
+	_, err := xp.Award(ctx, event.UserID, amount, XPSourceQuest, intPtr(quest.ID), "Completed quest: "+quest.Title)
+	return err
+}
+
+// HandleProjectMilestoneCompleted credits a checked-off project milestone's XP reward
+func (xp *XPService) HandleProjectMilestoneCompleted(ctx context.Context, event Event) error {
+	if event.Project == nil || event.Milestone == nil || event.Milestone.XPReward == 0 {
+		return nil
+	}
+	_, err := xp.Award(ctx, event.UserID, event.Milestone.XPReward, XPSourceProject, intPtr(event.Project.ID), "Completed project milestone: "+event.Milestone.Title)
+	return err
+}
+
+// XP handlers
+
+func (s *Server) getXPHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := defaultXPHistoryLimit, 0
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxXPHistoryLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxXPHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := s.xp.History(r.Context(), userID, limit, offset)
+	if err != nil {
+		log.Printf("xp history for user %d: %v", userID, err)
+		http.Error(w, "Failed to load XP history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
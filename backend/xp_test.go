@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestXPAwardUpdatesTotalAndLevel(t *testing.T) {
+	repos := NewMemoryRepositories()
+	service := NewXPService(repos.XP, repos.Users, NewEventBus())
+	ctx := context.Background()
+
+	award, err := service.Award(ctx, 1, 1000, XPSourceBadge, intPtr(3), "Earned Streak Champion badge")
+	if err != nil {
+		t.Fatalf("Award() error = %v", err)
+	}
+	if award.TotalXP != 1750 || award.Level != 6 || !award.LeveledUp {
+		t.Fatalf("award = %+v, want total 1750 leveled up to 6", award)
+	}
+	user, _ := repos.Users.GetByID(ctx, 1)
+	if user.XP != award.TotalXP || user.Level != award.Level {
+		t.Fatalf("user xp/level = %d/%d, want %d/%d", user.XP, user.Level, award.TotalXP, award.Level)
+	}
+
+	award, err = service.Award(ctx, 1, -5000, XPSourceQuest, nil, "Penalty")
+	if err != nil {
+		t.Fatalf("Award(negative) error = %v", err)
+	}
+	if award.TotalXP != 0 || award.Level != 1 {
+		t.Fatalf("award = %+v, want total clamped to 0 at level 1", award)
+	}
+
+	if _, err := service.Award(ctx, 1, 0, XPSourceQuest, nil, ""); !errors.Is(err, ErrZeroXP) {
+		t.Fatalf("Award(0) error = %v, want ErrZeroXP", err)
+	}
+}
+
+func TestXPConcurrentAwardsReportEachLevelUpOnce(t *testing.T) {
+	repos := NewMemoryRepositories()
+	service := NewXPService(repos.XP, repos.Users, nil)
+	ctx := context.Background()
+	user := &User{Username: "sam", Email: "sam@example.com", Level: 1}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 20 awards of 50 take sam from 0 to 1000 XP, through levels 2, 3, 4 and 5
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	levelUps := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			award, err := service.Award(ctx, user.ID, 50, XPSourceQuest, nil, "Quest")
+			if err != nil {
+				t.Errorf("Award() error = %v", err)
+				return
+			}
+			if award.LeveledUp {
+				mu.Lock()
+				levelUps++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if levelUps != 4 {
+		t.Fatalf("level ups = %d, want 4", levelUps)
+	}
+}
+
+func TestXPQuestCompletionWritesLedger(t *testing.T) {
+	repos := NewMemoryRepositories()
+	events := NewEventBus()
+	xp := NewXPService(repos.XP, repos.Users, events)
+	events.Subscribe(xp.HandleQuestCompleted, EventQuestCompleted)
+	var awarded int
+	events.Subscribe(func(ctx context.Context, event Event) error {
+		awarded += event.XP.Transaction.Amount
+		return nil
+	}, EventXPAwarded)
+	quests := NewQuestService(repos.Quests, events)
+	ctx := context.Background()
+
+	if _, err := quests.CompleteTask(ctx, 1, 3); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+
+	history, err := xp.History(ctx, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if history.Total != 7 || len(history.Transactions) != 1 {
+		t.Fatalf("history total/len = %d/%d, want 7/1", history.Total, len(history.Transactions))
+	}
+	latest := history.Transactions[0]
+	if latest.SourceType != XPSourceQuest || latest.SourceID == nil || *latest.SourceID != 1 || latest.Amount != 150 {
+		t.Fatalf("latest transaction = %+v, want +150 from quest 1", latest)
+	}
+	if awarded != 150 {
+		t.Fatalf("xp.awarded total = %d, want 150", awarded)
+	}
+}
@@ -0,0 +1,10 @@
+#!/bin/bash
+# Applies db/migrations in order when the compose database is first created.
+# docker-compose mounts this as /docker-entrypoint-initdb.d/migrations.sh so it
+# runs after init.sql, and mounts the migrations at /docker-entrypoint-migrations.
+set -e
+
+for migration in /docker-entrypoint-migrations/*.sql; do
+    echo "Running migration: $(basename "$migration")"
+    psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" -f "$migration"
+done
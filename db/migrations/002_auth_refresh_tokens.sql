@@ -0,0 +1,14 @@
+-- Migration: Add refresh tokens for JWT authentication
+-- Version: 002
+-- Date: 2026-10-18
+
+-- Refresh tokens issued at login; a token is single-use and is revoked on refresh or logout
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id VARCHAR(64) PRIMARY KEY, -- JWT ID (jti) of the refresh token
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
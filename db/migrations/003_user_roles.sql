@@ -0,0 +1,8 @@
+-- Migration: Add user roles for authorization
+-- Version: 003
+-- Date: 2026-10-18
+
+-- 'learner' can only access their own data, 'admin' can act on any user
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'learner';
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
@@ -0,0 +1,15 @@
+-- Migration: Add activity log for badge rules
+-- Version: 004
+-- Date: 2026-10-18
+
+-- Append-only log of counted learner activity ('quests_completed', 'early_sessions',
+-- 'helped_users', ...). Badge requirements are evaluated against sums of this log.
+CREATE TABLE IF NOT EXISTS user_activity (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    stat VARCHAR(50) NOT NULL,
+    amount INTEGER NOT NULL DEFAULT 1,
+    occurred_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_activity_user_stat ON user_activity(user_id, stat, occurred_at);
@@ -0,0 +1,6 @@
+-- Migration: Add streak freeze tokens
+-- Version: 005
+-- Date: 2026-10-18
+
+-- Each token covers one missed day without breaking the user's streak
+ALTER TABLE users ADD COLUMN IF NOT EXISTS streak_freeze_tokens INTEGER NOT NULL DEFAULT 0;
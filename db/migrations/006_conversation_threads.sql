@@ -0,0 +1,22 @@
+-- Migration: Add buddy conversation threads
+-- Version: 006
+-- Date: 2026-10-18
+
+-- A thread groups chat turns; summary is the rolling memory note of turns
+-- up to summarized_through (a buddy_conversations id) that are no longer sent verbatim
+CREATE TABLE IF NOT EXISTS buddy_threads (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    summary TEXT NOT NULL DEFAULT '',
+    summarized_through INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_buddy_threads_user_updated ON buddy_threads(user_id, updated_at DESC);
+
+-- Turns saved before threads existed keep a NULL thread_id
+ALTER TABLE buddy_conversations ADD COLUMN IF NOT EXISTS thread_id INTEGER REFERENCES buddy_threads(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_buddy_conversations_thread ON buddy_conversations(thread_id, id);
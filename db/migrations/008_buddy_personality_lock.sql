@@ -0,0 +1,19 @@
+-- Migration: Link buddy personalities to ai-core and let users lock theirs
+-- Version: 008
+-- Date: 2026-10-18
+
+-- personality_key is the ai-core personality name (mentor, cheerleader, ...)
+ALTER TABLE buddy_personalities ADD COLUMN IF NOT EXISTS personality_key VARCHAR(50);
+
+UPDATE buddy_personalities SET personality_key = CASE name
+    WHEN 'Mentor' THEN 'mentor'
+    WHEN 'Cheerleader' THEN 'cheerleader'
+    WHEN 'Chill Friend' THEN 'chill'
+    WHEN 'Focused Analyst' THEN 'focused'
+END
+WHERE personality_key IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_buddy_personalities_key ON buddy_personalities(personality_key);
+
+-- A locked personality is used for every reply instead of adapting to the detected emotion
+ALTER TABLE users ADD COLUMN IF NOT EXISTS buddy_personality_locked BOOLEAN NOT NULL DEFAULT FALSE;
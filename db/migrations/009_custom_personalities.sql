@@ -0,0 +1,17 @@
+-- Migration: User-defined buddy personalities
+-- Version: 009
+-- Date: 2026-10-18
+
+-- Built-in personalities have no creator. Custom ones belong to the user who
+-- created them, and admins' are shared with every learner.
+ALTER TABLE buddy_personalities ADD COLUMN IF NOT EXISTS prompt_modifiers JSONB;
+ALTER TABLE buddy_personalities ADD COLUMN IF NOT EXISTS color_theme VARCHAR(7);
+ALTER TABLE buddy_personalities ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE buddy_personalities ADD COLUMN IF NOT EXISTS shared BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_buddy_personalities_created_by ON buddy_personalities(created_by);
+
+-- Deleting a custom personality clears the preference of users who chose it
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_buddy_personality_id_fkey;
+ALTER TABLE users ADD CONSTRAINT users_buddy_personality_id_fkey
+    FOREIGN KEY (buddy_personality_id) REFERENCES buddy_personalities(id) ON DELETE SET NULL;
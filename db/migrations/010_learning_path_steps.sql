@@ -0,0 +1,22 @@
+-- Migration: Learning path steps and unique titles
+-- Version: 010
+-- Date: 2026-10-18
+
+-- Prerequisites name other paths by title, so titles must be unique
+CREATE UNIQUE INDEX IF NOT EXISTS idx_learning_paths_title ON learning_paths(title);
+
+-- The ordered steps of a path; user_learning_progress.current_step indexes them from 1
+ALTER TABLE learning_paths ADD COLUMN IF NOT EXISTS steps JSONB NOT NULL DEFAULT '[]';
+
+UPDATE learning_paths SET steps = '["Variables and types", "Control flow", "Functions and scope", "Arrays and objects"]'
+WHERE title = 'JavaScript Fundamentals' AND steps = '[]';
+UPDATE learning_paths SET steps = '["Components and JSX", "State and props", "Hooks", "Routing and data fetching"]'
+WHERE title = 'React Development' AND steps = '[]';
+UPDATE learning_paths SET steps = '["Modules and npm", "HTTP servers with Express", "Files and streams", "Build a REST API"]'
+WHERE title = 'Backend with Node.js' AND steps = '[]';
+UPDATE learning_paths SET steps = '["Relational modeling", "SQL queries", "Normalization", "Indexes and performance"]'
+WHERE title = 'Database Design' AND steps = '[]';
+UPDATE learning_paths SET steps = '["Plan the application", "Build the API", "Build the frontend", "Deploy and monitor"]'
+WHERE title = 'Full-Stack Development' AND steps = '[]';
+
+CREATE INDEX IF NOT EXISTS idx_user_learning_progress_path ON user_learning_progress(learning_path_id);
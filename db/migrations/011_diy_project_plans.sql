@@ -0,0 +1,13 @@
+-- Migration: DIY project plans
+-- Version: 011
+-- Date: 2026-10-18
+
+-- Plans from the diy_project_generator template also list the skills they
+-- practice and how to tell the project succeeded
+ALTER TABLE diy_projects ADD COLUMN IF NOT EXISTS skills JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE diy_projects ADD COLUMN IF NOT EXISTS success_metrics JSONB NOT NULL DEFAULT '[]';
+
+-- Set when the first milestone is checked off or the learner starts the project
+ALTER TABLE diy_projects ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_diy_projects_user_created ON diy_projects(user_id, created_at DESC);
@@ -0,0 +1,17 @@
+-- Migration: Session tracking
+-- Version: 012
+-- Date: 2026-10-18
+
+-- Counters for the session events endpoint. response_seconds sums the time
+-- learners took on timed_tasks of their tasks, so their average response time
+-- survives gaps that were not counted.
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS idle_events INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS response_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS timed_tasks INTEGER NOT NULL DEFAULT 0;
+
+-- Response time is measured from the previous event; starting a new session
+-- closes an open one at this time
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS last_event_at TIMESTAMP;
+UPDATE user_sessions SET last_event_at = COALESCE(session_end, session_start) WHERE last_event_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_start ON user_sessions(user_id, session_start DESC);
@@ -0,0 +1,25 @@
+-- Migration: Focus sessions
+-- Version: 013
+-- Date: 2026-10-18
+
+-- Pomodoro-style timers. Only server timestamps count: focused_seconds grows
+-- by the time between heartbeats, and a session with too many heartbeat gaps
+-- is invalidated instead of crediting its quest.
+CREATE TABLE IF NOT EXISTS focus_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    quest_id INTEGER REFERENCES quests(id) ON DELETE SET NULL,
+    planned_minutes INTEGER NOT NULL CHECK (planned_minutes > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'paused', 'completed', 'invalidated')),
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_heartbeat_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    paused_at TIMESTAMP,
+    paused_seconds INTEGER NOT NULL DEFAULT 0,
+    focused_seconds INTEGER NOT NULL DEFAULT 0,
+    gaps INTEGER NOT NULL DEFAULT 0,
+    verified_minutes INTEGER NOT NULL DEFAULT 0,
+    invalid_reason TEXT,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_focus_sessions_user_status ON focus_sessions(user_id, status);
@@ -0,0 +1,12 @@
+-- Migration: Scope custom personality keys to their creator
+-- Version: 014
+-- Date: 2026-10-18
+
+-- Learners name their personalities independently, so a key only has to be
+-- unique among one creator's personalities. Built-in keys stay globally unique.
+DROP INDEX IF EXISTS idx_buddy_personalities_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_buddy_personalities_builtin_key
+    ON buddy_personalities(personality_key) WHERE created_by IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_buddy_personalities_owner_key
+    ON buddy_personalities(created_by, personality_key) WHERE created_by IS NOT NULL;
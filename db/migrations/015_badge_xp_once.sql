@@ -0,0 +1,8 @@
+-- Migration: Credit each badge's XP at most once
+-- Version: 015
+-- Date: 2026-10-18
+
+-- Badge evaluation retries XP credits that failed after the badge was
+-- recorded, so the ledger has to reject a second credit for the same badge.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_xp_transactions_badge_once
+    ON xp_transactions(user_id, source_id) WHERE source_type = 'badge';